@@ -0,0 +1,154 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+const AuditRedactedValue = "***"
+
+// IsSensitiveAuditField 判断字段是否需要在审计记录中脱敏，如密钥、密码、令牌等
+func IsSensitiveAuditField(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, "key") ||
+		strings.HasSuffix(name, "token") ||
+		strings.Contains(name, "secret") ||
+		strings.Contains(name, "password")
+}
+
+// ToAuditMap 将结构体等值转换为 map 以便比较字段，无法转换为对象时返回 nil
+func ToAuditMap(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	if m, ok := v.(map[string]any); ok {
+		return m
+	}
+	data, err := Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// RedactAuditMap 返回脱敏后的副本，嵌套对象与数组中敏感字段的值也被替换为 AuditRedactedValue
+func RedactAuditMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(m))
+	for k, v := range m {
+		if IsSensitiveAuditField(k) && !isEmptyAuditValue(v) {
+			redacted[k] = AuditRedactedValue
+			continue
+		}
+		redacted[k] = RedactAuditValue(v)
+	}
+	return redacted
+}
+
+// RedactAuditValue 返回脱敏后的 JSON 值，对象与数组逐层处理，其他值原样返回
+func RedactAuditValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		return RedactAuditMap(value)
+	case []any:
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = RedactAuditValue(item)
+		}
+		return redacted
+	}
+	return v
+}
+
+// BuildAuditDiff 比较变更前后的状态，返回形如 {"field": {"before": x, "after": y}} 的差异。
+// 两侧都是对象的字段会继续逐层比较，因此 JSON 配置（如模型倍率）能定位到具体的键；
+// 顶层敏感字段只记录是否变更，不记录明文。
+func BuildAuditDiff(before, after any) map[string]any {
+	return diffAuditMaps(ToAuditMap(before), ToAuditMap(after), true)
+}
+
+func diffAuditMaps(before, after map[string]any, topLevel bool) map[string]any {
+	diff := make(map[string]any)
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+	for k := range keys {
+		b, hasBefore := before[k]
+		a, hasAfter := after[k]
+		if hasBefore && hasAfter && reflect.DeepEqual(b, a) {
+			continue
+		}
+		if topLevel && IsSensitiveAuditField(k) {
+			diff[k] = map[string]any{
+				"before": redactAuditValue(b),
+				"after":  redactAuditValue(a),
+			}
+			continue
+		}
+		bm, bIsMap := b.(map[string]any)
+		am, aIsMap := a.(map[string]any)
+		if bIsMap && aIsMap {
+			if nested := diffAuditMaps(bm, am, false); len(nested) > 0 {
+				diff[k] = nested
+			}
+			continue
+		}
+		diff[k] = map[string]any{
+			"before": RedactAuditValue(b),
+			"after":  RedactAuditValue(a),
+		}
+	}
+	return diff
+}
+
+func redactAuditValue(v any) any {
+	if isEmptyAuditValue(v) {
+		return v
+	}
+	return AuditRedactedValue
+}
+
+func isEmptyAuditValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	if s, ok := v.(string); ok {
+		return s == ""
+	}
+	return false
+}
+
+// SetAuditEntity 指定本次管理操作的目标实体 ID，未指定时由审计中间件从路径参数或请求体推断
+func SetAuditEntity(c *gin.Context, entityId any) {
+	SetContextKey(c, constant.ContextKeyAuditEntityId, fmt.Sprintf("%v", entityId))
+}
+
+// SetAuditAction 覆盖按请求方法推断的审计动作
+func SetAuditAction(c *gin.Context, action string) {
+	SetContextKey(c, constant.ContextKeyAuditAction, action)
+}
+
+// SetAuditBefore 记录变更前的状态，立即转换为 map 以免后续修改影响快照
+func SetAuditBefore(c *gin.Context, before any) {
+	SetContextKey(c, constant.ContextKeyAuditBefore, ToAuditMap(before))
+}
+
+// SetAuditAfter 记录变更后的状态
+func SetAuditAfter(c *gin.Context, after any) {
+	SetContextKey(c, constant.ContextKeyAuditAfter, ToAuditMap(after))
+}
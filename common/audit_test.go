@@ -0,0 +1,50 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAuditDiff(t *testing.T) {
+	type channel struct {
+		Name   string `json:"name"`
+		Key    string `json:"key"`
+		Weight int    `json:"weight"`
+	}
+	diff := BuildAuditDiff(
+		channel{Name: "openai", Key: "sk-old", Weight: 1},
+		channel{Name: "openai", Key: "sk-new", Weight: 2},
+	)
+	assert.NotContains(t, diff, "name", "未变更的字段不记录")
+	assert.Equal(t, map[string]any{"before": AuditRedactedValue, "after": AuditRedactedValue}, diff["key"])
+	assert.Equal(t, map[string]any{"before": float64(1), "after": float64(2)}, diff["weight"])
+
+	// JSON 配置逐层比较，定位到具体模型
+	diff = BuildAuditDiff(
+		map[string]any{"ModelRatio": map[string]any{"gpt-5": 1.25, "gpt-4o": 2.5}},
+		map[string]any{"ModelRatio": map[string]any{"gpt-5": 0.625, "gpt-4o": 2.5}},
+	)
+	assert.Equal(t, map[string]any{
+		"ModelRatio": map[string]any{
+			"gpt-5": map[string]any{"before": 1.25, "after": 0.625},
+		},
+	}, diff)
+
+	diff = BuildAuditDiff(nil, map[string]any{"SMTPToken": "secret", "SMTPServer": "smtp.example.com"})
+	assert.Equal(t, map[string]any{"before": nil, "after": AuditRedactedValue}, diff["SMTPToken"])
+	assert.Equal(t, map[string]any{"before": nil, "after": "smtp.example.com"}, diff["SMTPServer"])
+}
+
+func TestRedactAuditValueNested(t *testing.T) {
+	redacted := RedactAuditValue(map[string]any{
+		"mode":    "batch",
+		"channel": map[string]any{"name": "openai", "key": "sk-upstream"},
+		"items":   []any{map[string]any{"api_key": "sk-1"}, "plain"},
+	})
+	assert.Equal(t, map[string]any{
+		"mode":    "batch",
+		"channel": map[string]any{"name": "openai", "key": AuditRedactedValue},
+		"items":   []any{map[string]any{"api_key": AuditRedactedValue}, "plain"},
+	}, redacted)
+}
@@ -35,6 +35,13 @@ var DefaultCollapseSidebar = false // default value of collapse sidebar
 var SessionSecret = uuid.New().String()
 var CryptoSecret = uuid.New().String()
 
+// TokenKeySecret 用于计算令牌密钥的 HMAC 摘要，未通过环境变量配置时由 model 层从数据库加载
+var TokenKeySecret = ""
+
+// ChannelKeyHashSecret 用于计算渠道密钥的 HMAC 摘要（按密钥搜索渠道），与 TokenKeySecret 相互独立，
+// 未通过环境变量配置时由 model 层从数据库加载
+var ChannelKeyHashSecret = ""
+
 var OptionMap map[string]string
 var OptionMapRWMutex sync.RWMutex
 
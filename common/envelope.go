@@ -0,0 +1,200 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 渠道密钥信封加密：每条记录使用独立的数据密钥(DEK)加密明文，DEK 再由主密钥加密后与密文一起存储。
+// 存储格式：enc:v1:<主密钥ID>:<base64(加密后的DEK)>:<base64(密文)>
+const envelopePrefix = "enc:v1:"
+
+var envelopeDEKAAD = []byte("new-api-envelope-dek")
+
+type envelopeMasterKey struct {
+	id  string
+	key []byte
+}
+
+var (
+	envelopeCurrentKey *envelopeMasterKey
+	envelopeKeys       = map[string]*envelopeMasterKey{}
+)
+
+func newEnvelopeMasterKey(secret string) *envelopeMasterKey {
+	key := sha256.Sum256([]byte(secret))
+	id := sha256.Sum256(key[:])
+	return &envelopeMasterKey{id: hex.EncodeToString(id[:4]), key: key[:]}
+}
+
+// InitEnvelopeEncryption 从环境变量加载渠道密钥加密所用的主密钥。
+// CHANNEL_KEY_MASTER_KEY 或 CHANNEL_KEY_MASTER_KEY_FILE 指定当前主密钥，
+// CHANNEL_KEY_OLD_MASTER_KEYS（逗号分隔）指定轮换前的旧主密钥，仅用于解密。
+func InitEnvelopeEncryption() error {
+	current := os.Getenv("CHANNEL_KEY_MASTER_KEY")
+	if path := os.Getenv("CHANNEL_KEY_MASTER_KEY_FILE"); current == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read master key file: %w", err)
+		}
+		current = strings.TrimSpace(string(data))
+	}
+	envelopeCurrentKey = nil
+	envelopeKeys = map[string]*envelopeMasterKey{}
+	for _, old := range strings.Split(os.Getenv("CHANNEL_KEY_OLD_MASTER_KEYS"), ",") {
+		old = strings.TrimSpace(old)
+		if old == "" {
+			continue
+		}
+		k := newEnvelopeMasterKey(old)
+		envelopeKeys[k.id] = k
+	}
+	if current != "" {
+		envelopeCurrentKey = newEnvelopeMasterKey(current)
+		envelopeKeys[envelopeCurrentKey.id] = envelopeCurrentKey
+	}
+	if envelopeCurrentKey == nil && len(envelopeKeys) > 0 {
+		return errors.New("CHANNEL_KEY_OLD_MASTER_KEYS is set but no current master key is configured")
+	}
+	return nil
+}
+
+// EnvelopeEncryptionEnabled 是否配置了主密钥
+func EnvelopeEncryptionEnabled() bool {
+	return envelopeCurrentKey != nil
+}
+
+// IsEnvelopeEncrypted 判断字符串是否为信封加密后的密文
+func IsEnvelopeEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// IsEnvelopeEncryptedWithCurrentKey 判断密文的数据密钥是否已由当前主密钥加密
+func IsEnvelopeEncryptedWithCurrentKey(value string) bool {
+	if envelopeCurrentKey == nil || !IsEnvelopeEncrypted(value) {
+		return false
+	}
+	return strings.HasPrefix(value, envelopePrefix+envelopeCurrentKey.id+":")
+}
+
+func aesGCMSeal(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func aesGCMOpen(key []byte, sealed []byte, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+}
+
+// EnvelopeEncrypt 使用新的数据密钥加密明文，并用当前主密钥加密数据密钥
+func EnvelopeEncrypt(plaintext string) (string, error) {
+	if envelopeCurrentKey == nil {
+		return "", errors.New("master key is not configured")
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := aesGCMSeal(envelopeCurrentKey.key, dek, envelopeDEKAAD)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := aesGCMSeal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + envelopeCurrentKey.id + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func parseEnvelope(value string) (dek []byte, sealed []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("invalid envelope format")
+	}
+	master, ok := envelopeKeys[parts[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("master key %s is not configured", parts[0])
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	dek, err = aesGCMOpen(master.key, wrapped, envelopeDEKAAD)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	sealed, err = base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, err
+	}
+	return dek, sealed, nil
+}
+
+// EnvelopeDecrypt 解密信封密文，非密文原样返回以兼容未加密的旧数据
+func EnvelopeDecrypt(value string) (string, error) {
+	if !IsEnvelopeEncrypted(value) {
+		return value, nil
+	}
+	dek, sealed, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aesGCMOpen(dek, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EnvelopeRewrap 使用当前主密钥重新加密数据密钥，密文本身不变；未加密的值会被完整加密
+func EnvelopeRewrap(value string) (string, error) {
+	if envelopeCurrentKey == nil {
+		return "", errors.New("master key is not configured")
+	}
+	if !IsEnvelopeEncrypted(value) {
+		return EnvelopeEncrypt(value)
+	}
+	dek, sealed, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := aesGCMSeal(envelopeCurrentKey.key, dek, envelopeDEKAAD)
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + envelopeCurrentKey.id + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(sealed), nil
+}
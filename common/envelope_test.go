@@ -0,0 +1,51 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelopeEncryptAndRotate(t *testing.T) {
+	t.Setenv("CHANNEL_KEY_MASTER_KEY", "old-master")
+	require.NoError(t, InitEnvelopeEncryption())
+	t.Cleanup(func() {
+		envelopeCurrentKey = nil
+		envelopeKeys = map[string]*envelopeMasterKey{}
+	})
+
+	plaintext := "sk-a\nsk-b\n{\"type\":\"service_account\"}"
+	encrypted, err := EnvelopeEncrypt(plaintext)
+	require.NoError(t, err)
+	assert.True(t, IsEnvelopeEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "sk-a")
+
+	decrypted, err := EnvelopeDecrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// 未加密的旧数据原样返回
+	legacy, err := EnvelopeDecrypt("sk-legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-legacy", legacy)
+
+	// 切换主密钥后，旧密文需要旧主密钥才能解密
+	t.Setenv("CHANNEL_KEY_MASTER_KEY", "new-master")
+	require.NoError(t, InitEnvelopeEncryption())
+	_, err = EnvelopeDecrypt(encrypted)
+	assert.Error(t, err)
+
+	t.Setenv("CHANNEL_KEY_OLD_MASTER_KEYS", "old-master")
+	require.NoError(t, InitEnvelopeEncryption())
+	assert.False(t, IsEnvelopeEncryptedWithCurrentKey(encrypted))
+	rotated, err := EnvelopeRewrap(encrypted)
+	require.NoError(t, err)
+	assert.True(t, IsEnvelopeEncryptedWithCurrentKey(rotated))
+
+	t.Setenv("CHANNEL_KEY_OLD_MASTER_KEYS", "")
+	require.NoError(t, InitEnvelopeEncryption())
+	decrypted, err = EnvelopeDecrypt(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
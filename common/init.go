@@ -19,13 +19,15 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+
+	RotateChannelKeys = flag.Bool("rotate-channel-keys", false, "re-encrypt all channel keys with the current master key and exit")
 )
 
 func printHelp() {
 	fmt.Println("NewAPI(Based OneAPI) " + Version + " - The next-generation LLM gateway and AI asset management system supports multiple languages.")
 	fmt.Println("Original Project: OneAPI by JustSong - https://github.com/songquanpeng/one-api")
 	fmt.Println("Maintainer: QuantumNous - https://github.com/QuantumNous/new-api")
-	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--rotate-channel-keys] [--version] [--help]")
 }
 
 func InitEnv() {
@@ -61,6 +63,15 @@ func InitEnv() {
 	} else {
 		CryptoSecret = SessionSecret
 	}
+	if os.Getenv("TOKEN_KEY_SECRET") != "" {
+		TokenKeySecret = os.Getenv("TOKEN_KEY_SECRET")
+	}
+	if os.Getenv("CHANNEL_KEY_HASH_SECRET") != "" {
+		ChannelKeyHashSecret = os.Getenv("CHANNEL_KEY_HASH_SECRET")
+	}
+	if err := InitEnvelopeEncryption(); err != nil {
+		log.Fatal(err)
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
@@ -173,4 +184,23 @@ func initConstantEnv() {
 		}
 	}
 	constant.TrustedRedirectDomains = trustedDomains
+
+	// Prometheus 指标，METRICS_PORT 为空时挂载在主端口的 /metrics
+	constant.MetricsEnabled = GetEnvOrDefaultBool("METRICS_ENABLED", false)
+	constant.MetricsPort = GetEnvOrDefaultString("METRICS_PORT", "")
+	// 非空时抓取需携带 Authorization: Bearer <token>
+	constant.MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
+	// 保留的业务标签（model,group,channel），用于控制指标基数
+	var metricsLabels []string
+	for _, label := range strings.Split(GetEnvOrDefaultString("METRICS_LABELS", "model,group,channel"), ",") {
+		if trimmedLabel := strings.TrimSpace(label); trimmedLabel != "" {
+			metricsLabels = append(metricsLabels, strings.ToLower(trimmedLabel))
+		}
+	}
+	constant.MetricsLabels = metricsLabels
+	// 每个业务标签最多保留的取值数量，超出部分归入 other，0 表示不限制
+	constant.MetricsMaxLabelValues = GetEnvOrDefault("METRICS_MAX_LABEL_VALUES", 200)
+
+	// OpenTelemetry 链路追踪，导出地址、采样等沿用标准的 OTEL_* 环境变量
+	constant.TracingEnabled = GetEnvOrDefaultBool("OTEL_TRACING_ENABLED", false)
 }
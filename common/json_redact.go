@@ -0,0 +1,233 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath 是已解析的 JSONPath 表达式，支持 $、.name、['name']、[n]、[*]、.* 与递归下降 ..name
+type JSONPath struct {
+	raw      string
+	segments []jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	name      string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+func (p JSONPath) String() string {
+	return p.raw
+}
+
+// ParseJSONPath 解析单个 JSONPath 表达式
+func ParseJSONPath(raw string) (JSONPath, error) {
+	path := strings.TrimSpace(raw)
+	if !strings.HasPrefix(path, "$") {
+		return JSONPath{}, fmt.Errorf("json path %q must start with $", raw)
+	}
+	var segments []jsonPathSegment
+	recursive := false
+	for i := 1; i < len(path); {
+		switch path[i] {
+		case '.':
+			if recursive {
+				return JSONPath{}, fmt.Errorf("json path %q has an empty segment", raw)
+			}
+			i++
+			if i < len(path) && path[i] == '.' {
+				recursive = true
+				i++
+				// "..[*]" 与 "..['name']" 由下一个方括号选择器处理
+				if i < len(path) && path[i] == '[' {
+					continue
+				}
+			}
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			name := path[i:end]
+			if name == "" {
+				return JSONPath{}, fmt.Errorf("json path %q has an empty segment", raw)
+			}
+			segments = append(segments, jsonPathSegment{name: name, wildcard: name == "*", recursive: recursive})
+			recursive = false
+			i = end
+		case '[':
+			close := strings.IndexByte(path[i:], ']')
+			if close < 0 {
+				return JSONPath{}, fmt.Errorf("json path %q has an unclosed bracket", raw)
+			}
+			segment, err := parseJSONPathBracket(path[i+1 : i+close])
+			if err != nil {
+				return JSONPath{}, fmt.Errorf("json path %q: %w", raw, err)
+			}
+			segment.recursive = recursive
+			recursive = false
+			segments = append(segments, segment)
+			i += close + 1
+		default:
+			return JSONPath{}, fmt.Errorf("json path %q has an unexpected character at %d", raw, i)
+		}
+	}
+	if recursive {
+		return JSONPath{}, fmt.Errorf("json path %q has an empty segment", raw)
+	}
+	if len(segments) == 0 {
+		return JSONPath{}, fmt.Errorf("json path %q selects the whole document", raw)
+	}
+	return JSONPath{raw: path, segments: segments}, nil
+}
+
+func parseJSONPathBracket(content string) (jsonPathSegment, error) {
+	content = strings.TrimSpace(content)
+	switch {
+	case content == "*":
+		return jsonPathSegment{wildcard: true}, nil
+	case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+		return jsonPathSegment{name: content[1 : len(content)-1]}, nil
+	default:
+		index, err := strconv.Atoi(content)
+		if err != nil || index < 0 {
+			return jsonPathSegment{}, fmt.Errorf("invalid bracket selector [%s]", content)
+		}
+		return jsonPathSegment{index: index, isIndex: true}, nil
+	}
+}
+
+// ParseJSONPaths 解析一组 JSONPath 表达式，忽略空行
+func ParseJSONPaths(raws []string) ([]JSONPath, error) {
+	paths := make([]JSONPath, 0, len(raws))
+	for _, raw := range raws {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		path, err := ParseJSONPath(raw)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// RedactJSONPaths 将 JSON 文档中命中任一路径的值替换为 AuditRedactedValue，SSE 响应逐个处理 data: 行。
+// 形似 JSON 却无法解析的内容（通常是被截断的载荷）无法可靠脱敏，整体替换为 AuditRedactedValue；
+// 非 JSON 内容（如 multipart 表单、音频）原样返回。
+func RedactJSONPaths(body []byte, paths []JSONPath) []byte {
+	if len(paths) == 0 || len(body) == 0 {
+		return body
+	}
+	if redacted, ok := redactJSONDocument(body, paths); ok {
+		return redacted
+	}
+	if bytes.Contains(body, []byte("data:")) {
+		lines := bytes.Split(body, []byte("\n"))
+		for i, line := range lines {
+			trimmed := bytes.TrimSpace(line)
+			if !bytes.HasPrefix(trimmed, []byte("data:")) {
+				continue
+			}
+			data := bytes.TrimSpace(trimmed[len("data:"):])
+			if len(data) == 0 || string(data) == "[DONE]" {
+				continue
+			}
+			if redacted, ok := redactJSONDocument(data, paths); ok {
+				lines[i] = append([]byte("data: "), redacted...)
+			} else {
+				lines[i] = []byte("data: " + AuditRedactedValue)
+			}
+		}
+		return bytes.Join(lines, []byte("\n"))
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return []byte(AuditRedactedValue)
+	}
+	return body
+}
+
+func redactJSONDocument(data []byte, paths []JSONPath) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil || decoder.More() {
+		return nil, false
+	}
+	for _, path := range paths {
+		redactJSONSegments(doc, path.segments)
+	}
+	redacted, err := Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func redactJSONSegments(node any, segments []jsonPathSegment) {
+	if len(segments) == 0 {
+		return
+	}
+	segment := segments[0]
+	applyJSONPathSegment(node, segment, segments[1:])
+	if !segment.recursive {
+		return
+	}
+	switch v := node.(type) {
+	case map[string]any:
+		for _, child := range v {
+			redactJSONSegments(child, segments)
+		}
+	case []any:
+		for _, child := range v {
+			redactJSONSegments(child, segments)
+		}
+	}
+}
+
+func applyJSONPathSegment(node any, segment jsonPathSegment, rest []jsonPathSegment) {
+	switch v := node.(type) {
+	case map[string]any:
+		if segment.isIndex {
+			return
+		}
+		for key, child := range v {
+			if !segment.wildcard && key != segment.name {
+				continue
+			}
+			if len(rest) == 0 {
+				if child != nil {
+					v[key] = AuditRedactedValue
+				}
+				continue
+			}
+			redactJSONSegments(child, rest)
+		}
+	case []any:
+		if !segment.isIndex && !segment.wildcard {
+			return
+		}
+		for i, child := range v {
+			if segment.isIndex && i != segment.index {
+				continue
+			}
+			if len(rest) == 0 {
+				if child != nil {
+					v[i] = AuditRedactedValue
+				}
+				continue
+			}
+			redactJSONSegments(child, rest)
+		}
+	}
+}
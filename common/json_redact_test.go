@@ -0,0 +1,32 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactJSONPaths(t *testing.T) {
+	paths, err := ParseJSONPaths([]string{"$.messages[*].content", "$..api_key", "$.tools[0]"})
+	require.NoError(t, err)
+
+	body := []byte(`{"model":"gpt-4o","max_tokens":12345678901234567,"messages":[{"role":"user","content":"secret"}],"meta":{"nested":{"api_key":"sk-1"}},"tools":["a","b"]}`)
+	redacted := string(RedactJSONPaths(body, paths))
+	assert.JSONEq(t, `{"model":"gpt-4o","max_tokens":12345678901234567,"messages":[{"role":"user","content":"***"}],"meta":{"nested":{"api_key":"***"}},"tools":["***","b"]}`, redacted)
+
+	stream := []byte("data: {\"api_key\":\"sk-1\",\"x\":1}\n\ndata: {\"api_ke\n")
+	assert.Equal(t, "data: {\"api_key\":\"***\",\"x\":1}\n\ndata: ***\n", string(RedactJSONPaths(stream, paths)), "截断的事件整体脱敏")
+
+	assert.Equal(t, AuditRedactedValue, string(RedactJSONPaths([]byte(`{"messages":[{"content":"sec`), paths)), "截断的 JSON 整体脱敏")
+	assert.Equal(t, "plain text", string(RedactJSONPaths([]byte("plain text"), paths)))
+}
+
+func TestParseJSONPathInvalid(t *testing.T) {
+	for _, raw := range []string{"messages", "$.", "$..", "$.a[", "$.a[-1]", "$"} {
+		_, err := ParseJSONPath(raw)
+		assert.Error(t, err, raw)
+	}
+	_, err := ParseJSONPath("$..['content']")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,83 @@
+package constant
+
+// 管理权限，用于组合自定义管理角色
+const (
+	AdminPermissionUsersRead        = "users:read"        // 查看用户、充值记录与组织
+	AdminPermissionUsersWrite       = "users:write"       // 创建、编辑、封禁、删除用户
+	AdminPermissionChannelsRead     = "channels:read"     // 查看渠道、测试渠道、刷新余额
+	AdminPermissionChannelsWrite    = "channels:write"    // 新增、编辑、删除渠道
+	AdminPermissionChannelsReadKey  = "channels:read_key" // 查看渠道密钥明文
+	AdminPermissionBillingRead      = "billing:read"      // 查看订阅套餐、兑换码、额度统计
+	AdminPermissionBillingWrite     = "billing:write"     // 管理订阅套餐、兑换码、组织额度
+	AdminPermissionBillingRefund    = "billing:refund"    // 补单、作废订阅
+	AdminPermissionLogsRead         = "logs:read"         // 查看日志与任务列表（不含日志内容）
+	AdminPermissionLogsReadContent  = "logs:read_content" // 查看日志内容
+	AdminPermissionLogsDelete       = "logs:delete"       // 清理历史日志
+	AdminPermissionModelsRead       = "models:read"       // 查看模型、供应商、分组、预填组
+	AdminPermissionModelsWrite      = "models:write"      // 管理模型、供应商、预填组
+	AdminPermissionDeploymentsRead  = "deployments:read"  // 查看模型部署
+	AdminPermissionDeploymentsWrite = "deployments:write" // 创建、修改、删除模型部署
+	AdminPermissionOptionsRead      = "options:read"      // 查看系统设置
+	AdminPermissionOptionsWrite     = "options:write"     // 修改系统设置、OAuth 提供商、性能与倍率同步
+	AdminPermissionRolesManage      = "roles:manage"      // 管理自定义角色及分配
+	AdminPermissionAuditRead        = "audit:read"        // 查看管理审计日志
+	AdminPermissionPayloadRead      = "payload:read"      // 查看请求载荷抓包，默认不授予
+	AdminPermissionPayloadReplay    = "payload:replay"    // 使用渠道密钥向上游重放抓包请求，不计费，默认不授予
+)
+
+var AllAdminPermissions = []string{
+	AdminPermissionUsersRead,
+	AdminPermissionUsersWrite,
+	AdminPermissionChannelsRead,
+	AdminPermissionChannelsWrite,
+	AdminPermissionChannelsReadKey,
+	AdminPermissionBillingRead,
+	AdminPermissionBillingWrite,
+	AdminPermissionBillingRefund,
+	AdminPermissionLogsRead,
+	AdminPermissionLogsReadContent,
+	AdminPermissionLogsDelete,
+	AdminPermissionModelsRead,
+	AdminPermissionModelsWrite,
+	AdminPermissionDeploymentsRead,
+	AdminPermissionDeploymentsWrite,
+	AdminPermissionOptionsRead,
+	AdminPermissionOptionsWrite,
+	AdminPermissionRolesManage,
+	AdminPermissionAuditRead,
+	AdminPermissionPayloadRead,
+	AdminPermissionPayloadReplay,
+}
+
+// DefaultAdminPermissions 未分配自定义角色的管理员所拥有的权限，与原先 AdminAuth 的范围一致
+var DefaultAdminPermissions = []string{
+	AdminPermissionUsersRead,
+	AdminPermissionUsersWrite,
+	AdminPermissionChannelsRead,
+	AdminPermissionChannelsWrite,
+	AdminPermissionBillingRead,
+	AdminPermissionBillingWrite,
+	AdminPermissionBillingRefund,
+	AdminPermissionLogsRead,
+	AdminPermissionLogsReadContent,
+	AdminPermissionLogsDelete,
+	AdminPermissionModelsRead,
+	AdminPermissionModelsWrite,
+	AdminPermissionDeploymentsRead,
+	AdminPermissionDeploymentsWrite,
+}
+
+// RootOnlyAdminPermissions 只能由超级管理员授予的权限，持有者可借此修改系统设置或角色以扩大自身权限
+var RootOnlyAdminPermissions = []string{
+	AdminPermissionOptionsWrite,
+	AdminPermissionRolesManage,
+}
+
+func IsValidAdminPermission(permission string) bool {
+	for _, p := range AllAdminPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
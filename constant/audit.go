@@ -0,0 +1,34 @@
+package constant
+
+// 审计实体类型
+const (
+	AuditEntityChannel          = "channel"
+	AuditEntityOption           = "option"
+	AuditEntityUser             = "user"
+	AuditEntityRedemption       = "redemption"
+	AuditEntitySubscriptionPlan = "subscription_plan"
+	AuditEntitySubscription     = "subscription"
+	AuditEntityTopUp            = "topup"
+	AuditEntityModel            = "model"
+	AuditEntityVendor           = "vendor"
+	AuditEntityPrefillGroup     = "prefill_group"
+	AuditEntityDeployment       = "deployment"
+	AuditEntityOAuthProvider    = "oauth_provider"
+	AuditEntityOrganization     = "organization"
+	AuditEntityAdminRole        = "admin_role"
+	AuditEntityLog              = "log"
+	AuditEntitySystem           = "system"
+	AuditEntityPayloadCapture   = "payload_capture"
+	AuditEntityStatement        = "statement"
+	AuditEntityCredit           = "credit"
+)
+
+// 审计动作，未显式指定时按请求方法推断
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionReadKey = "read_key"
+	AuditActionReplay  = "replay"
+	AuditActionRestore = "restore"
+)
@@ -23,6 +23,7 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -56,7 +57,22 @@ const (
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
 
+	// ContextKeyAdminPermissions stores the effective admin permissions resolved by RequirePermission.
+	ContextKeyAdminPermissions ContextKey = "admin_permissions"
+
+	/* admin audit related keys, see middleware.AdminAudit */
+	ContextKeyAuditEntityType ContextKey = "audit_entity_type"
+	ContextKeyAuditEntityId   ContextKey = "audit_entity_id"
+	ContextKeyAuditAction     ContextKey = "audit_action"
+	ContextKeyAuditBefore     ContextKey = "audit_before"
+	ContextKeyAuditAfter      ContextKey = "audit_after"
+
+	// ContextKeyPayloadCapture stores the in-flight payload capture of a request, see middleware.PayloadCapture
+	ContextKeyPayloadCapture ContextKey = "payload_capture"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
+	// ContextKeyClientCancelled is set when the client disconnects before a streaming response completes
+	ContextKeyClientCancelled ContextKey = "client_cancelled"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
 
@@ -18,6 +18,16 @@ var ErrorLogEnabled bool
 var TaskQueryLimit int
 var TaskTimeoutMinutes int
 
+// Prometheus metrics
+var MetricsEnabled bool
+var MetricsPort string
+var MetricsToken string
+var MetricsLabels []string
+var MetricsMaxLabelValues int
+
+// OpenTelemetry tracing
+var TracingEnabled bool
+
 // temporary variable for sora patch, will be removed in future
 var TaskPricePatches []string
 
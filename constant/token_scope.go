@@ -0,0 +1,28 @@
+package constant
+
+type TokenScope string
+
+// 令牌作用域，空作用域表示不限制（兼容旧令牌）
+const (
+	TokenScopeChat       TokenScope = "chat"        // chat/completions, messages, responses, moderations, gemini generateContent
+	TokenScopeEmbeddings TokenScope = "embeddings"  // embeddings, gemini embedContent
+	TokenScopeImages     TokenScope = "images"      // images/generations, images/edits
+	TokenScopeAudio      TokenScope = "audio"       // audio/speech, audio/transcriptions, audio/translations
+	TokenScopeRerank     TokenScope = "rerank"      // rerank
+	TokenScopeRealtime   TokenScope = "realtime"    // realtime websocket
+	TokenScopeTasks      TokenScope = "tasks"       // video, midjourney, suno 等异步任务
+	TokenScopeModelsRead TokenScope = "models:read" // 模型列表
+	TokenScopeKeysManage TokenScope = "keys:manage" // 管理子令牌，必须显式授予
+)
+
+var AllTokenScopes = []TokenScope{
+	TokenScopeChat,
+	TokenScopeEmbeddings,
+	TokenScopeImages,
+	TokenScopeAudio,
+	TokenScopeRerank,
+	TokenScopeRealtime,
+	TokenScopeTasks,
+	TokenScopeModelsRead,
+	TokenScopeKeysManage,
+}
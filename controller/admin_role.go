@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type adminRoleRequest struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type adminRoleAssignRequest struct {
+	UserId int `json:"user_id"`
+	RoleId int `json:"role_id"`
+}
+
+// validateAdminRoleRequest 校验角色名称与权限，返回规范化后的权限字符串
+func validateAdminRoleRequest(req *adminRoleRequest) (string, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "", errors.New("角色名称不能为空")
+	}
+	if len(req.Name) > 64 {
+		return "", errors.New("角色名称过长")
+	}
+	if len(req.Description) > 255 {
+		return "", errors.New("角色描述过长")
+	}
+	permissions, err := model.ValidateAdminPermissions(req.Permissions)
+	if err != nil {
+		return "", err
+	}
+	if permissions == "" {
+		return "", errors.New("至少需要选择一项权限")
+	}
+	duplicated, err := model.IsAdminRoleNameDuplicated(req.Id, req.Name)
+	if err != nil {
+		return "", err
+	}
+	if duplicated {
+		return "", errors.New("角色名称已存在")
+	}
+	return permissions, nil
+}
+
+// adminRoleOperator 发起角色管理操作的登录用户
+type adminRoleOperator struct {
+	id          int
+	role        int
+	adminRoleId int
+	permissions []string
+}
+
+func getAdminRoleOperator(c *gin.Context) (*adminRoleOperator, error) {
+	operator := &adminRoleOperator{
+		id:          c.GetInt("id"),
+		role:        c.GetInt("role"),
+		permissions: common.GetContextKeyStringSlice(c, constant.ContextKeyAdminPermissions),
+	}
+	if operator.isRoot() {
+		return operator, nil
+	}
+	userCache, err := model.GetUserCache(operator.id)
+	if err != nil {
+		return nil, err
+	}
+	operator.adminRoleId = userCache.AdminRoleId
+	return operator, nil
+}
+
+func (operator *adminRoleOperator) isRoot() bool {
+	return operator.role >= common.RoleRootUser
+}
+
+// checkGrantable 非超级管理员只能授予或变更自己拥有、且不属于超级管理员专属的权限
+func (operator *adminRoleOperator) checkGrantable(permissions []string) error {
+	if operator.isRoot() {
+		return nil
+	}
+	for _, permission := range permissions {
+		if slices.Contains(constant.RootOnlyAdminPermissions, permission) {
+			return fmt.Errorf("权限 %s 仅超级管理员可以授予", permission)
+		}
+		if !slices.Contains(operator.permissions, permission) {
+			return fmt.Errorf("无权授予自己未拥有的权限 %s", permission)
+		}
+	}
+	return nil
+}
+
+// checkEditable 非超级管理员不能修改或分配自己持有的角色，也不能修改或分配权限超出自身的角色
+func (operator *adminRoleOperator) checkEditable(role *model.AdminRole) error {
+	if operator.isRoot() {
+		return nil
+	}
+	if role.Id == operator.adminRoleId {
+		return errors.New("不能修改或分配自己持有的角色")
+	}
+	return operator.checkGrantable(role.GetPermissions())
+}
+
+// adminManageRank 计算用户在管理关系中的等级，持有自定义角色的普通用户视同管理员
+func adminManageRank(role int, adminRoleId int) int {
+	if adminRoleId != 0 && role < common.RoleAdminUser {
+		return common.RoleAdminUser
+	}
+	return role
+}
+
+// canManageUser 判断登录用户能否管理目标用户。路由已按管理权限授权，这里保证非超级管理员
+// 不能管理自己，也不能管理等级相同或更高的用户
+func canManageUser(c *gin.Context, target *model.User) bool {
+	if c.GetInt("role") >= common.RoleRootUser {
+		return true
+	}
+	if target.Id == c.GetInt("id") {
+		return false
+	}
+	return getOperatorManageRank(c) > adminManageRank(target.Role, target.AdminRoleId)
+}
+
+// getOperatorManageRank 返回登录用户的管理等级，用于限制可创建或设置的用户角色
+func getOperatorManageRank(c *gin.Context) int {
+	myRole := c.GetInt("role")
+	if myRole >= common.RoleRootUser {
+		return myRole
+	}
+	userCache, err := model.GetUserCache(c.GetInt("id"))
+	if err != nil {
+		return myRole
+	}
+	return adminManageRank(myRole, userCache.AdminRoleId)
+}
+
+// GetAdminPermissions 返回可用于组合角色的全部权限及未分配角色的管理员默认权限
+func GetAdminPermissions(c *gin.Context) {
+	common.ApiSuccess(c, gin.H{
+		"permissions":         constant.AllAdminPermissions,
+		"default_permissions": constant.DefaultAdminPermissions,
+	})
+}
+
+func GetAdminRoles(c *gin.Context) {
+	roles, err := model.GetAllAdminRoles()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, roles)
+}
+
+func GetAdminRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	role, err := model.GetAdminRoleById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, role)
+}
+
+func CreateAdminRole(c *gin.Context) {
+	var req adminRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req.Id = 0
+	permissions, err := validateAdminRoleRequest(&req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	role := &model.AdminRole{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	operator, err := getAdminRoleOperator(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := operator.checkGrantable(role.GetPermissions()); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := role.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, role)
+}
+
+func UpdateAdminRole(c *gin.Context) {
+	var req adminRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	role, err := model.GetAdminRoleById(req.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	operator, err := getAdminRoleOperator(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := operator.checkEditable(role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	permissions, err := validateAdminRoleRequest(&req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	role.Name = req.Name
+	role.Description = req.Description
+	role.Permissions = permissions
+	if err := operator.checkGrantable(role.GetPermissions()); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := role.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, role)
+}
+
+func DeleteAdminRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	role, err := model.GetAdminRoleById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	operator, err := getAdminRoleOperator(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := operator.checkEditable(role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteAdminRole(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// AssignAdminRole 为用户分配自定义角色，role_id 为 0 表示恢复为按系统角色授权
+func AssignAdminRole(c *gin.Context) {
+	var req adminRoleAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	user, err := model.GetUserById(req.UserId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Role == common.RoleRootUser {
+		common.ApiErrorMsg(c, "超级管理员拥有全部权限，无需分配角色")
+		return
+	}
+	if !canManageUser(c, user) {
+		common.ApiErrorMsg(c, "无权为自己或同权限等级、更高权限等级的用户分配角色")
+		return
+	}
+	operator, err := getAdminRoleOperator(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 目标用户当前的角色与新角色都不能超出操作者的权限范围
+	for _, roleId := range []int{user.AdminRoleId, req.RoleId} {
+		if roleId == 0 {
+			continue
+		}
+		role, err := model.GetAdminRoleById(roleId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				common.ApiErrorMsg(c, "角色不存在")
+				return
+			}
+			common.ApiError(c, err)
+			return
+		}
+		if err := operator.checkEditable(role); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if err := model.AssignAdminRole(user.Id, req.RoleId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 各时间分桶允许查询的最大跨度
+var analyticsBuckets = map[string]struct {
+	seconds int64
+	maxSpan int64
+}{
+	"":     {seconds: 0, maxSpan: 366 * 24 * 3600},
+	"hour": {seconds: 3600, maxSpan: 31 * 24 * 3600},
+	"day":  {seconds: 24 * 3600, maxSpan: 366 * 24 * 3600},
+}
+
+// 普通用户可用的分组维度，渠道与用户维度仅管理员可用
+var selfAnalyticsDimensions = []string{"token", "model", "group"}
+
+func parseAnalyticsQuery(c *gin.Context) (model.UsageRollupQuery, bool) {
+	bucket, ok := analyticsBuckets[c.Query("bucket")]
+	if !ok {
+		common.ApiErrorMsg(c, "无效的参数：bucket，可选 hour、day")
+		return model.UsageRollupQuery{}, false
+	}
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp <= 0 {
+		endTimestamp = common.GetTimestamp()
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	if startTimestamp <= 0 {
+		startTimestamp = endTimestamp - 7*24*3600
+	}
+	if endTimestamp < startTimestamp || endTimestamp-startTimestamp > bucket.maxSpan {
+		common.ApiErrorMsg(c, "时间跨度超出限制，按小时分桶最多 31 天，其他最多 366 天")
+		return model.UsageRollupQuery{}, false
+	}
+	tzOffset, _ := strconv.ParseInt(c.Query("tz_offset"), 10, 64)
+	if tzOffset < -14*3600 || tzOffset > 14*3600 {
+		common.ApiErrorMsg(c, "无效的参数：tz_offset")
+		return model.UsageRollupQuery{}, false
+	}
+	var groupBy []string
+	for _, dimension := range strings.Split(c.Query("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			groupBy = append(groupBy, dimension)
+		}
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	return model.UsageRollupQuery{
+		StartTime: startTimestamp,
+		EndTime:   endTimestamp,
+		Bucket:    bucket.seconds,
+		TzOffset:  tzOffset,
+		GroupBy:   groupBy,
+		UserId:    userId,
+		TokenId:   tokenId,
+		ModelName: c.Query("model_name"),
+		ChannelId: channel,
+		Group:     c.Query("group"),
+	}, true
+}
+
+// GetUsageAnalytics 管理员按维度与时间分桶查询用量聚合
+func GetUsageAnalytics(c *gin.Context) {
+	query, ok := parseAnalyticsQuery(c)
+	if !ok {
+		return
+	}
+	rows, err := model.QueryUsageRollups(query)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, rows)
+}
+
+// GetUserUsageAnalytics 用户查询自己的用量聚合，仅可按令牌、模型与分组分组
+func GetUserUsageAnalytics(c *gin.Context) {
+	query, ok := parseAnalyticsQuery(c)
+	if !ok {
+		return
+	}
+	for _, dimension := range query.GroupBy {
+		if !slices.Contains(selfAnalyticsDimensions, dimension) {
+			common.ApiErrorMsg(c, "不支持的分组维度: "+dimension)
+			return
+		}
+	}
+	query.UserId = c.GetInt("id")
+	query.ChannelId = 0
+	rows, err := model.QueryUsageRollups(query)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, rows)
+}
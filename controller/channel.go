@@ -522,6 +522,7 @@ func GetChannel(c *gin.Context) {
 // GetChannelKey 获取渠道密钥（需要通过安全验证中间件）
 // 此函数依赖 SecureVerificationRequired 中间件，确保用户已通过安全验证
 func GetChannelKey(c *gin.Context) {
+	common.SetAuditAction(c, constant.AuditActionReadKey)
 	userId := c.GetInt("id")
 	channelId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -794,6 +795,7 @@ func AddChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	common.SetAuditAfter(c, common.RedactAuditMap(common.ToAuditMap(addChannelRequest.Channel)))
 	service.ResetProxyClientCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -804,6 +806,9 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	if originChannel, err := model.GetChannelById(id, true); err == nil {
+		common.SetAuditBefore(c, originChannel)
+	}
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
@@ -1004,6 +1009,8 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 
+	common.SetAuditBefore(c, originChannel)
+
 	// Always copy the original ChannelInfo so that fields like IsMultiKey and MultiKeySize are retained.
 	channel.ChannelInfo = originChannel.ChannelInfo
 
@@ -1097,6 +1104,9 @@ func UpdateChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if updatedChannel, err := model.GetChannelById(channel.Id, true); err == nil {
+		common.SetAuditAfter(c, updatedChannel)
+	}
 	model.InitChannelCache()
 	service.ResetProxyClientCache()
 	channel.Key = ""
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelPerformance 返回本节点最近一小时各渠道、模型的首字时间、总耗时、排队耗时与输出速度分位数。
+// 统计只包含本节点处理的请求且重启后清空，多节点部署时各节点结果不同，响应中的 scope 与 node 用于标明来源
+func GetChannelPerformance(c *gin.Context) {
+	channelId := 0
+	if raw := strings.TrimSpace(c.Query("channel_id")); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			common.ApiErrorMsg(c, "无效的参数：channel_id")
+			return
+		}
+		channelId = id
+	}
+	stats := service.GetRelayPerformanceStats(channelId, strings.TrimSpace(c.Query("model")))
+
+	ids := make([]int, 0, len(stats))
+	for _, stat := range stats {
+		ids = append(ids, stat.ChannelId)
+	}
+	if len(ids) > 0 {
+		channels, err := model.GetChannelsByIds(ids)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		names := make(map[int]string, len(channels))
+		for _, channel := range channels {
+			names[channel.Id] = channel.Name
+		}
+		for i := range stats {
+			stats[i].ChannelName = names[stats[i].ChannelId]
+		}
+	}
+	common.ApiSuccess(c, service.NewRelayPerformanceReport(stats))
+}
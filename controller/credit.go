@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validCreditSubject(subjectType string, subjectId int) bool {
+	return (subjectType == model.CreditSubjectUser || subjectType == model.CreditSubjectOrganization) && subjectId > 0
+}
+
+// GetCreditAccounts 列出所有配置了信用额度的用户与组织
+func GetCreditAccounts(c *gin.Context) {
+	accounts, err := model.GetCreditAccounts()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, accounts)
+}
+
+type CreditLimitRequest struct {
+	SubjectType string `json:"subject_type"`
+	SubjectId   int    `json:"subject_id"`
+	CreditLimit int    `json:"credit_limit"`
+}
+
+// UpdateCreditLimit 设置用户或组织的后付费信用额度
+func UpdateCreditLimit(c *gin.Context) {
+	var req CreditLimitRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil || !validCreditSubject(req.SubjectType, req.SubjectId) {
+		common.ApiErrorMsg(c, "无效的参数：subject_type 或 subject_id")
+		return
+	}
+	if err := model.SetCreditLimit(req.SubjectType, req.SubjectId, req.CreditLimit); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.SetAuditAction(c, constant.AuditActionUpdate)
+	common.SetAuditEntity(c, fmt.Sprintf("%s:%d", req.SubjectType, req.SubjectId))
+	common.SetAuditAfter(c, map[string]any{"credit_limit": req.CreditLimit})
+	common.ApiSuccess(c, nil)
+}
+
+type CreditSettleRequest struct {
+	SubjectType string  `json:"subject_type"`
+	SubjectId   int     `json:"subject_id"`
+	Money       float64 `json:"money"`
+	InvoiceNo   string  `json:"invoice_no"`
+	Remark      string  `json:"remark"`
+}
+
+// SettleCreditInvoice 登记后付费发票的收款，并将透支的余额补回 0
+func SettleCreditInvoice(c *gin.Context) {
+	var req CreditSettleRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil || !validCreditSubject(req.SubjectType, req.SubjectId) {
+		common.ApiErrorMsg(c, "无效的参数：subject_type 或 subject_id")
+		return
+	}
+	if req.InvoiceNo == "" || len(req.InvoiceNo) > 64 {
+		common.ApiErrorMsg(c, "发票号不能为空且不能超过 64 个字符")
+		return
+	}
+	if req.Money < 0 {
+		common.ApiErrorMsg(c, "收款金额不能为负数")
+		return
+	}
+	settlement, err := model.SettleCreditInvoice(req.SubjectType, req.SubjectId, req.Money, req.InvoiceNo, req.Remark, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.SetAuditAction(c, constant.AuditActionCreate)
+	common.SetAuditEntity(c, fmt.Sprintf("%s:%d", req.SubjectType, req.SubjectId))
+	common.SetAuditAfter(c, settlement)
+	common.ApiSuccess(c, settlement)
+}
+
+// GetCreditSettlements 分页查询发票结算记录
+func GetCreditSettlements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	subjectId, _ := strconv.Atoi(c.Query("subject_id"))
+	settlements, total, err := model.GetCreditSettlements(c.Query("subject_type"), subjectId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(settlements)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserCreditGrants 分页查询当前用户的赠送额度及其过期时间，可按状态过滤，并返回当前有效额度汇总
+func GetUserCreditGrants(c *gin.Context) {
+	userId := c.GetInt("id")
+	pageInfo := common.GetPageQuery(c)
+	grants, total, err := model.GetUserCreditGrants(userId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	summary, err := model.GetUserCreditGrantSummary(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(grants)
+	common.ApiSuccess(c, gin.H{
+		"summary": summary,
+		"grants":  pageInfo,
+	})
+}
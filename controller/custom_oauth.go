@@ -36,6 +36,7 @@ type CustomOAuthProviderResponse struct {
 	AuthStyle             int    `json:"auth_style"`
 	AccessPolicy          string `json:"access_policy"`
 	AccessDeniedMessage   string `json:"access_denied_message"`
+	ClaimMappings         string `json:"claim_mappings"`
 }
 
 type UserOAuthBindingResponse struct {
@@ -66,6 +67,7 @@ func toCustomOAuthProviderResponse(p *model.CustomOAuthProvider) *CustomOAuthPro
 		AuthStyle:             p.AuthStyle,
 		AccessPolicy:          p.AccessPolicy,
 		AccessDeniedMessage:   p.AccessDeniedMessage,
+		ClaimMappings:         p.ClaimMappings,
 	}
 }
 
@@ -131,6 +133,7 @@ type CreateCustomOAuthProviderRequest struct {
 	AuthStyle             int    `json:"auth_style"`
 	AccessPolicy          string `json:"access_policy"`
 	AccessDeniedMessage   string `json:"access_denied_message"`
+	ClaimMappings         string `json:"claim_mappings"`
 }
 
 type FetchCustomOAuthDiscoveryRequest struct {
@@ -249,6 +252,7 @@ func CreateCustomOAuthProvider(c *gin.Context) {
 		AuthStyle:             req.AuthStyle,
 		AccessPolicy:          req.AccessPolicy,
 		AccessDeniedMessage:   req.AccessDeniedMessage,
+		ClaimMappings:         req.ClaimMappings,
 	}
 
 	if err := model.CreateCustomOAuthProvider(provider); err != nil {
@@ -286,6 +290,7 @@ type UpdateCustomOAuthProviderRequest struct {
 	AuthStyle             *int    `json:"auth_style"`            // Optional: if nil, keep existing
 	AccessPolicy          *string `json:"access_policy"`         // Optional: if nil, keep existing
 	AccessDeniedMessage   *string `json:"access_denied_message"` // Optional: if nil, keep existing
+	ClaimMappings         *string `json:"claim_mappings"`        // Optional: if nil, keep existing
 }
 
 // UpdateCustomOAuthProvider updates an existing custom OAuth provider
@@ -380,6 +385,9 @@ func UpdateCustomOAuthProvider(c *gin.Context) {
 	if req.AccessDeniedMessage != nil {
 		provider.AccessDeniedMessage = *req.AccessDeniedMessage
 	}
+	if req.ClaimMappings != nil {
+		provider.ClaimMappings = *req.ClaimMappings
+	}
 
 	if err := model.UpdateCustomOAuthProvider(provider); err != nil {
 		common.ApiError(c, err)
@@ -500,8 +508,7 @@ func GetUserOAuthBindingsByAdmin(c *gin.Context) {
 		return
 	}
 
-	myRole := c.GetInt("role")
-	if myRole <= targetUser.Role && myRole != common.RoleRootUser {
+	if !canManageUser(c, targetUser) {
 		common.ApiErrorMsg(c, "no permission")
 		return
 	}
@@ -559,8 +566,7 @@ func UnbindCustomOAuthByAdmin(c *gin.Context) {
 		return
 	}
 
-	myRole := c.GetInt("role")
-	if myRole <= targetUser.Role && myRole != common.RoleRootUser {
+	if !canManageUser(c, targetUser) {
 		common.ApiErrorMsg(c, "no permission")
 		return
 	}
@@ -2,9 +2,11 @@ package controller
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/model"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +28,12 @@ func GetAllLogs(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	// 没有 logs:read_content 权限时隐藏日志内容
+	if !slices.Contains(common.GetContextKeyStringSlice(c, constant.ContextKeyAdminPermissions), constant.AdminPermissionLogsReadContent) {
+		for _, log := range logs {
+			log.Content = ""
+		}
+	}
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(logs)
 	common.ApiSuccess(c, pageInfo)
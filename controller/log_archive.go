@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLogArchives 分页查询日志归档索引
+func GetLogArchives(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	archives, total, err := model.GetLogArchives(c.Query("day"), c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(archives)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// QueryLogArchive 直接从归档文件中按条件查询日志，无需先恢复
+func QueryLogArchive(c *gin.Context) {
+	archive, ok := loadLogArchive(c)
+	if !ok {
+		return
+	}
+	store, err := service.NewLogArchiveStore(operation_setting.GetLogArchiveSetting())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	filter := service.LogArchiveFilter{
+		Type:      logType,
+		UserId:    userId,
+		Username:  c.Query("username"),
+		TokenName: c.Query("token_name"),
+		ModelName: c.Query("model_name"),
+		ChannelId: channel,
+		RequestId: c.Query("request_id"),
+	}
+	logs, total, err := service.QueryLogArchive(c.Request.Context(), store, archive, filter, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 没有 logs:read_content 权限时隐藏日志内容
+	if !slices.Contains(common.GetContextKeyStringSlice(c, constant.ContextKeyAdminPermissions), constant.AdminPermissionLogsReadContent) {
+		for _, log := range logs {
+			log.Content = ""
+		}
+	}
+	pageInfo.SetTotal(total)
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type LogArchiveRestoreRequest struct {
+	HoldHours int `json:"hold_hours"`
+}
+
+// RestoreLogArchive 将归档写回日志表以便审计，保留 hold_hours（默认 24）小时后重新归档删除
+func RestoreLogArchive(c *gin.Context) {
+	var req LogArchiveRestoreRequest
+	if c.Request.ContentLength > 0 {
+		if err := common.DecodeJson(c.Request.Body, &req); err != nil || req.HoldHours < 0 {
+			common.ApiErrorMsg(c, "无效的参数：hold_hours")
+			return
+		}
+	}
+	if req.HoldHours == 0 {
+		req.HoldHours = 24
+	}
+	archive, ok := loadLogArchive(c)
+	if !ok {
+		return
+	}
+	common.SetAuditAction(c, constant.AuditActionRestore)
+	common.SetAuditEntity(c, archive.Id)
+	if archive.Status == model.LogArchiveStatusUploaded {
+		common.ApiErrorMsg(c, "该归档尚未完成，日志仍在数据库中")
+		return
+	}
+	store, err := service.NewLogArchiveStore(operation_setting.GetLogArchiveSetting())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	holdUntil := time.Now().Add(time.Duration(req.HoldHours) * time.Hour).Unix()
+	restored, err := service.RestoreLogArchive(c.Request.Context(), store, archive, holdUntil)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.SetAuditAfter(c, map[string]any{"day": archive.Day, "rows": restored, "restored_until": holdUntil})
+	common.ApiSuccess(c, gin.H{
+		"restored":       restored,
+		"restored_until": holdUntil,
+	})
+}
+
+func loadLogArchive(c *gin.Context) (*model.LogArchive, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的参数：id")
+		return nil, false
+	}
+	archive, err := model.GetLogArchiveById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return archive, true
+}
@@ -177,7 +177,7 @@ func UpdateMidjourneyTaskBulk() {
 				if err != nil {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else if won && shouldReturnQuota {
-					err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+					err = model.IncreaseUserQuota(task.UserId, task.Quota, false, model.QuotaLedgerRef{Source: model.QuotaSourceTask, RefId: task.MjId})
 					if err != nil {
 						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
@@ -123,7 +123,17 @@ func HandleOAuth(c *gin.Context) {
 		return
 	}
 
+	// 每次登录都按声明映射规则同步分组与角色，使 IdP 中的调整及时生效
+	if mapping := oauth.GetClaimMappingResult(oauthUser); mapping != nil {
+		if err := user.UpdateIdentityMapping(mapping.Group, mapping.Role); err != nil {
+			common.SysError(fmt.Sprintf("[OAuth] Failed to apply claim mapping to user %d: %s", user.Id, err.Error()))
+		}
+	}
+
 	// 9. Setup login
+	if _, ok := provider.(*oauth.SAMLProvider); ok {
+		c.Set(samlLoginKey, true)
+	}
 	setupLogin(user, c)
 }
 
@@ -234,6 +244,9 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	if !common.RegisterEnabled {
 		return nil, &OAuthRegistrationDisabledError{}
 	}
+	if _, ok := provider.(*oauth.SAMLProvider); !ok && isSSOOnlyEnforced() {
+		return nil, &OAuthRegistrationDisabledError{}
+	}
 
 	// Set up new user
 	user.Username = provider.GetProviderPrefix() + strconv.Itoa(model.GetMaxUserId()+1)
@@ -259,6 +272,19 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	}
 	user.Role = common.RoleCommonUser
 	user.Status = common.UserStatusEnabled
+	// 身份提供方映射的分组仅在首次创建用户时生效，已存在用户的分组由管理员维护
+	if group, ok := oauthUser.Extra["group"].(string); ok && group != "" {
+		user.Group = group
+	}
+	mapping := oauth.GetClaimMappingResult(oauthUser)
+	if mapping != nil {
+		if mapping.Group != "" {
+			user.Group = mapping.Group
+		}
+		if mapping.Role != 0 {
+			user.Role = mapping.Role
+		}
+	}
 
 	// Handle affiliate code
 	affCode := session.Get("aff")
@@ -308,6 +334,7 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 				"github_id":   user.GitHubId,
 				"discord_id":  user.DiscordId,
 				"oidc_id":     user.OidcId,
+				"saml_id":     user.SamlId,
 				"linux_do_id": user.LinuxDOId,
 				"wechat_id":   user.WeChatId,
 				"telegram_id": user.TelegramId,
@@ -325,6 +352,12 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 		user.FinalizeOAuthUserCreation(inviterId)
 	}
 
+	if mapping != nil && mapping.Quota != nil {
+		if err := user.ApplyMappedInitialQuota(*mapping.Quota); err != nil {
+			common.SysError(fmt.Sprintf("[OAuth] Failed to apply mapped initial quota to user %d: %s", user.Id, err.Error()))
+		}
+	}
+
 	return user, nil
 }
 
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
@@ -25,7 +27,8 @@ func GetOptions(c *gin.Context) {
 			strings.HasSuffix(k, "Secret") ||
 			strings.HasSuffix(k, "Key") ||
 			strings.HasSuffix(k, "secret") ||
-			strings.HasSuffix(k, "api_key") {
+			strings.HasSuffix(k, "api_key") ||
+			strings.HasSuffix(k, "private_key") {
 			continue
 		}
 		options = append(options, &model.Option{
@@ -47,6 +50,18 @@ type OptionUpdateRequest struct {
 	Value any    `json:"value"`
 }
 
+// auditOptionSnapshot 将配置项包装为审计快照，JSON 对象会被展开，便于定位到具体模型的倍率等变更
+func auditOptionSnapshot(key string, value string) map[string]any {
+	var parsed any = value
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "{") {
+		var m map[string]any
+		if err := common.UnmarshalJsonStr(trimmed, &m); err == nil {
+			parsed = m
+		}
+	}
+	return map[string]any{key: parsed}
+}
+
 func UpdateOption(c *gin.Context) {
 	var option OptionUpdateRequest
 	err := json.NewDecoder(c.Request.Body).Decode(&option)
@@ -57,6 +72,20 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	if model.IsTokenKeySecretReserved(option.Key) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该配置项不允许修改",
+		})
+		return
+	}
+	if option.Key == "claim_mapping.allow_admin_role" && c.GetInt("role") < common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅超级管理员可以修改该配置项",
+		})
+		return
+	}
 	switch option.Value.(type) {
 	case bool:
 		option.Value = common.Interface2String(option.Value.(bool))
@@ -92,6 +121,55 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "oidc.claim_mappings":
+		if _, err := model.ParseClaimMappingRules(option.Value.(string)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "OIDC 声明映射规则不合法: " + err.Error(),
+			})
+			return
+		}
+	case "payload_capture_setting.redact_paths":
+		var paths []string
+		if err := common.UnmarshalJsonStr(option.Value.(string), &paths); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "脱敏路径必须是字符串数组: " + err.Error(),
+			})
+			return
+		}
+		if _, err := common.ParseJSONPaths(paths); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "脱敏路径不合法: " + err.Error(),
+			})
+			return
+		}
+	case "credit_setting.dunning_percents":
+		var percents []int
+		if err := common.UnmarshalJsonStr(option.Value.(string), &percents); err != nil || !operation_setting.ValidDunningPercents(percents) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "催缴档位必须是 1-100 之间且不重复的整数数组",
+			})
+			return
+		}
+	case "statement_setting.timezone":
+		if _, err := time.LoadLocation(option.Value.(string)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "账单时区不合法: " + err.Error(),
+			})
+			return
+		}
+	case "quota_setting.stream_overdraft_quota":
+		if quota, err := strconv.Atoi(option.Value.(string)); err != nil || quota < 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "流式透支额度必须是非负整数",
+			})
+			return
+		}
 	case "LinuxDOOAuthEnabled":
 		if option.Value == "true" && common.LinuxDOClientId == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -178,6 +256,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "TieredRatio":
+		err = ratio_setting.UpdateTieredRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "分档倍率设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "ModelRequestRateLimitGroup":
 		err = setting.CheckModelRequestRateLimitGroup(option.Value.(string))
 		if err != nil {
@@ -242,11 +329,17 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	previousValue := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	common.SetAuditEntity(c, option.Key)
+	common.SetAuditBefore(c, auditOptionSnapshot(option.Key, previousValue))
+	common.SetAuditAfter(c, auditOptionSnapshot(option.Key, option.Value.(string)))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
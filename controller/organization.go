@@ -0,0 +1,528 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+type organizationMemberRequest struct {
+	UserId     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+}
+
+type organizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+func validateOrganizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("组织名称不能为空")
+	}
+	if len(name) > 64 {
+		return "", errors.New("组织名称过长")
+	}
+	return name, nil
+}
+
+// getOrganizationMembership 读取路径中的组织并校验当前用户的成员身份
+func getOrganizationMembership(c *gin.Context) (*model.Organization, *model.OrganizationMember, bool) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(orgId, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, errors.New("您不是该组织的成员"))
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+func organizationPermissionDenied(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": "无权进行此操作",
+	})
+}
+
+func GetUserOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	req := organizationRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	name, err := validateOrganizationName(req.Name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.CreateOrganization(name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"member":       member,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageMembers() {
+		organizationPermissionDenied(c)
+		return
+	}
+	req := organizationRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	name, err := validateOrganizationName(req.Name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := org.Rename(name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func DeleteOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		organizationPermissionDenied(c)
+		return
+	}
+	if err := model.DeleteOrganization(org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("删除组织 %s (ID: %d)，剩余额度 %s 退回钱包", org.Name, org.Id, logger.LogQuota(org.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// TransferQuotaToOrganization 从当前用户钱包向组织钱包转入额度
+func TransferQuotaToOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageBilling() {
+		organizationPermissionDenied(c)
+		return
+	}
+	req := organizationQuotaRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	if err := model.TransferUserQuotaToOrganization(userId, org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("向组织 %s (ID: %d) 转入额度 %s", org.Name, org.Id, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+func TransferOrganizationOwnership(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		organizationPermissionDenied(c)
+		return
+	}
+	req := organizationMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.TransferOrganizationOwnership(org, req.UserId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageMembers() {
+		organizationPermissionDenied(c)
+		return
+	}
+	req := organizationMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	// 只有所有者可以任命管理员
+	if req.Role == model.OrganizationRoleAdmin && member.Role != model.OrganizationRoleOwner {
+		organizationPermissionDenied(c)
+		return
+	}
+	userId := req.UserId
+	if userId == 0 {
+		var err error
+		userId, err = model.GetUserIdByUsername(req.Username)
+		if err != nil {
+			common.ApiError(c, errors.New("用户不存在"))
+			return
+		}
+	}
+	newMember, err := model.AddOrganizationMember(org.Id, userId, req.Role, req.QuotaLimit)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, newMember)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageMembers() {
+		organizationPermissionDenied(c)
+		return
+	}
+	req := organizationMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	target, err := model.GetOrganizationMember(org.Id, req.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = target.Role
+	}
+	// 所有者角色只能通过转让变更；管理员只能调整财务和普通成员
+	if (target.Role == model.OrganizationRoleOwner) != (req.Role == model.OrganizationRoleOwner) {
+		common.ApiError(c, errors.New("所有者角色只能通过转让所有权变更"))
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner &&
+		(target.Role == model.OrganizationRoleOwner || target.Role == model.OrganizationRoleAdmin || req.Role == model.OrganizationRoleAdmin) {
+		organizationPermissionDenied(c)
+		return
+	}
+	target.Role = req.Role
+	target.QuotaLimit = req.QuotaLimit
+	if err := model.UpdateOrganizationMember(target); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, target)
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以通过该接口主动退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if userId != member.UserId {
+		if !member.CanManageMembers() {
+			organizationPermissionDenied(c)
+			return
+		}
+		target, err := model.GetOrganizationMember(org.Id, userId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if target.Role == model.OrganizationRoleAdmin && member.Role != model.OrganizationRoleOwner {
+			organizationPermissionDenied(c)
+			return
+		}
+	}
+	if err := model.RemoveOrganizationMember(org, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationTokens(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageMembers() {
+		organizationPermissionDenied(c)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	tokens, total, err := model.GetOrganizationTokens(org.Id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tokens)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AddOrganizationToken 创建组织令牌，令牌归属于创建者，成员离开组织后转交给所有者
+func AddOrganizationToken(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageMembers() {
+		organizationPermissionDenied(c)
+		return
+	}
+	token := model.Token{}
+	if err := c.ShouldBindJSON(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(token.Name) > 50 {
+		common.ApiError(c, errors.New("令牌名称过长"))
+		return
+	}
+	if !token.UnlimitedQuota && token.RemainQuota < 0 {
+		common.ApiError(c, errors.New("额度值不能为负数"))
+		return
+	}
+	var err error
+	token.Scopes, err = model.ValidateTokenScopes(token.Scopes)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	maxTokens := operation_setting.GetMaxUserTokens()
+	count, err := model.CountUserTokens(member.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if int(count) >= maxTokens {
+		common.ApiError(c, fmt.Errorf("已达到最大令牌数量限制 (%d)", maxTokens))
+		return
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.SysLog("failed to generate token key: " + err.Error())
+		common.ApiError(c, errors.New("生成令牌失败"))
+		return
+	}
+	cleanToken := model.Token{
+		UserId:             member.UserId,
+		OrganizationId:     org.Id,
+		Name:               token.Name,
+		CreatedTime:        common.GetTimestamp(),
+		AccessedTime:       common.GetTimestamp(),
+		ExpiredTime:        token.ExpiredTime,
+		RemainQuota:        token.RemainQuota,
+		UnlimitedQuota:     token.UnlimitedQuota,
+		ModelLimitsEnabled: token.ModelLimitsEnabled,
+		ModelLimits:        token.ModelLimits,
+		AllowIps:           token.AllowIps,
+		Group:              token.Group,
+		CrossGroupRetry:    token.CrossGroupRetry,
+		Scopes:             token.Scopes,
+	}
+	cleanToken.SetKey(key)
+	if err := cleanToken.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 明文密钥只在创建时返回这一次
+	common.ApiSuccess(c, cleanToken)
+}
+
+func DeleteOrganizationToken(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageMembers() {
+		organizationPermissionDenied(c)
+		return
+	}
+	tokenId, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	token, err := model.GetOrganizationToken(org.Id, tokenId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := token.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationUsage 按成员汇总组织用量，同时返回成员的累计消耗与消费上限
+func GetOrganizationUsage(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanViewUsage() {
+		organizationPermissionDenied(c)
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	usages, err := model.GetOrganizationUsage(org.Id, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"members":      members,
+		"usage":        usages,
+	})
+}
+
+func GetOrganizationLogs(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanViewUsage() {
+		organizationPermissionDenied(c)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	modelName := c.Query("model_name")
+	logs, total, err := model.GetOrganizationLogs(org.Id, logType, startTimestamp, endTimestamp, modelName, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AdminAdjustOrganizationQuota 管理员调整组织额度，quota 为负数时扣减
+func AdminAdjustOrganizationQuota(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req := organizationQuotaRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.AdjustOrganizationQuota(org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(org.OwnerId, model.LogTypeManage, fmt.Sprintf("管理员调整组织 %s (ID: %d) 额度 %s", org.Name, org.Id, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
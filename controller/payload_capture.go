@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPayloadCaptures 分页查询请求载荷抓包，不返回载荷内容
+func GetPayloadCaptures(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	query := model.PayloadCaptureQuery{
+		UserId:         userId,
+		TokenId:        tokenId,
+		ChannelId:      channelId,
+		ModelName:      c.Query("model_name"),
+		RequestId:      c.Query("request_id"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+	captures, total, err := model.GetPayloadCaptures(query, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(captures)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetPayloadCapture 返回单条抓包及解压后的四段载荷
+func GetPayloadCapture(c *gin.Context) {
+	capture, bodies, ok := loadPayloadCapture(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"capture": capture,
+		"bodies":  bodies,
+	})
+}
+
+type PayloadReplayRequest struct {
+	ChannelId int `json:"channel_id"`
+}
+
+// ReplayPayloadCapture 将抓包中的入站请求（已脱敏）发往指定渠道，并与当时返回给客户端的响应比较。
+// 重放不经过计费，也不会重试或切换渠道。
+func ReplayPayloadCapture(c *gin.Context) {
+	var req PayloadReplayRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil || req.ChannelId == 0 {
+		common.ApiErrorMsg(c, "无效的参数：channel_id")
+		return
+	}
+	capture, bodies, ok := loadPayloadCapture(c)
+	if !ok {
+		return
+	}
+	common.SetAuditAction(c, constant.AuditActionReplay)
+	if capture.RelayFormat == "" {
+		common.ApiErrorMsg(c, "该抓包未到达上游，无法重放")
+		return
+	}
+	if !json.Valid([]byte(bodies.InboundRequest)) {
+		common.ApiErrorMsg(c, "仅支持重放完整的 JSON 请求，该抓包的请求体已被截断或不是 JSON")
+		return
+	}
+	targetChannel, err := model.GetChannelById(req.ChannelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	result := replayPayload(capture, []byte(bodies.InboundRequest), targetChannel)
+	common.SetAuditAfter(c, map[string]any{
+		"channel_id":          result.ChannelId,
+		"original_channel_id": result.OriginalChannelId,
+		"model":               capture.ModelName,
+		"status_code":         result.StatusCode,
+		"duration_ms":         result.DurationMs,
+		"error":               result.Error,
+	})
+	if result.Error == "" {
+		paths, err := common.ParseJSONPaths(operation_setting.GetPayloadCaptureSetting().RedactPaths)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		replayed := common.RedactJSONPaths(result.rawResponse, paths)
+		result.Response = string(replayed)
+		result.Diff, result.Identical = service.DiffPayloadResponses([]byte(bodies.ClientResponse), replayed)
+	}
+	common.ApiSuccess(c, result)
+}
+
+func loadPayloadCapture(c *gin.Context) (*model.PayloadCapture, *model.PayloadCaptureBodies, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的参数：id")
+		return nil, nil, false
+	}
+	capture, err := model.GetPayloadCaptureById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	bodies, err := capture.DecodeBodies()
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	return capture, bodies, true
+}
+
+type payloadReplayResult struct {
+	ChannelId          int            `json:"channel_id"`
+	OriginalChannelId  int            `json:"original_channel_id"`
+	OriginalStatusCode int            `json:"original_status_code"`
+	StatusCode         int            `json:"status_code"`
+	DurationMs         int64          `json:"duration_ms"`
+	Error              string         `json:"error,omitempty"`
+	Response           string         `json:"response"`
+	Identical          bool           `json:"identical"`
+	Diff               map[string]any `json:"diff"`
+	rawResponse        []byte
+}
+
+func replayPayload(capture *model.PayloadCapture, inbound []byte, targetChannel *model.Channel) *payloadReplayResult {
+	result := &payloadReplayResult{
+		ChannelId:          targetChannel.Id,
+		OriginalChannelId:  capture.ChannelId,
+		OriginalStatusCode: capture.StatusCode,
+	}
+	start := time.Now()
+	statusCode, body, err := doPayloadReplay(capture, inbound, targetChannel)
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.StatusCode = statusCode
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.rawResponse = body
+	return result
+}
+
+func doPayloadReplay(capture *model.PayloadCapture, inbound []byte, targetChannel *model.Channel) (int, []byte, error) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(capture.Method, capture.Path, bytes.NewReader(inbound))
+	c.Request.Header.Set("Content-Type", "application/json")
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+
+	cache, err := model.GetUserCache(capture.UserId)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to load capture user: %w", err)
+	}
+	cache.WriteContext(c)
+	group, _ := model.GetUserGroup(capture.UserId, false)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, group)
+	if apiErr := middleware.SetupContextForSelectedChannel(c, targetChannel, capture.ModelName); apiErr != nil {
+		return 0, nil, apiErr
+	}
+
+	relayFormat := types.RelayFormat(capture.RelayFormat)
+	request, err := helper.GetAndValidateRequest(c, relayFormat)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := relaycommon.GenRelayInfo(c, relayFormat, request, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	info.InitChannelMeta(c)
+	if err = helper.ModelMappedHelper(c, info, request); err != nil {
+		return 0, nil, err
+	}
+	if _, err = helper.ModelPriceHelper(c, info, 0, request.GetTokenCountMeta()); err != nil {
+		return 0, nil, err
+	}
+	apiType, _ := common.ChannelType2APIType(targetChannel.Type)
+	adaptor := relay.GetAdaptor(apiType)
+	if adaptor == nil {
+		return 0, nil, fmt.Errorf("invalid api type: %d, adaptor is nil", apiType)
+	}
+	adaptor.Init(info)
+
+	converted, err := convertReplayRequest(c, adaptor, info, request)
+	if err != nil {
+		return 0, nil, err
+	}
+	jsonData, err := common.Marshal(converted)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride, relaycommon.BuildParamOverrideContext(info))
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, nil, err
+	}
+	httpResp, _ := resp.(*http.Response)
+	if httpResp == nil {
+		return 0, nil, errors.New("upstream returned no response")
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		if apiErr := service.RelayErrorHandler(c.Request.Context(), httpResp, true); apiErr != nil {
+			return httpResp.StatusCode, nil, apiErr
+		}
+		return httpResp.StatusCode, nil, fmt.Errorf("upstream returned status %d", httpResp.StatusCode)
+	}
+	if _, apiErr := adaptor.DoResponse(c, httpResp, info); apiErr != nil {
+		return apiErr.StatusCode, nil, apiErr
+	}
+	body, err := io.ReadAll(w.Result().Body)
+	return w.Code, body, err
+}
+
+// convertReplayRequest 按请求类型调用适配器对应的转换方法
+func convertReplayRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, request dto.Request) (any, error) {
+	switch req := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		return adaptor.ConvertOpenAIRequest(c, info, req)
+	case *dto.ClaudeRequest:
+		return adaptor.ConvertClaudeRequest(c, info, req)
+	case *dto.GeminiChatRequest:
+		return adaptor.ConvertGeminiRequest(c, info, req)
+	case *dto.EmbeddingRequest:
+		return adaptor.ConvertEmbeddingRequest(c, info, *req)
+	case *dto.ImageRequest:
+		return adaptor.ConvertImageRequest(c, info, *req)
+	case *dto.RerankRequest:
+		return adaptor.ConvertRerankRequest(c, info.RelayMode, *req)
+	case *dto.OpenAIResponsesRequest:
+		return adaptor.ConvertOpenAIResponsesRequest(c, info, *req)
+	case *dto.OpenAIResponsesCompactionRequest:
+		return adaptor.ConvertOpenAIResponsesRequest(c, info, dto.OpenAIResponsesRequest{
+			Model:              req.Model,
+			Input:              req.Input,
+			Instructions:       req.Instructions,
+			PreviousResponseID: req.PreviousResponseID,
+		})
+	default:
+		return nil, fmt.Errorf("replay does not support request type %T", request)
+	}
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func quotaLedgerQueryFromContext(c *gin.Context) model.QuotaLedgerQuery {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return model.QuotaLedgerQuery{
+		Source:         c.Query("source"),
+		RefId:          c.Query("ref_id"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+func writeQuotaLedgers(c *gin.Context, query model.QuotaLedgerQuery) {
+	pageInfo := common.GetPageQuery(c)
+	entries, total, err := model.GetQuotaLedgers(query, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(entries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetQuotaLedgers 管理员分页查询额度流水，可按用户、来源、关联单号与时间过滤
+func GetQuotaLedgers(c *gin.Context) {
+	query := quotaLedgerQueryFromContext(c)
+	query.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	writeQuotaLedgers(c, query)
+}
+
+// GetUserQuotaLedgers 分页查询当前用户的额度流水
+func GetUserQuotaLedgers(c *gin.Context) {
+	query := quotaLedgerQueryFromContext(c)
+	query.UserId = c.GetInt("id")
+	writeQuotaLedgers(c, query)
+}
+
+// GetQuotaLedgerMismatches 分页查询对账任务发现的异常
+func GetQuotaLedgerMismatches(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	mismatches, total, err := model.GetQuotaLedgerMismatches(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(mismatches)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -114,6 +114,9 @@ func AddRedemption(c *gin.Context) {
 
 func DeleteRedemption(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	if originRedemption, err := model.GetRedemptionById(id); err == nil {
+		common.SetAuditBefore(c, originRedemption)
+	}
 	err := model.DeleteRedemptionById(id)
 	if err != nil {
 		common.ApiError(c, err)
@@ -139,6 +142,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	common.SetAuditBefore(c, cleanRedemption)
 	if statusOnly == "" {
 		if valid, msg := validateExpiredTime(c, redemption.ExpiredTime); !valid {
 			c.JSON(http.StatusOK, gin.H{"success": false, "message": msg})
@@ -157,6 +161,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	common.SetAuditAfter(c, cleanRedemption)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -15,6 +15,8 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
@@ -28,6 +30,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
@@ -87,6 +90,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	defer func() {
 		if newAPIError != nil {
 			logger.LogError(c, fmt.Sprintf("relay error: %s", newAPIError.Error()))
+			metrics.IncRelayError(string(newAPIError.GetErrorCode()), common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+				common.GetContextKeyString(c, constant.ContextKeyUsingGroup), c.GetInt("channel_id"))
 			newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
 			switch relayFormat {
 			case types.RelayFormatOpenAIRealtime:
@@ -185,12 +190,19 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
+		// 每次尝试对应一个 span，记录所用渠道与失败原因
+		attemptSpan, endAttempt := tracing.StartSpan(c, "RelayAttempt", attribute.Int("relay.retry", retryParam.GetRetry()))
+		_, endSelect := tracing.StartSpan(c, "SelectChannel")
 		channel, channelErr := getChannel(c, relayInfo, retryParam)
+		endSelect()
 		if channelErr != nil {
 			logger.LogError(c, channelErr.Error())
 			newAPIError = channelErr
+			tracing.RecordError(attemptSpan, channelErr)
+			endAttempt()
 			break
 		}
+		attemptSpan.SetAttributes(attribute.Int("channel.id", channel.Id), attribute.Int("channel.type", channel.Type))
 
 		addUsedChannel(c, channel.Id)
 		bodyStorage, bodyErr := common.GetBodyStorage(c)
@@ -201,6 +213,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			} else {
 				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
 			}
+			tracing.RecordError(attemptSpan, newAPIError)
+			endAttempt()
 			break
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
@@ -217,16 +231,23 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 
 		if newAPIError == nil {
+			endAttempt()
+			observeRelayMetrics(relayInfo, channel.Id)
 			return
 		}
 
 		newAPIError = service.NormalizeViolationFeeError(newAPIError)
+		attemptSpan.SetAttributes(attribute.String("error.code", string(newAPIError.GetErrorCode())), attribute.Int("http.response.status_code", newAPIError.StatusCode))
+		tracing.RecordError(attemptSpan, newAPIError)
+		endAttempt()
 
 		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		recordUsageRollupError(c, channel.Id)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
 			break
 		}
+		metrics.IncRelayRetry(relayInfo.OriginModelName, relayInfo.UsingGroup, channel.Id)
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -243,6 +264,15 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// observeRelayMetrics 记录成功请求的总耗时，流式请求额外记录首字时间
+func observeRelayMetrics(info *relaycommon.RelayInfo, channelId int) {
+	var ttft time.Duration
+	if info.IsStream && info.HasSendResponse() {
+		ttft = info.FirstResponseTime.Sub(info.StartTime)
+	}
+	metrics.ObserveRelay(info.OriginModelName, info.UsingGroup, channelId, time.Since(info.StartTime), ttft)
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -346,6 +376,25 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return operation_setting.ShouldRetryByStatusCode(code)
 }
 
+// recordUsageRollupError 将一次失败的渠道尝试计入用量聚合
+func recordUsageRollupError(c *gin.Context, channelId int) {
+	startTime := common.GetContextKeyTime(c, constant.ContextKeyRequestStartTime)
+	latencyMs := int64(0)
+	if !startTime.IsZero() {
+		latencyMs = time.Since(startTime).Milliseconds()
+	}
+	model.RecordUsageRollup(model.UsageRollup{
+		UserId:    c.GetInt("id"),
+		TokenId:   c.GetInt("token_id"),
+		ModelName: c.GetString("original_model"),
+		ChannelId: channelId,
+		Group:     c.GetString("group"),
+		Requests:  1,
+		Errors:    1,
+		LatencyMs: latencyMs,
+	}, common.GetTimestamp())
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
@@ -549,6 +598,7 @@ func RelayTask(c *gin.Context) {
 				*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey,
 					common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()),
 				types.NewOpenAIError(taskErr.Error, types.ErrorCodeBadResponseStatusCode, taskErr.StatusCode))
+			recordUsageRollupError(c, channel.Id)
 		}
 
 		if !shouldRetryTaskRelay(c, channel.Id, taskErr, common.RetryTimes-retryParam.GetRetry()) {
@@ -573,6 +623,7 @@ func RelayTask(c *gin.Context) {
 		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
 		task.PrivateData.BillingSource = relayInfo.BillingSource
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+		task.PrivateData.OrganizationId = relayInfo.OrganizationId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
 			ModelPrice:      relayInfo.PriceData.ModelPrice,
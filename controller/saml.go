@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/crewjam/saml"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// samlLoginKey 标记本次登录经由 SAML 完成，用于仅允许单点登录时的校验
+const samlLoginKey = "saml_login"
+
+// isSSOOnlyEnforced 是否仅允许通过 SAML 登录
+func isSSOOnlyEnforced() bool {
+	settings := system_setting.GetSAMLSettings()
+	return settings.Enabled && settings.SSOOnly
+}
+
+// SAMLMetadata 输出 SP 元数据，供 IdP 导入
+func SAMLMetadata(c *gin.Context) {
+	sp, err := oauth.GetSAMLServiceProvider(c.Request.Context(), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	data, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", data)
+}
+
+// SAMLLogin 发起 SP 登录，state 由 /api/oauth/state 生成并作为 RelayState 透传
+func SAMLLogin(c *gin.Context) {
+	if !system_setting.GetSAMLSettings().Enabled {
+		common.ApiErrorI18n(c, i18n.MsgOAuthNotEnabled, providerParams("SAML"))
+		return
+	}
+	session := sessions.Default(c)
+	state := c.Query("state")
+	if state == "" || session.Get("oauth_state") == nil || state != session.Get("oauth_state").(string) {
+		common.ApiErrorI18n(c, i18n.MsgOAuthStateInvalid)
+		return
+	}
+	sp, err := oauth.GetSAMLServiceProvider(c.Request.Context(), true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	ssoURL := sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if ssoURL == "" {
+		common.ApiError(c, errors.New("IdP 元数据中缺少 HTTP-Redirect 单点登录地址"))
+		return
+	}
+	authnRequest, err := sp.MakeAuthenticationRequest(ssoURL, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	redirectURL, err := authnRequest.Redirect(state, sp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := oauth.SaveSAMLRequest(state, authnRequest.ID); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+// SAMLACS 校验 IdP 回传的断言，将映射后的用户暂存为一次性 code，再跳转到前端的 OAuth 回调页完成登录。
+// IdP 以跨站 POST 回调，SameSite=Strict 的会话 Cookie 不会随请求发送，因此 SP 发起的登录通过 RelayState 关联请求。
+func SAMLACS(c *gin.Context) {
+	settings := system_setting.GetSAMLSettings()
+	if !settings.Enabled {
+		common.ApiErrorI18n(c, i18n.MsgOAuthNotEnabled, providerParams("SAML"))
+		return
+	}
+	if err := c.Request.ParseForm(); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	state := c.Request.PostForm.Get("RelayState")
+	var possibleRequestIds []string
+	if state != "" {
+		if requestId, ok := oauth.TakeSAMLRequest(state); ok {
+			possibleRequestIds = []string{requestId}
+		}
+	}
+	if len(possibleRequestIds) == 0 {
+		if !settings.AllowIdPInitiated {
+			common.ApiErrorI18n(c, i18n.MsgOAuthStateInvalid)
+			return
+		}
+		// IdP 发起的登录没有预先生成的 state，在此生成并写入新会话
+		state = common.GetRandomString(12)
+		session := sessions.Default(c)
+		session.Set("oauth_state", state)
+		if err := session.Save(); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgUserSessionSaveFailed)
+			return
+		}
+	}
+
+	sp, err := oauth.GetSAMLServiceProvider(c.Request.Context(), true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	assertion, err := sp.ParseResponse(c.Request, possibleRequestIds)
+	if err != nil {
+		var invalidErr *saml.InvalidResponseError
+		if errors.As(err, &invalidErr) && invalidErr.PrivateErr != nil {
+			err = invalidErr.PrivateErr
+		}
+		logger.LogError(c, fmt.Sprintf("[SAML] failed to parse response: %s", err.Error()))
+		redirectSAMLCallback(c, url.Values{
+			"state":             {state},
+			"error":             {"saml_invalid"},
+			"error_description": {i18n.T(c, i18n.MsgOAuthSAMLInvalid)},
+		})
+		return
+	}
+	oauthUser, err := oauth.SAMLUserFromAssertion(assertion)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("[SAML] failed to map assertion: %s", err.Error()))
+		redirectSAMLCallback(c, url.Values{
+			"state":             {state},
+			"error":             {"saml_invalid"},
+			"error_description": {i18n.T(c, i18n.MsgOAuthSAMLInvalid)},
+		})
+		return
+	}
+	code, err := oauth.SaveSAMLUser(oauthUser)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	redirectSAMLCallback(c, url.Values{"code": {code}, "state": {state}})
+}
+
+func redirectSAMLCallback(c *gin.Context, query url.Values) {
+	c.Redirect(http.StatusFound, "/oauth/saml?"+query.Encode())
+}
@@ -0,0 +1,767 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	scimDefaultPageSize = 100
+	scimMaxPageSize     = 200
+)
+
+// scimFilterRegex 仅支持 `attr eq "value"` 形式的过滤，覆盖主流 IdP 的查重请求
+var scimFilterRegex = regexp.MustCompile(`(?i)^\s*([a-z.]+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// scimMemberPathRegex 匹配 members[value eq "id"] 形式的路径
+var scimMemberPathRegex = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+func scimJSON(c *gin.Context, status int, obj any) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, obj)
+}
+
+func scimError(c *gin.Context, status int, scimType string, detail string) {
+	scimJSON(c, status, dto.ScimError{
+		Schemas:  []string{dto.ScimSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func scimLocation(resource string, id string) string {
+	return fmt.Sprintf("%s/scim/v2/%s/%s", system_setting.ServerAddress, resource, id)
+}
+
+// scimPagination 解析 SCIM 的 startIndex（从 1 开始）和 count
+func scimPagination(c *gin.Context) (startIndex int, count int) {
+	startIndex, _ = strconv.Atoi(c.Query("startIndex"))
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < 0 {
+		count = scimDefaultPageSize
+	}
+	if count > scimMaxPageSize {
+		count = scimMaxPageSize
+	}
+	return startIndex, count
+}
+
+func parseScimFilter(filter string) (attr string, value string, err error) {
+	matches := scimFilterRegex.FindStringSubmatch(filter)
+	if matches == nil {
+		return "", "", errors.New("仅支持 attr eq \"value\" 形式的过滤条件")
+	}
+	return strings.ToLower(matches[1]), strings.ReplaceAll(matches[2], `\"`, `"`), nil
+}
+
+func scimUserName(user *model.User) string {
+	if user.ScimUserName != "" {
+		return user.ScimUserName
+	}
+	return user.Username
+}
+
+func scimUserResource(user *model.User) dto.ScimUser {
+	id := strconv.Itoa(user.Id)
+	active := user.Status == common.UserStatusEnabled
+	resource := dto.ScimUser{
+		Schemas:     []string{dto.ScimSchemaUser},
+		Id:          id,
+		ExternalId:  user.ScimExternalId,
+		UserName:    scimUserName(user),
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta:        &dto.ScimMeta{ResourceType: "User", Location: scimLocation("Users", id)},
+	}
+	if user.DisplayName != "" {
+		resource.Name = &dto.ScimName{Formatted: user.DisplayName}
+	}
+	if user.Email != "" {
+		resource.Emails = []dto.ScimEmail{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.Group != "" {
+		resource.Groups = []dto.ScimMember{{Value: user.Group, Display: user.Group}}
+	}
+	return resource
+}
+
+// truncateRunes 按字符截断，适配用户表的长度校验
+func truncateRunes(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+func scimDisplayName(resource *dto.ScimUser) string {
+	if resource.DisplayName != "" {
+		return resource.DisplayName
+	}
+	if resource.Name != nil {
+		if resource.Name.Formatted != "" {
+			return resource.Name.Formatted
+		}
+		return strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName)
+	}
+	return ""
+}
+
+func scimPrimaryEmail(emails []dto.ScimEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func setScimEmail(user *model.User, email string) {
+	// 超出用户表长度限制的邮箱不保存，避免后续编辑用户时校验失败
+	if len(email) <= 50 {
+		user.Email = email
+	}
+}
+
+// getScimUser 根据路径参数加载用户，失败时已写入 SCIM 错误响应
+func getScimUser(c *gin.Context) *model.User {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "用户不存在")
+		return nil
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			scimError(c, http.StatusNotFound, "", "用户不存在")
+		} else {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+		}
+		return nil
+	}
+	return user
+}
+
+// isScimProtectedUser 超级管理员、管理员及持有自定义管理角色的用户不允许通过 SCIM 修改或删除，
+// 避免 IdP 侧的误操作或账号泄露影响管理账号
+func isScimProtectedUser(c *gin.Context, user *model.User) bool {
+	if user.Role >= common.RoleAdminUser || user.AdminRoleId != 0 {
+		scimError(c, http.StatusForbidden, "mutability", "不能通过 SCIM 修改或删除管理员账号")
+		return true
+	}
+	return false
+}
+
+// applyScimActive 同步 active 状态，停用时同时禁用该用户的全部令牌
+func applyScimActive(user *model.User, active bool) error {
+	if active == (user.Status == common.UserStatusEnabled) {
+		return nil
+	}
+	if active {
+		if err := model.ActivateUser(user.Id); err != nil {
+			return err
+		}
+		user.Status = common.UserStatusEnabled
+		return nil
+	}
+	if user.Role == common.RoleRootUser {
+		return errors.New("不能通过 SCIM 停用超级管理员")
+	}
+	disabled, err := model.DeactivateUser(user.Id)
+	if err != nil {
+		return err
+	}
+	user.Status = common.UserStatusDisabled
+	common.SysLog(fmt.Sprintf("SCIM 停用用户 %s (id: %d)，禁用令牌 %d 个", user.Username, user.Id, disabled))
+	return nil
+}
+
+func ScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, dto.ScimServiceProviderConfig{
+		Schemas: []string{dto.ScimSchemaServiceProviderConfig},
+		Patch:   dto.ScimSupported{Supported: true},
+		Filter:  dto.ScimFilterSupported{Supported: true, MaxResults: scimMaxPageSize},
+		AuthenticationSchemes: []dto.ScimAuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "Bearer Token",
+			Description: "在系统设置中配置的 SCIM 密钥",
+		}},
+	})
+}
+
+func ScimListUsers(c *gin.Context) {
+	var filter model.ScimUserFilter
+	if raw := c.Query("filter"); raw != "" {
+		attr, value, err := parseScimFilter(raw)
+		if err != nil {
+			scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		switch attr {
+		case "username":
+			filter.UserName = value
+		case "externalid":
+			filter.ExternalId = value
+		case "emails", "emails.value":
+			filter.Email = value
+		default:
+			scimError(c, http.StatusBadRequest, "invalidFilter", "不支持按 "+attr+" 过滤")
+			return
+		}
+	}
+	startIndex, count := scimPagination(c)
+	users, total, err := model.GetScimUsers(filter, startIndex-1, count)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	resources := make([]dto.ScimUser, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, scimUserResource(user))
+	}
+	scimJSON(c, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{dto.ScimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func ScimGetUser(c *gin.Context) {
+	user := getScimUser(c)
+	if user == nil {
+		return
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user))
+}
+
+// ScimCreateUser 创建用户。userName 超出用户名长度或已被占用时生成 scim_ 前缀的用户名，原值保存在 ScimUserName
+func ScimCreateUser(c *gin.Context) {
+	var req dto.ScimUser
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "无效的参数")
+		return
+	}
+	req.UserName = strings.TrimSpace(req.UserName)
+	if req.UserName == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName 不能为空")
+		return
+	}
+	_, total, err := model.GetScimUsers(model.ScimUserFilter{UserName: req.UserName}, 0, 1)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if total > 0 {
+		scimError(c, http.StatusConflict, "uniqueness", "userName 已存在")
+		return
+	}
+
+	user := &model.User{
+		Username:       "scim_" + strconv.Itoa(model.GetMaxUserId()+1),
+		ScimUserName:   req.UserName,
+		ScimExternalId: req.ExternalId,
+		DisplayName:    truncateRunes(scimDisplayName(&req), model.UserNameMaxLength),
+		Role:           common.RoleCommonUser,
+		Status:         common.UserStatusEnabled,
+	}
+	if len(req.UserName) <= model.UserNameMaxLength {
+		if exists, err := model.CheckUserExistOrDeleted(req.UserName, ""); err == nil && !exists {
+			user.Username = req.UserName
+		}
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = truncateRunes(req.UserName, model.UserNameMaxLength)
+	}
+	setScimEmail(user, scimPrimaryEmail(req.Emails))
+	if err := user.InsertWithTx(model.DB, 0); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	user.FinalizeOAuthUserCreation(0)
+	common.SetAuditEntity(c, user.Id)
+	common.SetAuditAction(c, constant.AuditActionCreate)
+	if req.Active != nil && !*req.Active {
+		if err := applyScimActive(user, false); err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+	}
+	c.Header("Location", scimLocation("Users", strconv.Itoa(user.Id)))
+	scimJSON(c, http.StatusCreated, scimUserResource(user))
+}
+
+// ScimReplaceUser 以请求内容整体替换可由 SCIM 管理的属性
+func ScimReplaceUser(c *gin.Context) {
+	user := getScimUser(c)
+	if user == nil {
+		return
+	}
+	if isScimProtectedUser(c, user) {
+		return
+	}
+	var req dto.ScimUser
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "无效的参数")
+		return
+	}
+	if userName := strings.TrimSpace(req.UserName); userName != "" {
+		user.ScimUserName = userName
+	}
+	user.ScimExternalId = req.ExternalId
+	if displayName := truncateRunes(scimDisplayName(&req), model.UserNameMaxLength); displayName != "" {
+		user.DisplayName = displayName
+	}
+	setScimEmail(user, scimPrimaryEmail(req.Emails))
+	if err := user.UpdateScimAttributes(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if req.Active != nil {
+		if err := applyScimActive(user, *req.Active); err != nil {
+			scimError(c, http.StatusBadRequest, "mutability", err.Error())
+			return
+		}
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user))
+}
+
+// parseScimBool 兼容部分 IdP 以字符串形式传递布尔值，如 "False"
+func parseScimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := common.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := common.Unmarshal(raw, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
+
+// applyScimUserAttribute 应用单个属性的 PATCH 操作，返回 active 的目标值（如有）
+func applyScimUserAttribute(user *model.User, op string, path string, raw json.RawMessage) (*bool, error) {
+	remove := op == "remove"
+	var str string
+	if !remove && path != "active" && path != "emails" {
+		if err := common.Unmarshal(raw, &str); err != nil {
+			return nil, fmt.Errorf("%s 的值无效", path)
+		}
+		str = strings.TrimSpace(str)
+	}
+	switch {
+	case path == "active":
+		if remove {
+			return nil, nil
+		}
+		active, err := parseScimBool(raw)
+		if err != nil {
+			return nil, errors.New("active 的值无效")
+		}
+		return &active, nil
+	case path == "username":
+		if !remove && str != "" {
+			user.ScimUserName = str
+		}
+	case path == "externalid":
+		user.ScimExternalId = str
+	case path == "displayname", path == "name.formatted":
+		if !remove && str != "" {
+			user.DisplayName = truncateRunes(str, model.UserNameMaxLength)
+		}
+	case path == "emails":
+		if remove {
+			user.Email = ""
+			break
+		}
+		var emails []dto.ScimEmail
+		if err := common.Unmarshal(raw, &emails); err != nil {
+			return nil, errors.New("emails 的值无效")
+		}
+		setScimEmail(user, scimPrimaryEmail(emails))
+	case strings.HasPrefix(path, "emails[") && strings.HasSuffix(path, ".value"):
+		if remove {
+			user.Email = ""
+		} else {
+			setScimEmail(user, str)
+		}
+	}
+	// 其他属性（如 name.givenName）不对应用户字段，按规范忽略
+	return nil, nil
+}
+
+func ScimPatchUser(c *gin.Context) {
+	user := getScimUser(c)
+	if user == nil {
+		return
+	}
+	if isScimProtectedUser(c, user) {
+		return
+	}
+	var req dto.ScimPatchRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "无效的参数")
+		return
+	}
+	var active *bool
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			scimError(c, http.StatusBadRequest, "invalidSyntax", "不支持的操作 "+operation.Op)
+			return
+		}
+		if operation.Path != "" {
+			result, err := applyScimUserAttribute(user, op, strings.ToLower(operation.Path), operation.Value)
+			if err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+			if result != nil {
+				active = result
+			}
+			continue
+		}
+		// 无 path 时 value 为属性到值的映射
+		var attrs map[string]json.RawMessage
+		if err := common.Unmarshal(operation.Value, &attrs); err != nil {
+			scimError(c, http.StatusBadRequest, "invalidValue", "无效的参数")
+			return
+		}
+		for attr, raw := range attrs {
+			result, err := applyScimUserAttribute(user, op, strings.ToLower(attr), raw)
+			if err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+			if result != nil {
+				active = result
+			}
+		}
+	}
+	if err := user.UpdateScimAttributes(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if active != nil {
+		if err := applyScimActive(user, *active); err != nil {
+			scimError(c, http.StatusBadRequest, "mutability", err.Error())
+			return
+		}
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user))
+}
+
+// ScimDeleteUser 停用用户、禁用其令牌后删除用户
+func ScimDeleteUser(c *gin.Context) {
+	user := getScimUser(c)
+	if user == nil {
+		return
+	}
+	if isScimProtectedUser(c, user) {
+		return
+	}
+	if err := applyScimActive(user, false); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if err := user.Delete(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// resolveScimGroup 将 SCIM 组名解析为用户分组，优先使用配置的映射
+func resolveScimGroup(name string) (string, bool) {
+	if mapped, ok := system_setting.GetSCIMSettings().GroupMapping[name]; ok {
+		name = mapped
+	}
+	return name, name != "" && ratio_setting.ContainsGroupRatio(name)
+}
+
+func scimGroupResource(group string, withMembers bool) (dto.ScimGroup, error) {
+	resource := dto.ScimGroup{
+		Schemas:     []string{dto.ScimSchemaGroup},
+		Id:          group,
+		DisplayName: group,
+		Meta:        &dto.ScimMeta{ResourceType: "Group", Location: scimLocation("Groups", group)},
+	}
+	if !withMembers {
+		return resource, nil
+	}
+	users, err := model.GetUsersByGroup(group)
+	if err != nil {
+		return resource, err
+	}
+	for _, user := range users {
+		resource.Members = append(resource.Members, dto.ScimMember{Value: strconv.Itoa(user.Id), Display: scimUserName(user)})
+	}
+	return resource, nil
+}
+
+// scimExcludesMembers 组成员可能很多，IdP 通常通过 excludedAttributes=members 跳过
+func scimExcludesMembers(c *gin.Context) bool {
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attr), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+func scimMemberIds(members []dto.ScimMember) []int {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		if id, err := strconv.Atoi(member.Value); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// replaceScimGroupMembers 整体替换组成员，仅将由 SCIM 管理的原有成员移回默认分组，手动分配的用户不受影响
+func replaceScimGroupMembers(group string, memberIds []int) error {
+	current, err := model.GetUsersByGroup(group)
+	if err != nil {
+		return err
+	}
+	keep := make(map[int]bool, len(memberIds))
+	for _, id := range memberIds {
+		keep[id] = true
+	}
+	var removed []int
+	for _, user := range current {
+		if !keep[user.Id] && user.ScimUserName != "" {
+			removed = append(removed, user.Id)
+		}
+	}
+	if err := model.RemoveUsersFromGroup(removed, group); err != nil {
+		return err
+	}
+	return model.SetUsersGroup(memberIds, group)
+}
+
+func getScimGroup(c *gin.Context) (string, bool) {
+	group := c.Param("id")
+	if !ratio_setting.ContainsGroupRatio(group) {
+		scimError(c, http.StatusNotFound, "", "分组不存在")
+		return "", false
+	}
+	return group, true
+}
+
+func ScimListGroups(c *gin.Context) {
+	var groups []string
+	if raw := c.Query("filter"); raw != "" {
+		attr, value, err := parseScimFilter(raw)
+		if err != nil {
+			scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		if attr != "displayname" && attr != "id" {
+			scimError(c, http.StatusBadRequest, "invalidFilter", "不支持按 "+attr+" 过滤")
+			return
+		}
+		if group, ok := resolveScimGroup(value); ok {
+			groups = append(groups, group)
+		}
+	} else {
+		for group := range ratio_setting.GetGroupRatioCopy() {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+	}
+	startIndex, count := scimPagination(c)
+	total := len(groups)
+	if startIndex-1 < len(groups) {
+		groups = groups[startIndex-1:]
+	} else {
+		groups = nil
+	}
+	if len(groups) > count {
+		groups = groups[:count]
+	}
+	withMembers := !scimExcludesMembers(c)
+	resources := make([]dto.ScimGroup, 0, len(groups))
+	for _, group := range groups {
+		resource, err := scimGroupResource(group, withMembers)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		resources = append(resources, resource)
+	}
+	scimJSON(c, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{dto.ScimSchemaListResponse},
+		TotalResults: int64(total),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func ScimGetGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	resource, err := scimGroupResource(group, !scimExcludesMembers(c))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusOK, resource)
+}
+
+// ScimCreateGroup 分组由倍率设置定义，不能通过 SCIM 新建；组名能解析到已有分组时视为关联该分组
+func ScimCreateGroup(c *gin.Context) {
+	var req dto.ScimGroup
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "无效的参数")
+		return
+	}
+	group, ok := resolveScimGroup(req.DisplayName)
+	if !ok {
+		scimError(c, http.StatusBadRequest, "invalidValue", "分组 "+req.DisplayName+" 未配置，请先在分组倍率中添加或配置 SCIM 组映射")
+		return
+	}
+	if err := model.SetUsersGroup(scimMemberIds(req.Members), group); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	resource, err := scimGroupResource(group, true)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Header("Location", scimLocation("Groups", group))
+	scimJSON(c, http.StatusCreated, resource)
+}
+
+func ScimReplaceGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimGroup
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "无效的参数")
+		return
+	}
+	if err := replaceScimGroupMembers(group, scimMemberIds(req.Members)); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	resource, err := scimGroupResource(group, true)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusOK, resource)
+}
+
+func ScimPatchGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimPatchRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "无效的参数")
+		return
+	}
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.ToLower(operation.Path)
+		var members []dto.ScimMember
+		if matches := scimMemberPathRegex.FindStringSubmatch(operation.Path); matches != nil {
+			members = []dto.ScimMember{{Value: matches[1]}}
+			path = "members"
+		} else if path == "members" && len(operation.Value) > 0 {
+			if err := common.Unmarshal(operation.Value, &members); err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", "members 的值无效")
+				return
+			}
+		} else if path == "" {
+			var value struct {
+				Members *[]dto.ScimMember `json:"members"`
+			}
+			if err := common.Unmarshal(operation.Value, &value); err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", "无效的参数")
+				return
+			}
+			if value.Members == nil {
+				// 仅修改组名等属性，分组名称由倍率设置决定，忽略
+				continue
+			}
+			members = *value.Members
+			path = "members"
+		}
+		if path != "members" {
+			continue
+		}
+		var err error
+		switch op {
+		case "add":
+			err = model.SetUsersGroup(scimMemberIds(members), group)
+		case "remove":
+			if len(members) == 0 {
+				err = replaceScimGroupMembers(group, nil)
+			} else {
+				err = model.RemoveUsersFromGroup(scimMemberIds(members), group)
+			}
+		case "replace":
+			err = replaceScimGroupMembers(group, scimMemberIds(members))
+		default:
+			scimError(c, http.StatusBadRequest, "invalidSyntax", "不支持的操作 "+operation.Op)
+			return
+		}
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+	}
+	resource, err := scimGroupResource(group, !scimExcludesMembers(c))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusOK, resource)
+}
+
+// ScimDeleteGroup 解除 SCIM 组与分组的关联，由 SCIM 管理的成员移回默认分组，分组本身保留
+func ScimDeleteGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	if err := replaceScimGroupMembers(group, nil); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -118,6 +118,7 @@ func PostSetup(c *gin.Context) {
 			DisplayName: "Root User",
 			AccessToken: nil,
 			Quota:       100000000,
+			CreatedTime: common.GetTimestamp(),
 		}
 		err = model.DB.Create(&rootUser).Error
 		if err != nil {
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeStatement 按 format 参数输出账单：csv、pdf 以附件下载，其他返回 JSON
+func writeStatement(c *gin.Context, statement *model.Statement) {
+	filename := fmt.Sprintf("statement-%s-%d-%s", statement.SubjectType, statement.SubjectId, statement.Period)
+	switch c.Query("format") {
+	case "csv":
+		data, err := service.RenderStatementCSV(statement)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+		c.Data(http.StatusOK, "application/pdf", service.RenderStatementPDF(statement))
+	default:
+		common.ApiSuccess(c, statement)
+	}
+}
+
+// GetSelfStatement 下载当前用户的月度账单，period 为空时取上一个月
+func GetSelfStatement(c *gin.Context) {
+	statement, err := service.GetStatement(model.StatementSubjectUser, c.GetInt("id"), c.Query("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	writeStatement(c, statement)
+}
+
+// GetOrganizationStatement 下载组织的月度账单，需要组织的账单管理权限
+func GetOrganizationStatement(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManageBilling() {
+		organizationPermissionDenied(c)
+		return
+	}
+	statement, err := service.GetStatement(model.StatementSubjectOrganization, org.Id, c.Query("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	writeStatement(c, statement)
+}
+
+func parseStatementSubject(subjectType string, subjectId int) bool {
+	return (subjectType == model.StatementSubjectUser || subjectType == model.StatementSubjectOrganization) && subjectId > 0
+}
+
+// GetStatement 管理员下载任意用户或组织的月度账单
+func GetStatement(c *gin.Context) {
+	subjectType := c.DefaultQuery("subject_type", model.StatementSubjectUser)
+	subjectId, _ := strconv.Atoi(c.Query("subject_id"))
+	if !parseStatementSubject(subjectType, subjectId) {
+		common.ApiErrorMsg(c, "无效的参数：subject_type 或 subject_id")
+		return
+	}
+	statement, err := service.GetStatement(subjectType, subjectId, c.Query("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	writeStatement(c, statement)
+}
+
+type StatementRegenerateRequest struct {
+	SubjectType string `json:"subject_type"`
+	SubjectId   int    `json:"subject_id"`
+	Period      string `json:"period"`
+}
+
+// RegenerateStatement 管理员重新生成已结束月份的账单，用于补单或数据修正后更新快照，尚无快照时创建
+func RegenerateStatement(c *gin.Context) {
+	var req StatementRegenerateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil || !parseStatementSubject(req.SubjectType, req.SubjectId) {
+		common.ApiErrorMsg(c, "无效的参数：subject_type 或 subject_id")
+		return
+	}
+	statement, err := service.RegenerateStatement(req.SubjectType, req.SubjectId, req.Period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.SetAuditEntity(c, fmt.Sprintf("%s:%d:%s", statement.SubjectType, statement.SubjectId, statement.Period))
+	common.SetAuditAfter(c, map[string]any{
+		"payment_money":      statement.PaymentMoney,
+		"subscription_money": statement.SubscriptionMoney,
+		"consumed_quota":     statement.ConsumedQuota,
+		"refunded_quota":     statement.RefundedQuota,
+		"request_count":      statement.RequestCount,
+	})
+	common.ApiSuccess(c, statement)
+}
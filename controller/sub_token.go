@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 子令牌接口由拥有 keys:manage 作用域的父令牌调用，见 middleware.SubTokenAuth
+
+func getSubTokenParent(c *gin.Context) (*model.Token, error) {
+	parent, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		return nil, err
+	}
+	if !parent.CanIssueChildTokens() {
+		return nil, errors.New("父令牌无权签发子令牌")
+	}
+	return parent, nil
+}
+
+func validateSubTokenRequest(c *gin.Context, token *model.Token) bool {
+	if len(token.Name) > 50 {
+		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
+		return false
+	}
+	if token.RemainQuota < 0 {
+		common.ApiErrorI18n(c, i18n.MsgTokenQuotaNegative)
+		return false
+	}
+	var err error
+	token.Scopes, err = model.ValidateTokenScopes(token.Scopes)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	return true
+}
+
+func GetSubTokens(c *gin.Context) {
+	parentId := c.GetInt("token_id")
+	pageInfo := common.GetPageQuery(c)
+	tokens, err := model.GetChildTokens(parentId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	total, _ := model.CountChildTokens(parentId)
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tokens)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetSubToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	token, err := model.GetChildTokenById(id, c.GetInt("token_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, token)
+}
+
+func AddSubToken(c *gin.Context) {
+	token := model.Token{}
+	if err := c.ShouldBindJSON(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !validateSubTokenRequest(c, &token) {
+		return
+	}
+	parent, err := getSubTokenParent(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	maxTokens := operation_setting.GetMaxUserTokens()
+	count, err := model.CountUserTokens(parent.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if int(count) >= maxTokens {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("已达到最大令牌数量限制 (%d)", maxTokens),
+		})
+		return
+	}
+
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.SysLog("failed to generate token key: " + err.Error())
+		common.ApiErrorI18n(c, i18n.MsgTokenGenerateFailed)
+		return
+	}
+	cleanToken := model.Token{
+		Name:               token.Name,
+		CreatedTime:        common.GetTimestamp(),
+		AccessedTime:       common.GetTimestamp(),
+		ExpiredTime:        token.ExpiredTime,
+		RemainQuota:        token.RemainQuota,
+		ModelLimitsEnabled: token.ModelLimitsEnabled,
+		ModelLimits:        token.ModelLimits,
+		AllowIps:           token.AllowIps,
+		Scopes:             token.Scopes,
+	}
+	cleanToken.SetKey(key)
+	if err = model.InsertChildToken(parent, &cleanToken); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 明文密钥只在创建时返回这一次
+	common.ApiSuccess(c, cleanToken)
+}
+
+func UpdateSubToken(c *gin.Context) {
+	token := model.Token{}
+	if err := c.ShouldBindJSON(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !validateSubTokenRequest(c, &token) {
+		return
+	}
+	parent, err := getSubTokenParent(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanToken, err := model.GetChildTokenById(token.Id, parent.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.Status == common.TokenStatusEnabled || token.Status == common.TokenStatusDisabled {
+		cleanToken.Status = token.Status
+	}
+	cleanToken.Name = token.Name
+	cleanToken.ExpiredTime = token.ExpiredTime
+	cleanToken.RemainQuota = token.RemainQuota
+	cleanToken.ModelLimitsEnabled = token.ModelLimitsEnabled
+	cleanToken.ModelLimits = token.ModelLimits
+	cleanToken.AllowIps = token.AllowIps
+	cleanToken.Scopes = token.Scopes
+	if cleanToken.Status == common.TokenStatusExhausted && cleanToken.RemainQuota > 0 {
+		cleanToken.Status = common.TokenStatusEnabled
+	}
+	if err = model.UpdateChildToken(parent, cleanToken); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanToken)
+}
+
+func DeleteSubToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	parent, err := getSubTokenParent(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	token, err := model.GetChildTokenById(id, parent.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.DeleteChildToken(parent, token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
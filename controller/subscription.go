@@ -219,6 +219,9 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		common.ApiErrorMsg(c, "自定义重置周期需大于0秒")
 		return
 	}
+	if originPlan, err := model.GetSubscriptionPlanById(id); err == nil {
+		common.SetAuditBefore(c, originPlan)
+	}
 
 	err := model.DB.Transaction(func(tx *gorm.DB) error {
 		// update plan (allow zero values updates with map)
@@ -251,6 +254,9 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		return
 	}
 	model.InvalidateSubscriptionPlanCache(id)
+	if updatedPlan, err := model.GetSubscriptionPlanById(id); err == nil {
+		common.SetAuditAfter(c, updatedPlan)
+	}
 	common.ApiSuccess(c, nil)
 }
 
@@ -269,11 +275,15 @@ func AdminUpdateSubscriptionPlanStatus(c *gin.Context) {
 		common.ApiErrorMsg(c, "参数错误")
 		return
 	}
+	if originPlan, err := model.GetSubscriptionPlanById(id); err == nil {
+		common.SetAuditBefore(c, map[string]any{"enabled": originPlan.Enabled})
+	}
 	if err := model.DB.Model(&model.SubscriptionPlan{}).Where("id = ?", id).Update("enabled", *req.Enabled).Error; err != nil {
 		common.ApiError(c, err)
 		return
 	}
 	model.InvalidateSubscriptionPlanCache(id)
+	common.SetAuditAfter(c, map[string]any{"enabled": *req.Enabled})
 	common.ApiSuccess(c, nil)
 }
 
@@ -132,6 +132,7 @@ func GetTokenUsage(c *gin.Context) {
 			"unlimited_quota":      token.UnlimitedQuota,
 			"model_limits":         token.GetModelLimitsMap(),
 			"model_limits_enabled": token.ModelLimitsEnabled,
+			"scopes":               token.GetScopes(),
 			"expires_at":           expiredAt,
 		},
 	})
@@ -162,6 +163,12 @@ func AddToken(c *gin.Context) {
 		}
 	}
 
+	token.Scopes, err = model.ValidateTokenScopes(token.Scopes)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -179,7 +186,7 @@ func AddToken(c *gin.Context) {
 
 	// 如果前端没有传递token或token为空，则自动生成
 	var key string
-	if token.Key == "" {
+	if token.PlainKey == "" {
 		key, err = common.GenerateKey()
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
@@ -190,13 +197,12 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	} else {
-		key = token.Key
+		key = token.PlainKey
 	}
 
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
 		Name:               token.Name,
-		Key:                key,
 		CreatedTime:        common.GetTimestamp(),
 		AccessedTime:       common.GetTimestamp(),
 		ExpiredTime:        token.ExpiredTime,
@@ -207,15 +213,19 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		Scopes:             token.Scopes,
 	}
+	cleanToken.SetKey(key)
 	err = cleanToken.Insert()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	// 明文密钥只在创建时返回这一次
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data":    cleanToken,
 	})
 	return
 }
@@ -259,6 +269,11 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	token.Scopes, err = model.ValidateTokenScopes(token.Scopes)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -287,9 +302,10 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.Scopes = token.Scopes
 		// 只有当前端传递了非空的key时才更新
-		if token.Key != "" {
-			cleanToken.Key = token.Key
+		if token.PlainKey != "" {
+			cleanToken.SetKey(token.PlainKey)
 		}
 	}
 	err = cleanToken.Update()
@@ -304,6 +320,53 @@ func UpdateToken(c *gin.Context) {
 	})
 }
 
+// maxKeyRotationGracePeriod 令牌轮换时允许指定的最长宽限期（秒）
+const maxKeyRotationGracePeriod = 30 * 24 * 3600
+
+type RotateTokenRequest struct {
+	GracePeriod *int64 `json:"grace_period"` // 旧密钥宽限期（秒），为空时使用系统默认值
+}
+
+func RotateToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	req := RotateTokenRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+			return
+		}
+	}
+	gracePeriod := operation_setting.GetKeyRotationGracePeriod()
+	if req.GracePeriod != nil {
+		gracePeriod = *req.GracePeriod
+	}
+	if gracePeriod < 0 || gracePeriod > maxKeyRotationGracePeriod {
+		common.ApiErrorI18n(c, i18n.MsgTokenGracePeriodInvalid, map[string]any{"Max": maxKeyRotationGracePeriod})
+		return
+	}
+	token, err := model.GetTokenByIds(id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err = token.RotateKey(gracePeriod); err != nil {
+		common.SysLog("failed to rotate token key: " + err.Error())
+		common.ApiErrorI18n(c, i18n.MsgTokenGenerateFailed)
+		return
+	}
+	// 新的明文密钥只在轮换时返回这一次
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    token,
+	})
+}
+
 type TokenBatch struct {
 	Ids []int `json:"ids"`
 }
@@ -280,38 +280,53 @@ func EpayNotify(c *gin.Context) {
 
 	if verifyInfo.TradeStatus == epay.StatusTradeSuccess {
 		log.Println(verifyInfo)
-		LockOrder(verifyInfo.ServiceTradeNo)
-		defer UnlockOrder(verifyInfo.ServiceTradeNo)
-		topUp := model.GetTopUpByTradeNo(verifyInfo.ServiceTradeNo)
-		if topUp == nil {
-			log.Printf("易支付回调未找到订单: %v", verifyInfo)
-			return
-		}
-		if topUp.Status == "pending" {
-			topUp.Status = "success"
-			err := topUp.Update()
-			if err != nil {
-				log.Printf("易支付回调更新订单失败: %v", topUp)
-				return
-			}
-			//user, _ := model.GetUserById(topUp.UserId, false)
-			//user.Quota += topUp.Amount * 500000
-			dAmount := decimal.NewFromInt(int64(topUp.Amount))
-			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
-			err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true)
-			if err != nil {
-				log.Printf("易支付回调更新用户失败: %v", topUp)
-				return
-			}
-			log.Printf("易支付回调更新用户成功 %v", topUp)
-			model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), topUp.Money))
+		// 同一订单的回调可能被多个实例同时收到，按订单号去重，跨实例时通过 Redis 协调
+		executed, err := service.RunIdempotent("webhook:epay:"+verifyInfo.ServiceTradeNo, func() error {
+			return completeEpayTopUp(verifyInfo)
+		})
+		if err != nil {
+			log.Printf("易支付回调处理失败: %v, 订单号: %s", err, verifyInfo.ServiceTradeNo)
+		} else if !executed {
+			log.Printf("易支付回调已处理过，跳过: %s", verifyInfo.ServiceTradeNo)
 		}
 	} else {
 		log.Printf("易支付异常回调: %v", verifyInfo)
 	}
 }
 
+// completeEpayTopUp 将易支付回调对应的待支付订单标记为成功并为用户增加额度
+func completeEpayTopUp(verifyInfo *epay.VerifyRes) error {
+	LockOrder(verifyInfo.ServiceTradeNo)
+	defer UnlockOrder(verifyInfo.ServiceTradeNo)
+	topUp := model.GetTopUpByTradeNo(verifyInfo.ServiceTradeNo)
+	if topUp == nil {
+		log.Printf("易支付回调未找到订单: %v", verifyInfo)
+		return nil
+	}
+	if topUp.Status != "pending" {
+		return nil
+	}
+	topUp.Status = "success"
+	err := topUp.Update()
+	if err != nil {
+		log.Printf("易支付回调更新订单失败: %v", topUp)
+		return err
+	}
+	//user, _ := model.GetUserById(topUp.UserId, false)
+	//user.Quota += topUp.Amount * 500000
+	dAmount := decimal.NewFromInt(int64(topUp.Amount))
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
+	err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true, model.QuotaLedgerRef{Source: model.QuotaSourceTopUp, RefId: topUp.TradeNo})
+	if err != nil {
+		log.Printf("易支付回调更新用户失败: %v", topUp)
+		return err
+	}
+	log.Printf("易支付回调更新用户成功 %v", topUp)
+	model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), topUp.Money))
+	return nil
+}
+
 func RequestAmount(c *gin.Context) {
 	var req AmountRequest
 	err := c.ShouldBindJSON(&req)
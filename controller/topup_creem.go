@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"io"
 	"log"
@@ -275,7 +276,28 @@ func CreemWebhook(c *gin.Context) {
 	// 根据事件类型处理不同的webhook
 	switch webhookEvent.EventType {
 	case "checkout.completed":
-		handleCheckoutCompleted(c, &webhookEvent)
+		// Creem 会重复投递同一事件，按事件 ID 去重，跨实例时通过 Redis 协调
+		executed, err := service.RunIdempotent("webhook:creem:"+webhookEvent.Id, func() error {
+			handleCheckoutCompleted(c, &webhookEvent)
+			if c.Writer.Status() >= http.StatusInternalServerError {
+				return fmt.Errorf("处理失败，状态码: %d", c.Writer.Status())
+			}
+			return nil
+		})
+		if errors.Is(err, service.ErrIdempotencyInFlight) {
+			c.AbortWithStatus(http.StatusConflict)
+			return
+		}
+		if err != nil && !executed {
+			// 幂等存储不可用
+			log.Printf("Creem Webhook幂等处理失败: %v, EventId: %s", err, webhookEvent.Id)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !executed {
+			log.Printf("Creem Webhook事件已处理过，跳过: %s", webhookEvent.Id)
+			c.Status(http.StatusOK)
+		}
 	default:
 		log.Printf("忽略Creem Webhook事件类型: %s", webhookEvent.EventType)
 		c.Status(http.StatusOK)
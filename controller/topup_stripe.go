@@ -12,6 +12,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/system_setting"
@@ -165,13 +166,30 @@ func StripeWebhook(c *gin.Context) {
 		return
 	}
 
-	switch event.Type {
-	case stripe.EventTypeCheckoutSessionCompleted:
-		sessionCompleted(event)
-	case stripe.EventTypeCheckoutSessionExpired:
-		sessionExpired(event)
-	default:
-		log.Printf("不支持的Stripe Webhook事件类型: %s\n", event.Type)
+	// Stripe 会重复投递同一事件，按事件 ID 去重，跨实例时通过 Redis 协调
+	executed, err := service.RunIdempotent("webhook:stripe:"+event.ID, func() error {
+		switch event.Type {
+		case stripe.EventTypeCheckoutSessionCompleted:
+			sessionCompleted(event)
+		case stripe.EventTypeCheckoutSessionExpired:
+			sessionExpired(event)
+		default:
+			log.Printf("不支持的Stripe Webhook事件类型: %s\n", event.Type)
+		}
+		return nil
+	})
+	if errors.Is(err, service.ErrIdempotencyInFlight) {
+		// 同一事件正在其他请求中处理，返回非 2xx 让 Stripe 稍后重试
+		c.AbortWithStatus(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Stripe Webhook幂等处理失败: %v, 事件: %s\n", err, event.ID)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !executed {
+		log.Printf("Stripe Webhook事件已处理过，跳过: %s\n", event.ID)
 	}
 
 	c.Status(http.StatusOK)
@@ -86,6 +86,11 @@ func Login(c *gin.Context) {
 
 // setup session & cookies and then return user info
 func setupLogin(user *model.User, c *gin.Context) {
+	// 仅允许单点登录时保留超级管理员的其他登录方式，避免 IdP 故障时无法进入系统
+	if isSSOOnlyEnforced() && user.Role != common.RoleRootUser && !c.GetBool(samlLoginKey) {
+		common.ApiErrorI18n(c, i18n.MsgUserSSOOnly)
+		return
+	}
 	session := sessions.Default(c)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
@@ -137,6 +142,10 @@ func Register(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserPasswordRegisterDisabled)
 		return
 	}
+	if isSSOOnlyEnforced() {
+		common.ApiErrorI18n(c, i18n.MsgUserSSOOnly)
+		return
+	}
 	var user model.User
 	err := json.NewDecoder(c.Request.Body).Decode(&user)
 	if err != nil {
@@ -202,7 +211,6 @@ func Register(c *gin.Context) {
 		token := model.Token{
 			UserId:             insertedUser.Id, // 使用插入后的用户ID
 			Name:               cleanUser.Username + "的初始令牌",
-			Key:                key,
 			CreatedTime:        common.GetTimestamp(),
 			AccessedTime:       common.GetTimestamp(),
 			ExpiredTime:        -1,     // 永不过期
@@ -210,6 +218,7 @@ func Register(c *gin.Context) {
 			UnlimitedQuota:     true,
 			ModelLimitsEnabled: false,
 		}
+		token.SetKey(key)
 		if setting.DefaultUseAutoGroup {
 			token.Group = "auto"
 		}
@@ -268,8 +277,7 @@ func GetUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if !canManageUser(c, user) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
 		return
 	}
@@ -378,6 +386,11 @@ func GetSelf(c *gin.Context) {
 
 	// 计算用户权限信息
 	permissions := calculateUserPermissions(userRole)
+	adminPermissions, err := model.GetUserAdminPermissions(id, userRole)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 
 	// 获取用户设置并提取sidebar_modules
 	userSetting := user.GetSetting()
@@ -393,6 +406,7 @@ func GetSelf(c *gin.Context) {
 		"github_id":         user.GitHubId,
 		"discord_id":        user.DiscordId,
 		"oidc_id":           user.OidcId,
+		"saml_id":           user.SamlId,
 		"wechat_id":         user.WeChatId,
 		"telegram_id":       user.TelegramId,
 		"group":             user.Group,
@@ -409,6 +423,8 @@ func GetSelf(c *gin.Context) {
 		"stripe_customer":   user.StripeCustomer,
 		"sidebar_modules":   userSetting.SidebarModules, // 正确提取sidebar_modules字段
 		"permissions":       permissions,                // 新增权限字段
+		"admin_role_id":     user.AdminRoleId,
+		"admin_permissions": adminPermissions,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -555,12 +571,12 @@ func UpdateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= originUser.Role && myRole != common.RoleRootUser {
+	if !canManageUser(c, originUser) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
-	if myRole <= updatedUser.Role && myRole != common.RoleRootUser {
+	myRole := c.GetInt("role")
+	if getOperatorManageRank(c) <= updatedUser.Role && myRole != common.RoleRootUser {
 		common.ApiErrorI18n(c, i18n.MsgUserCannotCreateHigherLevel)
 		return
 	}
@@ -572,6 +588,10 @@ func UpdateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	common.SetAuditBefore(c, originUser)
+	if editedUser, err := model.GetUserById(updatedUser.Id, false); err == nil {
+		common.SetAuditAfter(c, editedUser)
+	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
@@ -601,8 +621,7 @@ func AdminClearUserBinding(c *gin.Context) {
 		return
 	}
 
-	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if !canManageUser(c, user) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
 		return
 	}
@@ -763,11 +782,15 @@ func DeleteUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= originUser.Role {
+	if originUser.Role == common.RoleRootUser {
+		common.ApiErrorI18n(c, i18n.MsgUserCannotDeleteRootUser)
+		return
+	}
+	if !canManageUser(c, originUser) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
+	common.SetAuditBefore(c, originUser)
 	err = model.HardDeleteUserById(id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -814,8 +837,7 @@ func CreateUser(c *gin.Context) {
 	if user.DisplayName == "" {
 		user.DisplayName = user.Username
 	}
-	myRole := c.GetInt("role")
-	if user.Role >= myRole {
+	if user.Role >= getOperatorManageRank(c) {
 		common.ApiErrorI18n(c, i18n.MsgUserCannotCreateHigherLevel)
 		return
 	}
@@ -861,11 +883,13 @@ func ManageUser(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserNotExists)
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if !canManageUser(c, &user) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
+	myRole := c.GetInt("role")
+	common.SetAuditAction(c, req.Action)
+	common.SetAuditBefore(c, map[string]any{"role": user.Role, "status": user.Status})
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
@@ -913,6 +937,7 @@ func ManageUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	common.SetAuditAfter(c, map[string]any{"role": user.Role, "status": user.Status})
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
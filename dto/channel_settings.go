@@ -9,6 +9,7 @@ type ChannelSettings struct {
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
 	ExpirationTime         string `json:"expiration_time,omitempty"` // RFC3339 format with timezone, e.g., "2006-01-02T15:04:05Z07:00"
 	StreamSupport          string `json:"stream_support,omitempty"` // 流式支持配置：BOTH-支持流式和非流式（默认），STREAM_ONLY-仅支持流式，NON_STREAM_ONLY-仅支持非流式
+	DrainOnClientCancel    bool   `json:"drain_on_client_cancel,omitempty"` // 客户端断开后继续读取上游流式响应，用于断开后仍按完整生成计费的上游
 }
 
 type VertexKeyType string
@@ -0,0 +1,115 @@
+package dto
+
+import "encoding/json"
+
+const (
+	ScimSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimSchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimSchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ScimSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+type ScimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type ScimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type ScimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// ScimMember 组成员或用户所属组的引用，value 为资源 id
+type ScimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type ScimUser struct {
+	Schemas     []string     `json:"schemas"`
+	Id          string       `json:"id,omitempty"`
+	ExternalId  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *ScimName    `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []ScimEmail  `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"` // 请求中缺省表示不修改
+	Groups      []ScimMember `json:"groups,omitempty"`
+	Meta        *ScimMeta    `json:"meta,omitempty"`
+}
+
+type ScimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	Id          string       `json:"id,omitempty"`
+	ExternalId  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []ScimMember `json:"members,omitempty"`
+	Meta        *ScimMeta    `json:"meta,omitempty"`
+}
+
+type ScimListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+type ScimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations"`
+}
+
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type ScimSupported struct {
+	Supported bool `json:"supported"`
+}
+
+type ScimFilterSupported struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type ScimBulkSupported struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type ScimAuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type ScimServiceProviderConfig struct {
+	Schemas               []string                   `json:"schemas"`
+	Patch                 ScimSupported              `json:"patch"`
+	Bulk                  ScimBulkSupported          `json:"bulk"`
+	Filter                ScimFilterSupported        `json:"filter"`
+	ChangePassword        ScimSupported              `json:"changePassword"`
+	Sort                  ScimSupported              `json:"sort"`
+	Etag                  ScimSupported              `json:"etag"`
+	AuthenticationSchemes []ScimAuthenticationScheme `json:"authenticationSchemes"`
+}
@@ -42,6 +42,8 @@ const (
 	MsgTokenExhausted            = "token.exhausted"
 	MsgTokenStatusUnavailable    = "token.status_unavailable"
 	MsgTokenDbError              = "token.db_error"
+	MsgTokenScopeForbidden       = "token.scope_forbidden"
+	MsgTokenGracePeriodInvalid   = "token.grace_period_invalid"
 )
 
 // Redemption related messages
@@ -63,6 +65,7 @@ const (
 	MsgUserPasswordLoginDisabled     = "user.password_login_disabled"
 	MsgUserRegisterDisabled          = "user.register_disabled"
 	MsgUserPasswordRegisterDisabled  = "user.password_register_disabled"
+	MsgUserSSOOnly                   = "user.sso_only"
 	MsgUserUsernameOrPasswordEmpty   = "user.username_or_password_empty"
 	MsgUserUsernameOrPasswordError   = "user.username_or_password_error"
 	MsgUserEmailOrPasswordEmpty      = "user.email_or_password_empty"
@@ -278,6 +281,7 @@ const (
 	MsgOAuthTokenFailed     = "oauth.token_failed"
 	MsgOAuthUserInfoEmpty   = "oauth.user_info_empty"
 	MsgOAuthTrustLevelLow   = "oauth.trust_level_low"
+	MsgOAuthSAMLInvalid     = "oauth.saml_invalid"
 )
 
 // Model layer error messages (for translation in controller)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -19,6 +20,7 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	"github.com/QuantumNous/new-api/router"
 	"github.com/QuantumNous/new-api/service"
@@ -49,6 +51,16 @@ func main() {
 		return
 	}
 
+	if *common.RotateChannelKeys {
+		rotated, err := model.RotateChannelKeys()
+		if err != nil {
+			common.FatalLog("failed to rotate channel keys: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("rotated %d channel keys", rotated))
+		_ = model.CloseDB()
+		return
+	}
+
 	common.SysLog("New API " + common.Version + " started")
 	if os.Getenv("GIN_MODE") != "debug" {
 		gin.SetMode(gin.ReleaseMode)
@@ -95,6 +107,7 @@ func main() {
 
 	// 数据看板
 	go model.UpdateQuotaData()
+	go model.UpdateUsageRollups()
 
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
@@ -126,6 +139,24 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Audit log retention cleanup
+	service.StartAuditLogCleanupTask()
+
+	// Payload capture retention cleanup
+	service.StartPayloadCaptureCleanupTask()
+
+	// Log archival to local disk or object storage
+	service.StartLogArchiveTask()
+
+	// Quota ledger reconciliation
+	service.StartQuotaLedgerReconcileTask()
+
+	// Expire promotional credit grants
+	service.StartCreditGrantExpireTask()
+
+	// Save monthly statement snapshots after each period closes
+	service.StartStatementSnapshotTask()
+
 	// Wire task polling adaptor factory (breaks service -> relay import cycle)
 	service.GetTaskAdaptorFunc = func(platform constant.TaskPlatform) service.TaskPollingAdaptor {
 		a := relay.GetTaskAdaptor(platform)
@@ -157,6 +188,19 @@ func main() {
 		common.SysLog("pprof enabled")
 	}
 
+	service.InitMetrics()
+	router.StartMetricsServer()
+
+	if constant.TracingEnabled {
+		shutdownTracing, err := tracing.Init(context.Background(), "new-api", common.Version)
+		if err != nil {
+			common.SysError(fmt.Sprintf("start opentelemetry tracing error: %v", err))
+		} else {
+			defer shutdownTracing(context.Background())
+			common.SysLog("opentelemetry tracing enabled")
+		}
+	}
+
 	err = common.StartPyroScope()
 	if err != nil {
 		common.SysError(fmt.Sprintf("start pyroscope error : %v", err))
@@ -176,6 +220,8 @@ func main() {
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
+	server.Use(middleware.Metrics())
+	server.Use(middleware.Tracing())
 	server.Use(middleware.PoweredBy())
 	server.Use(middleware.I18n())
 	middleware.SetUpLogger(server)
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditMaxRequestBody  = 1 << 20
+	auditMaxResponseBody = 64 << 10
+	auditActiveKey       = "audit_active"
+)
+
+// auditResponseWriter 截取响应体的前一部分，用于判断管理操作是否成功
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if remaining := auditMaxResponseBody - w.body.Len(); remaining > 0 {
+		if len(data) > remaining {
+			w.body.Write(data[:remaining])
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// AdminAudit 为管理端的变更接口写入结构化审计记录。
+// 只记录执行成功的非只读请求；控制器可通过 common.SetAuditBefore/SetAuditAfter 提供前后快照以生成字段级差异。
+// 嵌套使用时内层只覆盖实体类型，由最外层负责写入。
+func AdminAudit(entityType string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyAuditEntityType, entityType)
+		if c.GetBool(auditActiveKey) {
+			c.Next()
+			return
+		}
+		c.Set(auditActiveKey, true)
+
+		requestBody := readAuditRequestBody(c)
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if c.IsAborted() || !auditResponseSucceeded(writer.Status(), writer.body.Bytes()) {
+			return
+		}
+		auditLog := &model.AuditLog{
+			UserId:     c.GetInt("id"),
+			Username:   c.GetString("username"),
+			Ip:         c.ClientIP(),
+			EntityType: common.GetContextKeyString(c, constant.ContextKeyAuditEntityType),
+			EntityId:   auditEntityId(c, requestBody),
+			Action:     auditAction(c),
+			Method:     c.Request.Method,
+			Route:      c.FullPath(),
+		}
+		// 有快照时差异已完整描述变更，不再记录请求体，避免配置项等键值形式的请求泄露敏感值
+		before, _ := common.GetContextKeyType[map[string]any](c, constant.ContextKeyAuditBefore)
+		after, _ := common.GetContextKeyType[map[string]any](c, constant.ContextKeyAuditAfter)
+		if before != nil || after != nil {
+			if diff, err := common.Marshal(common.BuildAuditDiff(before, after)); err == nil {
+				auditLog.Diff = string(diff)
+			}
+		} else {
+			auditLog.Request = auditRequestSummary(c, requestBody)
+		}
+		if err := model.RecordAuditLog(auditLog); err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to record audit log: %s", err.Error()))
+		}
+	}
+}
+
+// readAuditRequestBody 读取请求体并原样放回，超过上限时不记录
+func readAuditRequestBody(c *gin.Context) []byte {
+	if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(c.Request.Body, auditMaxRequestBody+1))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf), c.Request.Body))
+	if err != nil || len(buf) > auditMaxRequestBody {
+		return nil
+	}
+	return buf
+}
+
+func auditResponseSucceeded(status int, body []byte) bool {
+	if status >= http.StatusBadRequest {
+		return false
+	}
+	var resp struct {
+		Success *bool `json:"success"`
+	}
+	if err := common.Unmarshal(body, &resp); err != nil || resp.Success == nil {
+		return true
+	}
+	return *resp.Success
+}
+
+func auditEntityId(c *gin.Context, requestBody []byte) string {
+	if entityId := common.GetContextKeyString(c, constant.ContextKeyAuditEntityId); entityId != "" {
+		return entityId
+	}
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	var body map[string]any
+	if len(requestBody) > 0 && common.Unmarshal(requestBody, &body) == nil {
+		if id, ok := body["id"]; ok && id != nil {
+			return fmt.Sprintf("%v", id)
+		}
+	}
+	return ""
+}
+
+func auditAction(c *gin.Context) string {
+	if action := common.GetContextKeyString(c, constant.ContextKeyAuditAction); action != "" {
+		return action
+	}
+	switch c.Request.Method {
+	case http.MethodDelete:
+		return constant.AuditActionDelete
+	case http.MethodPost:
+		if strings.HasSuffix(c.FullPath(), "/") {
+			return constant.AuditActionCreate
+		}
+	}
+	return constant.AuditActionUpdate
+}
+
+// auditRequestSummary 返回脱敏后的请求体，无请求体时记录查询参数
+func auditRequestSummary(c *gin.Context, requestBody []byte) string {
+	var summary any
+	if len(requestBody) > 0 {
+		var body any
+		if err := common.Unmarshal(requestBody, &body); err != nil {
+			return ""
+		}
+		summary = common.RedactAuditValue(body)
+	} else if query := c.Request.URL.Query(); len(query) > 0 {
+		params := make(map[string]any, len(query))
+		for k := range query {
+			params[k] = query.Get(k)
+		}
+		summary = common.RedactAuditMap(params)
+	} else {
+		return ""
+	}
+	data, err := common.Marshal(summary)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -30,7 +33,7 @@ func validUserInfo(username string, role int) bool {
 	return true
 }
 
-func authHelper(c *gin.Context, minRole int) {
+func authHelper(c *gin.Context, minRole int, permissions ...string) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
@@ -125,6 +128,28 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	if len(permissions) > 0 {
+		granted, err := model.GetUserAdminPermissions(id.(int), role.(int))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，读取权限失败",
+			})
+			c.Abort()
+			return
+		}
+		for _, permission := range permissions {
+			if !slices.Contains(granted, permission) {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("无权进行此操作，缺少权限 %s", permission),
+				})
+				c.Abort()
+				return
+			}
+		}
+		common.SetContextKey(c, constant.ContextKeyAdminPermissions, granted)
+	}
 	// 防止不同newapi版本冲突，导致数据不通用
 	c.Header("Auth-Version", "864b7076dbcd0a3c01b5520316720ebf")
 	c.Set("username", username)
@@ -166,6 +191,14 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// RequirePermission 校验登录用户拥有全部指定的管理权限。
+// 超级管理员拥有全部权限，未分配自定义角色的管理员拥有 constant.DefaultAdminPermissions。
+func RequirePermission(permissions ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, common.RoleCommonUser, permissions...)
+	}
+}
+
 func WssAuth(c *gin.Context) {
 
 }
@@ -247,6 +280,8 @@ func TokenAuthReadOnly() func(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		_, endSpan := tracing.StartSpan(c, "TokenAuth")
+		defer endSpan()
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -329,6 +364,14 @@ func TokenAuth() func(c *gin.Context) {
 			logger.LogDebug(c, "Client IP %s passed the token IP restrictions check", clientIp)
 		}
 
+		if token.Scopes != "" {
+			scope := getRequestTokenScope(c.Request.Method, c.Request.URL.Path)
+			if scope == "" || !token.HasScope(scope) {
+				abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgTokenScopeForbidden, map[string]any{"Scope": scope}), types.ErrorCodeAccessDenied)
+				return
+			}
+		}
+
 		userCache, err := model.GetUserCache(token.UserId)
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusInternalServerError, err.Error())
@@ -365,6 +408,85 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		endSpan()
+		c.Next()
+	}
+}
+
+// SubTokenAuth 子令牌管理接口的令牌认证，要求令牌显式拥有 keys:manage 作用域。
+// 父令牌额度划完后仍需能查询和吊销子令牌，因此不检查剩余额度。
+func SubTokenAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := c.Request.Header.Get("Authorization")
+		if strings.HasPrefix(key, "Bearer ") || strings.HasPrefix(key, "bearer ") {
+			key = strings.TrimSpace(key[7:])
+		}
+		key = strings.TrimPrefix(key, "sk-")
+		if key == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "未提供令牌",
+			})
+			c.Abort()
+			return
+		}
+		token, err := model.GetTokenByKey(key, false)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "无效的令牌",
+			})
+			c.Abort()
+			return
+		}
+		expired := token.ExpiredTime != -1 && token.ExpiredTime < common.GetTimestamp()
+		if (token.Status != common.TokenStatusEnabled && token.Status != common.TokenStatusExhausted) || expired {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "该令牌状态不可用",
+			})
+			c.Abort()
+			return
+		}
+		if !token.CanIssueChildTokens() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": i18n.T(c, i18n.MsgTokenScopeForbidden, map[string]any{"Scope": constant.TokenScopeKeysManage}),
+			})
+			c.Abort()
+			return
+		}
+		allowIps := token.GetIpLimits()
+		if len(allowIps) > 0 {
+			ip := net.ParseIP(c.ClientIP())
+			if ip == nil || !common.IsIpInCIDRList(ip, allowIps) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"success": false,
+					"message": "您的 IP 不在令牌允许访问的列表中",
+				})
+				c.Abort()
+				return
+			}
+		}
+		userCache, err := model.GetUserCache(token.UserId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if userCache.Status != common.UserStatusEnabled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "用户已被封禁",
+			})
+			c.Abort()
+			return
+		}
+		c.Set("id", token.UserId)
+		c.Set("token_id", token.Id)
 		c.Next()
 	}
 }
@@ -377,6 +499,7 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	c.Set("token_id", token.Id)
 	c.Set("token_key", token.Key)
 	c.Set("token_name", token.Name)
+	c.Set("token_parent_id", token.ParentTokenId)
 	c.Set("token_unlimited_quota", token.UnlimitedQuota)
 	if !token.UnlimitedQuota {
 		c.Set("token_quota", token.RemainQuota)
@@ -389,6 +512,7 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenOrganizationId, token.OrganizationId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotencyWriter 在写给客户端的同时记录响应，超过 limit 后不再记录
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(data) > w.limit {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Idempotency 支持 Idempotency-Key 请求头：同一令牌在时间窗口内重复提交相同的 key 时，
+// 等待处理中的请求或直接重放已成功的响应，不会再次转发和扣费。需放在 TokenAuth 之后
+func Idempotency() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		setting := operation_setting.GetIdempotencySetting()
+		if key == "" || c.Request.Method != http.MethodPost || !setting.Enabled {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortWithOpenAiMessage(c, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key 长度不能超过 %d", maxIdempotencyKeyLength), types.ErrorCodeInvalidRequest)
+			return
+		}
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusBadRequest, err.Error(), types.ErrorCodeReadRequestBodyFailed)
+			return
+		}
+
+		scopedKey := fmt.Sprintf("relay:%d:%s", c.GetInt("token_id"), key)
+		wait := time.Duration(setting.WaitSeconds) * time.Second
+		status, record, err := service.BeginIdempotency(c.Request.Context(), scopedKey, fingerprint, wait)
+		if err != nil {
+			// 存储不可用时不阻塞请求，退化为不幂等
+			logger.LogError(c, "idempotency store error: "+err.Error())
+			c.Next()
+			return
+		}
+		switch status {
+		case service.IdempotencyMismatch:
+			abortWithOpenAiMessage(c, http.StatusUnprocessableEntity, "Idempotency-Key 已用于内容不同的请求", types.ErrorCodeIdempotencyKeyReused)
+			return
+		case service.IdempotencyInFlight:
+			abortWithOpenAiMessage(c, http.StatusConflict, "相同 Idempotency-Key 的请求正在处理中，请稍后重试", types.ErrorCodeIdempotencyInFlight)
+			return
+		case service.IdempotencyReplay:
+			if !record.Replayable {
+				abortWithOpenAiMessage(c, http.StatusConflict, "相同 Idempotency-Key 的请求已处理完成，但未保存响应无法重放", types.ErrorCodeIdempotencyKeyReused)
+				return
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		owner := record.Owner
+		// 未启用 Redis 时不保存响应体，也就不需要记录
+		limit := 0
+		if service.IdempotencyStoresResponse() {
+			limit = setting.MaxResponseBytes
+		}
+		writer := &idempotencyWriter{ResponseWriter: c.Writer, limit: limit, overflow: limit <= 0}
+		c.Writer = writer
+		finished := false
+		defer func() {
+			if !finished {
+				// 处理过程中 panic，释放 key 允许客户端重试
+				_ = service.ReleaseIdempotency(scopedKey, owner)
+			}
+		}()
+		c.Next()
+		finished = true
+		c.Writer = writer.ResponseWriter
+
+		// 只保存成功的响应；失败的请求已退还预扣费，释放 key 允许客户端重试
+		statusCode := writer.Status()
+		if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+			if err := service.ReleaseIdempotency(scopedKey, owner); err != nil {
+				logger.LogError(c, "failed to release idempotency key: "+err.Error())
+			}
+			return
+		}
+		record = &service.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			StatusCode:  statusCode,
+			ContentType: writer.Header().Get("Content-Type"),
+			Replayable:  !writer.overflow,
+		}
+		if record.Replayable {
+			record.Body = writer.body.Bytes()
+		}
+		if err := service.CompleteIdempotency(scopedKey, owner, record); err != nil {
+			logger.LogError(c, "failed to save idempotent response: "+err.Error())
+		}
+	}
+}
+
+// requestFingerprint 以请求方法、URI 与请求体计算指纹，用于识别相同 key 被用于不同的请求
+func requestFingerprint(c *gin.Context) (string, error) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return "", err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录请求数与耗时，以路由模板作为标签避免路径参数导致基数膨胀
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !metrics.Enabled() {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsAuth 配置了 METRICS_TOKEN 时要求抓取方携带对应的 Bearer 令牌
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if constant.MetricsToken == "" {
+			c.Next()
+			return
+		}
+		token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(constant.MetricsToken)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// payloadCaptureWriter 在写给客户端的同时记录最终响应
+type payloadCaptureWriter struct {
+	gin.ResponseWriter
+	session *service.PayloadCaptureSession
+}
+
+func (w *payloadCaptureWriter) Write(data []byte) (int, error) {
+	w.session.AppendClientResponse(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *payloadCaptureWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// PayloadCapture 对开启抓包的用户或令牌记录入站请求、上游请求、上游响应与最终响应，需放在 TokenAuth 之后
+func PayloadCapture() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !operation_setting.ShouldCapturePayload(c.GetInt("id"), common.GetContextKeyInt(c, constant.ContextKeyTokenId)) {
+			c.Next()
+			return
+		}
+		session := service.NewPayloadCaptureSession()
+		common.SetContextKey(c, constant.ContextKeyPayloadCapture, session)
+		writer := &payloadCaptureWriter{ResponseWriter: c.Writer, session: session}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		var inboundRequest []byte
+		if storage, err := common.GetBodyStorage(c); err == nil {
+			inboundRequest, _ = io.ReadAll(storage)
+		}
+		service.SavePayloadCapture(c, session, inboundRequest, writer.Status())
+	}
+}
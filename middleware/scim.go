@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+)
+
+// ScimAuth 校验 SCIM 接口的 Bearer 密钥，该密钥独立于用户令牌与系统访问令牌
+func ScimAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		settings := system_setting.GetSCIMSettings()
+		if !settings.Enabled || settings.Secret == "" {
+			abortWithScimError(c, http.StatusForbidden, "SCIM 未启用")
+			return
+		}
+		auth := c.Request.Header.Get("Authorization")
+		secret := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(auth, "Bearer "), "bearer "))
+		if secret == "" || secret == auth || subtle.ConstantTimeCompare([]byte(secret), []byte(settings.Secret)) != 1 {
+			abortWithScimError(c, http.StatusUnauthorized, "SCIM 密钥无效")
+			return
+		}
+		// 审计日志中以 scim 标识操作者
+		c.Set("username", "scim")
+		c.Next()
+	}
+}
+
+func abortWithScimError(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", "application/scim+json")
+	c.AbortWithStatusJSON(status, dto.ScimError{
+		Schemas: []string{dto.ScimSchemaError},
+		Status:  strconv.Itoa(status),
+		Detail:  detail,
+	})
+}
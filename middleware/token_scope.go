@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/constant"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+)
+
+// getRequestTokenScope 根据请求路径推断访问所需的令牌作用域，无法识别时返回空字符串
+func getRequestTokenScope(method string, path string) constant.TokenScope {
+	switch {
+	case method == http.MethodGet && (path == "/v1/models" || strings.HasPrefix(path, "/v1/models/") ||
+		strings.HasPrefix(path, "/v1beta/models") || strings.HasPrefix(path, "/v1beta/openai/models")):
+		return constant.TokenScopeModelsRead
+	case strings.Contains(path, "/mj/") || strings.HasPrefix(path, "/suno/") ||
+		strings.HasPrefix(path, "/v1/video") || strings.HasPrefix(path, "/kling/") || strings.HasPrefix(path, "/jimeng"):
+		return constant.TokenScopeTasks
+	}
+
+	switch relayconstant.Path2RelayMode(path) {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions,
+		relayconstant.RelayModeResponses, relayconstant.RelayModeResponsesCompact,
+		relayconstant.RelayModeModerations:
+		return constant.TokenScopeChat
+	case relayconstant.RelayModeEmbeddings:
+		return constant.TokenScopeEmbeddings
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeEdits:
+		return constant.TokenScopeImages
+	case relayconstant.RelayModeAudioSpeech, relayconstant.RelayModeAudioTranscription, relayconstant.RelayModeAudioTranslation:
+		return constant.TokenScopeAudio
+	case relayconstant.RelayModeRerank:
+		return constant.TokenScopeRerank
+	case relayconstant.RelayModeRealtime:
+		return constant.TokenScopeRealtime
+	case relayconstant.RelayModeGemini:
+		if strings.Contains(path, "embedContent") || strings.Contains(path, "batchEmbedContents") {
+			return constant.TokenScopeEmbeddings
+		}
+		return constant.TokenScopeChat
+	}
+	if strings.HasPrefix(path, "/v1/messages") {
+		return constant.TokenScopeChat
+	}
+	return ""
+}
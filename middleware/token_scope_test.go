@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRequestTokenScope(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		want   constant.TokenScope
+	}{
+		{http.MethodPost, "/v1/chat/completions", constant.TokenScopeChat},
+		{http.MethodPost, "/v1/messages", constant.TokenScopeChat},
+		{http.MethodPost, "/v1/responses/compact", constant.TokenScopeChat},
+		{http.MethodPost, "/v1/embeddings", constant.TokenScopeEmbeddings},
+		{http.MethodPost, "/v1/engines/text-embedding-3-small/embeddings", constant.TokenScopeEmbeddings},
+		{http.MethodPost, "/v1beta/models/gemini-embedding-001:embedContent", constant.TokenScopeEmbeddings},
+		{http.MethodPost, "/v1beta/models/gemini-2.5-pro:streamGenerateContent", constant.TokenScopeChat},
+		{http.MethodPost, "/v1/images/generations", constant.TokenScopeImages},
+		{http.MethodPost, "/v1/audio/speech", constant.TokenScopeAudio},
+		{http.MethodPost, "/v1/rerank", constant.TokenScopeRerank},
+		{http.MethodGet, "/v1/realtime", constant.TokenScopeRealtime},
+		{http.MethodGet, "/v1/models", constant.TokenScopeModelsRead},
+		{http.MethodGet, "/v1beta/models", constant.TokenScopeModelsRead},
+		{http.MethodPost, "/v1/videos", constant.TokenScopeTasks},
+		{http.MethodGet, "/v1/video/generations/task_123", constant.TokenScopeTasks},
+		{http.MethodPost, "/mj/submit/imagine", constant.TokenScopeTasks},
+		{http.MethodPost, "/fast/mj/submit/imagine", constant.TokenScopeTasks},
+		{http.MethodPost, "/suno/submit/music", constant.TokenScopeTasks},
+		{http.MethodPost, "/kling/v1/videos/text2video", constant.TokenScopeTasks},
+		{http.MethodGet, "/v1/files", ""},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, getRequestTokenScope(tc.method, tc.path), "%s %s", tc.method, tc.path)
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/tracing"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Tracing 为每个请求创建根 span，并沿用调用方传入的 traceparent
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		span, end := tracing.StartServerSpan(c, c.Request.Method+" "+route,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("request.id", c.GetString(common.RequestIdKey)),
+		)
+		defer end()
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", status),
+			attribute.Int("user.id", c.GetInt("id")),
+			attribute.String("relay.model", common.GetContextKeyString(c, constant.ContextKeyOriginalModel)),
+			attribute.String("relay.group", common.GetContextKeyString(c, constant.ContextKeyUsingGroup)),
+			attribute.Int("channel.id", c.GetInt("channel_id")),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"gorm.io/gorm"
+)
+
+// AdminRole 自定义管理角色，由若干管理权限组合而成并分配给用户。
+// 超级管理员始终拥有全部权限；未分配角色的管理员使用 constant.DefaultAdminPermissions。
+type AdminRole struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description string `json:"description" gorm:"type:varchar(255);default:''"`
+	Permissions string `json:"permissions" gorm:"type:text"` // 逗号分隔
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+	UserCount   int64  `json:"user_count" gorm:"-:all"`
+}
+
+func (role *AdminRole) GetPermissions() []string {
+	permissions := make([]string, 0)
+	for _, permission := range strings.Split(role.Permissions, ",") {
+		permission = strings.TrimSpace(permission)
+		if permission != "" {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+// ValidateAdminPermissions 校验并规范化权限字符串，返回去重后的逗号分隔结果
+func ValidateAdminPermissions(permissions []string) (string, error) {
+	normalized := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if permission == "" {
+			continue
+		}
+		if !constant.IsValidAdminPermission(permission) {
+			return "", fmt.Errorf("无效的管理权限: %s", permission)
+		}
+		if !slices.Contains(normalized, permission) {
+			normalized = append(normalized, permission)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func GetAllAdminRoles() ([]*AdminRole, error) {
+	var roles []*AdminRole
+	if err := DB.Order("id asc").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	type roleCount struct {
+		AdminRoleId int
+		Count       int64
+	}
+	var counts []roleCount
+	if err := DB.Model(&User{}).Select("admin_role_id, count(*) as count").
+		Where("admin_role_id > 0").Group("admin_role_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countMap := make(map[int]int64, len(counts))
+	for _, item := range counts {
+		countMap[item.AdminRoleId] = item.Count
+	}
+	for _, role := range roles {
+		role.UserCount = countMap[role.Id]
+	}
+	return roles, nil
+}
+
+func GetAdminRoleById(id int) (*AdminRole, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var role AdminRole
+	err := DB.First(&role, "id = ?", id).Error
+	return &role, err
+}
+
+func IsAdminRoleNameDuplicated(id int, name string) (bool, error) {
+	var count int64
+	err := DB.Model(&AdminRole{}).Where("name = ? AND id <> ?", name, id).Count(&count).Error
+	return count > 0, err
+}
+
+func (role *AdminRole) Insert() error {
+	role.CreatedTime = common.GetTimestamp()
+	role.UpdatedTime = role.CreatedTime
+	return DB.Create(role).Error
+}
+
+func (role *AdminRole) Update() error {
+	role.UpdatedTime = common.GetTimestamp()
+	if err := DB.Model(role).Select("name", "description", "permissions", "updated_time").Updates(role).Error; err != nil {
+		return err
+	}
+	invalidateAdminRolePermissionsCache(role.Id)
+	return nil
+}
+
+// DeleteAdminRole 删除角色，并将持有该角色的用户恢复为按系统角色授权
+func DeleteAdminRole(id int) error {
+	var userIds []int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("admin_role_id = ?", id).Pluck("id", &userIds).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("admin_role_id = ?", id).Update("admin_role_id", 0).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&AdminRole{}, "id = ?", id).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err := invalidateUserCache(userId); err != nil {
+			common.SysLog("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	invalidateAdminRolePermissionsCache(id)
+	return nil
+}
+
+// AssignAdminRole 为用户分配自定义角色，roleId 为 0 表示取消分配
+func AssignAdminRole(userId int, roleId int) error {
+	if roleId != 0 {
+		if _, err := GetAdminRoleById(roleId); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("角色不存在")
+			}
+			return err
+		}
+	}
+	result := DB.Model(&User{}).Where("id = ?", userId).Update("admin_role_id", roleId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysLog("failed to invalidate user cache: " + err.Error())
+	}
+	return nil
+}
+
+func getAdminRolePermissionsCacheKey(roleId int) string {
+	return fmt.Sprintf("admin_role_permissions:%d", roleId)
+}
+
+func invalidateAdminRolePermissionsCache(roleId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDel(getAdminRolePermissionsCacheKey(roleId)); err != nil {
+		common.SysLog("failed to invalidate admin role cache: " + err.Error())
+	}
+}
+
+// getAdminRolePermissions 读取角色的权限字符串，启用 Redis 时随用户缓存一同缓存，
+// 角色被修改或删除时清除。角色不存在时返回空字符串
+func getAdminRolePermissions(roleId int) (string, error) {
+	if common.RedisEnabled {
+		if permissions, err := common.RedisGet(getAdminRolePermissionsCacheKey(roleId)); err == nil {
+			return permissions, nil
+		}
+	}
+	var role AdminRole
+	err := DB.Select("permissions").First(&role, "id = ?", roleId).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if common.RedisEnabled {
+		if err := common.RedisSet(getAdminRolePermissionsCacheKey(roleId), role.Permissions,
+			time.Duration(common.RedisKeyCacheSeconds())*time.Second); err != nil {
+			common.SysLog("failed to update admin role cache: " + err.Error())
+		}
+	}
+	return role.Permissions, nil
+}
+
+// GetUserAdminPermissions 计算用户实际拥有的管理权限。
+// 用户的角色分配来自用户缓存，角色权限单独缓存，分配或修改角色时清除对应缓存以立即生效
+func GetUserAdminPermissions(userId int, userRole int) ([]string, error) {
+	if userRole >= common.RoleRootUser {
+		return constant.AllAdminPermissions, nil
+	}
+	userCache, err := GetUserCache(userId)
+	if err != nil {
+		return nil, err
+	}
+	if userCache.AdminRoleId == 0 {
+		if userRole >= common.RoleAdminUser {
+			return constant.DefaultAdminPermissions, nil
+		}
+		return []string{}, nil
+	}
+	permissions, err := getAdminRolePermissions(userCache.AdminRoleId)
+	if err != nil {
+		return nil, err
+	}
+	role := AdminRole{Permissions: permissions}
+	return role.GetPermissions(), nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAdminPermissions(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM admin_roles")
+	})
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "root", AffCode: "aff1", Role: common.RoleRootUser}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "admin", AffCode: "aff2", Role: common.RoleAdminUser}).Error)
+	require.NoError(t, DB.Create(&User{Id: 3, Username: "support", AffCode: "aff3", Role: common.RoleCommonUser}).Error)
+
+	permissions, err := GetUserAdminPermissions(1, common.RoleRootUser)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, constant.AllAdminPermissions, permissions)
+
+	permissions, err = GetUserAdminPermissions(2, common.RoleAdminUser)
+	require.NoError(t, err)
+	assert.Contains(t, permissions, constant.AdminPermissionChannelsWrite)
+	assert.NotContains(t, permissions, constant.AdminPermissionOptionsWrite, "默认管理员权限与原先 AdminAuth 一致")
+
+	permissions, err = GetUserAdminPermissions(3, common.RoleCommonUser)
+	require.NoError(t, err)
+	assert.Empty(t, permissions)
+
+	_, err = ValidateAdminPermissions([]string{"channels:delete_all"})
+	assert.Error(t, err)
+	normalized, err := ValidateAdminPermissions([]string{constant.AdminPermissionLogsRead, " logs:read_content ", constant.AdminPermissionLogsRead})
+	require.NoError(t, err)
+	assert.Equal(t, "logs:read,logs:read_content", normalized)
+
+	role := &AdminRole{Name: "support", Permissions: normalized}
+	require.NoError(t, role.Insert())
+	require.NoError(t, AssignAdminRole(3, role.Id))
+	require.NoError(t, AssignAdminRole(2, role.Id))
+	assert.Error(t, AssignAdminRole(3, role.Id+100), "角色不存在时不能分配")
+
+	for _, userId := range []int{2, 3} {
+		user, err := GetUserById(userId, false)
+		require.NoError(t, err)
+		permissions, err = GetUserAdminPermissions(userId, user.Role)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{constant.AdminPermissionLogsRead, constant.AdminPermissionLogsReadContent}, permissions)
+	}
+
+	roles, err := GetAllAdminRoles()
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	assert.EqualValues(t, 2, roles[0].UserCount)
+
+	require.NoError(t, DeleteAdminRole(role.Id))
+	permissions, err = GetUserAdminPermissions(2, common.RoleAdminUser)
+	require.NoError(t, err)
+	assert.Contains(t, permissions, constant.AdminPermissionChannelsWrite, "删除角色后恢复默认管理员权限")
+	permissions, err = GetUserAdminPermissions(3, common.RoleCommonUser)
+	require.NoError(t, err)
+	assert.Empty(t, permissions)
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"context"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// AuditLog 管理端变更的结构化审计记录。
+// Diff 保存脱敏后的字段级前后差异，Request 保存脱敏后的请求体，便于追溯未提供快照的接口。
+type AuditLog struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Username   string `json:"username" gorm:"type:varchar(64);default:''"`
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	EntityType string `json:"entity_type" gorm:"type:varchar(32);index:idx_audit_entity,priority:1"`
+	EntityId   string `json:"entity_id" gorm:"type:varchar(128);index:idx_audit_entity,priority:2"`
+	Action     string `json:"action" gorm:"type:varchar(32);index"`
+	Method     string `json:"method" gorm:"type:varchar(8)"`
+	Route      string `json:"route" gorm:"type:varchar(255)"`
+	Diff       string `json:"diff" gorm:"type:text"`
+	Request    string `json:"request" gorm:"type:text"`
+}
+
+type AuditLogQuery struct {
+	UserId         int
+	Username       string
+	EntityType     string
+	EntityId       string
+	Action         string
+	Keyword        string // 在差异内容中模糊匹配，例如模型名称
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func RecordAuditLog(log *AuditLog) error {
+	if log.CreatedAt == 0 {
+		log.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(log).Error
+}
+
+func GetAuditLogs(query AuditLogQuery, startIdx int, num int) (logs []*AuditLog, total int64, err error) {
+	tx := DB.Model(&AuditLog{})
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.Username != "" {
+		tx = tx.Where("username = ?", query.Username)
+	}
+	if query.EntityType != "" {
+		tx = tx.Where("entity_type = ?", query.EntityType)
+	}
+	if query.EntityId != "" {
+		tx = tx.Where("entity_id = ?", query.EntityId)
+	}
+	if query.Action != "" {
+		tx = tx.Where("action = ?", query.Action)
+	}
+	if query.Keyword != "" {
+		tx = tx.Where("diff LIKE ?", "%"+query.Keyword+"%")
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, total, err
+}
+
+func DeleteOldAuditLogs(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+
+		result := DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&AuditLog{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+
+	return total, nil
+}
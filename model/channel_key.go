@@ -0,0 +1,188 @@
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	channelKeyHashBatchSize = 500
+	// channelKeyHashSecretOptionKey 未配置 CHANNEL_KEY_HASH_SECRET 时，自动生成的渠道密钥摘要密钥在 options 表中的键名
+	channelKeyHashSecretOptionKey = "ChannelKeyHashSecret"
+	// channelKeyHashFingerprintOptionKey 记录计算现有摘要所用密钥的指纹，密钥变更后据此重新计算全部摘要
+	channelKeyHashFingerprintOptionKey = "ChannelKeyHashFingerprint"
+)
+
+func init() {
+	schema.RegisterSerializer("channel_key", ChannelKeySerializer{})
+}
+
+// ChannelKeySerializer 渠道密钥的 gorm 序列化器：配置主密钥时写入前进行信封加密，读取时透明解密，
+// 因此 Channel.Key 在内存（包括渠道缓存、GetKeys、GetNextEnabledKey）中始终为明文。
+// 注意：通过 map 或 Update(column, value) 更新时不会经过序列化器，请使用 UpdateChannelKey。
+type ChannelKeySerializer struct{}
+
+func (ChannelKeySerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("failed to scan channel key: %#v", dbValue)
+	}
+	plaintext, err := common.EnvelopeDecrypt(value)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt channel key: %w", err)
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (ChannelKeySerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, _ := fieldValue.(string)
+	if value == "" || !common.EnvelopeEncryptionEnabled() || common.IsEnvelopeEncrypted(value) {
+		return value, nil
+	}
+	return common.EnvelopeEncrypt(value)
+}
+
+// HashChannelKey 计算渠道密钥的确定性摘要（43 位 base64）。密钥列加密后无法直接比较，按密钥搜索渠道时比较该摘要。
+// 使用独立于令牌摘要的密钥，轮换任一密钥不会影响另一方
+func HashChannelKey(key string) string {
+	h := hmac.New(sha256.New, []byte(common.ChannelKeyHashSecret))
+	h.Write([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// initChannelKeyHashSecret 确保渠道密钥摘要密钥可用：优先使用 CHANNEL_KEY_HASH_SECRET 环境变量，
+// 否则从 options 表加载，主节点在首次启动时生成并持久化
+func initChannelKeyHashSecret() error {
+	return initHashSecret(&common.ChannelKeyHashSecret, channelKeyHashSecretOptionKey, "CHANNEL_KEY_HASH_SECRET")
+}
+
+// channelKeyHashFingerprint 返回当前摘要密钥的指纹，用于判断已存储的摘要是否由当前密钥计算
+func channelKeyHashFingerprint() string {
+	return HashChannelKey("fingerprint")[:16]
+}
+
+// syncChannelKeyHashes 摘要密钥与上次计算时不同（包括首次启用独立密钥的升级）时清空全部摘要，
+// 再为缺少摘要的渠道补充计算
+func syncChannelKeyHashes() error {
+	fingerprint := channelKeyHashFingerprint()
+	var option Option
+	err := DB.Where(&Option{Key: channelKeyHashFingerprintOptionKey}).First(&option).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if option.Value == fingerprint {
+		return migrateChannelKeyHashes()
+	}
+	if err := DB.Model(&Channel{}).Where("key_hash <> ?", "").UpdateColumn("key_hash", "").Error; err != nil {
+		return err
+	}
+	if err := migrateChannelKeyHashes(); err != nil {
+		return err
+	}
+	return DB.Save(&Option{Key: channelKeyHashFingerprintOptionKey, Value: fingerprint}).Error
+}
+
+// BeforeSave 写入密钥时同步更新密钥摘要；未携带密钥的更新（如 Omit("key") 或只更新其他列）不受影响
+func (channel *Channel) BeforeSave(tx *gorm.DB) error {
+	if channel.Key != "" {
+		tx.Statement.SetColumn("KeyHash", HashChannelKey(channel.Key))
+	}
+	return nil
+}
+
+// UpdateChannelKey 仅更新渠道密钥，通过结构体更新以确保经过加密序列化
+func UpdateChannelKey(channelId int, key string) error {
+	return DB.Model(&Channel{}).Where("id = ?", channelId).Select("key", "key_hash").
+		Updates(&Channel{Key: key, KeyHash: HashChannelKey(key)}).Error
+}
+
+// migrateChannelKeyHashes 为升级前创建的渠道补充密钥摘要
+func migrateChannelKeyHashes() error {
+	migrated := 0
+	lastId := 0
+	for {
+		var channels []Channel
+		err := DB.Select("id", "key").Where("id > ? AND key_hash = ?", lastId, "").
+			Order("id").Limit(channelKeyHashBatchSize).Find(&channels).Error
+		if err != nil {
+			return err
+		}
+		if len(channels) == 0 {
+			break
+		}
+		for _, channel := range channels {
+			lastId = channel.Id
+			if channel.Key == "" {
+				continue
+			}
+			err = DB.Model(&Channel{}).Where("id = ?", channel.Id).
+				UpdateColumn("key_hash", HashChannelKey(channel.Key)).Error
+			if err != nil {
+				return fmt.Errorf("failed to migrate key hash of channel %d: %w", channel.Id, err)
+			}
+			migrated++
+		}
+	}
+	if migrated > 0 {
+		common.SysLog(fmt.Sprintf("computed key hashes for %d channels", migrated))
+	}
+	return nil
+}
+
+// RotateChannelKeys 使用当前主密钥重新加密所有渠道的数据密钥，未加密的渠道密钥会被加密，
+// 旧主密钥需通过 CHANNEL_KEY_OLD_MASTER_KEYS 提供。返回处理的渠道数量。
+func RotateChannelKeys() (int, error) {
+	if !common.EnvelopeEncryptionEnabled() {
+		return 0, fmt.Errorf("channel key master key is not configured")
+	}
+	type channelKeyRow struct {
+		Id  int
+		Key string
+	}
+	rotated := 0
+	lastId := 0
+	for {
+		var rows []channelKeyRow
+		// 直接读取原始列值，绕过序列化器的解密
+		err := DB.Table("channels").Select("id", commonKeyCol).Where("id > ?", lastId).
+			Order("id").Limit(100).Scan(&rows).Error
+		if err != nil {
+			return rotated, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			lastId = row.Id
+			if row.Key == "" || common.IsEnvelopeEncryptedWithCurrentKey(row.Key) {
+				continue
+			}
+			newKey, err := common.EnvelopeRewrap(row.Key)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to rotate key of channel %d: %w", row.Id, err)
+			}
+			err = DB.Table("channels").Where("id = ?", row.Id).Update("key", newKey).Error
+			if err != nil {
+				return rotated, fmt.Errorf("failed to save key of channel %d: %w", row.Id, err)
+			}
+			rotated++
+		}
+	}
+	return rotated, nil
+}
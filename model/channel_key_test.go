@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchChannelsMatchesKeyHash(t *testing.T) {
+	truncateTables(t)
+
+	channel := &Channel{Name: "search-by-key", Key: "sk-search-channel-key", Models: "gpt-4o"}
+	require.NoError(t, DB.Create(channel).Error)
+
+	var stored Channel
+	require.NoError(t, DB.Select("id", "key_hash").First(&stored, channel.Id).Error)
+	require.Equal(t, HashChannelKey("sk-search-channel-key"), stored.KeyHash)
+
+	channels, err := SearchChannels("sk-search-channel-key", "", "", false)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	require.Equal(t, channel.Id, channels[0].Id)
+
+	require.NoError(t, UpdateChannelKey(channel.Id, "sk-rotated-channel-key"))
+	channels, err = SearchChannels("sk-search-channel-key", "", "", false)
+	require.NoError(t, err)
+	require.Empty(t, channels)
+	channels, err = SearchChannels("sk-rotated-channel-key", "", "", false)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+}
+
+func TestSyncChannelKeyHashesRecomputesAfterSecretChange(t *testing.T) {
+	truncateTables(t)
+	DB.Where(&Option{Key: channelKeyHashFingerprintOptionKey}).Delete(&Option{})
+	defer func(secret string) { common.ChannelKeyHashSecret = secret }(common.ChannelKeyHashSecret)
+
+	common.ChannelKeyHashSecret = "old-channel-secret"
+	channel := &Channel{Name: "rehash", Key: "sk-rehash-channel-key", Models: "gpt-4o"}
+	require.NoError(t, DB.Create(channel).Error)
+	require.NoError(t, syncChannelKeyHashes())
+
+	common.ChannelKeyHashSecret = "new-channel-secret"
+	channels, err := SearchChannels("sk-rehash-channel-key", "", "", false)
+	require.NoError(t, err)
+	require.Empty(t, channels)
+
+	require.NoError(t, syncChannelKeyHashes())
+	channels, err = SearchChannels("sk-rehash-channel-key", "", "", false)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+}
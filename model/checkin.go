@@ -91,6 +91,10 @@ func UserCheckin(userId int) (*Checkin, error) {
 	return userCheckinWithTransaction(checkin, userId, quotaAwarded)
 }
 
+func checkinLedgerRef(checkin *Checkin) QuotaLedgerRef {
+	return QuotaLedgerRef{Source: QuotaSourceCheckin, RefId: checkin.CheckinDate}
+}
+
 // userCheckinWithTransaction 使用事务执行签到（适用于 MySQL 和 PostgreSQL）
 func userCheckinWithTransaction(checkin *Checkin, userId int, quotaAwarded int) (*Checkin, error) {
 	err := DB.Transaction(func(tx *gorm.DB) error {
@@ -101,10 +105,12 @@ func userCheckinWithTransaction(checkin *Checkin, userId int, quotaAwarded int)
 		}
 
 		// 步骤2: 在事务中增加用户额度
-		if err := tx.Model(&User{}).Where("id = ?", userId).
-			Update("quota", gorm.Expr("quota + ?", quotaAwarded)).Error; err != nil {
+		if err := changeUserQuotaTx(tx, userId, quotaAwarded, checkinLedgerRef(checkin)); err != nil {
 			return errors.New("签到失败：更新额度出错")
 		}
+		if err := createCreditGrantTx(tx, userId, operation_setting.CreditGrantSourceCheckin, checkin.CheckinDate, quotaAwarded); err != nil {
+			return errors.New("签到失败：记录赠送额度出错")
+		}
 
 		return nil
 	})
@@ -129,9 +135,8 @@ func userCheckinWithoutTransaction(checkin *Checkin, userId int, quotaAwarded in
 		return nil, errors.New("签到失败，请稍后重试")
 	}
 
-	// 步骤2: 增加用户额度
-	// 使用 db=true 强制直接写入数据库，不使用批量更新
-	if err := IncreaseUserQuota(userId, quotaAwarded, true); err != nil {
+	// 步骤2: 增加用户额度并记录赠送额度有效期
+	if err := grantPromotionQuota(userId, quotaAwarded, checkinLedgerRef(checkin), operation_setting.CreditGrantSourceCheckin); err != nil {
 		// 如果增加额度失败，需要回滚签到记录
 		DB.Delete(checkin)
 		return nil, errors.New("签到失败：更新额度出错")
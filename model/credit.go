@@ -0,0 +1,239 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"gorm.io/gorm"
+)
+
+// 信用额度的结算对象
+const (
+	CreditSubjectUser         = "user"
+	CreditSubjectOrganization = "organization"
+)
+
+var ErrNoOutstandingCredit = errors.New("当前没有待结算的欠款")
+
+// CreditSettlement 后付费客户的发票结算记录：记录线下收款，并将透支的余额补回 0
+type CreditSettlement struct {
+	Id            int     `json:"id"`
+	SubjectType   string  `json:"subject_type" gorm:"type:varchar(16);index:idx_credit_settlement_subject,priority:1"`
+	SubjectId     int     `json:"subject_id" gorm:"index:idx_credit_settlement_subject,priority:2"`
+	InvoiceNo     string  `json:"invoice_no" gorm:"type:varchar(64);index"`
+	Money         float64 `json:"money"`          // 实际收款金额
+	Quota         int     `json:"quota"`          // 补回的额度，即结算前的欠款
+	BalanceBefore int     `json:"balance_before"` // 结算前余额（负数）
+	Remark        string  `json:"remark" gorm:"type:varchar(255);default:''"`
+	OperatorId    int     `json:"operator_id"`
+	CreatedAt     int64   `json:"created_at" gorm:"bigint;index"`
+}
+
+// CreditAccount 配置了信用额度的用户或组织
+type CreditAccount struct {
+	SubjectType   string `json:"subject_type"`
+	SubjectId     int    `json:"subject_id"`
+	Name          string `json:"name"`
+	Quota         int    `json:"quota"`
+	CreditLimit   int    `json:"credit_limit"`
+	CreditDunning int    `json:"credit_dunning"`
+}
+
+// GetUserCreditLimit 从缓存读取用户的信用额度
+func GetUserCreditLimit(userId int) (int, error) {
+	cache, err := GetUserCache(userId)
+	if err != nil {
+		return 0, err
+	}
+	return cache.CreditLimit, nil
+}
+
+// SetCreditLimit 设置用户或组织的信用额度，0 表示关闭后付费
+func SetCreditLimit(subjectType string, subjectId int, creditLimit int) error {
+	if creditLimit < 0 {
+		return errors.New("信用额度不能为负数")
+	}
+	switch subjectType {
+	case CreditSubjectUser:
+		result := DB.Model(&User{}).Where("id = ?", subjectId).Update("credit_limit", creditLimit)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return invalidateUserCache(subjectId)
+	case CreditSubjectOrganization:
+		result := DB.Model(&Organization{}).Where("id = ?", subjectId).Update("credit_limit", creditLimit)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+	return errors.New("未知的信用额度对象类型")
+}
+
+// GetCreditAccounts 列出所有配置了信用额度的用户与组织，欠款多的在前
+func GetCreditAccounts() ([]*CreditAccount, error) {
+	var accounts []*CreditAccount
+	err := DB.Model(&User{}).Select("id AS subject_id, username AS name, quota, credit_limit, credit_dunning").
+		Where("credit_limit > 0").Order("quota").Scan(&accounts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		account.SubjectType = CreditSubjectUser
+	}
+	var orgAccounts []*CreditAccount
+	err = DB.Model(&Organization{}).Select("id AS subject_id, name, quota, credit_limit, credit_dunning").
+		Where("credit_limit > 0").Order("quota").Scan(&orgAccounts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range orgAccounts {
+		account.SubjectType = CreditSubjectOrganization
+	}
+	return append(accounts, orgAccounts...), nil
+}
+
+// GetCreditAccount 读取用户或组织当前的余额、信用额度与催缴进度
+func GetCreditAccount(subjectType string, subjectId int) (*CreditAccount, error) {
+	account := &CreditAccount{SubjectType: subjectType, SubjectId: subjectId}
+	var err error
+	switch subjectType {
+	case CreditSubjectUser:
+		err = DB.Model(&User{}).Select("username AS name, quota, credit_limit, credit_dunning").
+			Where("id = ?", subjectId).Take(account).Error
+	case CreditSubjectOrganization:
+		err = DB.Model(&Organization{}).Select("name, quota, credit_limit, credit_dunning").
+			Where("id = ?", subjectId).Take(account).Error
+	default:
+		err = errors.New("未知的信用额度对象类型")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AdvanceCreditDunning 将催缴进度推进到 level，已达到或超过该进度时返回 false，用于保证每档只通知一次
+func AdvanceCreditDunning(subjectType string, subjectId int, level int) (bool, error) {
+	var tx *gorm.DB
+	switch subjectType {
+	case CreditSubjectUser:
+		tx = DB.Model(&User{})
+	case CreditSubjectOrganization:
+		tx = DB.Model(&Organization{})
+	default:
+		return false, errors.New("未知的信用额度对象类型")
+	}
+	result := tx.Where("id = ? AND credit_dunning < ?", subjectId, level).Update("credit_dunning", level)
+	return result.RowsAffected > 0, result.Error
+}
+
+// resetCreditDunningTx 余额恢复为非负后重置催缴进度，下次透支时重新从第一档开始通知
+func resetCreditDunningTx(tx *gorm.DB, subjectType string, subjectId int) error {
+	switch subjectType {
+	case CreditSubjectUser:
+		tx = tx.Model(&User{})
+	case CreditSubjectOrganization:
+		tx = tx.Model(&Organization{})
+	default:
+		return errors.New("未知的信用额度对象类型")
+	}
+	return tx.Where("id = ? AND credit_dunning > 0 AND quota >= 0", subjectId).Update("credit_dunning", 0).Error
+}
+
+// resetsCreditDunning 判断该来源的额度增加是否为充值，充值使余额恢复为非负时重置催缴进度
+func resetsCreditDunning(source string) bool {
+	switch source {
+	case QuotaSourceTopUp, QuotaSourceRedemption, QuotaSourceAdmin:
+		return true
+	}
+	return false
+}
+
+// SettleCreditInvoice 结算后付费发票：记录收款，将透支的余额补回 0 并重置催缴进度
+func SettleCreditInvoice(subjectType string, subjectId int, money float64, invoiceNo string, remark string, operatorId int) (*CreditSettlement, error) {
+	settlement := &CreditSettlement{
+		SubjectType: subjectType,
+		SubjectId:   subjectId,
+		InvoiceNo:   invoiceNo,
+		Money:       money,
+		Remark:      remark,
+		OperatorId:  operatorId,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var balance int
+		switch subjectType {
+		case CreditSubjectUser:
+			err := tx.Set("gorm:query_option", "FOR UPDATE").Model(&User{}).Where("id = ?", subjectId).
+				Select("quota").Scan(&balance).Error
+			if err != nil {
+				return err
+			}
+			if balance >= 0 {
+				return ErrNoOutstandingCredit
+			}
+			ref := QuotaLedgerRef{Source: QuotaSourceInvoice, RefId: invoiceNo, Remark: remark}
+			if err := changeUserQuotaTx(tx, subjectId, -balance, ref); err != nil {
+				return err
+			}
+			if err := tx.Model(&User{}).Where("id = ?", subjectId).Update("credit_dunning", 0).Error; err != nil {
+				return err
+			}
+		case CreditSubjectOrganization:
+			err := tx.Set("gorm:query_option", "FOR UPDATE").Model(&Organization{}).Where("id = ?", subjectId).
+				Select("quota").Scan(&balance).Error
+			if err != nil {
+				return err
+			}
+			if balance >= 0 {
+				return ErrNoOutstandingCredit
+			}
+			err = tx.Model(&Organization{}).Where("id = ?", subjectId).Updates(map[string]interface{}{
+				"quota":          gorm.Expr("quota + ?", -balance),
+				"credit_dunning": 0,
+			}).Error
+			if err != nil {
+				return err
+			}
+		default:
+			return errors.New("未知的信用额度对象类型")
+		}
+		settlement.BalanceBefore = balance
+		settlement.Quota = -balance
+		return tx.Create(settlement).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if subjectType == CreditSubjectUser {
+		_ = cacheIncrUserQuota(subjectId, int64(settlement.Quota))
+		RecordLog(subjectId, LogTypeTopup, fmt.Sprintf("后付费账单结算成功，发票号：%s，补回额度：%s，收款金额：%.2f",
+			invoiceNo, logger.LogQuota(settlement.Quota), money))
+	}
+	return settlement, nil
+}
+
+func GetCreditSettlements(subjectType string, subjectId int, startIdx int, num int) (settlements []*CreditSettlement, total int64, err error) {
+	tx := DB.Model(&CreditSettlement{})
+	if subjectType != "" {
+		tx = tx.Where("subject_type = ?", subjectType)
+	}
+	if subjectId != 0 {
+		tx = tx.Where("subject_id = ?", subjectId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&settlements).Error
+	return settlements, total, err
+}
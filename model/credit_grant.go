@@ -0,0 +1,213 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 赠送额度状态
+const (
+	CreditGrantStatusActive  = "active"
+	CreditGrantStatusExpired = "expired"
+)
+
+// CreditGrant 有有效期的赠送额度。赠送时额度同时计入 User.Quota，CreditGrant 只记录其中尚未消耗的部分；
+// 钱包扣费时先消耗优先级数值最小的赠送额度，同优先级按过期时间先后消耗，过期后由后台任务从余额中扣除剩余部分
+type CreditGrant struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index:idx_credit_grant_user_status,priority:1"`
+	Source    string `json:"source" gorm:"type:varchar(32)"`
+	RefId     string `json:"ref_id" gorm:"type:varchar(64);default:''"`
+	Amount    int    `json:"amount"`
+	Remaining int    `json:"remaining"`
+	Priority  int    `json:"priority"`
+	Status    string `json:"status" gorm:"type:varchar(16);index:idx_credit_grant_user_status,priority:2"`
+	ExpiresAt int64  `json:"expires_at" gorm:"bigint;index"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// createCreditGrantTx 按配置为赠送额度记录有效期，该来源未配置有效期时不记录
+func createCreditGrantTx(tx *gorm.DB, userId int, source string, refId string, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	expireDays, priority := operation_setting.GetCreditGrantSetting().Rule(source)
+	if expireDays <= 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	expiresAt := now + int64(expireDays)*24*3600
+	err := tx.Create(&CreditGrant{
+		UserId:    userId,
+		Source:    source,
+		RefId:     refId,
+		Amount:    amount,
+		Remaining: amount,
+		Priority:  priority,
+		Status:    CreditGrantStatusActive,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&User{}).Where("id = ? AND credit_grant_until < ?", userId, expiresAt).
+		Update("credit_grant_until", expiresAt).Error
+}
+
+// grantPromotionQuota 在同一事务中为用户增加赠送额度、记录流水并按配置记录有效期
+func grantPromotionQuota(userId int, quota int, ref QuotaLedgerRef, grantSource string) error {
+	if quota <= 0 {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := changeUserQuotaTx(tx, userId, quota, ref); err != nil {
+			return err
+		}
+		return createCreditGrantTx(tx, userId, grantSource, ref.RefId, quota)
+	})
+	if err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheIncrUserQuota(userId, int64(quota)); err != nil {
+			common.SysLog("failed to increase user quota: " + err.Error())
+		}
+	})
+	return nil
+}
+
+// consumesCreditGrants 判断该来源的额度变动是否为钱包扣费或退还，需要分摊到赠送额度
+func consumesCreditGrants(source string) bool {
+	return source == QuotaSourceConsume || source == QuotaSourceTask
+}
+
+// applyCreditGrantUsageTx 在额度变动的事务中将钱包扣费分摊到赠送额度上：delta > 0 表示消耗，按优先级数值从小到大、过期时间先后扣减；
+// delta < 0 表示退还，按相反顺序补回已消耗的赠送额度。超出赠送额度的部分计入普通余额，不做处理。
+// 用户没有未过期的赠送额度时（User.CreditGrantUntil 已过）直接跳过，不锁定赠送额度记录
+func applyCreditGrantUsageTx(tx *gorm.DB, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	var grantUntil int64
+	if err := tx.Model(&User{}).Where("id = ?", userId).Select("credit_grant_until").Scan(&grantUntil).Error; err != nil {
+		return err
+	}
+	if grantUntil <= now {
+		return nil
+	}
+	query := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("user_id = ? AND status = ? AND expires_at > ?", userId, CreditGrantStatusActive, now)
+	if delta > 0 {
+		query = query.Where("remaining > 0").Order("priority, expires_at, id")
+	} else {
+		query = query.Where("remaining < amount").Order("priority desc, expires_at desc, id desc")
+	}
+	var grants []*CreditGrant
+	if err := query.Find(&grants).Error; err != nil {
+		return err
+	}
+	left := delta
+	for _, grant := range grants {
+		if left == 0 {
+			break
+		}
+		var change int
+		if left > 0 {
+			change = min(left, grant.Remaining)
+		} else {
+			change = -min(-left, grant.Amount-grant.Remaining)
+		}
+		err := tx.Model(&CreditGrant{}).Where("id = ?", grant.Id).
+			Update("remaining", gorm.Expr("remaining - ?", change)).Error
+		if err != nil {
+			return err
+		}
+		left -= change
+	}
+	return nil
+}
+
+// GetExpiredCreditGrants 返回已到期但尚未处理的赠送额度
+func GetExpiredCreditGrants(now int64, limit int) (grants []*CreditGrant, err error) {
+	err = DB.Where("status = ? AND expires_at <= ?", CreditGrantStatusActive, now).
+		Order("expires_at, id").Limit(limit).Find(&grants).Error
+	return grants, err
+}
+
+// ExpireCreditGrant 将到期的赠送额度标记为过期，并从用户余额中扣除其剩余部分（最多扣至 0）。
+// 返回实际扣除的额度，赠送额度已被处理过时返回 0
+func ExpireCreditGrant(grantId int) (int, error) {
+	var grant CreditGrant
+	expired := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&grant, grantId).Error; err != nil {
+			return err
+		}
+		if grant.Status != CreditGrantStatusActive {
+			return nil
+		}
+		if grant.Remaining > 0 {
+			var quota int
+			if err := tx.Model(&User{}).Where("id = ?", grant.UserId).Select("quota").Scan(&quota).Error; err != nil {
+				return err
+			}
+			expired = max(min(grant.Remaining, quota), 0)
+			if expired > 0 {
+				ref := QuotaLedgerRef{Source: QuotaSourceCreditExpire, RefId: strconv.Itoa(grant.Id)}
+				if err := changeUserQuotaTx(tx, grant.UserId, -expired, ref); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&CreditGrant{}).Where("id = ?", grant.Id).Updates(map[string]interface{}{
+			"status":    CreditGrantStatusExpired,
+			"remaining": 0,
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	if expired > 0 {
+		_ = cacheDecrUserQuota(grant.UserId, int64(expired))
+	}
+	return expired, nil
+}
+
+// GetUserCreditGrants 分页查询用户的赠送额度，status 为空时返回全部
+func GetUserCreditGrants(userId int, status string, startIdx int, num int) (grants []*CreditGrant, total int64, err error) {
+	tx := DB.Model(&CreditGrant{}).Where("user_id = ?", userId)
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("expires_at desc, id desc").Limit(num).Offset(startIdx).Find(&grants).Error
+	return grants, total, err
+}
+
+// CreditGrantSummary 用户当前有效的赠送额度汇总
+type CreditGrantSummary struct {
+	Remaining     int64 `json:"remaining"`
+	NextExpiresAt int64 `json:"next_expires_at"` // 最近一笔未消耗完的赠送额度的过期时间，没有时为 0
+}
+
+func GetUserCreditGrantSummary(userId int) (*CreditGrantSummary, error) {
+	summary := &CreditGrantSummary{}
+	if userId == 0 {
+		return nil, errors.New("user id is empty")
+	}
+	err := DB.Model(&CreditGrant{}).
+		Select("COALESCE(SUM(remaining), 0) AS remaining, COALESCE(MIN(expires_at), 0) AS next_expires_at").
+		Where("user_id = ? AND status = ? AND remaining > 0 AND expires_at > ?", userId, CreditGrantStatusActive, common.GetTimestamp()).
+		Scan(summary).Error
+	return summary, err
+}
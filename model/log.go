@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/metrics"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -33,6 +35,8 @@ type Log struct {
 	ChannelId        int    `json:"channel" gorm:"index"`
 	ChannelName      string `json:"channel_name" gorm:"->"`
 	TokenId          int    `json:"token_id" gorm:"default:0;index"`
+	ParentTokenId    int    `json:"parent_token_id" gorm:"default:0;index"` // 子令牌的父令牌，用于按父令牌归集用量
+	OrganizationId   int    `json:"organization_id" gorm:"default:0;index"` // 组织令牌的消耗归属的组织
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	RequestId        string `json:"request_id,omitempty" gorm:"type:varchar(64);index:idx_logs_request_id;default:''"`
@@ -115,6 +119,8 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		Quota:            0,
 		ChannelId:        channelId,
 		TokenId:          tokenId,
+		ParentTokenId:    c.GetInt("token_parent_id"),
+		OrganizationId:   common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 		UseTime:          useTimeSeconds,
 		IsStream:         isStream,
 		Group:            group,
@@ -149,6 +155,24 @@ type RecordConsumeLogParams struct {
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	metrics.AddConsumption(params.ModelName, params.Group, params.ChannelId, params.PromptTokens, params.CompletionTokens, params.Quota)
+	latencyMs := usageRollupOtherInt(params.Other, "duration_ms")
+	if latencyMs == 0 {
+		latencyMs = int64(params.UseTimeSeconds) * 1000
+	}
+	RecordUsageRollup(UsageRollup{
+		UserId:           userId,
+		TokenId:          params.TokenId,
+		ModelName:        params.ModelName,
+		ChannelId:        params.ChannelId,
+		Group:            params.Group,
+		Requests:         1,
+		PromptTokens:     int64(params.PromptTokens),
+		CompletionTokens: int64(params.CompletionTokens),
+		CachedTokens:     usageRollupOtherInt(params.Other, "cache_tokens"),
+		Quota:            int64(params.Quota),
+		LatencyMs:        latencyMs,
+	}, common.GetTimestamp())
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -176,6 +200,8 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		Quota:            params.Quota,
 		ChannelId:        params.ChannelId,
 		TokenId:          params.TokenId,
+		ParentTokenId:    c.GetInt("token_parent_id"),
+		OrganizationId:   common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 		UseTime:          params.UseTimeSeconds,
 		IsStream:         params.IsStream,
 		Group:            params.Group,
@@ -212,29 +238,50 @@ type RecordTaskBillingLogParams struct {
 }
 
 func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
+	// 异步任务的差额结算与退款只调整额度，不计为新的请求
+	rollupQuota := int64(params.Quota)
+	if params.LogType == LogTypeRefund {
+		rollupQuota = -rollupQuota
+	}
+	if params.LogType == LogTypeConsume || params.LogType == LogTypeRefund {
+		RecordUsageRollup(UsageRollup{
+			UserId:    params.UserId,
+			TokenId:   params.TokenId,
+			ModelName: params.ModelName,
+			ChannelId: params.ChannelId,
+			Group:     params.Group,
+			Quota:     rollupQuota,
+		}, common.GetTimestamp())
+	}
 	if params.LogType == LogTypeConsume && !common.LogConsumeEnabled {
 		return
 	}
 	username, _ := GetUsernameById(params.UserId, false)
 	tokenName := ""
+	parentTokenId := 0
+	organizationId := 0
 	if params.TokenId > 0 {
 		if token, err := GetTokenById(params.TokenId); err == nil {
 			tokenName = token.Name
+			parentTokenId = token.ParentTokenId
+			organizationId = token.OrganizationId
 		}
 	}
 	log := &Log{
-		UserId:    params.UserId,
-		Username:  username,
-		CreatedAt: common.GetTimestamp(),
-		Type:      params.LogType,
-		Content:   params.Content,
-		TokenName: tokenName,
-		ModelName: params.ModelName,
-		Quota:     params.Quota,
-		ChannelId: params.ChannelId,
-		TokenId:   params.TokenId,
-		Group:     params.Group,
-		Other:     common.MapToJsonStr(params.Other),
+		UserId:         params.UserId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           params.LogType,
+		Content:        params.Content,
+		TokenName:      tokenName,
+		ModelName:      params.ModelName,
+		Quota:          params.Quota,
+		ChannelId:      params.ChannelId,
+		TokenId:        params.TokenId,
+		ParentTokenId:  parentTokenId,
+		OrganizationId: organizationId,
+		Group:          params.Group,
+		Other:          common.MapToJsonStr(params.Other),
 	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
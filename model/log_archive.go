@@ -0,0 +1,164 @@
+package model
+
+import (
+	"context"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm/clause"
+)
+
+// 归档状态
+const (
+	LogArchiveStatusUploaded = "uploaded" // 已写入存储，尚未校验并删除数据库中的日志
+	LogArchiveStatusArchived = "archived" // 已校验行数并从数据库删除
+	LogArchiveStatusRestored = "restored" // 已恢复到数据库，保留期结束后重新校验删除
+)
+
+// LogArchive 日志归档索引，每条记录对应存储中的一个 NDJSON 文件，覆盖某一天（UTC）内 id 连续的一段日志。
+// 与日志位于同一个库中，便于在同一事务语义下核对行数。
+type LogArchive struct {
+	Id            int    `json:"id"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+	Day           string `json:"day" gorm:"type:varchar(10);index"`
+	StartTime     int64  `json:"start_time" gorm:"bigint;index"` // 包含
+	EndTime       int64  `json:"end_time" gorm:"bigint"`         // 不包含
+	MinLogId      int    `json:"min_log_id"`
+	MaxLogId      int    `json:"max_log_id"`
+	RowCount      int64  `json:"row_count"`
+	SizeBytes     int64  `json:"size_bytes"`
+	Sha256        string `json:"sha256" gorm:"type:varchar(64)"`
+	Storage       string `json:"storage" gorm:"type:varchar(16)"`
+	ObjectKey     string `json:"object_key" gorm:"type:varchar(255)"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	RestoredUntil int64  `json:"restored_until" gorm:"bigint;default:0"`
+}
+
+func CreateLogArchive(archive *LogArchive) error {
+	if archive.CreatedAt == 0 {
+		archive.CreatedAt = common.GetTimestamp()
+	}
+	return LOG_DB.Create(archive).Error
+}
+
+func UpdateLogArchiveStatus(id int, status string, restoredUntil int64) error {
+	return LOG_DB.Model(&LogArchive{}).Where("id = ?", id).Updates(map[string]any{
+		"status":         status,
+		"restored_until": restoredUntil,
+	}).Error
+}
+
+func GetLogArchiveById(id int) (*LogArchive, error) {
+	var archive LogArchive
+	if err := LOG_DB.First(&archive, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func GetLogArchives(day string, status string, startIdx int, num int) (archives []*LogArchive, total int64, err error) {
+	tx := LOG_DB.Model(&LogArchive{})
+	if day != "" {
+		tx = tx.Where("day = ?", day)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("start_time desc, id desc").Limit(num).Offset(startIdx).Find(&archives).Error
+	return archives, total, err
+}
+
+// GetPendingLogArchives 返回需要校验并删除日志的归档：上次未完成的，以及恢复保留期已结束的
+func GetPendingLogArchives(now int64) (archives []*LogArchive, err error) {
+	err = LOG_DB.Where("status = ? OR (status = ? AND restored_until < ?)",
+		LogArchiveStatusUploaded, LogArchiveStatusRestored, now).Order("id asc").Find(&archives).Error
+	return archives, err
+}
+
+// HasHeldLogArchive 判断某天是否有仍在恢复保留期内的归档，此时不再归档该天，避免重复导出
+func HasHeldLogArchive(startTime int64, now int64) (bool, error) {
+	var count int64
+	err := LOG_DB.Model(&LogArchive{}).Where("start_time = ? AND status = ? AND restored_until >= ?",
+		startTime, LogArchiveStatusRestored, now).Count(&count).Error
+	return count > 0, err
+}
+
+// GetOldestLogCreatedAt 返回 [from, before) 内最早的日志时间
+func GetOldestLogCreatedAt(from int64, before int64) (int64, bool, error) {
+	var oldest *int64
+	err := LOG_DB.Model(&Log{}).Where("created_at >= ? AND created_at < ?", from, before).
+		Select("MIN(created_at)").Scan(&oldest).Error
+	if err != nil || oldest == nil {
+		return 0, false, err
+	}
+	return *oldest, true, nil
+}
+
+// ExportLogsInRange 按 id 顺序分批读取 [startTime, endTime) 内的日志
+func ExportLogsInRange(ctx context.Context, startTime int64, endTime int64, batchSize int, fn func(logs []*Log) error) error {
+	lastId := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var logs []*Log
+		err := LOG_DB.Where("created_at >= ? AND created_at < ? AND id > ?", startTime, endTime, lastId).
+			Order("id asc").Limit(batchSize).Find(&logs).Error
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+		lastId = logs[len(logs)-1].Id
+		if len(logs) < batchSize {
+			return nil
+		}
+	}
+}
+
+func CountArchivedLogs(archive *LogArchive) (int64, error) {
+	var count int64
+	err := LOG_DB.Model(&Log{}).Where("created_at >= ? AND created_at < ? AND id >= ? AND id <= ?",
+		archive.StartTime, archive.EndTime, archive.MinLogId, archive.MaxLogId).Count(&count).Error
+	return count, err
+}
+
+// DeleteArchivedLogs 删除归档覆盖的日志，限定在时间与 id 范围内，不会误删归档之后写入的日志
+func DeleteArchivedLogs(ctx context.Context, archive *LogArchive, limit int) (int64, error) {
+	var total int64 = 0
+
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+
+		result := LOG_DB.Where("created_at >= ? AND created_at < ? AND id >= ? AND id <= ?",
+			archive.StartTime, archive.EndTime, archive.MinLogId, archive.MaxLogId).Limit(limit).Delete(&Log{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// RestoreLogs 按原 id 写回日志，已存在的行跳过，可重复执行
+func RestoreLogs(logs []*Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return LOG_DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&logs).Error
+}
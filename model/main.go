@@ -82,6 +82,7 @@ func createRootAccountIfNeed() error {
 			DisplayName: "Root User",
 			AccessToken: nil,
 			Quota:       100000000,
+			CreatedTime: common.GetTimestamp(),
 		}
 		DB.Create(&rootUser)
 	}
@@ -196,7 +197,10 @@ func InitDB() (err error) {
 		sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)))
 
 		if !common.IsMasterNode {
-			return nil
+			if err := initTokenKeySecret(); err != nil {
+				return err
+			}
+			return initChannelKeyHashSecret()
 		}
 		if common.UsingMySQL {
 			//_, _ = sqlDB.Exec("ALTER TABLE channels MODIFY model_mapping TEXT;") // TODO: delete this line when most users have upgraded
@@ -213,7 +217,11 @@ func InitDB() (err error) {
 func InitLogDB() (err error) {
 	if os.Getenv("LOG_SQL_DSN") == "" {
 		LOG_DB = DB
-		return
+		// 仅通过 LOG_DB 访问的表只在 migrateLOGDB 中迁移，共用主库时同样需要执行
+		if !common.IsMasterNode {
+			return nil
+		}
+		return migrateLOGDB()
 	}
 	db, err := chooseDB("LOG_SQL_DSN", true)
 	if err == nil {
@@ -276,6 +284,16 @@ func migrateDB() error {
 		&SubscriptionPreConsumeRecord{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
+		&Organization{},
+		&OrganizationMember{},
+		&AdminRole{},
+		&AuditLog{},
+		&UsageRollup{},
+		&Statement{},
+		&QuotaLedger{},
+		&QuotaLedgerMismatch{},
+		&CreditGrant{},
+		&CreditSettlement{},
 	)
 	if err != nil {
 		return err
@@ -289,7 +307,16 @@ func migrateDB() error {
 			return err
 		}
 	}
-	return nil
+	if err := initTokenKeySecret(); err != nil {
+		return err
+	}
+	if err := initChannelKeyHashSecret(); err != nil {
+		return err
+	}
+	if err := syncChannelKeyHashes(); err != nil {
+		return err
+	}
+	return migrateTokenKeys()
 }
 
 func migrateDBFast() error {
@@ -324,6 +351,16 @@ func migrateDBFast() error {
 		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
 		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
 		{&UserOAuthBinding{}, "UserOAuthBinding"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&AdminRole{}, "AdminRole"},
+		{&AuditLog{}, "AuditLog"},
+		{&UsageRollup{}, "UsageRollup"},
+		{&Statement{}, "Statement"},
+		{&QuotaLedger{}, "QuotaLedger"},
+		{&QuotaLedgerMismatch{}, "QuotaLedgerMismatch"},
+		{&CreditGrant{}, "CreditGrant"},
+		{&CreditSettlement{}, "CreditSettlement"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -363,7 +400,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &PayloadCapture{}, &LogArchive{}); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+const (
+	// ClaimMappingAny matches every user, useful as a trailing fallback rule
+	ClaimMappingAny = "*"
+	// ClaimMappingEmailDomain matches the domain part of the user's email
+	ClaimMappingEmailDomain = "email_domain"
+)
+
+// ClaimMappingRule maps a claim returned by the identity provider to the user's group, role and initial quota.
+// Rules are evaluated in order; for each of group, role and quota the first matching rule that sets it wins.
+// The role is recomputed on every login, so it follows the claims in the identity provider.
+type ClaimMappingRule struct {
+	Claim string `json:"claim"` // gjson path such as "groups", "roles" or "realm_access.roles", or email_domain / *
+	Value string `json:"value"` // case-insensitive; for array claims any element may match
+	Group string `json:"group,omitempty"`
+	Role  int    `json:"role,omitempty"`  // common.RoleCommonUser, or common.RoleAdminUser when claim_mapping.allow_admin_role is on
+	Quota *int   `json:"quota,omitempty"` // extra quota granted on top of the new user quota, only when the user is created
+}
+
+// ParseClaimMappingRules parses and validates the JSON rule list; an empty string yields no rules
+func ParseClaimMappingRules(raw string) ([]ClaimMappingRule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var rules []ClaimMappingRule
+	if err := common.UnmarshalJsonStr(raw, &rules); err != nil {
+		return nil, errors.New("claim_mappings must be a valid JSON array")
+	}
+	for i := range rules {
+		rule := &rules[i]
+		rule.Claim = strings.TrimSpace(rule.Claim)
+		rule.Value = strings.TrimSpace(rule.Value)
+		rule.Group = strings.TrimSpace(rule.Group)
+		if rule.Claim == "" {
+			return nil, fmt.Errorf("rule %d: claim is required", i+1)
+		}
+		if rule.Value == "" && rule.Claim != ClaimMappingAny {
+			return nil, fmt.Errorf("rule %d: value is required", i+1)
+		}
+		if rule.Group == "" && rule.Role == 0 && rule.Quota == nil {
+			return nil, fmt.Errorf("rule %d: at least one of group, role or quota is required", i+1)
+		}
+		if rule.Group != "" && !ratio_setting.ContainsGroupRatio(rule.Group) {
+			return nil, fmt.Errorf("rule %d: group %s does not exist", i+1, rule.Group)
+		}
+		if rule.Role != 0 && rule.Role != common.RoleCommonUser && rule.Role != common.RoleAdminUser {
+			return nil, fmt.Errorf("rule %d: role must be %d or %d", i+1, common.RoleCommonUser, common.RoleAdminUser)
+		}
+		if rule.Quota != nil && *rule.Quota < 0 {
+			return nil, fmt.Errorf("rule %d: quota must not be negative", i+1)
+		}
+	}
+	return rules, nil
+}
+
+// UpdateIdentityMapping applies the group and role resolved from identity provider claims.
+// Empty group or zero role keeps the current value, and the root user's role is never changed.
+func (user *User) UpdateIdentityMapping(group string, role int) error {
+	updates := map[string]interface{}{}
+	var changes []string
+	if group != "" && group != user.Group {
+		updates["group"] = group
+		changes = append(changes, fmt.Sprintf("分组 %s -> %s", user.Group, group))
+	}
+	if role != 0 && role != user.Role && user.Role != common.RoleRootUser {
+		updates["role"] = role
+		changes = append(changes, fmt.Sprintf("角色 %d -> %d", user.Role, role))
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("id = ?", user.Id).Updates(updates).Error; err != nil {
+		return err
+	}
+	if group, ok := updates["group"].(string); ok {
+		user.Group = group
+	}
+	if role, ok := updates["role"].(int); ok {
+		user.Role = role
+	}
+	RecordLog(user.Id, LogTypeSystem, "身份提供方映射规则更新用户："+strings.Join(changes, "，"))
+	return invalidateUserCache(user.Id)
+}
+
+// ApplyMappedInitialQuota credits the quota resolved from identity provider claims as a separate grant
+// on top of the new user quota, recorded in the ledger under QuotaSourceOAuthClaim.
+func (user *User) ApplyMappedInitialQuota(quota int) error {
+	if quota <= 0 {
+		return nil
+	}
+	if err := grantPromotionQuota(user.Id, quota, QuotaLedgerRef{Source: QuotaSourceOAuthClaim}, operation_setting.CreditGrantSourceOAuthClaim); err != nil {
+		return err
+	}
+	user.Quota += quota
+	RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("身份提供方映射规则赠送初始额度 %s", logger.LogQuota(quota)))
+	return nil
+}
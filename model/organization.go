@@ -0,0 +1,503 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 组织成员角色
+const (
+	OrganizationRoleOwner   = "owner"   // 所有者，拥有全部权限，每个组织只有一个
+	OrganizationRoleAdmin   = "admin"   // 管理员，管理成员与组织令牌
+	OrganizationRoleBilling = "billing" // 财务，充值组织额度并查看用量
+	OrganizationRoleMember  = "member"  // 普通成员，使用组织令牌
+)
+
+var (
+	ErrOrganizationQuotaInsufficient   = errors.New("组织额度不足")
+	ErrOrganizationMemberQuotaExceeded = errors.New("组织成员消费已达上限或不是组织成员")
+)
+
+var organizationRoles = []string{
+	OrganizationRoleOwner,
+	OrganizationRoleAdmin,
+	OrganizationRoleBilling,
+	OrganizationRoleMember,
+}
+
+func IsValidOrganizationRole(role string) bool {
+	return slices.Contains(organizationRoles, role)
+}
+
+// Organization 组织拥有共享的额度钱包，组织令牌的消耗从组织额度中扣除
+type Organization struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId       int    `json:"owner_id" gorm:"index"`
+	Quota         int    `json:"quota" gorm:"default:0"`
+	UsedQuota     int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	CreditLimit   int    `json:"credit_limit" gorm:"default:0"`   // 后付费信用额度，余额最多可透支到 -CreditLimit
+	CreditDunning int    `json:"credit_dunning" gorm:"default:0"` // 本结算周期内已发送的最高催缴百分比
+}
+
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member_user,priority:1"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_org_member_user,priority:2;index"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"default:0"` // 成员在组织内的消费上限，0 表示不限制
+	UsedQuota      int    `json:"used_quota" gorm:"default:0"`  // 成员通过组织令牌累计消耗的额度
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	Username       string `json:"username" gorm:"->;-:migration"`
+}
+
+// UserOrganization 用户所在的组织及其角色
+type UserOrganization struct {
+	Organization
+	Role string `json:"role"`
+}
+
+// CanManageMembers 所有者和管理员可以管理成员与组织令牌
+func (member *OrganizationMember) CanManageMembers() bool {
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleAdmin
+}
+
+// CanManageBilling 所有者和财务可以充值组织额度，所有者、管理员和财务可以查看用量
+func (member *OrganizationMember) CanManageBilling() bool {
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleBilling
+}
+
+func (member *OrganizationMember) CanViewUsage() bool {
+	return member.CanManageMembers() || member.CanManageBilling()
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	org := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		CreatedTime: common.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    common.GetTimestamp(),
+		}).Error
+	})
+	return org, err
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var org Organization
+	err := DB.First(&org, "id = ?", id).Error
+	return &org, err
+}
+
+func GetAllOrganizations(startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	err = DB.Model(&Organization{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	var orgs []*UserOrganization
+	err := DB.Table("organizations").
+		Select("organizations.*, organization_members.role").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userId).
+		Order("organizations.id desc").
+		Scan(&orgs).Error
+	return orgs, err
+}
+
+func (org *Organization) Rename(name string) error {
+	org.Name = name
+	return DB.Model(org).Update("name", name).Error
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.First(&member, "organization_id = ? AND user_id = ?", orgId, userId).Error
+	return &member, err
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	err := DB.Model(&OrganizationMember{}).
+		Select("organization_members.*, users.username").
+		Joins("LEFT JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", orgId).
+		Order("organization_members.id asc").
+		Find(&members).Error
+	return members, err
+}
+
+func AddOrganizationMember(orgId int, userId int, role string, quotaLimit int) (*OrganizationMember, error) {
+	if role == OrganizationRoleOwner || !IsValidOrganizationRole(role) {
+		return nil, errors.New("无效的成员角色")
+	}
+	if quotaLimit < 0 {
+		return nil, errors.New("成员消费上限不能为负数")
+	}
+	var count int64
+	if err := DB.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgId, userId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("该用户已是组织成员")
+	}
+	member := &OrganizationMember{
+		OrganizationId: orgId,
+		UserId:         userId,
+		Role:           role,
+		QuotaLimit:     quotaLimit,
+		CreatedTime:    common.GetTimestamp(),
+	}
+	return member, DB.Create(member).Error
+}
+
+// UpdateOrganizationMember 更新成员角色与消费上限，所有者角色只能通过 TransferOrganizationOwnership 变更
+func UpdateOrganizationMember(member *OrganizationMember) error {
+	if !IsValidOrganizationRole(member.Role) {
+		return errors.New("无效的成员角色")
+	}
+	if member.QuotaLimit < 0 {
+		return errors.New("成员消费上限不能为负数")
+	}
+	return DB.Model(member).Select("role", "quota_limit").Updates(member).Error
+}
+
+// TransferOrganizationOwnership 将所有者转让给另一名成员，原所有者降为管理员
+func TransferOrganizationOwnership(org *Organization, newOwnerId int) error {
+	if org.OwnerId == newOwnerId {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.Id, newOwnerId).
+			Update("role", OrganizationRoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该用户不是组织成员")
+		}
+		if err := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.Id, org.OwnerId).
+			Update("role", OrganizationRoleAdmin).Error; err != nil {
+			return err
+		}
+		return tx.Model(org).Update("owner_id", newOwnerId).Error
+	})
+	if err == nil {
+		org.OwnerId = newOwnerId
+	}
+	return err
+}
+
+// RemoveOrganizationMember 移除成员，该成员创建的组织令牌转交给组织所有者，保证令牌在成员离开后继续可用
+func RemoveOrganizationMember(org *Organization, userId int) error {
+	if userId == org.OwnerId {
+		return errors.New("不能移除组织所有者，请先转让所有权")
+	}
+	var tokens []Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("organization_id = ? AND user_id = ?", org.Id, userId).Delete(&OrganizationMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该用户不是组织成员")
+		}
+		if err := tx.Select("id", commonKeyCol).Where("organization_id = ? AND user_id = ?", org.Id, userId).Find(&tokens).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("organization_id = ? AND user_id = ?", org.Id, userId).
+			Update("user_id", org.OwnerId).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateTokenCaches(tokens)
+	return nil
+}
+
+// transferMemberOrganizationTokens 成员账号停用时将其在他人组织中创建的令牌转交组织所有者，
+// 令牌鉴权会拒绝停用用户的令牌，转交后组织令牌可以继续使用。其作为所有者的组织令牌保持不变
+func transferMemberOrganizationTokens(userId int) error {
+	var orgIds []int
+	err := DB.Model(&Token{}).Where("user_id = ? AND organization_id <> 0", userId).
+		Distinct().Pluck("organization_id", &orgIds).Error
+	if err != nil {
+		return err
+	}
+	for _, orgId := range orgIds {
+		org, err := GetOrganizationById(orgId)
+		if err != nil {
+			return err
+		}
+		if org.OwnerId == userId {
+			continue
+		}
+		var tokens []Token
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Select("id", commonKeyCol).Where("organization_id = ? AND user_id = ?", org.Id, userId).Find(&tokens).Error; err != nil {
+				return err
+			}
+			return tx.Model(&Token{}).Where("organization_id = ? AND user_id = ?", org.Id, userId).
+				Update("user_id", org.OwnerId).Error
+		})
+		if err != nil {
+			return err
+		}
+		invalidateTokenCaches(tokens)
+	}
+	return nil
+}
+
+// removeUserFromOrganizations 用户被删除时退出其加入的组织，其作为所有者的组织保持不变
+func removeUserFromOrganizations(userId int) error {
+	var members []OrganizationMember
+	if err := DB.Where("user_id = ? AND role <> ?", userId, OrganizationRoleOwner).Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		org, err := GetOrganizationById(member.OrganizationId)
+		if err != nil {
+			return err
+		}
+		if err := RemoveOrganizationMember(org, userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteOrganization 删除组织及其令牌，组织剩余额度退回所有者钱包
+func DeleteOrganization(org *Organization) error {
+	var tokens []Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var current Organization
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&current, org.Id).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id", commonKeyCol).Where("organization_id = ?", org.Id).Find(&tokens).Error; err != nil {
+			return err
+		}
+		// 组织令牌签发的子令牌继承了 organization_id，会在这里一并删除
+		if err := tx.Where("organization_id = ?", org.Id).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		if current.Quota > 0 {
+			ref := QuotaLedgerRef{
+				Source:         QuotaSourceOrganization,
+				RefId:          strconv.Itoa(current.Id),
+				Remark:         "解散组织退回余额",
+				CounterAccount: fmt.Sprintf("organization:%d", current.Id),
+			}
+			if err := changeUserQuotaTx(tx, current.OwnerId, current.Quota, ref); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("organization_id = ?", org.Id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&current).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateTokenCaches(tokens)
+	_ = invalidateUserCache(org.OwnerId)
+	return nil
+}
+
+// TransferUserQuotaToOrganization 从用户钱包向组织钱包转入额度
+func TransferUserQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户额度不足")
+		}
+		err := appendQuotaLedgerTx(tx, userId, QuotaLedgerRef{
+			Source:         QuotaSourceOrganization,
+			RefId:          strconv.Itoa(orgId),
+			CounterAccount: fmt.Sprintf("organization:%d", orgId),
+		}.entry(-int64(quota)))
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Organization{}).Where("id = ?", orgId).
+			Update("quota", gorm.Expr("quota + ?", quota)).Error
+		if err != nil {
+			return err
+		}
+		return resetCreditDunningTx(tx, CreditSubjectOrganization, orgId)
+	})
+	if err != nil {
+		return err
+	}
+	_ = invalidateUserCache(userId)
+	return nil
+}
+
+// AdjustOrganizationQuota 管理员直接调整组织额度，quota 为负数时扣减
+func AdjustOrganizationQuota(orgId int, quota int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Organization{}).Where("id = ?", orgId).
+			Update("quota", gorm.Expr("quota + ?", quota)).Error
+		if err != nil || quota <= 0 {
+			return err
+		}
+		return resetCreditDunningTx(tx, CreditSubjectOrganization, orgId)
+	})
+}
+
+// PreConsumeOrganizationQuota 从组织钱包预扣额度（可透支到信用额度），同时检查并累计成员的消费上限
+func PreConsumeOrganizationQuota(orgId int, userId int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ? AND (quota_limit = 0 OR used_quota + ? <= quota_limit)", orgId, userId, quota).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationMemberQuotaExceeded
+		}
+		result = tx.Model(&Organization{}).Where("id = ? AND quota + credit_limit >= ?", orgId, quota).
+			Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", quota),
+				"used_quota": gorm.Expr("used_quota + ?", quota),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationQuotaInsufficient
+		}
+		return nil
+	})
+}
+
+// AdjustOrganizationConsumedQuota 按差额调整组织与成员的消耗（正数补扣，负数退还），结算阶段不做上限检查
+func AdjustOrganizationConsumedQuota(orgId int, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", delta)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Organization{}).Where("id = ?", orgId).
+			Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", delta),
+				"used_quota": gorm.Expr("used_quota + ?", delta),
+			}).Error
+	})
+}
+
+func GetOrganizationTokens(orgId int, startIdx int, num int) (tokens []*Token, total int64, err error) {
+	err = DB.Model(&Token{}).Where("organization_id = ?", orgId).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Where("organization_id = ?", orgId).Order("id desc").Limit(num).Offset(startIdx).Find(&tokens).Error
+	return tokens, total, err
+}
+
+func GetOrganizationToken(orgId int, tokenId int) (*Token, error) {
+	var token Token
+	err := DB.First(&token, "id = ? AND organization_id = ?", tokenId, orgId).Error
+	return &token, err
+}
+
+// OrganizationMemberUsage 组织内按成员汇总的用量
+type OrganizationMemberUsage struct {
+	UserId           int    `json:"user_id"`
+	Username         string `json:"username"`
+	Quota            int    `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	RequestCount     int    `json:"request_count"`
+}
+
+// GetOrganizationUsage 按成员汇总组织在时间范围内的消费日志
+func GetOrganizationUsage(orgId int, startTimestamp int64, endTimestamp int64) ([]*OrganizationMemberUsage, error) {
+	var usages []*OrganizationMemberUsage
+	tx := LOG_DB.Table("logs").
+		Select("user_id, MAX(username) as username, SUM(quota) as quota, SUM(prompt_tokens) as prompt_tokens, SUM(completion_tokens) as completion_tokens, COUNT(*) as request_count").
+		Where("organization_id = ? AND type = ?", orgId, LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err := tx.Group("user_id").Order("quota desc").Scan(&usages).Error
+	return usages, err
+}
+
+func GetOrganizationLogs(orgId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Model(&Log{}).Where("organization_id = ?", orgId)
+	if logType != LogTypeUnknown {
+		tx = tx.Where("type = ?", logType)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	formatUserLogs(logs, startIdx)
+	return logs, total, nil
+}
+
+func invalidateTokenCaches(tokens []Token) {
+	if !common.RedisEnabled || len(tokens) == 0 {
+		return
+	}
+	gopool.Go(func() {
+		for _, token := range tokens {
+			_ = cacheDeleteToken(token.Key)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func truncateOrganizationTables(t *testing.T) {
+	t.Helper()
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM organizations")
+		DB.Exec("DELETE FROM organization_members")
+	})
+}
+
+func TestOrganizationQuotaAndMemberCap(t *testing.T) {
+	truncateOrganizationTables(t)
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "owner", AffCode: "aff1", Quota: 1000}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "member", AffCode: "aff2"}).Error)
+
+	org, err := CreateOrganization("acme", 1)
+	require.NoError(t, err)
+	require.NoError(t, TransferUserQuotaToOrganization(1, org.Id, 600))
+	assert.Error(t, TransferUserQuotaToOrganization(1, org.Id, 600), "钱包额度不足时不能转入")
+
+	_, err = AddOrganizationMember(org.Id, 2, OrganizationRoleMember, 100)
+	require.NoError(t, err)
+
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 2, 80))
+	assert.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 2, 30), ErrOrganizationMemberQuotaExceeded)
+	require.NoError(t, AdjustOrganizationConsumedQuota(org.Id, 2, -50))
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 2, 30))
+	assert.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 1, 1000), ErrOrganizationQuotaInsufficient)
+
+	org, err = GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 540, org.Quota)
+	assert.Equal(t, 60, org.UsedQuota)
+	member, err := GetOrganizationMember(org.Id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 60, member.UsedQuota)
+
+	// 配置信用额度后组织钱包可透支到 -CreditLimit
+	require.NoError(t, SetCreditLimit(CreditSubjectOrganization, org.Id, 500))
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 1, 1000))
+	assert.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 1, 100), ErrOrganizationQuotaInsufficient)
+	org, err = GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	assert.Equal(t, -460, org.Quota)
+}
+
+func TestRemoveMemberKeepsOrganizationTokens(t *testing.T) {
+	truncateOrganizationTables(t)
+	initCol()
+	common.TokenKeySecret = "test-secret"
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "owner", AffCode: "aff1", Quota: 100}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "member", AffCode: "aff2"}).Error)
+
+	org, err := CreateOrganization("acme", 1)
+	require.NoError(t, err)
+	_, err = AddOrganizationMember(org.Id, 2, OrganizationRoleAdmin, 0)
+	require.NoError(t, err)
+
+	token := &Token{UserId: 2, OrganizationId: org.Id, Name: "shared", Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+	token.SetKey("orgTokenKey0123456789abcdefghijklmnopqrstuvwxyz")
+	require.NoError(t, token.Insert())
+
+	assert.Error(t, RemoveOrganizationMember(org, 1), "所有者不能被移除")
+	require.NoError(t, RemoveOrganizationMember(org, 2))
+	reloaded, err := GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reloaded.UserId)
+	assert.Equal(t, org.Id, reloaded.OrganizationId)
+
+	require.NoError(t, DeleteOrganization(org))
+	_, err = GetTokenById(token.Id)
+	assert.Error(t, err)
+	var owner User
+	require.NoError(t, DB.First(&owner, 1).Error)
+	assert.Equal(t, 100, owner.Quota)
+}
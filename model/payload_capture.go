@@ -0,0 +1,136 @@
+package model
+
+import (
+	"context"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// PayloadCapture 一次中继请求的载荷抓包，四段载荷均已按配置脱敏并以 gzip 压缩存储。
+// 载荷体积较大，与消费日志一起存放在日志库中。
+type PayloadCapture struct {
+	Id               int    `json:"id"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	RequestId        string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	ChannelId        int    `json:"channel_id" gorm:"index"`
+	ModelName        string `json:"model_name" gorm:"type:varchar(128);default:''"`
+	RelayFormat      string `json:"relay_format" gorm:"type:varchar(32);default:''"`
+	Method           string `json:"method" gorm:"type:varchar(8)"`
+	Path             string `json:"path" gorm:"type:varchar(255)"`
+	StatusCode       int    `json:"status_code"`
+	IsStream         bool   `json:"is_stream"`
+	Truncated        bool   `json:"truncated"` // 任一段载荷超过长度上限被截断
+	InboundRequest   []byte `json:"-"`         // 客户端原始请求
+	UpstreamRequest  []byte `json:"-"`         // 转换后发往上游的请求
+	UpstreamResponse []byte `json:"-"`         // 上游原始响应
+	ClientResponse   []byte `json:"-"`         // 最终返回给客户端的响应
+}
+
+type PayloadCaptureQuery struct {
+	UserId         int
+	TokenId        int
+	ChannelId      int
+	ModelName      string
+	RequestId      string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+var payloadCaptureBodyColumns = []string{"inbound_request", "upstream_request", "upstream_response", "client_response"}
+
+func CreatePayloadCapture(capture *PayloadCapture) error {
+	if capture.CreatedAt == 0 {
+		capture.CreatedAt = common.GetTimestamp()
+	}
+	return LOG_DB.Create(capture).Error
+}
+
+// GetPayloadCaptures 分页查询抓包记录，不加载载荷内容
+func GetPayloadCaptures(query PayloadCaptureQuery, startIdx int, num int) (captures []*PayloadCapture, total int64, err error) {
+	tx := LOG_DB.Model(&PayloadCapture{})
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.TokenId != 0 {
+		tx = tx.Where("token_id = ?", query.TokenId)
+	}
+	if query.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", query.ChannelId)
+	}
+	if query.ModelName != "" {
+		tx = tx.Where("model_name = ?", query.ModelName)
+	}
+	if query.RequestId != "" {
+		tx = tx.Where("request_id = ?", query.RequestId)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Omit(payloadCaptureBodyColumns...).Order("id desc").Limit(num).Offset(startIdx).Find(&captures).Error
+	return captures, total, err
+}
+
+func GetPayloadCaptureById(id int) (*PayloadCapture, error) {
+	var capture PayloadCapture
+	if err := LOG_DB.First(&capture, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// PayloadCaptureBodies 解压后的四段载荷
+type PayloadCaptureBodies struct {
+	InboundRequest   string `json:"inbound_request"`
+	UpstreamRequest  string `json:"upstream_request"`
+	UpstreamResponse string `json:"upstream_response"`
+	ClientResponse   string `json:"client_response"`
+}
+
+func (capture *PayloadCapture) DecodeBodies() (*PayloadCaptureBodies, error) {
+	fields := []*[]byte{&capture.InboundRequest, &capture.UpstreamRequest, &capture.UpstreamResponse, &capture.ClientResponse}
+	decoded := make([]string, len(fields))
+	for i, field := range fields {
+		data, err := common.GunzipBytes(*field)
+		if err != nil {
+			return nil, err
+		}
+		decoded[i] = string(data)
+	}
+	return &PayloadCaptureBodies{
+		InboundRequest:   decoded[0],
+		UpstreamRequest:  decoded[1],
+		UpstreamResponse: decoded[2],
+		ClientResponse:   decoded[3],
+	}, nil
+}
+
+func DeleteOldPayloadCaptures(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+
+		result := LOG_DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&PayloadCapture{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,281 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 额度变动来源
+const (
+	QuotaSourceOpening      = "opening"       // 启用流水前的期初余额
+	QuotaSourceSignup       = "signup"        // 注册赠送
+	QuotaSourceInvite       = "invite"        // 邀请码赠送
+	QuotaSourceConsume      = "consume"       // 请求计费：预扣、结算与退还
+	QuotaSourceTask         = "task"          // 异步任务计费与退款
+	QuotaSourceTopUp        = "topup"         // 在线充值与补单
+	QuotaSourceRedemption   = "redemption"    // 兑换码
+	QuotaSourceCheckin      = "checkin"       // 签到奖励
+	QuotaSourceAffTransfer  = "aff_transfer"  // 邀请额度划转
+	QuotaSourceAdmin        = "admin"         // 管理员调整
+	QuotaSourceOAuthClaim   = "oauth_claim"   // 身份提供方声明映射的初始额度
+	QuotaSourceOrganization = "organization"  // 转入组织钱包
+	QuotaSourceCreditExpire = "credit_expire" // 赠送额度过期
+	QuotaSourceInvoice      = "invoice"       // 后付费发票结算
+)
+
+// quotaSourceCounterAccounts 各来源对应的对方科目，用户额度的每笔增减都由对方科目等额反向记账
+var quotaSourceCounterAccounts = map[string]string{
+	QuotaSourceOpening:      "equity:opening",
+	QuotaSourceSignup:       "expense:promotion",
+	QuotaSourceInvite:       "expense:promotion",
+	QuotaSourceConsume:      "revenue:usage",
+	QuotaSourceTask:         "revenue:usage",
+	QuotaSourceTopUp:        "asset:payment",
+	QuotaSourceRedemption:   "liability:redemption",
+	QuotaSourceCheckin:      "expense:promotion",
+	QuotaSourceAffTransfer:  "liability:aff_quota",
+	QuotaSourceAdmin:        "equity:adjustment",
+	QuotaSourceOAuthClaim:   "expense:promotion",
+	QuotaSourceOrganization: "liability:organization",
+	QuotaSourceCreditExpire: "expense:promotion",
+	QuotaSourceInvoice:      "asset:receivable",
+}
+
+// QuotaLedger 用户额度流水，只追加不修改。每条记录表示用户账户与对方科目之间的一笔等额反向变动，
+// Delta 为用户账户的变动（正数为增加），Balance 为变动后的用户余额。
+// 流水与余额变更在同一事务中写入，批量更新模式下在批量落库时一并写入。
+type QuotaLedger struct {
+	Id             int64  `json:"id"`
+	UserId         int    `json:"user_id" gorm:"index:idx_quota_ledger_user_id,priority:1"`
+	Delta          int64  `json:"delta"`
+	Balance        int64  `json:"balance"`
+	Source         string `json:"source" gorm:"type:varchar(32);index"`
+	CounterAccount string `json:"counter_account" gorm:"type:varchar(64)"`
+	RefId          string `json:"ref_id" gorm:"type:varchar(128);index;default:''"` // request_id、trade_no、task_id 等
+	Remark         string `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// QuotaLedgerRef 额度变动的来源与关联单号
+type QuotaLedgerRef struct {
+	Source         string
+	RefId          string
+	Remark         string
+	CounterAccount string // 为空时按 Source 推断
+}
+
+func (ref QuotaLedgerRef) entry(delta int64) *QuotaLedger {
+	counterAccount := ref.CounterAccount
+	if counterAccount == "" {
+		counterAccount = quotaSourceCounterAccounts[ref.Source]
+	}
+	return &QuotaLedger{
+		Delta:          delta,
+		Source:         ref.Source,
+		CounterAccount: counterAccount,
+		RefId:          limitLedgerText(ref.RefId, 128),
+		Remark:         limitLedgerText(ref.Remark, 255),
+	}
+}
+
+func limitLedgerText(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// changeUserQuotaTx 在事务中调整用户额度并追加流水，钱包扣费与退还同时分摊到赠送额度，充值后重置催缴进度
+func changeUserQuotaTx(tx *gorm.DB, userId int, delta int, ref QuotaLedgerRef) error {
+	if delta != 0 {
+		err := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", delta)).Error
+		if err != nil {
+			return err
+		}
+		if consumesCreditGrants(ref.Source) {
+			if err := applyCreditGrantUsageTx(tx, userId, -delta); err != nil {
+				return err
+			}
+		}
+		if delta > 0 && resetsCreditDunning(ref.Source) {
+			if err := resetCreditDunningTx(tx, CreditSubjectUser, userId); err != nil {
+				return err
+			}
+		}
+	}
+	return appendQuotaLedgerTx(tx, userId, ref.entry(int64(delta)))
+}
+
+// changeUserQuota 在独立事务中调整用户额度并追加流水
+func changeUserQuota(userId int, delta int, ref QuotaLedgerRef) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return changeUserQuotaTx(tx, userId, delta, ref)
+	})
+}
+
+// appendQuotaLedgerTx 为已在事务中生效的余额变动追加流水，entries 按发生顺序排列，其 Delta 之和即本次变动量。
+// 用户首次产生流水时先补记一条期初余额，使流水的 Delta 之和始终等于最新余额。
+func appendQuotaLedgerTx(tx *gorm.DB, userId int, entries ...*QuotaLedger) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var balance int64
+	err := tx.Model(&User{}).Where("id = ?", userId).Select("quota").Scan(&balance).Error
+	if err != nil {
+		return err
+	}
+	total := int64(0)
+	for _, entry := range entries {
+		total += entry.Delta
+	}
+	running := balance - total
+
+	var existing []int64
+	err = tx.Model(&QuotaLedger{}).Where("user_id = ?", userId).Limit(1).Pluck("id", &existing).Error
+	if err != nil {
+		return err
+	}
+	now := common.GetTimestamp()
+	rows := make([]*QuotaLedger, 0, len(entries)+1)
+	if len(existing) == 0 && running != 0 {
+		rows = append(rows, &QuotaLedger{
+			UserId:         userId,
+			Delta:          running,
+			Balance:        running,
+			Source:         QuotaSourceOpening,
+			CounterAccount: quotaSourceCounterAccounts[QuotaSourceOpening],
+			CreatedAt:      now,
+		})
+	}
+	for _, entry := range entries {
+		running += entry.Delta
+		entry.UserId = userId
+		entry.Balance = running
+		if entry.CreatedAt == 0 {
+			entry.CreatedAt = now
+		}
+		rows = append(rows, entry)
+	}
+	return tx.CreateInBatches(rows, 100).Error
+}
+
+// QuotaLedgerQuery 流水查询条件，零值表示不过滤
+type QuotaLedgerQuery struct {
+	UserId         int
+	Source         string
+	RefId          string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func GetQuotaLedgers(query QuotaLedgerQuery, startIdx int, num int) (entries []*QuotaLedger, total int64, err error) {
+	tx := DB.Model(&QuotaLedger{})
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.Source != "" {
+		tx = tx.Where("source = ?", query.Source)
+	}
+	if query.RefId != "" {
+		tx = tx.Where("ref_id = ?", query.RefId)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&entries).Error
+	return entries, total, err
+}
+
+// 对账异常类型
+const (
+	QuotaLedgerMismatchBalance = "balance" // 用户当前余额与最新流水余额不一致
+	QuotaLedgerMismatchGap     = "gap"     // 相邻两条流水之间的余额不连续，说明有未记流水的变动
+)
+
+// QuotaLedgerMismatch 对账任务发现的异常
+type QuotaLedgerMismatch struct {
+	Id         int    `json:"id"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Kind       string `json:"kind" gorm:"type:varchar(16)"`
+	LedgerId   int64  `json:"ledger_id"`
+	Expected   int64  `json:"expected"`
+	Actual     int64  `json:"actual"`
+	DetectedAt int64  `json:"detected_at" gorm:"bigint;index"`
+}
+
+// QuotaBalanceDiff 用户余额与最新流水余额的差异
+type QuotaBalanceDiff struct {
+	UserId   int   `json:"user_id"`
+	Quota    int64 `json:"quota"`
+	Balance  int64 `json:"balance"`
+	LedgerId int64 `json:"ledger_id"`
+}
+
+// FindQuotaBalanceDiffs 找出当前余额与最新一条流水余额不一致的用户
+func FindQuotaBalanceDiffs(limit int) (diffs []*QuotaBalanceDiff, err error) {
+	err = DB.Table("users").
+		Select("users.id AS user_id, users.quota AS quota, l.balance AS balance, l.id AS ledger_id").
+		Joins("JOIN quota_ledgers l ON l.id = (SELECT MAX(id) FROM quota_ledgers WHERE user_id = users.id)").
+		Where("users.quota <> l.balance").
+		Limit(limit).
+		Scan(&diffs).Error
+	return diffs, err
+}
+
+// GetQuotaLedgersAfter 按 id 顺序读取 afterId 之后的流水，用于连续性校验
+func GetQuotaLedgersAfter(afterId int64, limit int) (entries []*QuotaLedger, err error) {
+	err = DB.Where("id > ?", afterId).Order("id").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// GetPreviousQuotaLedger 返回用户在 beforeId 之前的最后一条流水，不存在时返回 nil
+func GetPreviousQuotaLedger(userId int, beforeId int64) (*QuotaLedger, error) {
+	var entry QuotaLedger
+	err := DB.Where("user_id = ? AND id < ?", userId, beforeId).Order("id desc").First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func GetMaxQuotaLedgerId() (int64, error) {
+	var id int64
+	err := DB.Model(&QuotaLedger{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func CreateQuotaLedgerMismatches(mismatches []*QuotaLedgerMismatch) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+	return DB.CreateInBatches(mismatches, 100).Error
+}
+
+func GetQuotaLedgerMismatches(userId int, startIdx int, num int) (mismatches []*QuotaLedgerMismatch, total int64, err error) {
+	tx := DB.Model(&QuotaLedgerMismatch{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&mismatches).Error
+	return mismatches, total, err
+}
+
+func (m *QuotaLedgerMismatch) String() string {
+	return fmt.Sprintf("user %d %s mismatch at ledger %d: expected %d, actual %d", m.UserId, m.Kind, m.LedgerId, m.Expected, m.Actual)
+}
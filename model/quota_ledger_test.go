@@ -0,0 +1,103 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quotaLedgersOf(t *testing.T, userId int) []*QuotaLedger {
+	t.Helper()
+	var entries []*QuotaLedger
+	require.NoError(t, DB.Where("user_id = ?", userId).Order("id").Find(&entries).Error)
+	return entries
+}
+
+func TestQuotaLedger_OpeningAndRunningBalance(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "ledger_user", Quota: 1000}).Error)
+
+	require.NoError(t, DecreaseUserQuota(1, 300, QuotaLedgerRef{Source: QuotaSourceConsume, RefId: "req-1"}))
+	require.NoError(t, IncreaseUserQuota(1, 100, true, QuotaLedgerRef{Source: QuotaSourceConsume, RefId: "req-1"}))
+
+	entries := quotaLedgersOf(t, 1)
+	require.Len(t, entries, 3)
+	assert.Equal(t, QuotaSourceOpening, entries[0].Source)
+	assert.EqualValues(t, 1000, entries[0].Balance)
+	assert.EqualValues(t, -300, entries[1].Delta)
+	assert.EqualValues(t, 700, entries[1].Balance)
+	assert.Equal(t, "req-1", entries[1].RefId)
+	assert.Equal(t, "revenue:usage", entries[1].CounterAccount)
+	assert.EqualValues(t, 800, entries[2].Balance)
+
+	diffs, err := FindQuotaBalanceDiffs(10)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestQuotaLedger_BatchFlushWritesEntriesWithBalance(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "ledger_batch", Quota: 500}).Error)
+
+	common.BatchUpdateEnabled = true
+	t.Cleanup(func() { common.BatchUpdateEnabled = false })
+
+	require.NoError(t, DecreaseUserQuota(2, 200, QuotaLedgerRef{Source: QuotaSourceConsume, RefId: "req-a"}))
+	require.NoError(t, IncreaseUserQuota(2, 50, false, QuotaLedgerRef{Source: QuotaSourceConsume, RefId: "req-a"}))
+	assert.Empty(t, quotaLedgersOf(t, 2), "ledger entries must wait for the batch flush")
+
+	batchUpdate()
+
+	quota, err := GetUserQuota(2, true)
+	require.NoError(t, err)
+	assert.Equal(t, 350, quota)
+	entries := quotaLedgersOf(t, 2)
+	require.Len(t, entries, 3)
+	assert.EqualValues(t, 500, entries[0].Balance)
+	assert.EqualValues(t, 300, entries[1].Balance)
+	assert.EqualValues(t, 350, entries[2].Balance)
+}
+
+func TestFindQuotaBalanceDiffs_UnrecordedChange(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&User{Id: 3, Username: "ledger_drift", Quota: 100}).Error)
+	require.NoError(t, IncreaseUserQuota(3, 10, true, QuotaLedgerRef{Source: QuotaSourceAdmin}))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", 3).Update("quota", 42).Error)
+
+	diffs, err := FindQuotaBalanceDiffs(10)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, 3, diffs[0].UserId)
+	assert.EqualValues(t, 42, diffs[0].Quota)
+	assert.EqualValues(t, 110, diffs[0].Balance)
+}
+
+func TestQuotaLedger_BatchFlushConsumesCreditGrants(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() { DB.Exec("DELETE FROM credit_grants") })
+	require.NoError(t, DB.Create(&User{Id: 3, Username: "ledger_grant", AffCode: "aff_grant", Quota: 500}).Error)
+
+	setting := operation_setting.GetCreditGrantSetting()
+	setting.CheckinExpireDays = 7
+	t.Cleanup(func() { setting.CheckinExpireDays = 0 })
+	require.NoError(t, grantPromotionQuota(3, 100, QuotaLedgerRef{Source: QuotaSourceCheckin}, operation_setting.CreditGrantSourceCheckin))
+
+	common.BatchUpdateEnabled = true
+	t.Cleanup(func() { common.BatchUpdateEnabled = false })
+	require.NoError(t, DecreaseUserQuota(3, 80, QuotaLedgerRef{Source: QuotaSourceConsume, RefId: "req-g"}))
+
+	var grant CreditGrant
+	require.NoError(t, DB.Where("user_id = ?", 3).First(&grant).Error)
+	assert.Equal(t, 100, grant.Remaining, "credit grants must follow the batch flush")
+
+	batchUpdate()
+
+	require.NoError(t, DB.First(&grant, grant.Id).Error)
+	assert.Equal(t, 20, grant.Remaining)
+	quota, err := GetUserQuota(3, true)
+	require.NoError(t, err)
+	assert.Equal(t, 520, quota)
+}
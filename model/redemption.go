@@ -7,6 +7,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"gorm.io/gorm"
 )
@@ -140,7 +141,11 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if redemption.ExpiredTime != 0 && redemption.ExpiredTime < common.GetTimestamp() {
 			return errors.New("该兑换码已过期")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		err = changeUserQuotaTx(tx, userId, redemption.Quota, QuotaLedgerRef{Source: QuotaSourceRedemption, RefId: strconv.Itoa(redemption.Id)})
+		if err != nil {
+			return err
+		}
+		err = createCreditGrantTx(tx, userId, operation_setting.CreditGrantSourceRedemption, strconv.Itoa(redemption.Id), redemption.Quota)
 		if err != nil {
 			return err
 		}
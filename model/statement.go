@@ -0,0 +1,273 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatementSubjectUser         = "user"
+	StatementSubjectOrganization = "organization"
+)
+
+// statementMaxTopupEvents 单份账单最多列出的充值记录条数，超出部分仍计入汇总
+const statementMaxTopupEvents = 1000
+
+// Statement 按月生成的用户或组织账单。账期结束后由定时任务保存快照，管理员可重新生成
+type Statement struct {
+	Id                int              `json:"id"`
+	SubjectType       string           `json:"subject_type" gorm:"type:varchar(16);uniqueIndex:idx_statement_subject_period,priority:1"`
+	SubjectId         int              `json:"subject_id" gorm:"uniqueIndex:idx_statement_subject_period,priority:2"`
+	SubjectName       string           `json:"subject_name" gorm:"type:varchar(128);default:''"`
+	Period            string           `json:"period" gorm:"type:varchar(7);uniqueIndex:idx_statement_subject_period,priority:3"` // YYYY-MM
+	PeriodStart       int64            `json:"period_start" gorm:"bigint"`
+	PeriodEnd         int64            `json:"period_end" gorm:"bigint"`
+	PaymentMoney      float64          `json:"payment_money"`      // 充值支付金额，不含订阅
+	SubscriptionMoney float64          `json:"subscription_money"` // 订阅支付金额
+	ConsumedQuota     int64            `json:"consumed_quota"`
+	RefundedQuota     int64            `json:"refunded_quota"`
+	RequestCount      int64            `json:"request_count"`
+	Detail            string           `json:"-" gorm:"type:text"`
+	Details           *StatementDetail `json:"details,omitempty" gorm:"-"`
+	GeneratedAt       int64            `json:"generated_at" gorm:"bigint"`
+}
+
+// StatementDetail 账单明细，以 JSON 保存在 Statement.Detail 中
+type StatementDetail struct {
+	Payments    []StatementPayment    `json:"payments"`
+	TopupEvents []StatementTopupEvent `json:"topup_events"`
+	Usage       []StatementUsageLine  `json:"usage"`
+	Refunds     []StatementRefundLine `json:"refunds"`
+}
+
+// StatementPayment 一笔已完成的支付，Subscription 表示订阅购买
+type StatementPayment struct {
+	TradeNo       string  `json:"trade_no"`
+	PaymentMethod string  `json:"payment_method"`
+	Amount        int64   `json:"amount"`
+	Money         float64 `json:"money"`
+	CompleteTime  int64   `json:"complete_time"`
+	Subscription  bool    `json:"subscription"`
+}
+
+// StatementTopupEvent 一条充值日志（在线充值、兑换码、补单等）
+type StatementTopupEvent struct {
+	CreatedAt int64  `json:"created_at"`
+	Content   string `json:"content"`
+}
+
+// StatementUsageLine 按模型与令牌汇总的消费
+type StatementUsageLine struct {
+	ModelName        string `json:"model_name"`
+	TokenName        string `json:"token_name"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+// StatementRefundLine 按模型汇总的退款
+type StatementRefundLine struct {
+	ModelName string `json:"model_name"`
+	Count     int64  `json:"count"`
+	Quota     int64  `json:"quota"`
+}
+
+// BuildStatement 汇总 [start, end) 内的支付、充值日志、消费与退款，生成账单（不保存）。
+// 组织账单只包含组织令牌产生的消费与退款，组织额度由成员转入，没有支付记录。
+func BuildStatement(subjectType string, subjectId int, period string, start int64, end int64) (*Statement, error) {
+	statement := &Statement{
+		SubjectType: subjectType,
+		SubjectId:   subjectId,
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: common.GetTimestamp(),
+		Details:     &StatementDetail{},
+	}
+	var logScope func(tx *gorm.DB) *gorm.DB
+	switch subjectType {
+	case StatementSubjectUser:
+		user, err := GetUserById(subjectId, false)
+		if err != nil {
+			return nil, err
+		}
+		statement.SubjectName = user.Username
+		// 组织令牌的用量计入组织账单，不重复计入成员的个人账单
+		logScope = func(tx *gorm.DB) *gorm.DB { return tx.Where("user_id = ? AND organization_id = 0", subjectId) }
+		if err := fillStatementPayments(statement); err != nil {
+			return nil, err
+		}
+	case StatementSubjectOrganization:
+		org, err := GetOrganizationById(subjectId)
+		if err != nil {
+			return nil, err
+		}
+		statement.SubjectName = org.Name
+		logScope = func(tx *gorm.DB) *gorm.DB { return tx.Where("organization_id = ?", subjectId) }
+	default:
+		return nil, errors.New("未知的账单对象类型")
+	}
+	rangeScope := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("created_at >= ? AND created_at < ?", start, end)
+	}
+
+	if subjectType == StatementSubjectUser {
+		err := LOG_DB.Model(&Log{}).Select("created_at, content").
+			Scopes(logScope, rangeScope).Where("type = ?", LogTypeTopup).
+			Order("id").Limit(statementMaxTopupEvents).
+			Scan(&statement.Details.TopupEvents).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := LOG_DB.Model(&Log{}).
+		Select("model_name, token_name, COUNT(*) AS requests, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, SUM(quota) AS quota").
+		Scopes(logScope, rangeScope).Where("type = ?", LogTypeConsume).
+		Group("model_name, token_name").Order("quota desc").
+		Scan(&statement.Details.Usage).Error
+	if err != nil {
+		return nil, err
+	}
+	err = LOG_DB.Model(&Log{}).
+		Select("model_name, COUNT(*) AS count, SUM(quota) AS quota").
+		Scopes(logScope, rangeScope).Where("type = ?", LogTypeRefund).
+		Group("model_name").Order("quota desc").
+		Scan(&statement.Details.Refunds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range statement.Details.Usage {
+		statement.RequestCount += line.Requests
+		statement.ConsumedQuota += line.Quota
+	}
+	for _, line := range statement.Details.Refunds {
+		statement.RefundedQuota += line.Quota
+	}
+	return statement, nil
+}
+
+// fillStatementPayments 填充账期内已完成的支付，与订阅订单同单号的支付计为订阅费用
+func fillStatementPayments(statement *Statement) error {
+	var topUps []*TopUp
+	err := DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?",
+		statement.SubjectId, common.TopUpStatusSuccess, statement.PeriodStart, statement.PeriodEnd).
+		Order("complete_time").Find(&topUps).Error
+	if err != nil {
+		return err
+	}
+	if len(topUps) == 0 {
+		return nil
+	}
+	tradeNos := make([]string, 0, len(topUps))
+	for _, topUp := range topUps {
+		tradeNos = append(tradeNos, topUp.TradeNo)
+	}
+	var subscriptionTradeNos []string
+	err = DB.Model(&SubscriptionOrder{}).Where("trade_no IN ?", tradeNos).Pluck("trade_no", &subscriptionTradeNos).Error
+	if err != nil {
+		return err
+	}
+	isSubscription := make(map[string]bool, len(subscriptionTradeNos))
+	for _, tradeNo := range subscriptionTradeNos {
+		isSubscription[tradeNo] = true
+	}
+	for _, topUp := range topUps {
+		payment := StatementPayment{
+			TradeNo:       topUp.TradeNo,
+			PaymentMethod: topUp.PaymentMethod,
+			Amount:        topUp.Amount,
+			Money:         topUp.Money,
+			CompleteTime:  topUp.CompleteTime,
+			Subscription:  isSubscription[topUp.TradeNo],
+		}
+		if payment.Subscription {
+			statement.SubscriptionMoney += payment.Money
+		} else {
+			statement.PaymentMoney += payment.Money
+		}
+		statement.Details.Payments = append(statement.Details.Payments, payment)
+	}
+	return nil
+}
+
+// SaveStatement 保存账单快照，覆盖同一对象同一月份的旧账单
+func SaveStatement(statement *Statement) error {
+	detail, err := common.Marshal(statement.Details)
+	if err != nil {
+		return err
+	}
+	statement.Detail = string(detail)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("subject_type = ? AND subject_id = ? AND period = ?", statement.SubjectType, statement.SubjectId, statement.Period).
+			Delete(&Statement{}).Error
+		if err != nil {
+			return err
+		}
+		statement.Id = 0
+		return tx.Create(statement).Error
+	})
+}
+
+// GetStatement 读取已保存的账单快照，不存在时返回 gorm.ErrRecordNotFound
+func GetStatement(subjectType string, subjectId int, period string) (*Statement, error) {
+	var statement Statement
+	err := DB.Where("subject_type = ? AND subject_id = ? AND period = ?", subjectType, subjectId, period).First(&statement).Error
+	if err != nil {
+		return nil, err
+	}
+	statement.Details = &StatementDetail{}
+	if statement.Detail != "" {
+		if err := common.UnmarshalJsonStr(statement.Detail, statement.Details); err != nil {
+			return nil, err
+		}
+	}
+	return &statement, nil
+}
+
+// GetStatementSubjects 返回 [start, end) 内有消费、退款、充值或支付记录的用户与组织
+func GetStatementSubjects(start int64, end int64) (userIds []int, orgIds []int, err error) {
+	rangeScope := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("created_at >= ? AND created_at < ?", start, end)
+	}
+	err = LOG_DB.Model(&Log{}).Scopes(rangeScope).
+		Where("organization_id = 0 AND type IN ?", []int{LogTypeConsume, LogTypeRefund, LogTypeTopup}).
+		Distinct("user_id").Pluck("user_id", &userIds).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	var payers []int
+	err = DB.Model(&TopUp{}).Where("status = ? AND complete_time >= ? AND complete_time < ?", common.TopUpStatusSuccess, start, end).
+		Distinct("user_id").Pluck("user_id", &payers).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[int]bool, len(userIds))
+	for _, id := range userIds {
+		seen[id] = true
+	}
+	for _, id := range payers {
+		if !seen[id] {
+			seen[id] = true
+			userIds = append(userIds, id)
+		}
+	}
+	err = LOG_DB.Model(&Log{}).Scopes(rangeScope).
+		Where("organization_id > 0 AND type IN ?", []int{LogTypeConsume, LogTypeRefund}).
+		Distinct("organization_id").Pluck("organization_id", &orgIds).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return userIds, orgIds, nil
+}
+
+// GetSavedStatementSubjectIds 返回指定月份已保存快照的对象 ID
+func GetSavedStatementSubjectIds(subjectType string, period string) ([]int, error) {
+	var ids []int
+	err := DB.Model(&Statement{}).Where("subject_type = ? AND period = ?", subjectType, period).Pluck("subject_id", &ids).Error
+	return ids, err
+}
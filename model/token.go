@@ -3,36 +3,47 @@ package model
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/bytedance/gopkg/util/gopool"
 	"gorm.io/gorm"
 )
 
 type Token struct {
-	Id                 int            `json:"id"`
-	UserId             int            `json:"user_id" gorm:"index"`
-	Key                string         `json:"key" gorm:"type:char(48);uniqueIndex"`
-	Status             int            `json:"status" gorm:"default:1"`
-	Name               string         `json:"name" gorm:"index" `
-	CreatedTime        int64          `json:"created_time" gorm:"bigint"`
-	AccessedTime       int64          `json:"accessed_time" gorm:"bigint"`
-	ExpiredTime        int64          `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
-	RemainQuota        int            `json:"remain_quota" gorm:"default:0"`
-	UnlimitedQuota     bool           `json:"unlimited_quota"`
-	ModelLimitsEnabled bool           `json:"model_limits_enabled"`
-	ModelLimits        string         `json:"model_limits" gorm:"type:varchar(1024);default:''"`
-	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
-	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
-	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
-	DeletedAt          gorm.DeletedAt `gorm:"index"`
+	Id                     int            `json:"id"`
+	UserId                 int            `json:"user_id" gorm:"index"`
+	Key                    string         `json:"-" gorm:"type:char(48);uniqueIndex"` // 密钥摘要，见 HashTokenKey
+	KeyPrefix              string         `json:"key_prefix" gorm:"type:varchar(16);default:''"`
+	PlainKey               string         `json:"key,omitempty" gorm:"-"` // 明文密钥，仅在创建或重置时返回一次
+	Status                 int            `json:"status" gorm:"default:1"`
+	Name                   string         `json:"name" gorm:"index" `
+	CreatedTime            int64          `json:"created_time" gorm:"bigint"`
+	AccessedTime           int64          `json:"accessed_time" gorm:"bigint"`
+	ExpiredTime            int64          `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	RemainQuota            int            `json:"remain_quota" gorm:"default:0"`
+	UnlimitedQuota         bool           `json:"unlimited_quota"`
+	ModelLimitsEnabled     bool           `json:"model_limits_enabled"`
+	ModelLimits            string         `json:"model_limits" gorm:"type:varchar(1024);default:''"`
+	AllowIps               *string        `json:"allow_ips" gorm:"default:''"`
+	UsedQuota              int            `json:"used_quota" gorm:"default:0"` // used quota
+	Group                  string         `json:"group" gorm:"default:''"`
+	CrossGroupRetry        bool           `json:"cross_group_retry"`                          // 跨分组重试，仅auto分组有效
+	Scopes                 string         `json:"scopes" gorm:"type:varchar(255);default:''"` // 逗号分隔，为空表示不限制
+	PreviousKey            string         `json:"-" gorm:"type:varchar(48);index;default:''"` // 轮换前的密钥摘要，宽限期内仍可使用
+	PreviousKeyExpiredTime int64          `json:"previous_key_expired_time" gorm:"bigint;default:0;index"`
+	ParentTokenId          int            `json:"parent_token_id" gorm:"index;default:0"` // 通过父令牌签发的子令牌，额度从父令牌中划出
+	OrganizationId         int            `json:"organization_id" gorm:"index;default:0"` // 组织令牌，消耗从组织额度中扣除
+	DeletedAt              gorm.DeletedAt `gorm:"index"`
 }
 
 func (token *Token) Clean() {
 	token.Key = ""
+	token.PlainKey = ""
+	token.PreviousKey = ""
 }
 
 func (token *Token) GetIpLimits() []string {
@@ -145,7 +156,7 @@ func SearchUserTokens(userId int, keyword string, token string, offset int, limi
 		if err != nil {
 			return nil, 0, err
 		}
-		baseQuery = baseQuery.Where(commonKeyCol+" LIKE ? ESCAPE '!'", tokenPattern)
+		baseQuery = baseQuery.Where("("+commonKeyCol+" = ? OR key_prefix LIKE ? ESCAPE '!')", HashTokenKey(token), tokenPattern)
 	}
 
 	// 先查匹配总数（用于分页，受 maxTokens 上限保护，避免全表 COUNT）
@@ -240,7 +251,13 @@ func GetTokenById(id int) (*Token, error) {
 	return &token, err
 }
 
+// GetTokenByKey 通过明文密钥获取令牌
 func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
+	return GetTokenByKeyHash(HashTokenKey(key), fromDB)
+}
+
+// GetTokenByKeyHash 通过密钥摘要（即 Token.Key）获取令牌
+func GetTokenByKeyHash(keyHash string, fromDB bool) (token *Token, err error) {
 	defer func() {
 		// Update Redis cache asynchronously on successful DB read
 		if shouldUpdateRedis(fromDB, err) && token != nil {
@@ -253,14 +270,19 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	}()
 	if !fromDB && common.RedisEnabled {
 		// Try Redis first
-		token, err := cacheGetTokenByKey(key)
+		token, err := cacheGetTokenByKey(keyHash)
 		if err == nil {
 			return token, nil
 		}
 		// Don't return error - fall through to DB
 	}
 	fromDB = true
-	err = DB.Where(commonKeyCol+" = ?", key).First(&token).Error
+	err = DB.Where(commonKeyCol+" = ?", keyHash).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if previous, previousErr := getTokenByPreviousKeyHash(keyHash); previousErr == nil {
+			return previous, nil
+		}
+	}
 	return token, err
 }
 
@@ -283,7 +305,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "key").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "scopes", "key", "key_prefix").Updates(token).Error
 	return err
 }
 
@@ -303,6 +325,7 @@ func (token *Token) SelectUpdate() (err error) {
 }
 
 func (token *Token) Delete() (err error) {
+	var childKeys []string
 	defer func() {
 		if shouldUpdateRedis(true, err) {
 			gopool.Go(func() {
@@ -310,10 +333,19 @@ func (token *Token) Delete() (err error) {
 				if err != nil {
 					common.SysLog("failed to delete token cache: " + err.Error())
 				}
+				for _, key := range childKeys {
+					_ = cacheDeleteToken(key)
+				}
 			})
 		}
 	}()
-	err = DB.Delete(token).Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(token).Error; err != nil {
+			return err
+		}
+		childKeys, err = deleteChildTokensOf(tx, []int{token.Id})
+		return err
+	})
 	return err
 }
 
@@ -337,6 +369,42 @@ func (token *Token) GetModelLimitsMap() map[string]bool {
 	return limitsMap
 }
 
+func (token *Token) GetScopes() []constant.TokenScope {
+	scopes := make([]constant.TokenScope, 0)
+	for _, scope := range strings.Split(token.Scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope != "" {
+			scopes = append(scopes, constant.TokenScope(scope))
+		}
+	}
+	return scopes
+}
+
+// HasScope 判断令牌是否允许访问指定作用域，未配置作用域的令牌允许访问除 keys:manage 外的全部作用域
+func (token *Token) HasScope(scope constant.TokenScope) bool {
+	scopes := token.GetScopes()
+	if len(scopes) == 0 {
+		return scope != constant.TokenScopeKeysManage
+	}
+	return slices.Contains(scopes, scope)
+}
+
+// ValidateTokenScopes 校验并规范化作用域字符串，返回去重后的逗号分隔结果
+func ValidateTokenScopes(scopes string) (string, error) {
+	normalized := make([]string, 0)
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || slices.Contains(normalized, scope) {
+			continue
+		}
+		if !slices.Contains(constant.AllTokenScopes, constant.TokenScope(scope)) {
+			return "", fmt.Errorf("无效的令牌作用域: %s", scope)
+		}
+		normalized = append(normalized, scope)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
 func DisableModelLimits(tokenId int) error {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
@@ -446,6 +514,20 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 		return 0, err
 	}
 
+	deletedIds := make([]int, 0, len(tokens))
+	for _, t := range tokens {
+		deletedIds = append(deletedIds, t.Id)
+	}
+	childKeys := make([]string, 0)
+	if len(deletedIds) > 0 {
+		keys, err := deleteChildTokensOf(tx, deletedIds)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		childKeys = keys
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, err
 	}
@@ -455,8 +537,41 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 			for _, t := range tokens {
 				_ = cacheDeleteToken(t.Key)
 			}
+			for _, key := range childKeys {
+				_ = cacheDeleteToken(key)
+			}
 		})
 	}
 
 	return len(tokens), nil
 }
+
+// DisableUserTokens 禁用用户名下所有启用中的个人令牌并清除缓存，用于停用账号时立即阻断调用。
+// 组织令牌归属组织，不随成员停用而禁用，由 DeactivateUser 转交组织所有者
+func DisableUserTokens(userId int) (int64, error) {
+	var tokens []*Token
+	if err := DB.Where("user_id = ? AND organization_id = 0 AND status = ?", userId, common.TokenStatusEnabled).Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	ids := make([]int, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.Id)
+	}
+	result := DB.Model(&Token{}).Where("id IN ?", ids).Update("status", common.TokenStatusDisabled)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			for _, token := range tokens {
+				if err := cacheDeleteToken(token.Key); err != nil {
+					common.SysLog("failed to delete token cache: " + err.Error())
+				}
+			}
+		})
+	}
+	return result.RowsAffected, nil
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 )
 
+// 令牌缓存以密钥摘要（Token.Key）作为键，下列函数的 key 参数均为摘要而非明文
+
 func cacheSetToken(token Token) error {
-	key := common.GenerateHMAC(token.Key)
+	key := token.Key
 	token.Clean()
 	err := common.RedisHSetObj(fmt.Sprintf("token:%s", key), &token, time.Duration(common.RedisKeyCacheSeconds())*time.Second)
 	if err != nil {
@@ -19,7 +21,6 @@ func cacheSetToken(token Token) error {
 }
 
 func cacheDeleteToken(key string) error {
-	key = common.GenerateHMAC(key)
 	err := common.RedisDelKey(fmt.Sprintf("token:%s", key))
 	if err != nil {
 		return err
@@ -28,7 +29,6 @@ func cacheDeleteToken(key string) error {
 }
 
 func cacheIncrTokenQuota(key string, increment int64) error {
-	key = common.GenerateHMAC(key)
 	err := common.RedisHIncrBy(fmt.Sprintf("token:%s", key), constant.TokenFiledRemainQuota, increment)
 	if err != nil {
 		return err
@@ -41,7 +41,6 @@ func cacheDecrTokenQuota(key string, decrement int64) error {
 }
 
 func cacheSetTokenField(key string, field string, value string) error {
-	key = common.GenerateHMAC(key)
 	err := common.RedisHSetField(fmt.Sprintf("token:%s", key), field, value)
 	if err != nil {
 		return err
@@ -51,12 +50,11 @@ func cacheSetTokenField(key string, field string, value string) error {
 
 // CacheGetTokenByKey 从缓存中获取 token，如果缓存中不存在，则从数据库中获取
 func cacheGetTokenByKey(key string) (*Token, error) {
-	hmacKey := common.GenerateHMAC(key)
 	if !common.RedisEnabled {
 		return nil, fmt.Errorf("redis is not enabled")
 	}
 	var token Token
-	err := common.RedisHGetObj(fmt.Sprintf("token:%s", hmacKey), &token)
+	err := common.RedisHGetObj(fmt.Sprintf("token:%s", key), &token)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,252 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 子令牌由拥有 keys:manage 作用域的父令牌签发，归属于同一用户。
+// 子令牌的额度从父令牌的剩余额度中划出，删除时剩余额度退回父令牌；
+// 模型限制、作用域、分组和过期时间不能超出父令牌的范围。
+
+func GetChildTokens(parentId int, startIdx int, num int) ([]*Token, error) {
+	var tokens []*Token
+	err := DB.Where("parent_token_id = ?", parentId).Order("id desc").Limit(num).Offset(startIdx).Find(&tokens).Error
+	return tokens, err
+}
+
+func CountChildTokens(parentId int) (int64, error) {
+	var total int64
+	err := DB.Model(&Token{}).Where("parent_token_id = ?", parentId).Count(&total).Error
+	return total, err
+}
+
+func GetChildTokenById(id int, parentId int) (*Token, error) {
+	if id == 0 || parentId == 0 {
+		return nil, errors.New("id 或 parentId 为空！")
+	}
+	var token Token
+	err := DB.First(&token, "id = ? and parent_token_id = ?", id, parentId).Error
+	return &token, err
+}
+
+// CanIssueChildTokens 判断令牌能否签发子令牌，子令牌不能再签发子令牌
+func (token *Token) CanIssueChildTokens() bool {
+	return token.ParentTokenId == 0 && token.HasScope(constant.TokenScopeKeysManage)
+}
+
+// applyParentLimits 校验子令牌的设置不超出父令牌范围，并继承父令牌的分组等设置
+func (token *Token) applyParentLimits(parent *Token) error {
+	if !parent.CanIssueChildTokens() {
+		return errors.New("父令牌无权签发子令牌")
+	}
+	token.UserId = parent.UserId
+	token.ParentTokenId = parent.Id
+	token.OrganizationId = parent.OrganizationId
+	token.Group = parent.Group
+	token.CrossGroupRetry = parent.CrossGroupRetry
+
+	if token.UnlimitedQuota {
+		return errors.New("子令牌不能设置为无限额度")
+	}
+	if token.RemainQuota < 0 {
+		return errors.New("子令牌额度不能为负数")
+	}
+
+	if parent.ExpiredTime != -1 {
+		if token.ExpiredTime == -1 || token.ExpiredTime > parent.ExpiredTime {
+			return fmt.Errorf("子令牌的过期时间不能晚于父令牌 (%d)", parent.ExpiredTime)
+		}
+	}
+
+	if parent.ModelLimitsEnabled {
+		if !token.ModelLimitsEnabled {
+			token.ModelLimitsEnabled = true
+			token.ModelLimits = parent.ModelLimits
+		} else {
+			parentModels := parent.GetModelLimitsMap()
+			for _, model := range token.GetModelLimits() {
+				if !parentModels[model] {
+					return fmt.Errorf("子令牌不能使用父令牌未允许的模型: %s", model)
+				}
+			}
+		}
+	}
+
+	allowedScopes := make([]string, 0)
+	for _, scope := range parent.GetScopes() {
+		if scope != constant.TokenScopeKeysManage {
+			allowedScopes = append(allowedScopes, string(scope))
+		}
+	}
+	childScopes := token.GetScopes()
+	if slices.Contains(childScopes, constant.TokenScopeKeysManage) {
+		return errors.New("子令牌不能拥有 keys:manage 作用域")
+	}
+	if len(allowedScopes) > 0 {
+		if len(childScopes) == 0 {
+			token.Scopes = strings.Join(allowedScopes, ",")
+		}
+		for _, scope := range childScopes {
+			if !slices.Contains(allowedScopes, string(scope)) {
+				return fmt.Errorf("子令牌不能拥有父令牌未授予的作用域: %s", scope)
+			}
+		}
+	}
+	return nil
+}
+
+// transferParentQuota 从父令牌划出额度（amount 为负数时退回），无限额度的父令牌不做扣减
+func transferParentQuota(tx *gorm.DB, parent *Token, amount int) error {
+	if parent.UnlimitedQuota || amount == 0 {
+		return nil
+	}
+	if amount < 0 {
+		return tx.Model(&Token{}).Where("id = ?", parent.Id).
+			Update("remain_quota", gorm.Expr("remain_quota + ?", -amount)).Error
+	}
+	result := tx.Model(&Token{}).Where("id = ? AND remain_quota >= ?", parent.Id, amount).
+		Update("remain_quota", gorm.Expr("remain_quota - ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("父令牌剩余额度不足")
+	}
+	return nil
+}
+
+func syncParentQuotaCache(parent *Token, amount int) {
+	if !common.RedisEnabled || parent.UnlimitedQuota || amount == 0 {
+		return
+	}
+	key := parent.Key
+	gopool.Go(func() {
+		if err := cacheDecrTokenQuota(key, int64(amount)); err != nil {
+			common.SysLog("failed to update parent token quota cache: " + err.Error())
+		}
+	})
+}
+
+// InsertChildToken 在父令牌下创建子令牌，并从父令牌划出子令牌的额度
+func InsertChildToken(parent *Token, token *Token) error {
+	if err := token.applyParentLimits(parent); err != nil {
+		return err
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := transferParentQuota(tx, parent, token.RemainQuota); err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return err
+	}
+	syncParentQuotaCache(parent, token.RemainQuota)
+	return nil
+}
+
+// UpdateChildToken 更新子令牌，剩余额度的增减同步到父令牌。
+// 剩余额度以增量方式写入，避免覆盖更新期间子令牌产生的用量扣减
+func UpdateChildToken(parent *Token, token *Token) (err error) {
+	if err = token.applyParentLimits(parent); err != nil {
+		return err
+	}
+	var delta int
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var current Token
+		if err := tx.Select("remain_quota").First(&current, "id = ? AND parent_token_id = ?", token.Id, parent.Id).Error; err != nil {
+			return err
+		}
+		delta = token.RemainQuota - current.RemainQuota
+		if err := transferParentQuota(tx, parent, delta); err != nil {
+			return err
+		}
+		if err := tx.Model(token).Select("name", "status", "expired_time", "unlimited_quota",
+			"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "scopes").Updates(token).Error; err != nil {
+			return err
+		}
+		if delta != 0 {
+			if err := tx.Model(&Token{}).Where("id = ?", token.Id).
+				Update("remain_quota", gorm.Expr("remain_quota + ?", delta)).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Select("remain_quota").First(&current, "id = ?", token.Id).Error; err != nil {
+			return err
+		}
+		token.RemainQuota = current.RemainQuota
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	syncParentQuotaCache(parent, delta)
+	if common.RedisEnabled {
+		// 删除缓存而非整体写入，下次读取时从数据库加载，避免覆盖缓存中的额度扣减
+		key := token.Key
+		gopool.Go(func() {
+			if err := cacheDeleteToken(key); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+		})
+	}
+	return nil
+}
+
+// DeleteChildToken 吊销子令牌，未使用的额度退回父令牌
+func DeleteChildToken(parent *Token, token *Token) error {
+	refund := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var current Token
+		if err := tx.Select("remain_quota").First(&current, "id = ? AND parent_token_id = ?", token.Id, parent.Id).Error; err != nil {
+			return err
+		}
+		if current.RemainQuota > 0 {
+			refund = current.RemainQuota
+		}
+		if err := tx.Delete(token).Error; err != nil {
+			return err
+		}
+		return transferParentQuota(tx, parent, -refund)
+	})
+	if err != nil {
+		return err
+	}
+	syncParentQuotaCache(parent, -refund)
+	if common.RedisEnabled {
+		key := token.Key
+		gopool.Go(func() {
+			if err := cacheDeleteToken(key); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+		})
+	}
+	return nil
+}
+
+// deleteChildTokensOf 删除父令牌时一并吊销其子令牌，返回被删除子令牌的密钥摘要用于清理缓存
+func deleteChildTokensOf(tx *gorm.DB, parentIds []int) ([]string, error) {
+	var children []Token
+	if err := tx.Select("id", commonKeyCol).Where("parent_token_id IN (?)", parentIds).Find(&children).Error; err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("parent_token_id IN (?)", parentIds).Delete(&Token{}).Error; err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(children))
+	for _, child := range children {
+		keys = append(keys, child.Key)
+	}
+	return keys, nil
+}
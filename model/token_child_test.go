@@ -0,0 +1,75 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildTokenQuotaCarveOut(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	common.TokenKeySecret = "test-secret"
+
+	parent := &Token{UserId: 1, Name: "parent", Status: common.TokenStatusEnabled, ExpiredTime: -1,
+		RemainQuota: 1000, Scopes: "chat,keys:manage", ModelLimitsEnabled: true, ModelLimits: "gpt-4o,gpt-4o-mini"}
+	parent.SetKey("parentKey0123456789abcdefghijklmnopqrstuvwxyzAB")
+	require.NoError(t, parent.Insert())
+	assert.True(t, parent.CanIssueChildTokens())
+
+	child := &Token{Name: "child", Status: common.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: 300}
+	child.SetKey("childKey0123456789abcdefghijklmnopqrstuvwxyzABC")
+	require.NoError(t, InsertChildToken(parent, child))
+	assert.Equal(t, parent.Id, child.ParentTokenId)
+	assert.Equal(t, "chat", child.Scopes)
+	assert.Equal(t, parent.ModelLimits, child.ModelLimits)
+	assert.False(t, child.CanIssueChildTokens())
+
+	reloaded, err := GetTokenById(parent.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 700, reloaded.RemainQuota)
+
+	// 超出父令牌额度或权限范围的子令牌应被拒绝
+	tooLarge := &Token{Name: "large", ExpiredTime: -1, RemainQuota: 800}
+	tooLarge.SetKey("largeKey0123456789abcdefghijklmnopqrstuvwxyzABC")
+	assert.Error(t, InsertChildToken(parent, tooLarge))
+	escalated := &Token{Name: "escalated", ExpiredTime: -1, Scopes: string(constant.TokenScopeKeysManage)}
+	escalated.SetKey("escKey0123456789abcdefghijklmnopqrstuvwxyzABCDE")
+	assert.Error(t, InsertChildToken(parent, escalated))
+	otherModel := &Token{Name: "model", ExpiredTime: -1, ModelLimitsEnabled: true, ModelLimits: "claude-3"}
+	otherModel.SetKey("modelKey0123456789abcdefghijklmnopqrstuvwxyzABC")
+	assert.Error(t, InsertChildToken(parent, otherModel))
+
+	child.RemainQuota = 500
+	require.NoError(t, UpdateChildToken(parent, child))
+	reloaded, err = GetTokenById(parent.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 500, reloaded.RemainQuota)
+
+	require.NoError(t, DeleteChildToken(parent, child))
+	reloaded, err = GetTokenById(parent.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, reloaded.RemainQuota)
+}
+
+func TestDeleteParentRevokesChildTokens(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	common.TokenKeySecret = "test-secret"
+
+	parent := &Token{UserId: 1, Name: "parent", Status: common.TokenStatusEnabled, ExpiredTime: -1,
+		UnlimitedQuota: true, Scopes: "keys:manage"}
+	parent.SetKey("parentKey0123456789abcdefghijklmnopqrstuvwxyzAB")
+	require.NoError(t, parent.Insert())
+	child := &Token{Name: "child", Status: common.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: 100}
+	child.SetKey("childKey0123456789abcdefghijklmnopqrstuvwxyzABC")
+	require.NoError(t, InsertChildToken(parent, child))
+	assert.Equal(t, "", child.Scopes)
+
+	require.NoError(t, DeleteTokenById(parent.Id, 1))
+	_, err := GetTokenByKey("childKey0123456789abcdefghijklmnopqrstuvwxyzABC", true)
+	assert.Error(t, err)
+}
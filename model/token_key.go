@@ -0,0 +1,122 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+const (
+	// tokenKeyHashPrefix 标记已哈希的令牌密钥，"hmac:" + 43 位 base64 摘要恰好填满 char(48) 列，
+	// 避免 PostgreSQL 对 char 类型补齐空格，也无需修改旧表结构
+	tokenKeyHashPrefix = "hmac:"
+	// tokenKeyVisibleLength 令牌密钥对用户可见的前缀长度，用于识别令牌
+	tokenKeyVisibleLength = 8
+	// tokenKeySecretOptionKey 未配置 TOKEN_KEY_SECRET 时，自动生成的密钥在 options 表中的键名
+	tokenKeySecretOptionKey  = "TokenKeyHashSecret"
+	tokenKeyMigrateBatchSize = 500
+)
+
+// HashTokenKey 计算令牌密钥的存储形式，传入不带 sk- 前缀的明文密钥
+func HashTokenKey(key string) string {
+	h := hmac.New(sha256.New, []byte(common.TokenKeySecret))
+	h.Write([]byte(key))
+	return tokenKeyHashPrefix + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func isHashedTokenKey(key string) bool {
+	return strings.HasPrefix(key, tokenKeyHashPrefix)
+}
+
+func getTokenKeyPrefix(key string) string {
+	if len(key) <= tokenKeyVisibleLength {
+		return key
+	}
+	return key[:tokenKeyVisibleLength]
+}
+
+// SetKey 设置令牌的明文密钥，数据库中只保存摘要与可见前缀，明文仅保留在 PlainKey 中用于本次返回
+func (token *Token) SetKey(key string) {
+	token.Key = HashTokenKey(key)
+	token.KeyPrefix = getTokenKeyPrefix(key)
+	token.PlainKey = key
+}
+
+// IsTokenKeySecretReserved 判断选项键是否为令牌或渠道密钥的摘要密钥，这些选项不允许通过选项接口修改
+func IsTokenKeySecretReserved(key string) bool {
+	return key == tokenKeySecretOptionKey || key == channelKeyHashSecretOptionKey || key == channelKeyHashFingerprintOptionKey
+}
+
+// initTokenKeySecret 确保令牌摘要密钥可用：优先使用 TOKEN_KEY_SECRET 环境变量，
+// 否则从 options 表加载，主节点在首次启动时生成并持久化
+func initTokenKeySecret() error {
+	return initHashSecret(&common.TokenKeySecret, tokenKeySecretOptionKey, "TOKEN_KEY_SECRET")
+}
+
+// initHashSecret 确保摘要密钥可用：已由环境变量配置时直接返回，否则从 options 表加载，
+// 主节点在首次启动时生成并持久化
+func initHashSecret(secret *string, optionKey string, envName string) error {
+	if *secret != "" {
+		return nil
+	}
+	var option Option
+	err := DB.Where(&Option{Key: optionKey}).First(&option).Error
+	if err == nil && option.Value != "" {
+		*secret = option.Value
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if !common.IsMasterNode {
+		return fmt.Errorf("%s not found, please start the master node first or set %s", optionKey, envName)
+	}
+	generated, err := common.GenerateRandomCharsKey(64)
+	if err != nil {
+		return err
+	}
+	option = Option{Key: optionKey, Value: generated}
+	if err := DB.Save(&option).Error; err != nil {
+		return err
+	}
+	*secret = generated
+	common.SysLog(fmt.Sprintf("%s generated and saved to database, set %s to manage it yourself", optionKey, envName))
+	return nil
+}
+
+// migrateTokenKeys 将旧版本明文存储的令牌密钥转换为摘要，包括已软删除的令牌
+func migrateTokenKeys() error {
+	migrated := 0
+	for {
+		var tokens []Token
+		err := DB.Unscoped().Select("id", "key").
+			Where(commonKeyCol+" NOT LIKE ?", tokenKeyHashPrefix+"%").
+			Limit(tokenKeyMigrateBatchSize).Find(&tokens).Error
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			break
+		}
+		for _, token := range tokens {
+			err = DB.Unscoped().Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]interface{}{
+				"key":        HashTokenKey(token.Key),
+				"key_prefix": getTokenKeyPrefix(token.Key),
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to migrate key of token %d: %w", token.Id, err)
+			}
+		}
+		migrated += len(tokens)
+	}
+	if migrated > 0 {
+		common.SysLog(fmt.Sprintf("migrated %d plaintext token keys to hashed keys", migrated))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTokenKey_FitsKeyColumn(t *testing.T) {
+	common.TokenKeySecret = "test-secret"
+	hash := HashTokenKey("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUV")
+	assert.Len(t, hash, 48)
+	assert.True(t, isHashedTokenKey(hash))
+	assert.Equal(t, hash, HashTokenKey("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUV"))
+	assert.NotEqual(t, hash, HashTokenKey("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUW"))
+}
+
+func TestMigrateTokenKeys(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	common.TokenKeySecret = "test-secret"
+
+	plain := "legacyPlaintextKey0123456789abcdefghijklmnopqrst"
+	require.NoError(t, DB.Exec("INSERT INTO tokens (user_id, `key`, name, status) VALUES (?, ?, ?, ?)",
+		1, plain, "legacy", common.TokenStatusEnabled).Error)
+
+	require.NoError(t, migrateTokenKeys())
+
+	token, err := GetTokenByKey(plain, true)
+	require.NoError(t, err)
+	assert.Equal(t, HashTokenKey(plain), token.Key)
+	assert.Equal(t, "legacyPl", token.KeyPrefix)
+
+	// 再次迁移不应重复哈希
+	require.NoError(t, migrateTokenKeys())
+	token, err = GetTokenByKey(plain, true)
+	require.NoError(t, err)
+	assert.Equal(t, HashTokenKey(plain), token.Key)
+}
+
+func TestRotateTokenKey(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	common.TokenKeySecret = "test-secret"
+
+	token := &Token{UserId: 1, Name: "rotate", Status: common.TokenStatusEnabled}
+	token.SetKey("oldPlaintextKey0123456789abcdefghijklmnopqrstuv")
+	require.NoError(t, token.Insert())
+
+	newKey, err := token.RotateKey(3600)
+	require.NoError(t, err)
+	assert.NotEqual(t, "oldPlaintextKey0123456789abcdefghijklmnopqrstuv", newKey)
+	assert.True(t, token.HasValidPreviousKey())
+
+	// 新旧密钥在宽限期内都指向同一令牌，且 Key 均为当前密钥摘要
+	current, err := GetTokenByKey(newKey, true)
+	require.NoError(t, err)
+	assert.Equal(t, token.Id, current.Id)
+	previous, err := GetTokenByKey("oldPlaintextKey0123456789abcdefghijklmnopqrstuv", true)
+	require.NoError(t, err)
+	assert.Equal(t, token.Id, previous.Id)
+	assert.Equal(t, HashTokenKey(newKey), previous.Key)
+
+	// 宽限期结束后旧密钥自动失效
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", token.Id).
+		Update("previous_key_expired_time", common.GetTimestamp()-1).Error)
+	_, err = GetTokenByKey("oldPlaintextKey0123456789abcdefghijklmnopqrstuv", true)
+	assert.Error(t, err)
+
+	// 宽限期为 0 时旧密钥立即失效
+	rotatedKey, err := token.RotateKey(0)
+	require.NoError(t, err)
+	_, err = GetTokenByKey(newKey, true)
+	assert.Error(t, err)
+	_, err = GetTokenByKey(rotatedKey, true)
+	assert.NoError(t, err)
+}
+
+func TestPreviousKeyGraceRefresh(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	common.TokenKeySecret = "test-secret"
+	previousKeyGraceUntil.Store(0)
+	previousKeyGraceCheckedAt.Store(0)
+
+	assert.False(t, hasPreviousKeysInGrace())
+	// 刷新间隔内不再查询数据库
+	token := &Token{UserId: 1, Name: "remote", Status: common.TokenStatusEnabled}
+	token.SetKey("remotePlaintextKey0123456789abcdefghijklmnopqr")
+	token.PreviousKey = HashTokenKey("remoteOldKey0123456789abcdefghijklmnopqrstuvw")
+	token.PreviousKeyExpiredTime = common.GetTimestamp() + 3600
+	require.NoError(t, token.Insert())
+	assert.False(t, hasPreviousKeysInGrace())
+
+	// 模拟其他节点完成的轮换在刷新间隔后被感知
+	previousKeyGraceCheckedAt.Store(common.GetTimestamp() - previousKeyGraceRefreshSeconds)
+	assert.True(t, hasPreviousKeysInGrace())
+	previous, err := GetTokenByKey("remoteOldKey0123456789abcdefghijklmnopqrstuvw", true)
+	require.NoError(t, err)
+	assert.Equal(t, token.Id, previous.Id)
+}
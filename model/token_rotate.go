@@ -0,0 +1,142 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/samber/hot"
+	"gorm.io/gorm"
+)
+
+const (
+	// previousKeyUsageLogInterval 同一令牌使用旧密钥时，系统日志的最小记录间隔
+	previousKeyUsageLogInterval = 10 * time.Minute
+	// previousKeyUsageLogCapacity 旧密钥日志去重集合的容量上限
+	previousKeyUsageLogCapacity = 10000
+	// previousKeyGraceRefreshSeconds 从数据库刷新旧密钥宽限期截止时间的最小间隔，
+	// 其他节点轮换的令牌最多在该间隔后对本节点生效
+	previousKeyGraceRefreshSeconds = 30
+)
+
+// previousKeyUsageLogged 记录近期已写过旧密钥使用日志的令牌 ID，条目到期自动清除
+var previousKeyUsageLogged = hot.NewHotCache[int, struct{}](hot.LRU, previousKeyUsageLogCapacity).
+	WithTTL(previousKeyUsageLogInterval).
+	WithJanitor().
+	Build()
+
+// previousKeyGraceUntil 已知的最晚旧密钥失效时间，不晚于当前时间时跳过旧密钥查询，
+// 避免无效密钥的每次请求都额外查询 previous_key
+var previousKeyGraceUntil atomic.Int64
+
+// previousKeyGraceCheckedAt 上次从数据库刷新 previousKeyGraceUntil 的时间
+var previousKeyGraceCheckedAt atomic.Int64
+
+// notePreviousKeyGrace 记录新的旧密钥宽限期截止时间
+func notePreviousKeyGrace(expiredTime int64) {
+	for {
+		current := previousKeyGraceUntil.Load()
+		if expiredTime <= current || previousKeyGraceUntil.CompareAndSwap(current, expiredTime) {
+			return
+		}
+	}
+}
+
+// hasPreviousKeysInGrace 判断是否可能存在宽限期内的旧密钥。本地记录已过期时，
+// 每 previousKeyGraceRefreshSeconds 秒最多查询一次数据库以感知其他节点的轮换
+func hasPreviousKeysInGrace() bool {
+	now := common.GetTimestamp()
+	if previousKeyGraceUntil.Load() > now {
+		return true
+	}
+	checkedAt := previousKeyGraceCheckedAt.Load()
+	if now-checkedAt < previousKeyGraceRefreshSeconds || !previousKeyGraceCheckedAt.CompareAndSwap(checkedAt, now) {
+		return false
+	}
+	var latest int64
+	err := DB.Model(&Token{}).Where("previous_key_expired_time > ?", now).
+		Select("COALESCE(MAX(previous_key_expired_time), 0)").Scan(&latest).Error
+	if err != nil {
+		common.SysLog("failed to load previous key grace period: " + err.Error())
+		// 查询失败时保守地认为存在旧密钥，避免拒绝宽限期内的请求
+		return true
+	}
+	notePreviousKeyGrace(latest)
+	return latest > now
+}
+
+// RotateKey 为令牌生成新密钥，旧密钥在 gracePeriod 秒内仍然有效，gracePeriod 为 0 时旧密钥立即失效。
+// 宽限期内再次轮换时，更早的旧密钥会被新的旧密钥替换并立即失效。
+// 返回新的明文密钥，同时保存在 token.PlainKey 中
+func (token *Token) RotateKey(gracePeriod int64) (string, error) {
+	if gracePeriod < 0 {
+		return "", errors.New("宽限期不能为负数")
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	oldKey := token.Key
+	if gracePeriod > 0 {
+		token.PreviousKey = oldKey
+		token.PreviousKeyExpiredTime = common.GetTimestamp() + gracePeriod
+	} else {
+		token.PreviousKey = ""
+		token.PreviousKeyExpiredTime = 0
+	}
+	token.SetKey(key)
+	err = DB.Model(token).Select("key", "key_prefix", "previous_key", "previous_key_expired_time").Updates(token).Error
+	if err != nil {
+		return "", err
+	}
+	if gracePeriod > 0 {
+		notePreviousKeyGrace(token.PreviousKeyExpiredTime)
+	}
+	if common.RedisEnabled {
+		cached := *token
+		gopool.Go(func() {
+			if err := cacheDeleteToken(oldKey); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+			if err := cacheSetToken(cached); err != nil {
+				common.SysLog("failed to update token cache: " + err.Error())
+			}
+		})
+	}
+	return key, nil
+}
+
+// HasValidPreviousKey 判断令牌是否存在仍在宽限期内的旧密钥
+func (token *Token) HasValidPreviousKey() bool {
+	return token.PreviousKey != "" && token.PreviousKeyExpiredTime > common.GetTimestamp()
+}
+
+// getTokenByPreviousKeyHash 通过宽限期内的旧密钥摘要查找令牌。旧密钥不写入缓存，
+// 返回的令牌 Key 为当前密钥摘要，以保证额度缓存的一致性
+func getTokenByPreviousKeyHash(keyHash string) (*Token, error) {
+	if !hasPreviousKeysInGrace() {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var token *Token
+	err := DB.Where("previous_key = ? AND previous_key_expired_time > ?", keyHash, common.GetTimestamp()).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	logPreviousKeyUsage(token)
+	return token, nil
+}
+
+func logPreviousKeyUsage(token *Token) {
+	if previousKeyUsageLogged.Has(token.Id) {
+		return
+	}
+	previousKeyUsageLogged.Set(token.Id, struct{}{})
+	userId, tokenId, name := token.UserId, token.Id, token.Name
+	expiredAt := time.Unix(token.PreviousKeyExpiredTime, 0).Format("2006-01-02 15:04:05")
+	gopool.Go(func() {
+		RecordLog(userId, LogTypeSystem, fmt.Sprintf("令牌 %s (ID: %d) 正在使用轮换前的旧密钥，旧密钥将于 %s 失效，请尽快更换为新密钥", name, tokenId, expiredAt))
+	})
+}
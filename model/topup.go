@@ -86,7 +86,11 @@ func Recharge(referenceId string, customerId string) (err error) {
 		}
 
 		quota = topUp.Money * common.QuotaPerUnit
-		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
+		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("stripe_customer", customerId).Error
+		if err != nil {
+			return err
+		}
+		err = changeUserQuotaTx(tx, topUp.UserId, int(quota), QuotaLedgerRef{Source: QuotaSourceTopUp, RefId: topUp.TradeNo})
 		if err != nil {
 			return err
 		}
@@ -289,7 +293,7 @@ func ManualCompleteTopUp(tradeNo string) error {
 		}
 
 		// 增加用户额度（立即写库，保持一致性）
-		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
+		if err := changeUserQuotaTx(tx, topUp.UserId, quotaToAdd, QuotaLedgerRef{Source: QuotaSourceTopUp, RefId: topUp.TradeNo}); err != nil {
 			return err
 		}
 
@@ -339,11 +343,13 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 		// Creem 直接使用 Amount 作为充值额度（整数）
 		quota = topUp.Amount
 
-		// 构建更新字段，优先使用邮箱，如果邮箱为空则使用用户名
-		updateFields := map[string]interface{}{
-			"quota": gorm.Expr("quota + ?", quota),
+		err = changeUserQuotaTx(tx, topUp.UserId, int(quota), QuotaLedgerRef{Source: QuotaSourceTopUp, RefId: topUp.TradeNo})
+		if err != nil {
+			return err
 		}
 
+		updateFields := map[string]interface{}{}
+
 		// 如果有客户邮箱，尝试更新用户邮箱（仅当用户邮箱为空时）
 		if customerEmail != "" {
 			// 先检查用户当前邮箱是否为空
@@ -359,9 +365,11 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 			}
 		}
 
-		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(updateFields).Error
-		if err != nil {
-			return err
+		if len(updateFields) > 0 {
+			err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(updateFields).Error
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -0,0 +1,283 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// UsageRollup 按小时预聚合的用量数据，维度为用户、令牌、模型、渠道与分组，供看板查询，避免扫描 logs 表。
+// Requests 为成功计费的请求与失败的渠道尝试之和，Errors 为其中失败的部分；LatencyMs 为耗时总和。
+type UsageRollup struct {
+	Id               int    `json:"id"`
+	BucketStart      int64  `json:"bucket_start" gorm:"bigint;uniqueIndex:idx_usage_rollup_key,priority:1;index:idx_usage_rollup_user,priority:2"`
+	UserId           int    `json:"user_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:2;index:idx_usage_rollup_user,priority:1"`
+	TokenId          int    `json:"token_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:3"`
+	ModelName        string `json:"model_name" gorm:"size:128;default:'';uniqueIndex:idx_usage_rollup_key,priority:4"`
+	ChannelId        int    `json:"channel_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:5"`
+	Group            string `json:"group" gorm:"size:64;default:'';uniqueIndex:idx_usage_rollup_key,priority:6"`
+	Requests         int64  `json:"requests" gorm:"default:0"`
+	Errors           int64  `json:"errors" gorm:"default:0"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"default:0"`
+	CachedTokens     int64  `json:"cached_tokens" gorm:"default:0"`
+	Quota            int64  `json:"quota" gorm:"default:0"`
+	LatencyMs        int64  `json:"latency_ms" gorm:"default:0"`
+}
+
+const usageRollupFlushInterval = time.Minute
+
+var (
+	usageRollupCache     = make(map[string]*UsageRollup)
+	usageRollupCacheLock sync.Mutex
+)
+
+func usageRollupKey(r *UsageRollup) string {
+	return fmt.Sprintf("%d-%d-%d-%s-%d-%s", r.BucketStart, r.UserId, r.TokenId, r.ModelName, r.ChannelId, r.Group)
+}
+
+// RecordUsageRollup 将一次用量累加到内存中对应小时的聚合行，由 UpdateUsageRollups 定期写入数据库
+func RecordUsageRollup(delta UsageRollup, createdAt int64) {
+	if delta.Requests == 0 && delta.Errors == 0 && delta.Quota == 0 {
+		return
+	}
+	delta.Id = 0
+	delta.BucketStart = createdAt - createdAt%3600
+
+	usageRollupCacheLock.Lock()
+	defer usageRollupCacheLock.Unlock()
+	mergeUsageRollup(&delta)
+}
+
+func mergeUsageRollup(delta *UsageRollup) {
+	key := usageRollupKey(delta)
+	row, ok := usageRollupCache[key]
+	if !ok {
+		row = &UsageRollup{
+			BucketStart: delta.BucketStart,
+			UserId:      delta.UserId,
+			TokenId:     delta.TokenId,
+			ModelName:   delta.ModelName,
+			ChannelId:   delta.ChannelId,
+			Group:       delta.Group,
+		}
+		usageRollupCache[key] = row
+	}
+	row.Requests += delta.Requests
+	row.Errors += delta.Errors
+	row.PromptTokens += delta.PromptTokens
+	row.CompletionTokens += delta.CompletionTokens
+	row.CachedTokens += delta.CachedTokens
+	row.Quota += delta.Quota
+	row.LatencyMs += delta.LatencyMs
+}
+
+func usageRollupOtherInt(other map[string]interface{}, key string) int64 {
+	switch v := other[key].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// UpdateUsageRollups 定期将内存中的聚合数据写入数据库，每个节点各自写入自己的增量
+func UpdateUsageRollups() {
+	for {
+		time.Sleep(usageRollupFlushInterval)
+		FlushUsageRollups()
+	}
+}
+
+// FlushUsageRollups 将内存中的聚合数据累加到数据库，写入失败的行放回内存等待下次重试
+func FlushUsageRollups() {
+	usageRollupCacheLock.Lock()
+	pending := usageRollupCache
+	usageRollupCache = make(map[string]*UsageRollup)
+	usageRollupCacheLock.Unlock()
+
+	var failed []*UsageRollup
+	for _, row := range pending {
+		if err := upsertUsageRollup(row); err != nil {
+			common.SysError(fmt.Sprintf("failed to save usage rollup: %s", err.Error()))
+			failed = append(failed, row)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	usageRollupCacheLock.Lock()
+	defer usageRollupCacheLock.Unlock()
+	for _, row := range failed {
+		mergeUsageRollup(row)
+	}
+}
+
+func upsertUsageRollup(row *UsageRollup) error {
+	increase := func() (int64, error) {
+		result := DB.Model(&UsageRollup{}).
+			Where("bucket_start = ? AND user_id = ? AND token_id = ? AND model_name = ? AND channel_id = ? AND "+commonGroupCol+" = ?",
+				row.BucketStart, row.UserId, row.TokenId, row.ModelName, row.ChannelId, row.Group).
+			Updates(map[string]interface{}{
+				"requests":          gorm.Expr("requests + ?", row.Requests),
+				"errors":            gorm.Expr("errors + ?", row.Errors),
+				"prompt_tokens":     gorm.Expr("prompt_tokens + ?", row.PromptTokens),
+				"completion_tokens": gorm.Expr("completion_tokens + ?", row.CompletionTokens),
+				"cached_tokens":     gorm.Expr("cached_tokens + ?", row.CachedTokens),
+				"quota":             gorm.Expr("quota + ?", row.Quota),
+				"latency_ms":        gorm.Expr("latency_ms + ?", row.LatencyMs),
+			})
+		return result.RowsAffected, result.Error
+	}
+	affected, err := increase()
+	if err != nil || affected > 0 {
+		return err
+	}
+	insert := *row
+	insert.Id = 0
+	createErr := DB.Create(&insert).Error
+	if createErr == nil {
+		return nil
+	}
+	// 其他节点可能同时插入了同一行，再尝试累加一次
+	affected, err = increase()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return createErr
+	}
+	return nil
+}
+
+// 可用于分组的维度及其列名
+var usageRollupDimensions = map[string]string{
+	"user":    "user_id",
+	"token":   "token_id",
+	"model":   "model_name",
+	"channel": "channel_id",
+	"group":   "group",
+}
+
+const usageRollupMaxRows = 5000
+
+// UsageRollupQuery 聚合查询条件。Bucket 为时间分桶的秒数（0 表示不按时间分桶，需为 3600 的整数倍），
+// TzOffset 为分桶时使用的时区偏移秒数，使按天分桶对齐到本地零点。
+type UsageRollupQuery struct {
+	StartTime int64
+	EndTime   int64
+	Bucket    int64
+	TzOffset  int64
+	GroupBy   []string
+	UserId    int
+	TokenId   int
+	ModelName string
+	ChannelId int
+	Group     string
+}
+
+// UsageRollupRow 聚合查询结果，未参与分组的维度为空
+type UsageRollupRow struct {
+	Bucket           *int64  `json:"bucket,omitempty"`
+	UserId           *int    `json:"user_id,omitempty"`
+	TokenId          *int    `json:"token_id,omitempty"`
+	ModelName        *string `json:"model_name,omitempty"`
+	ChannelId        *int    `json:"channel_id,omitempty"`
+	Group            *string `json:"group,omitempty"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CachedTokens     int64   `json:"cached_tokens"`
+	Quota            int64   `json:"quota"`
+	LatencyMs        int64   `json:"latency_ms"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms" gorm:"-"`
+}
+
+// QueryUsageRollups 按条件对小时聚合数据再次分组汇总
+func QueryUsageRollups(query UsageRollupQuery) ([]*UsageRollupRow, error) {
+	if query.Bucket < 0 || query.Bucket%3600 != 0 {
+		return nil, errors.New("时间分桶必须是整小时")
+	}
+	selects := []string{
+		"SUM(requests) AS requests",
+		"SUM(errors) AS errors",
+		"SUM(prompt_tokens) AS prompt_tokens",
+		"SUM(completion_tokens) AS completion_tokens",
+		"SUM(cached_tokens) AS cached_tokens",
+		"SUM(quota) AS quota",
+		"SUM(latency_ms) AS latency_ms",
+	}
+	var groups []string
+	var selectArgs []interface{}
+	if query.Bucket > 0 {
+		selects = append(selects, "(bucket_start + ?) - ((bucket_start + ?) % ?) - ? AS bucket")
+		selectArgs = append(selectArgs, query.TzOffset, query.TzOffset, query.Bucket, query.TzOffset)
+		groups = append(groups, "bucket")
+	}
+	seen := make(map[string]bool)
+	for _, dimension := range query.GroupBy {
+		column, ok := usageRollupDimensions[dimension]
+		if !ok {
+			return nil, fmt.Errorf("不支持的分组维度: %s", dimension)
+		}
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		if column == "group" {
+			column = commonGroupCol
+		}
+		selects = append(selects, column)
+		groups = append(groups, column)
+	}
+
+	tx := DB.Model(&UsageRollup{}).Select(strings.Join(selects, ", "), selectArgs...)
+	if query.StartTime > 0 {
+		tx = tx.Where("bucket_start >= ?", query.StartTime-query.StartTime%3600)
+	}
+	if query.EndTime > 0 {
+		tx = tx.Where("bucket_start <= ?", query.EndTime)
+	}
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.TokenId != 0 {
+		tx = tx.Where("token_id = ?", query.TokenId)
+	}
+	if query.ModelName != "" {
+		tx = tx.Where("model_name = ?", query.ModelName)
+	}
+	if query.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", query.ChannelId)
+	}
+	if query.Group != "" {
+		tx = tx.Where(commonGroupCol+" = ?", query.Group)
+	}
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", "))
+	}
+	if query.Bucket > 0 {
+		tx = tx.Order("bucket")
+	}
+	var rows []*UsageRollupRow
+	err := tx.Order("quota desc").Limit(usageRollupMaxRows).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.Requests > 0 {
+			row.AvgLatencyMs = float64(row.LatencyMs) / float64(row.Requests)
+		}
+	}
+	return rows, nil
+}
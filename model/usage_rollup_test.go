@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageRollupFlushAndQuery(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM usage_rollups") })
+
+	const day = int64(1_700_006_400) // 2023-11-15 00:00:00 UTC
+	RecordUsageRollup(UsageRollup{UserId: 1, ModelName: "gpt-4o", Group: "default", Requests: 1, PromptTokens: 10, Quota: 100, LatencyMs: 200}, day+60)
+	RecordUsageRollup(UsageRollup{UserId: 1, ModelName: "gpt-4o", Group: "default", Requests: 1, PromptTokens: 20, Quota: 200, LatencyMs: 400}, day+120)
+	FlushUsageRollups()
+	// 第二次写入同一小时应累加到已有行
+	RecordUsageRollup(UsageRollup{UserId: 1, ModelName: "gpt-4o", Group: "default", Requests: 1, Errors: 1, LatencyMs: 300}, day+180)
+	RecordUsageRollup(UsageRollup{UserId: 2, ModelName: "claude", Group: "vip", Requests: 1, Quota: 50}, day+3600+5)
+	RecordUsageRollup(UsageRollup{UserId: 1, ModelName: "gpt-4o", Group: "default"}, day)
+	FlushUsageRollups()
+
+	var count int64
+	require.NoError(t, DB.Model(&UsageRollup{}).Count(&count).Error)
+	assert.EqualValues(t, 2, count)
+
+	rows, err := QueryUsageRollups(UsageRollupQuery{StartTime: day, EndTime: day + 86400, Bucket: 86400, GroupBy: []string{"model", "group"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, day, *rows[0].Bucket)
+	assert.Equal(t, "gpt-4o", *rows[0].ModelName)
+	assert.Equal(t, "default", *rows[0].Group)
+	assert.Nil(t, rows[0].UserId)
+	assert.EqualValues(t, 3, rows[0].Requests)
+	assert.EqualValues(t, 1, rows[0].Errors)
+	assert.EqualValues(t, 300, rows[0].Quota)
+	assert.InDelta(t, 300, rows[0].AvgLatencyMs, 0.001)
+
+	rows, err = QueryUsageRollups(UsageRollupQuery{StartTime: day, EndTime: day + 86400, Bucket: 3600, UserId: 2})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, day+3600, *rows[0].Bucket)
+	assert.EqualValues(t, 50, rows[0].Quota)
+
+	_, err = QueryUsageRollups(UsageRollupQuery{GroupBy: []string{"ip"}})
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/bytedance/gopkg/util/gopool"
 	"gorm.io/gorm"
@@ -32,6 +33,7 @@ type User struct {
 	GitHubId         string         `json:"github_id" gorm:"column:github_id;index"`
 	DiscordId        string         `json:"discord_id" gorm:"column:discord_id;index"`
 	OidcId           string         `json:"oidc_id" gorm:"column:oidc_id;index"`
+	SamlId           string         `json:"saml_id" gorm:"column:saml_id;index"`
 	WeChatId         string         `json:"wechat_id" gorm:"column:wechat_id;index"`
 	TelegramId       string         `json:"telegram_id" gorm:"column:telegram_id;index"`
 	VerificationCode string         `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
@@ -50,17 +52,26 @@ type User struct {
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	AdminRoleId      int            `json:"admin_role_id" gorm:"type:int;default:0;index"`                           // 自定义管理角色，0 表示按 Role 授权
+	ScimUserName     string         `json:"scim_user_name" gorm:"type:varchar(255);column:scim_user_name;index"`     // SCIM 下发的 userName，可能超出用户名长度限制
+	ScimExternalId   string         `json:"scim_external_id" gorm:"type:varchar(255);column:scim_external_id;index"` // SCIM 下发的 externalId
+	CreditLimit      int            `json:"credit_limit" gorm:"type:int;default:0"`                                  // 后付费信用额度，余额最多可透支到 -CreditLimit
+	CreditDunning    int            `json:"credit_dunning" gorm:"type:int;default:0"`                                // 本结算周期内已发送的最高催缴百分比
+	CreatedTime      int64          `json:"created_time" gorm:"bigint;default:0"`                                    // 注册时间，早期创建的用户为 0
+	CreditGrantUntil int64          `json:"-" gorm:"bigint;default:0"`                                               // 有效赠送额度的最晚过期时间，过期后钱包扣费不再分摊赠送额度
 }
 
 func (user *User) ToBaseUser() *UserBase {
 	cache := &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
+		Id:          user.Id,
+		Group:       user.Group,
+		Quota:       user.Quota,
+		Status:      user.Status,
+		Username:    user.Username,
+		Setting:     user.Setting,
+		Email:       user.Email,
+		CreditLimit: user.CreditLimit,
+		AdminRoleId: user.AdminRoleId,
 	}
 	return cache
 }
@@ -371,6 +382,9 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 	if err := tx.Save(user).Error; err != nil {
 		return err
 	}
+	if err := appendQuotaLedgerTx(tx, user.Id, QuotaLedgerRef{Source: QuotaSourceAffTransfer}.entry(int64(quota))); err != nil {
+		return err
+	}
 
 	// 提交事务
 	return tx.Commit().Error
@@ -385,6 +399,7 @@ func (user *User) Insert(inviterId int) error {
 		}
 	}
 	user.Quota = common.QuotaForNewUser
+	user.CreatedTime = common.GetTimestamp()
 	//user.SetAccessToken(common.GetUUID())
 	user.AffCode = common.GetRandomString(4)
 
@@ -395,9 +410,14 @@ func (user *User) Insert(inviterId int) error {
 		user.SetSetting(defaultSetting)
 	}
 
-	result := DB.Create(user)
-	if result.Error != nil {
-		return result.Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return user.recordSignupQuotaTx(tx)
+	})
+	if err != nil {
+		return err
 	}
 
 	// 用户创建成功后，根据角色初始化边栏配置
@@ -420,7 +440,7 @@ func (user *User) Insert(inviterId int) error {
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = grantPromotionQuota(user.Id, common.QuotaForInvitee, QuotaLedgerRef{Source: QuotaSourceInvite, RefId: strconv.Itoa(inviterId)}, operation_setting.CreditGrantSourceInvite)
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -444,6 +464,7 @@ func (user *User) InsertWithTx(tx *gorm.DB, inviterId int) error {
 		}
 	}
 	user.Quota = common.QuotaForNewUser
+	user.CreatedTime = common.GetTimestamp()
 	user.AffCode = common.GetRandomString(4)
 
 	// 初始化用户设置
@@ -457,7 +478,18 @@ func (user *User) InsertWithTx(tx *gorm.DB, inviterId int) error {
 		return result.Error
 	}
 
-	return nil
+	return user.recordSignupQuotaTx(tx)
+}
+
+// recordSignupQuotaTx 记录注册赠送额度的流水及其有效期
+func (user *User) recordSignupQuotaTx(tx *gorm.DB) error {
+	if user.Quota == 0 {
+		return nil
+	}
+	if err := appendQuotaLedgerTx(tx, user.Id, QuotaLedgerRef{Source: QuotaSourceSignup}.entry(int64(user.Quota))); err != nil {
+		return err
+	}
+	return createCreditGrantTx(tx, user.Id, operation_setting.CreditGrantSourceSignup, "", user.Quota)
 }
 
 // FinalizeOAuthUserCreation performs post-transaction tasks for OAuth user creation.
@@ -481,7 +513,7 @@ func (user *User) FinalizeOAuthUserCreation(inviterId int) {
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = grantPromotionQuota(user.Id, common.QuotaForInvitee, QuotaLedgerRef{Source: QuotaSourceInvite, RefId: strconv.Itoa(inviterId)}, operation_setting.CreditGrantSourceInvite)
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -530,10 +562,31 @@ func (user *User) Edit(updatePassword bool) error {
 		updates["password"] = newUser.Password
 	}
 
-	DB.First(&user, user.Id)
-	if err = DB.Model(user).Updates(updates).Error; err != nil {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var oldQuota int
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Model(&User{}).Where("id = ?", user.Id).
+			Select("quota").Scan(&oldQuota).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", user.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+		delta := newUser.Quota - oldQuota
+		if delta == 0 {
+			return nil
+		}
+		if err := appendQuotaLedgerTx(tx, user.Id, QuotaLedgerRef{Source: QuotaSourceAdmin}.entry(int64(delta))); err != nil {
+			return err
+		}
+		if delta > 0 {
+			return resetCreditDunningTx(tx, CreditSubjectUser, user.Id)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
+	DB.First(&user, user.Id)
 
 	// Update cache
 	return updateUserCache(*user)
@@ -549,6 +602,7 @@ func (user *User) ClearBinding(bindingType string) error {
 		"github":   "github_id",
 		"discord":  "discord_id",
 		"oidc":     "oidc_id",
+		"saml":     "saml_id",
 		"wechat":   "wechat_id",
 		"telegram": "telegram_id",
 		"linuxdo":  "linux_do_id",
@@ -577,6 +631,9 @@ func (user *User) Delete() error {
 	if err := DB.Delete(user).Error; err != nil {
 		return err
 	}
+	if err := removeUserFromOrganizations(user.Id); err != nil {
+		common.SysLog(fmt.Sprintf("failed to remove user %d from organizations: %s", user.Id, err.Error()))
+	}
 
 	// 清除缓存
 	return invalidateUserCache(user.Id)
@@ -657,6 +714,14 @@ func (user *User) FillUserByOidcId() error {
 	return nil
 }
 
+func (user *User) FillUserBySamlId() error {
+	if user.SamlId == "" {
+		return errors.New("saml id 为空！")
+	}
+	DB.Where(User{SamlId: user.SamlId}).First(user)
+	return nil
+}
+
 func (user *User) FillUserByWeChatId() error {
 	if user.WeChatId == "" {
 		return errors.New("WeChat id 为空！")
@@ -696,6 +761,10 @@ func IsOidcIdAlreadyTaken(oidcId string) bool {
 	return DB.Where("oidc_id = ?", oidcId).Find(&User{}).RowsAffected == 1
 }
 
+func IsSamlIdAlreadyTaken(samlId string) bool {
+	return DB.Where("saml_id = ?", samlId).Find(&User{}).RowsAffected == 1
+}
+
 func IsTelegramIdAlreadyTaken(telegramId string) bool {
 	return DB.Unscoped().Where("telegram_id = ?", telegramId).Find(&User{}).RowsAffected == 1
 }
@@ -871,7 +940,8 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
-func IncreaseUserQuota(id int, quota int, db bool) (err error) {
+// IncreaseUserQuota 增加用户额度并记录流水，db 为 false 且启用批量更新时延后落库
+func IncreaseUserQuota(id int, quota int, db bool, ref QuotaLedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -882,21 +952,14 @@ func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 		}
 	})
 	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
+		addUserQuotaRecord(id, quota, ref)
 		return nil
 	}
-	return increaseUserQuota(id, quota)
-}
-
-func increaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+	return changeUserQuota(id, quota, ref)
 }
 
-func DecreaseUserQuota(id int, quota int) (err error) {
+// DecreaseUserQuota 扣减用户额度并记录流水
+func DecreaseUserQuota(id int, quota int, ref QuotaLedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -907,28 +970,20 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 		}
 	})
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
+		addUserQuotaRecord(id, -quota, ref)
 		return nil
 	}
-	return decreaseUserQuota(id, quota)
+	return changeUserQuota(id, -quota, ref)
 }
 
-func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
-}
-
-func DeltaUpdateUserQuota(id int, delta int) (err error) {
+func DeltaUpdateUserQuota(id int, delta int, ref QuotaLedgerRef) (err error) {
 	if delta == 0 {
 		return nil
 	}
 	if delta > 0 {
-		return IncreaseUserQuota(id, delta, false)
+		return IncreaseUserQuota(id, delta, false, ref)
 	} else {
-		return DecreaseUserQuota(id, -delta)
+		return DecreaseUserQuota(id, -delta, ref)
 	}
 }
 
@@ -987,6 +1042,15 @@ func updateUserRequestCount(id int, count int) {
 	}
 }
 
+func GetUserIdByUsername(username string) (int, error) {
+	if username == "" {
+		return 0, errors.New("用户名为空！")
+	}
+	var user User
+	err := DB.Select("id").Where("username = ?", username).First(&user).Error
+	return user.Id, err
+}
+
 // GetUsernameById gets username from Redis first, falls back to DB if needed
 func GetUsernameById(id int, fromDB bool) (username string, err error) {
 	defer func() {
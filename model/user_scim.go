@@ -0,0 +1,108 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// ScimUserFilter SCIM 用户列表支持的等值过滤条件
+type ScimUserFilter struct {
+	UserName   string
+	ExternalId string
+	Email      string
+}
+
+// GetScimUsers 按 SCIM 过滤条件分页查询用户。
+// userName 优先匹配 SCIM 下发的原始值，未经 SCIM 创建的用户按用户名匹配
+func GetScimUsers(filter ScimUserFilter, startIdx int, num int) (users []*User, total int64, err error) {
+	tx := DB.Model(&User{})
+	if filter.UserName != "" {
+		tx = tx.Where("scim_user_name = ? OR (scim_user_name = '' AND username = ?)", filter.UserName, filter.UserName)
+	}
+	if filter.ExternalId != "" {
+		tx = tx.Where("scim_external_id = ?", filter.ExternalId)
+	}
+	if filter.Email != "" {
+		tx = tx.Where("email = ?", filter.Email)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Omit("password").Order("id asc").Limit(num).Offset(startIdx).Find(&users).Error
+	return users, total, err
+}
+
+// GetUsersByGroup 返回指定分组下的用户，仅包含 SCIM 组成员所需的字段
+func GetUsersByGroup(group string) (users []*User, err error) {
+	err = DB.Select("id", "username", "display_name", "scim_user_name").Where(commonGroupCol+" = ?", group).Order("id asc").Find(&users).Error
+	return users, err
+}
+
+// SetUsersGroup 将用户移入指定分组，并清除缓存以免为未缓存的用户写入不完整的缓存
+func SetUsersGroup(userIds []int, group string) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("id IN ?", userIds).Update("group", group).Error; err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err := invalidateUserCache(userId); err != nil {
+			common.SysLog(fmt.Sprintf("failed to invalidate user %d cache: %s", userId, err.Error()))
+		}
+	}
+	return nil
+}
+
+// RemoveUsersFromGroup 将仍处于该分组的用户移回默认分组，已被移到其他分组的用户不受影响
+func RemoveUsersFromGroup(userIds []int, group string) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	var ids []int
+	if err := DB.Model(&User{}).Where("id IN ? AND "+commonGroupCol+" = ?", userIds, group).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	return SetUsersGroup(ids, "default")
+}
+
+// DeactivateUser 停用用户并禁用其个人令牌，其在他人组织中的令牌转交组织所有者，返回被禁用的令牌数量
+func DeactivateUser(userId int) (int64, error) {
+	if userId == 0 {
+		return 0, errors.New("id 为空！")
+	}
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("status", common.UserStatusDisabled).Error; err != nil {
+		return 0, err
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysLog(fmt.Sprintf("failed to invalidate user %d cache: %s", userId, err.Error()))
+	}
+	if err := transferMemberOrganizationTokens(userId); err != nil {
+		return 0, err
+	}
+	return DisableUserTokens(userId)
+}
+
+// ActivateUser 重新启用用户，停用时禁用的令牌需由用户或管理员手动启用
+func ActivateUser(userId int) error {
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("status", common.UserStatusEnabled).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// UpdateScimAttributes 保存 SCIM 可修改的用户属性，允许写入空值
+func (user *User) UpdateScimAttributes() error {
+	err := DB.Model(user).Updates(map[string]interface{}{
+		"scim_user_name":   user.ScimUserName,
+		"scim_external_id": user.ScimExternalId,
+		"display_name":     user.DisplayName,
+		"email":            user.Email,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(user.Id)
+}
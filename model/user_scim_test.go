@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeactivateUserDisablesTokens(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "leaver", AffCode: "scim1", Status: common.UserStatusEnabled}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "stayer", AffCode: "scim2", Status: common.UserStatusEnabled}).Error)
+	for i, userId := range []int{1, 1, 2} {
+		token := &Token{UserId: userId, Name: "t", Status: common.TokenStatusEnabled}
+		token.SetKey(common.GetRandomString(48))
+		require.NoError(t, token.Insert(), i)
+	}
+	require.NoError(t, DB.Create(&User{Id: 3, Username: "org_owner", AffCode: "scim5", Status: common.UserStatusEnabled}).Error)
+	require.NoError(t, DB.Create(&Organization{Id: 7, Name: "acme", OwnerId: 3}).Error)
+	t.Cleanup(func() { DB.Exec("DELETE FROM organizations") })
+	orgKey := common.GetRandomString(48)
+	orgToken := &Token{UserId: 1, OrganizationId: 7, Name: "org", Status: common.TokenStatusEnabled, UnlimitedQuota: true}
+	orgToken.SetKey(orgKey)
+	require.NoError(t, orgToken.Insert())
+
+	disabled, err := DeactivateUser(1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, disabled)
+
+	user, err := GetUserById(1, false)
+	require.NoError(t, err)
+	assert.Equal(t, common.UserStatusDisabled, user.Status)
+
+	var enabled int64
+	DB.Model(&Token{}).Where("status = ?", common.TokenStatusEnabled).Count(&enabled)
+	assert.EqualValues(t, 2, enabled, "其他用户的令牌与组织令牌不受影响")
+
+	// 组织令牌转交组织所有者后仍能通过鉴权
+	token, err := ValidateUserToken(orgKey)
+	require.NoError(t, err)
+	assert.Equal(t, 3, token.UserId)
+	owner, err := GetUserCache(token.UserId)
+	require.NoError(t, err)
+	assert.Equal(t, common.UserStatusEnabled, owner.Status)
+
+	require.NoError(t, ActivateUser(1))
+	user, err = GetUserById(1, false)
+	require.NoError(t, err)
+	assert.Equal(t, common.UserStatusEnabled, user.Status)
+}
+
+func TestScimGroupMembership(t *testing.T) {
+	truncateTables(t)
+	initCol()
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "alice", AffCode: "scim3", ScimUserName: "alice@corp.example"}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "bob", AffCode: "scim4"}).Error)
+
+	users, total, err := GetScimUsers(ScimUserFilter{UserName: "alice@corp.example"}, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, 1, users[0].Id)
+	_, total, err = GetScimUsers(ScimUserFilter{UserName: "alice"}, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, total, "SCIM 用户按下发的 userName 匹配")
+	_, total, err = GetScimUsers(ScimUserFilter{UserName: "bob"}, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total, "未经 SCIM 创建的用户按用户名匹配")
+
+	require.NoError(t, SetUsersGroup([]int{1, 2}, "research"))
+	members, err := GetUsersByGroup("research")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	require.NoError(t, SetUsersGroup([]int{2}, "vip"))
+	require.NoError(t, RemoveUsersFromGroup([]int{1, 2}, "research"))
+	alice, err := GetUserById(1, false)
+	require.NoError(t, err)
+	assert.Equal(t, "default", alice.Group)
+	bob, err := GetUserById(2, false)
+	require.NoError(t, err)
+	assert.Equal(t, "vip", bob.Group, "已移到其他分组的用户不受影响")
+}
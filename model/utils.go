@@ -23,6 +23,9 @@ const (
 var batchUpdateStores []map[int]int
 var batchUpdateLocks []sync.Mutex
 
+// batchUserQuotaLedgers 批量更新模式下待写入的额度流水，与用户额度增量一起落库，由用户额度的锁保护
+var batchUserQuotaLedgers = make(map[int][]*QuotaLedger)
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int))
@@ -49,6 +52,37 @@ func addNewRecord(type_ int, id int, value int) {
 	}
 }
 
+func addUserQuotaRecord(id int, delta int, ref QuotaLedgerRef) {
+	entry := ref.entry(int64(delta))
+	entry.CreatedAt = common.GetTimestamp()
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	defer batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	batchUpdateStores[BatchUpdateTypeUserQuota][id] += delta
+	batchUserQuotaLedgers[id] = append(batchUserQuotaLedgers[id], entry)
+}
+
+// flushUserQuotaRecord 在同一事务中写入用户额度增量及期间累积的流水，并将其中的钱包扣费分摊到赠送额度
+func flushUserQuotaRecord(id int, delta int, entries []*QuotaLedger) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if delta != 0 {
+			err := tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta)).Error
+			if err != nil {
+				return err
+			}
+		}
+		consumed := int64(0)
+		for _, entry := range entries {
+			if consumesCreditGrants(entry.Source) {
+				consumed -= entry.Delta
+			}
+		}
+		if err := applyCreditGrantUsageTx(tx, id, int(consumed)); err != nil {
+			return err
+		}
+		return appendQuotaLedgerTx(tx, id, entries...)
+	})
+}
+
 func batchUpdate() {
 	// check if there's any data to update
 	hasData := false
@@ -71,12 +105,17 @@ func batchUpdate() {
 		batchUpdateLocks[i].Lock()
 		store := batchUpdateStores[i]
 		batchUpdateStores[i] = make(map[int]int)
+		var ledgers map[int][]*QuotaLedger
+		if i == BatchUpdateTypeUserQuota {
+			ledgers = batchUserQuotaLedgers
+			batchUserQuotaLedgers = make(map[int][]*QuotaLedger)
+		}
 		batchUpdateLocks[i].Unlock()
 		// TODO: maybe we can combine updates with same key?
 		for key, value := range store {
 			switch i {
 			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
+				err := flushUserQuotaRecord(key, value, ledgers[key])
 				if err != nil {
 					common.SysLog("failed to batch update user quota: " + err.Error())
 				}
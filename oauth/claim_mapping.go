@@ -0,0 +1,117 @@
+package oauth
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/tidwall/gjson"
+)
+
+// ClaimMappingExtraKey is the OAuthUser.Extra key holding the *ClaimMappingResult
+const ClaimMappingExtraKey = "claim_mapping"
+
+// ClaimMappingResult is what the matching claim mapping rules resolved to; zero values mean no rule applied
+type ClaimMappingResult struct {
+	Group string
+	Role  int
+	Quota *int
+}
+
+// IsEmpty reports whether no rule set any attribute
+func (r *ClaimMappingResult) IsEmpty() bool {
+	return r == nil || (r.Group == "" && r.Role == 0 && r.Quota == nil)
+}
+
+// GetClaimMappingResult returns the claim mapping result attached to the user info, or nil
+func GetClaimMappingResult(user *OAuthUser) *ClaimMappingResult {
+	if user == nil || user.Extra == nil {
+		return nil
+	}
+	result, _ := user.Extra[ClaimMappingExtraKey].(*ClaimMappingResult)
+	return result
+}
+
+// evaluateClaimMappings applies the rules in order against the raw user info JSON.
+// For each attribute the first matching rule that sets it wins. When any rule maps a role but none
+// matches, the role falls back to common user so that removing a claim in the IdP revokes the role.
+// Mapped roles are capped at common user unless root enabled claim_mapping.allow_admin_role.
+func evaluateClaimMappings(body string, email string, rules []model.ClaimMappingRule) *ClaimMappingResult {
+	result := &ClaimMappingResult{}
+	mapsRole := false
+	for _, rule := range rules {
+		if rule.Role != 0 {
+			mapsRole = true
+		}
+		if !matchClaimMappingRule(body, email, rule) {
+			continue
+		}
+		// the group may have been removed after the rule was saved
+		if result.Group == "" && rule.Group != "" && ratio_setting.ContainsGroupRatio(rule.Group) {
+			result.Group = rule.Group
+		}
+		if result.Role == 0 && rule.Role != 0 {
+			result.Role = rule.Role
+			if result.Role > common.RoleCommonUser && !system_setting.GetClaimMappingSettings().AllowAdminRole {
+				result.Role = common.RoleCommonUser
+			}
+		}
+		if result.Quota == nil && rule.Quota != nil {
+			quota := *rule.Quota
+			result.Quota = &quota
+		}
+	}
+	if mapsRole && result.Role == 0 {
+		result.Role = common.RoleCommonUser
+	}
+	if result.IsEmpty() {
+		return nil
+	}
+	return result
+}
+
+func matchClaimMappingRule(body string, email string, rule model.ClaimMappingRule) bool {
+	switch rule.Claim {
+	case model.ClaimMappingAny:
+		return true
+	case model.ClaimMappingEmailDomain:
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return false
+		}
+		return strings.EqualFold(email[at+1:], rule.Value)
+	}
+	value := gjson.Get(body, rule.Claim)
+	if !value.Exists() {
+		return false
+	}
+	if value.IsArray() {
+		for _, item := range value.Array() {
+			if strings.EqualFold(item.String(), rule.Value) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.EqualFold(value.String(), rule.Value)
+}
+
+// attachClaimMapping parses the configured rules and stores the result in user.Extra.
+// Invalid rules are rejected on save, so a parse error here only skips the mapping.
+func attachClaimMapping(user *OAuthUser, body string, rawRules string) error {
+	rules, err := model.ParseClaimMappingRules(rawRules)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	result := evaluateClaimMappings(body, user.Email, rules)
+	if result == nil {
+		return nil
+	}
+	if user.Extra == nil {
+		user.Extra = map[string]any{}
+	}
+	user.Extra[ClaimMappingExtraKey] = result
+	return nil
+}
@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateClaimMappings(t *testing.T) {
+	rules, err := model.ParseClaimMappingRules(`[
+		{"claim": "groups", "value": "ML-Research", "group": "vip"},
+		{"claim": "realm_access.roles", "value": "gateway-admin", "role": 10},
+		{"claim": "email_domain", "value": "corp.example", "group": "svip", "quota": 500000},
+		{"claim": "*", "quota": 1000}
+	]`)
+	require.NoError(t, err)
+
+	body := `{"sub":"1","groups":["staff","ml-research"],"realm_access":{"roles":["gateway-admin"]}}`
+	result := evaluateClaimMappings(body, "alice@corp.example", rules)
+	require.NotNil(t, result)
+	assert.Equal(t, "vip", result.Group, "第一条命中的规则决定分组")
+	assert.Equal(t, common.RoleCommonUser, result.Role, "未开启时映射角色最高为普通用户")
+	require.NotNil(t, result.Quota)
+	assert.Equal(t, 500000, *result.Quota)
+
+	system_setting.GetClaimMappingSettings().AllowAdminRole = true
+	t.Cleanup(func() { system_setting.GetClaimMappingSettings().AllowAdminRole = false })
+	result = evaluateClaimMappings(body, "alice@corp.example", rules)
+	require.NotNil(t, result)
+	assert.Equal(t, common.RoleAdminUser, result.Role)
+
+	result = evaluateClaimMappings(`{"sub":"2","groups":"contractors"}`, "bob@other.example", rules)
+	require.NotNil(t, result)
+	assert.Empty(t, result.Group)
+	assert.Equal(t, common.RoleCommonUser, result.Role, "声明消失后角色回落为普通用户")
+	assert.Equal(t, 1000, *result.Quota, "通配规则兜底")
+}
+
+func TestParseClaimMappingRulesRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`{"claim": "groups"}`,
+		`[{"claim": "groups", "value": "a"}]`,
+		`[{"claim": "groups", "value": "a", "group": "no-such-group"}]`,
+		`[{"claim": "groups", "value": "a", "role": 100}]`,
+		`[{"claim": "groups", "value": "a", "quota": -1}]`,
+		`[{"claim": "", "value": "a", "group": "vip"}]`,
+	} {
+		_, err := model.ParseClaimMappingRules(raw)
+		assert.Error(t, err, raw)
+	}
+	rules, err := model.ParseClaimMappingRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+}
@@ -282,7 +282,7 @@ func (p *GenericOAuthProvider) GetUserInfo(ctx context.Context, token *OAuthToke
 		}
 	}
 
-	return &OAuthUser{
+	user := &OAuthUser{
 		ProviderUserID: userId,
 		Username:       username,
 		DisplayName:    displayName,
@@ -290,7 +290,11 @@ func (p *GenericOAuthProvider) GetUserInfo(ctx context.Context, token *OAuthToke
 		Extra: map[string]any{
 			"provider": p.config.Slug,
 		},
-	}, nil
+	}
+	if err := attachClaimMapping(user, bodyStr, p.config.ClaimMappings); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("[OAuth-Generic-%s] invalid claim mappings: %s", p.config.Slug, err.Error()))
+	}
+	return user, nil
 }
 
 func (p *GenericOAuthProvider) IsUserIDTaken(providerUserID string) bool {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -137,8 +138,13 @@ func (p *OIDCProvider) GetUserInfo(ctx context.Context, token *OAuthToken) (*OAu
 		return nil, NewOAuthError(i18n.MsgOAuthGetUserErr, nil)
 	}
 
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("[OAuth-OIDC] GetUserInfo read body error: %s", err.Error()))
+		return nil, err
+	}
 	var oidcUser oidcUser
-	err = json.NewDecoder(res.Body).Decode(&oidcUser)
+	err = json.Unmarshal(body, &oidcUser)
 	if err != nil {
 		logger.LogError(ctx, fmt.Sprintf("[OAuth-OIDC] GetUserInfo decode error: %s", err.Error()))
 		return nil, err
@@ -151,12 +157,17 @@ func (p *OIDCProvider) GetUserInfo(ctx context.Context, token *OAuthToken) (*OAu
 
 	logger.LogDebug(ctx, "[OAuth-OIDC] GetUserInfo success: sub=%s, username=%s, name=%s, email=%s", oidcUser.OpenID, oidcUser.PreferredUsername, oidcUser.Name, oidcUser.Email)
 
-	return &OAuthUser{
+	user := &OAuthUser{
 		ProviderUserID: oidcUser.OpenID,
 		Username:       oidcUser.PreferredUsername,
 		DisplayName:    oidcUser.Name,
 		Email:          oidcUser.Email,
-	}, nil
+	}
+	// Rules only see the userinfo response, so the IdP must release groups / roles claims there
+	if err := attachClaimMapping(user, string(body), settings.ClaimMappings); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("[OAuth-OIDC] invalid claim mappings: %s", err.Error()))
+	}
+	return user, nil
 }
 
 func (p *OIDCProvider) IsUserIDTaken(providerUserID string) bool {
@@ -0,0 +1,348 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/gin-gonic/gin"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	samlStoreTTL            = 5 * time.Minute
+	samlMetadataRefreshTime = 24 * time.Hour
+)
+
+func init() {
+	Register("saml", &SAMLProvider{})
+}
+
+// SAMLProvider implements login via SAML 2.0.
+// The assertion is validated at the ACS endpoint, which stores the mapped user
+// under a one-time code; the standard OAuth callback then exchanges that code.
+type SAMLProvider struct{}
+
+func (p *SAMLProvider) GetName() string {
+	return "SAML"
+}
+
+func (p *SAMLProvider) IsEnabled() bool {
+	return system_setting.GetSAMLSettings().Enabled
+}
+
+func (p *SAMLProvider) ExchangeToken(ctx context.Context, code string, c *gin.Context) (*OAuthToken, error) {
+	if code == "" {
+		return nil, NewOAuthError(i18n.MsgOAuthInvalidCode, nil)
+	}
+	return &OAuthToken{AccessToken: code}, nil
+}
+
+func (p *SAMLProvider) GetUserInfo(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	data, ok := samlStoreTake("user:" + token.AccessToken)
+	if !ok {
+		logger.LogError(ctx, "[SAML] GetUserInfo failed: code not found or expired")
+		return nil, NewOAuthError(i18n.MsgOAuthInvalidCode, nil)
+	}
+	var user OAuthUser
+	if err := common.UnmarshalJsonStr(data, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p *SAMLProvider) IsUserIDTaken(providerUserID string) bool {
+	return model.IsSamlIdAlreadyTaken(providerUserID)
+}
+
+func (p *SAMLProvider) FillUserByProviderID(user *model.User, providerUserID string) error {
+	user.SamlId = providerUserID
+	return user.FillUserBySamlId()
+}
+
+func (p *SAMLProvider) SetProviderUserID(user *model.User, providerUserID string) {
+	user.SamlId = providerUserID
+}
+
+func (p *SAMLProvider) GetProviderPrefix() string {
+	return "saml_"
+}
+
+// SAMLUserFromAssertion maps assertion attributes to an OAuthUser according to the configured attribute names.
+// The NameID is used as the stable provider user ID.
+func SAMLUserFromAssertion(assertion *saml.Assertion) (*OAuthUser, error) {
+	settings := system_setting.GetSAMLSettings()
+	user := &OAuthUser{
+		Username:    samlAttribute(assertion, settings.UsernameAttribute),
+		DisplayName: samlAttribute(assertion, settings.DisplayNameAttribute),
+		Email:       samlAttribute(assertion, settings.EmailAttribute),
+	}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		user.ProviderUserID = strings.TrimSpace(assertion.Subject.NameID.Value)
+	}
+	if user.ProviderUserID == "" {
+		user.ProviderUserID = user.Username
+	}
+	if user.ProviderUserID == "" {
+		return nil, NewOAuthError(i18n.MsgOAuthUserInfoEmpty, map[string]any{"Provider": "SAML"})
+	}
+	if settings.GroupAttribute != "" {
+		for _, group := range samlAttributeValues(assertion, settings.GroupAttribute) {
+			if ratio_setting.ContainsGroupRatio(group) {
+				user.Extra = map[string]any{"group": group}
+				break
+			}
+		}
+	}
+	return user, nil
+}
+
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	values := samlAttributeValues(assertion, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// samlAttributeValues matches the attribute by Name or FriendlyName, since IdPs differ in which one they populate.
+func samlAttributeValues(assertion *saml.Assertion, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var values []string
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if attr.Name != name && attr.FriendlyName != name {
+				continue
+			}
+			for _, v := range attr.Values {
+				if value := strings.TrimSpace(v.Value); value != "" {
+					values = append(values, value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// SaveSAMLRequest remembers the AuthnRequest ID issued for a login state so the ACS can verify InResponseTo.
+func SaveSAMLRequest(state string, requestId string) error {
+	return samlStoreSet("request:"+state, requestId)
+}
+
+// TakeSAMLRequest returns and removes the AuthnRequest ID issued for a login state.
+func TakeSAMLRequest(state string) (string, bool) {
+	return samlStoreTake("request:" + state)
+}
+
+// SaveSAMLUser stores the mapped user under a new one-time code.
+func SaveSAMLUser(user *OAuthUser) (string, error) {
+	data, err := common.Marshal(user)
+	if err != nil {
+		return "", err
+	}
+	code := common.GetRandomString(32)
+	if err := samlStoreSet("user:"+code, string(data)); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+type samlStoreEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	samlStoreMu  sync.Mutex
+	samlMemStore = make(map[string]samlStoreEntry)
+)
+
+func samlStoreSet(key string, value string) error {
+	if common.RedisEnabled {
+		return common.RedisSet("saml:"+key, value, samlStoreTTL)
+	}
+	samlStoreMu.Lock()
+	defer samlStoreMu.Unlock()
+	now := time.Now()
+	for k, entry := range samlMemStore {
+		if now.After(entry.expiresAt) {
+			delete(samlMemStore, k)
+		}
+	}
+	samlMemStore[key] = samlStoreEntry{value: value, expiresAt: now.Add(samlStoreTTL)}
+	return nil
+}
+
+func samlStoreTake(key string) (string, bool) {
+	if common.RedisEnabled {
+		value, err := common.RDB.GetDel(context.Background(), "saml:"+key).Result()
+		if err != nil {
+			return "", false
+		}
+		return value, true
+	}
+	samlStoreMu.Lock()
+	defer samlStoreMu.Unlock()
+	entry, ok := samlMemStore[key]
+	if !ok {
+		return "", false
+	}
+	delete(samlMemStore, key)
+	if time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.value, true
+}
+
+type samlMetadataCache struct {
+	source    string
+	metadata  *saml.EntityDescriptor
+	fetchedAt time.Time
+}
+
+var (
+	samlMetadataMu sync.Mutex
+	samlMetadata   samlMetadataCache
+)
+
+// getSAMLIdPMetadata returns the IdP metadata, preferring the inline XML over the URL.
+// Fetched metadata is cached and refreshed daily; a stale copy is kept if the refresh fails.
+func getSAMLIdPMetadata(ctx context.Context) (*saml.EntityDescriptor, error) {
+	settings := system_setting.GetSAMLSettings()
+	var source string
+	if xml := strings.TrimSpace(settings.IdPMetadataXML); xml != "" {
+		sum := sha256.Sum256([]byte(xml))
+		source = "xml:" + hex.EncodeToString(sum[:])
+	} else if settings.IdPMetadataURL != "" {
+		source = "url:" + settings.IdPMetadataURL
+	} else {
+		return nil, errors.New("SAML IdP metadata is not configured")
+	}
+
+	samlMetadataMu.Lock()
+	defer samlMetadataMu.Unlock()
+	if samlMetadata.source == source && samlMetadata.metadata != nil &&
+		(strings.HasPrefix(source, "xml:") || time.Since(samlMetadata.fetchedAt) < samlMetadataRefreshTime) {
+		return samlMetadata.metadata, nil
+	}
+
+	var metadata *saml.EntityDescriptor
+	var err error
+	if strings.HasPrefix(source, "xml:") {
+		metadata, err = samlsp.ParseMetadata([]byte(strings.TrimSpace(settings.IdPMetadataXML)))
+	} else {
+		var metadataURL *url.URL
+		metadataURL, err = url.Parse(settings.IdPMetadataURL)
+		if err == nil {
+			client := &http.Client{Timeout: 10 * time.Second}
+			metadata, err = samlsp.FetchMetadata(ctx, client, *metadataURL)
+		}
+	}
+	if err != nil {
+		if samlMetadata.source == source && samlMetadata.metadata != nil {
+			common.SysError(fmt.Sprintf("[SAML] failed to refresh IdP metadata, using cached copy: %s", err.Error()))
+			return samlMetadata.metadata, nil
+		}
+		return nil, fmt.Errorf("failed to load SAML IdP metadata: %w", err)
+	}
+	samlMetadata = samlMetadataCache{source: source, metadata: metadata, fetchedAt: time.Now()}
+	return metadata, nil
+}
+
+// GetSAMLServiceProvider builds the service provider from the current settings.
+// IdP metadata is optional here so that the SP metadata can be served before the IdP is configured.
+func GetSAMLServiceProvider(ctx context.Context, requireIdP bool) (*saml.ServiceProvider, error) {
+	settings := system_setting.GetSAMLSettings()
+	metadataURL, err := url.Parse(system_setting.ServerAddress + "/api/saml/metadata")
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(system_setting.ServerAddress + "/api/saml/acs")
+	if err != nil {
+		return nil, err
+	}
+	sp := &saml.ServiceProvider{
+		EntityID:          settings.EntityId,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		AllowIDPInitiated: settings.AllowIdPInitiated,
+	}
+	if settings.Certificate != "" && settings.PrivateKey != "" {
+		cert, key, err := parseSAMLKeyPair(settings.Certificate, settings.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		sp.Certificate = cert
+		sp.Key = key
+		if settings.SignRequests {
+			if _, ok := key.(*ecdsa.PrivateKey); ok {
+				sp.SignatureMethod = dsig.ECDSASHA256SignatureMethod
+			} else {
+				sp.SignatureMethod = dsig.RSASHA256SignatureMethod
+			}
+		}
+	}
+	metadata, err := getSAMLIdPMetadata(ctx)
+	if err != nil {
+		if requireIdP {
+			return nil, err
+		}
+		return sp, nil
+	}
+	sp.IDPMetadata = metadata
+	return sp, nil
+}
+
+func parseSAMLKeyPair(certPEM string, keyPEM string) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode([]byte(certPEM))
+	if certBlock == nil {
+		return nil, nil, errors.New("invalid SAML certificate")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SAML certificate: %w", err)
+	}
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("invalid SAML private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes); err == nil {
+		return cert, key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(keyBlock.Bytes); err == nil {
+		return cert, key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SAML private key: %w", err)
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return cert, k, nil
+	case *ecdsa.PrivateKey:
+		return cert, k, nil
+	}
+	return nil, nil, errors.New("unsupported SAML private key type")
+}
@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSAMLUserFromAssertion(t *testing.T) {
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Value: "alice@corp.example"}},
+		AttributeStatements: []saml.AttributeStatement{{
+			Attributes: []saml.Attribute{
+				{Name: "urn:oid:0.9.2342.19200300.100.1.1", FriendlyName: "uid", Values: []saml.AttributeValue{{Value: "alice"}}},
+				{Name: "email", Values: []saml.AttributeValue{{Value: "alice@corp.example"}}},
+				{Name: "displayName", Values: []saml.AttributeValue{{Value: " Alice "}}},
+			},
+		}},
+	}
+	user, err := SAMLUserFromAssertion(assertion)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@corp.example", user.ProviderUserID)
+	assert.Equal(t, "alice", user.Username, "按 FriendlyName 匹配属性")
+	assert.Equal(t, "alice@corp.example", user.Email)
+	assert.Equal(t, "Alice", user.DisplayName)
+
+	_, err = SAMLUserFromAssertion(&saml.Assertion{})
+	assert.Error(t, err, "缺少 NameID 和用户名时无法确定用户")
+}
+
+func TestSAMLStoreOneTimeCode(t *testing.T) {
+	common.RedisEnabled = false
+
+	code, err := SaveSAMLUser(&OAuthUser{ProviderUserID: "alice", Email: "alice@corp.example"})
+	require.NoError(t, err)
+
+	provider := &SAMLProvider{}
+	token, err := provider.ExchangeToken(context.Background(), code, nil)
+	require.NoError(t, err)
+	user, err := provider.GetUserInfo(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.ProviderUserID)
+
+	_, err = provider.GetUserInfo(context.Background(), token)
+	assert.Error(t, err, "code 只能使用一次")
+
+	require.NoError(t, SaveSAMLRequest("state1", "id-123"))
+	requestId, ok := TakeSAMLRequest("state1")
+	assert.True(t, ok)
+	assert.Equal(t, "id-123", requestId)
+	_, ok = TakeSAMLRequest("state1")
+	assert.False(t, ok)
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/QuantumNous/new-api/pkg/metrics"
 	"github.com/go-redis/redis/v8"
 	"github.com/samber/hot"
 )
@@ -95,9 +96,11 @@ func (c *HybridCache[V]) Get(key string) (value V, found bool, err error) {
 				var zero V
 				return zero, false, decErr
 			}
+			metrics.ObserveCacheLookup(string(c.ns), true)
 			return v, true, nil
 		}
 		if errors.Is(e, redis.Nil) {
+			metrics.ObserveCacheLookup(string(c.ns), false)
 			var zero V
 			return zero, false, nil
 		}
@@ -105,7 +108,11 @@ func (c *HybridCache[V]) Get(key string) (value V, found bool, err error) {
 		return zero, false, e
 	}
 
-	return c.memCache().Get(full)
+	value, found, err = c.memCache().Get(full)
+	if err == nil {
+		metrics.ObserveCacheLookup(string(c.ns), found)
+	}
+	return value, found, err
 }
 
 func (c *HybridCache[V]) SetWithTTL(key string, v V, ttl time.Duration) error {
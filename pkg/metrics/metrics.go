@@ -0,0 +1,271 @@
+// Package metrics exposes gateway telemetry in the Prometheus exposition format.
+//
+// All recording helpers are no-ops until Init is called, so call sites never need to check
+// whether metrics are enabled. Business labels (model, group, channel) go through a limiter
+// that can drop a label entirely or fold values beyond a per-label budget into "other",
+// keeping series cardinality bounded no matter what clients send.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "newapi"
+
+	LabelModel   = "model"
+	LabelGroup   = "group"
+	LabelChannel = "channel"
+
+	// OtherLabelValue replaces label values beyond the per-label budget
+	OtherLabelValue = "other"
+)
+
+// Config controls which business labels are exported and how many distinct values each may have.
+type Config struct {
+	// Labels lists the business labels to keep; labels not listed are exported with an empty value
+	Labels []string
+	// MaxLabelValues caps distinct values per label, 0 means unlimited
+	MaxLabelValues int
+}
+
+var (
+	enabled  atomic.Bool
+	initOnce sync.Once
+	registry = prometheus.NewRegistry()
+	limiter  = &labelLimiter{}
+
+	businessLabels = []string{LabelModel, LabelGroup, LabelChannel}
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route template and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "End-to-end latency of successful relay requests.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, businessLabels)
+	relayTTFT = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_time_to_first_token_seconds",
+		Help:      "Time until the first response chunk of streaming relay requests.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30},
+	}, businessLabels)
+	relayErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_errors_total",
+		Help:      "Relay requests that failed, by error code.",
+	}, append([]string{"error_code"}, businessLabels...))
+	relayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Relay attempts retried on another channel, labeled with the channel that failed.",
+	}, businessLabels)
+	tokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens consumed, by type (prompt or completion).",
+	}, append([]string{"type"}, businessLabels...))
+	quotaTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_consumed_total",
+		Help:      "Quota charged to users.",
+	}, businessLabels)
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Hybrid cache lookups, by namespace and result (hit or miss).",
+	}, []string{"namespace", "result"})
+	taskBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "task_polling_backlog",
+		Help:      "Unfinished async tasks seen by the last polling round, capped by the polling query limit.",
+	}, []string{"platform"})
+)
+
+// Init registers all collectors and turns recording on. Subsequent calls are ignored.
+func Init(cfg Config) {
+	initOnce.Do(func() {
+		limiter.configure(cfg)
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			httpRequests, httpDuration,
+			relayDuration, relayTTFT, relayErrors, relayRetries,
+			tokensTotal, quotaTotal,
+			cacheRequests, taskBacklog,
+		)
+		enabled.Store(true)
+	})
+}
+
+// Enabled reports whether Init has been called
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Register adds an extra collector, e.g. database or Redis pool stats
+func Register(c prometheus.Collector) error {
+	return registry.Register(c)
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records one HTTP request; route should be the route template, not the raw path
+func ObserveHTTPRequest(method string, route string, status int, duration time.Duration) {
+	if !Enabled() {
+		return
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveRelay records the latency of a successful relay request and, for streams, its time to first token
+func ObserveRelay(model string, group string, channelId int, duration time.Duration, ttft time.Duration) {
+	if !Enabled() {
+		return
+	}
+	labels := limiter.values(model, group, channelId)
+	relayDuration.WithLabelValues(labels...).Observe(duration.Seconds())
+	if ttft > 0 {
+		relayTTFT.WithLabelValues(labels...).Observe(ttft.Seconds())
+	}
+}
+
+// IncRelayError counts a relay request that ended with the given error code
+func IncRelayError(errorCode string, model string, group string, channelId int) {
+	if !Enabled() {
+		return
+	}
+	relayErrors.WithLabelValues(append([]string{errorCode}, limiter.values(model, group, channelId)...)...).Inc()
+}
+
+// IncRelayRetry counts a retry away from the given channel
+func IncRelayRetry(model string, group string, channelId int) {
+	if !Enabled() {
+		return
+	}
+	relayRetries.WithLabelValues(limiter.values(model, group, channelId)...).Inc()
+}
+
+// AddConsumption records tokens and quota charged for one request
+func AddConsumption(model string, group string, channelId int, promptTokens int, completionTokens int, quota int) {
+	if !Enabled() {
+		return
+	}
+	labels := limiter.values(model, group, channelId)
+	if promptTokens > 0 {
+		tokensTotal.WithLabelValues(append([]string{"prompt"}, labels...)...).Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		tokensTotal.WithLabelValues(append([]string{"completion"}, labels...)...).Add(float64(completionTokens))
+	}
+	if quota > 0 {
+		quotaTotal.WithLabelValues(labels...).Add(float64(quota))
+	}
+}
+
+// ObserveCacheLookup counts a cache hit or miss
+func ObserveCacheLookup(cacheNamespace string, hit bool) {
+	if !Enabled() {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cacheNamespace, result).Inc()
+}
+
+// SetTaskBacklog replaces the per-platform backlog gauge with the latest polling snapshot
+func SetTaskBacklog(backlog map[string]int) {
+	if !Enabled() {
+		return
+	}
+	taskBacklog.Reset()
+	for platform, count := range backlog {
+		taskBacklog.WithLabelValues(platform).Set(float64(count))
+	}
+}
+
+type labelLimiter struct {
+	mu   sync.RWMutex
+	keep map[string]bool
+	max  int
+	seen map[string]map[string]struct{}
+}
+
+func (l *labelLimiter) configure(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keep = make(map[string]bool, len(cfg.Labels))
+	for _, label := range cfg.Labels {
+		l.keep[label] = true
+	}
+	l.max = cfg.MaxLabelValues
+	l.seen = make(map[string]map[string]struct{}, len(businessLabels))
+	for _, label := range businessLabels {
+		l.seen[label] = make(map[string]struct{})
+	}
+}
+
+// values returns the model, group and channel label values in businessLabels order
+func (l *labelLimiter) values(model string, group string, channelId int) []string {
+	channel := ""
+	if channelId > 0 {
+		channel = strconv.Itoa(channelId)
+	}
+	return []string{
+		l.value(LabelModel, model),
+		l.value(LabelGroup, group),
+		l.value(LabelChannel, channel),
+	}
+}
+
+func (l *labelLimiter) value(label string, v string) string {
+	if v == "" {
+		return ""
+	}
+	l.mu.RLock()
+	if !l.keep[label] {
+		l.mu.RUnlock()
+		return ""
+	}
+	_, ok := l.seen[label][v]
+	l.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	set := l.seen[label]
+	if _, ok := set[v]; ok {
+		return v
+	}
+	if l.max > 0 && len(set) >= l.max {
+		return OtherLabelValue
+	}
+	set[v] = struct{}{}
+	return v
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelCardinalityIsBounded(t *testing.T) {
+	Init(Config{Labels: []string{LabelModel, LabelChannel}, MaxLabelValues: 2})
+
+	AddConsumption("gpt-4o", "vip", 1, 10, 5, 100)
+	AddConsumption("claude-3", "vip", 2, 10, 5, 100)
+	AddConsumption("unknown-model", "vip", 3, 10, 5, 100)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	assert.Contains(t, body, `newapi_quota_consumed_total{channel="1",group="",model="gpt-4o"} 100`)
+	assert.Contains(t, body, `newapi_quota_consumed_total{channel="other",group="",model="other"} 100`, "超出取值上限的标签归入 other")
+	assert.False(t, strings.Contains(body, `group="vip"`), "未保留的标签导出为空值")
+	assert.Contains(t, body, `newapi_tokens_total{channel="2",group="",model="claude-3",type="completion"} 5`)
+}
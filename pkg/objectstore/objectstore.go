@@ -0,0 +1,32 @@
+// Package objectstore stores archive files on local disk or in an S3-compatible bucket.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when the object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Store is a minimal object storage used for archives: objects are written once and read back whole
+type Store interface {
+	// Put uploads size bytes read from body under key, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Name describes the backend for logs and the archive index, e.g. "local" or "s3"
+	Name() string
+}
+
+// cleanKey rejects keys that would escape the store root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.TrimSpace(key))
+	if cleaned == "/" {
+		return "", errors.New("object key is empty")
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
@@ -0,0 +1,84 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "../logs/dt=2026-01-01/a.gz", strings.NewReader("hello"), 5))
+	_, err = os.Stat(filepath.Join(root, "logs", "dt=2026-01-01", "a.gz"))
+	require.NoError(t, err, "keys cannot escape the root")
+
+	r, err := store.Get(ctx, "logs/dt=2026-01-01/a.gz")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	require.Equal(t, "hello", string(data))
+
+	_, err = store.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Error(t, store.Put(ctx, "short", strings.NewReader("abc"), 5))
+}
+
+func TestS3StorePathStyle(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(data)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(data))
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "archive",
+		AccessKeyId:     "AKID",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}, server.Client())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "logs/dt=2026-01-01/part.ndjson.gz", strings.NewReader("payload"), 7))
+	require.Contains(t, objects, "/archive/logs/dt=2026-01-01/part.ndjson.gz")
+
+	r, err := store.Get(ctx, "logs/dt=2026-01-01/part.ndjson.gz")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	require.Equal(t, "payload", string(data))
+
+	_, err = store.Get(ctx, "logs/missing")
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,139 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config addresses a bucket on AWS S3 or any S3-compatible service (MinIO, R2, OSS, COS ...)
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com, defaults to the AWS regional endpoint
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	PathStyle       bool // address the bucket as endpoint/bucket instead of bucket.endpoint, required by most self-hosted services
+}
+
+type s3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	signer   *v4.Signer
+}
+
+// NewS3 talks to the S3 REST API directly with SigV4-signed requests
+func NewS3(cfg S3Config, client *http.Client) (Store, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyId == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3 bucket and credentials are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Minute}
+	}
+	return &s3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   client,
+		signer: v4.NewSigner(func(o *v4.SignerOptions) {
+			// S3 expects the object key exactly as sent, not escaped a second time
+			o.DisableURIPathEscaping = true
+		}),
+	}, nil
+}
+
+func (s *s3Store) Name() string {
+	return "s3"
+}
+
+func (s *s3Store) objectURL(key string) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Split(cleaned, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	u := *s.endpoint
+	if s.cfg.PathStyle {
+		u.Path = u.Path + "/" + url.PathEscape(s.cfg.Bucket) + "/" + strings.Join(segments, "/")
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = u.Path + "/" + strings.Join(segments, "/")
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) do(ctx context.Context, method string, key string, body io.Reader, size int64) (*http.Response, error) {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	credentials := aws.Credentials{AccessKeyID: s.cfg.AccessKeyId, SecretAccessKey: s.cfg.SecretAccessKey}
+	if err := s.signer.SignHTTP(ctx, credentials, req, unsignedPayload, "s3", s.cfg.Region, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+	return resp.Body, nil
+}
+
+func s3Error(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
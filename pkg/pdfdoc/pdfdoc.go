@@ -0,0 +1,209 @@
+// Package pdfdoc writes simple text-and-table PDF documents without external
+// dependencies. Text is set in the non-embedded Adobe CJK font STSong-Light
+// with the UniGB-UTF16-H encoding, so both Latin and Chinese text render in
+// any viewer that ships the standard Asian font packs.
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 portrait in points.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+	margin     = 48.0
+	cellPad    = 4.0
+)
+
+// Document accumulates pages of content streams.
+type Document struct {
+	pages []*bytes.Buffer
+	cur   *bytes.Buffer
+	y     float64
+}
+
+// New returns a document with one empty page.
+func New() *Document {
+	d := &Document{}
+	d.newPage()
+	return d
+}
+
+func (d *Document) newPage() {
+	d.cur = &bytes.Buffer{}
+	d.pages = append(d.pages, d.cur)
+	d.y = PageHeight - margin
+}
+
+// ContentWidth is the usable width between the left and right margins.
+func (d *Document) ContentWidth() float64 {
+	return PageWidth - 2*margin
+}
+
+// ensure starts a new page when less than h points remain.
+func (d *Document) ensure(h float64) {
+	if d.y-h < margin {
+		d.newPage()
+	}
+}
+
+// Space moves the cursor down by h points.
+func (d *Document) Space(h float64) {
+	d.y -= h
+	if d.y < margin {
+		d.newPage()
+	}
+}
+
+// Text writes a single line at the given font size, truncated to the content width.
+func (d *Document) Text(size float64, s string) {
+	lineHeight := size * 1.4
+	d.ensure(lineHeight)
+	d.y -= lineHeight
+	d.writeText(margin, d.y+size*0.3, size, Fit(s, size, d.ContentWidth()))
+}
+
+// Rule draws a horizontal line across the content width.
+func (d *Document) Rule() {
+	d.ensure(4)
+	d.y -= 2
+	fmt.Fprintf(d.cur, "0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, d.y, PageWidth-margin, d.y)
+	d.y -= 2
+}
+
+// Table writes a header row followed by rows. Widths are fractions of the
+// content width; cells that do not fit are truncated. The header is repeated
+// after a page break.
+func (d *Document) Table(size float64, widths []float64, header []string, rows [][]string) {
+	lineHeight := size * 1.6
+	columns := make([]float64, len(widths))
+	for i, w := range widths {
+		columns[i] = w * d.ContentWidth()
+	}
+	writeRow := func(cells []string) {
+		x := margin
+		for i, cell := range cells {
+			if i >= len(columns) {
+				break
+			}
+			d.writeText(x+cellPad, d.y+size*0.45, size, Fit(cell, size, columns[i]-2*cellPad))
+			x += columns[i]
+		}
+	}
+	writeHeader := func() {
+		d.y -= lineHeight
+		writeRow(header)
+		fmt.Fprintf(d.cur, "0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, d.y, PageWidth-margin, d.y)
+	}
+	d.ensure(lineHeight * 2)
+	writeHeader()
+	for _, row := range rows {
+		if d.y-lineHeight < margin {
+			d.newPage()
+			writeHeader()
+		}
+		d.y -= lineHeight
+		writeRow(row)
+	}
+}
+
+func (d *Document) writeText(x, y, size float64, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(d.cur, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, encodeUTF16Hex(s))
+}
+
+// TextWidth estimates the rendered width: the font's ASCII glyphs are half width,
+// everything else is full width.
+func TextWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		if r >= 0x20 && r <= 0x7e {
+			w += 0.5
+		} else {
+			w += 1
+		}
+	}
+	return w * size
+}
+
+// Fit truncates s with ".." so that it fits into width points.
+func Fit(s string, size float64, width float64) string {
+	if TextWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + ".."
+		if TextWidth(candidate, size) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func encodeUTF16Hex(s string) string {
+	var b strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	return b.String()
+}
+
+// Bytes serializes the document.
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	startObject := func() int {
+		offsets = append(offsets, out.Len())
+		id := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n", id)
+		return id
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Fixed objects: 1 catalog, 2 page tree, 3 font, 4 CID font, 5 descriptor.
+	// Pages and their content streams follow in pairs starting at 6.
+	pageIds := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageIds[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	startObject()
+	out.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	startObject()
+	fmt.Fprintf(&out, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(pageIds, " "), len(d.pages))
+	startObject()
+	out.WriteString("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UTF16-H /DescendantFonts [4 0 R] >>\nendobj\n")
+	startObject()
+	out.WriteString("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> " +
+		"/FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>\nendobj\n")
+	startObject()
+	out.WriteString("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>\nendobj\n")
+
+	for _, content := range d.pages {
+		pageId := startObject()
+		fmt.Fprintf(&out, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+			PageWidth, PageHeight, pageId+1)
+		startObject()
+		fmt.Fprintf(&out, "<< /Length %d >>\nstream\n", content.Len())
+		out.Write(content.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
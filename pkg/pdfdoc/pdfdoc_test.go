@@ -0,0 +1,46 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentXrefPointsAtObjects(t *testing.T) {
+	doc := New()
+	doc.Text(16, "Statement 账单")
+	doc.Rule()
+	rows := make([][]string, 80)
+	for i := range rows {
+		rows[i] = []string{fmt.Sprintf("model-%d", i), "token", strconv.Itoa(i)}
+	}
+	doc.Table(9, []float64{0.5, 0.3, 0.2}, []string{"Model", "Token", "Quota"}, rows)
+	out := doc.Bytes()
+
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4")))
+	assert.Contains(t, string(out), "/Count 2", "rows overflow onto a second page")
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	require.NotNil(t, startxref)
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	require.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(out[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))), "object %d offset", i+1)
+	}
+}
+
+func TestFitTruncatesByGlyphWidth(t *testing.T) {
+	assert.Equal(t, "abcd", Fit("abcd", 10, 20))
+	assert.Equal(t, "ab..", Fit("abcdef", 10, 20))
+	assert.Equal(t, "中..", Fit("中文字符", 10, 20))
+	assert.Equal(t, "4E2D0041", encodeUTF16Hex("中A"))
+}
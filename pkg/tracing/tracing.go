@@ -0,0 +1,148 @@
+// Package tracing wires OpenTelemetry tracing through the gin request lifecycle.
+//
+// Spans are parented through a context stored on the gin.Context instead of replacing
+// c.Request, so handlers that swap the request body are unaffected. Until Init is called
+// every helper is a no-op.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	instrumentationName = "github.com/QuantumNous/new-api"
+	ginContextKey       = "otel_trace_context"
+)
+
+var (
+	enabled atomic.Bool
+	tracer  trace.Tracer = noop.NewTracerProvider().Tracer(instrumentationName)
+)
+
+// Init installs an OTLP/HTTP exporter and the W3C trace context propagator.
+// Endpoint, headers, sampler and service name follow the standard OTEL_* environment variables.
+func Init(ctx context.Context, serviceName string, version string) (shutdown func(context.Context) error, err error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES take precedence over the defaults above
+	if envRes, envErr := resource.New(ctx, resource.WithFromEnv()); envErr == nil {
+		if merged, mergeErr := resource.Merge(res, envRes); mergeErr == nil {
+			res = merged
+		}
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	Setup(provider)
+	return provider.Shutdown, nil
+}
+
+// Setup makes provider the source of all spans; exported so tests can install an in-memory recorder
+func Setup(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	tracer = provider.Tracer(instrumentationName)
+	enabled.Store(true)
+}
+
+// Enabled reports whether a tracer provider has been installed
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Context returns the context carrying the current span of the request
+func Context(c *gin.Context) context.Context {
+	if v, ok := c.Get(ginContextKey); ok {
+		if ctx, ok := v.(context.Context); ok {
+			return ctx
+		}
+	}
+	if c.Request != nil {
+		return c.Request.Context()
+	}
+	return context.Background()
+}
+
+// StartServerSpan starts the root span of a request, continuing a trace from the incoming traceparent header
+func StartServerSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	if !Enabled() {
+		return trace.SpanFromContext(context.Background()), func() {}
+	}
+	parent := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	ctx, span := tracer.Start(parent, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	c.Set(ginContextKey, ctx)
+	return span, func() { span.End() }
+}
+
+// StartSpan starts a child of the request's current span and makes it current until end is called.
+// end is idempotent, so it can be both deferred and called early, e.g. before c.Next() in a middleware;
+// a request aborted by the time end runs marks the span as failed.
+func StartSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (span trace.Span, end func()) {
+	if !Enabled() {
+		return trace.SpanFromContext(context.Background()), func() {}
+	}
+	parent := Context(c)
+	ctx, span := tracer.Start(parent, name, trace.WithAttributes(attrs...))
+	c.Set(ginContextKey, ctx)
+	ended := false
+	return span, func() {
+		if ended {
+			return
+		}
+		ended = true
+		if c.IsAborted() {
+			span.SetStatus(codes.Error, "aborted")
+		}
+		c.Set(ginContextKey, parent)
+		span.End()
+	}
+}
+
+// SetAttributes adds attributes to the request's current span
+func SetAttributes(c *gin.Context, attrs ...attribute.KeyValue) {
+	if !Enabled() {
+		return
+	}
+	trace.SpanFromContext(Context(c)).SetAttributes(attrs...)
+}
+
+// RecordError marks the span as failed
+func RecordError(span trace.Span, err error) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// InjectHeaders writes the traceparent of the request's current span into an outgoing upstream request
+func InjectHeaders(c *gin.Context, header http.Header) {
+	if !Enabled() {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(Context(c), propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSpansFollowIncomingTraceAndPropagateUpstream(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	Setup(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+
+	_, endServer := StartServerSpan(c, "POST /v1/chat/completions")
+	_, endAttempt := StartSpan(c, "RelayAttempt")
+	_, endDoRequest := StartSpan(c, "DoRequest")
+	upstream := http.Header{}
+	InjectHeaders(c, upstream)
+	endDoRequest()
+	endDoRequest()
+	endAttempt()
+	endServer()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3, "重复调用 end 不会重复结束 span")
+	doRequest, attempt, server := spans[0], spans[1], spans[2]
+	assert.Equal(t, traceId, server.SpanContext().TraceID().String())
+	assert.Equal(t, server.SpanContext().SpanID(), attempt.Parent().SpanID())
+	assert.Equal(t, attempt.SpanContext().SpanID(), doRequest.Parent().SpanID())
+
+	traceparent := upstream.Get("traceparent")
+	assert.True(t, strings.Contains(traceparent, traceId+"-"+doRequest.SpanContext().SpanID().String()), traceparent)
+	assert.Equal(t, server.SpanContext(), trace.SpanContextFromContext(Context(c)), "子 span 结束后恢复父 span 为当前 span")
+}
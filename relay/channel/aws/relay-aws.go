@@ -264,6 +264,7 @@ func awsStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor) (
 		Usage:        &dto.Usage{},
 	}
 
+events:
 	for event := range stream.Events() {
 		switch v := event.(type) {
 		case *bedrockruntimeTypes.ResponseStreamMemberChunk:
@@ -272,6 +273,10 @@ func awsStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor) (
 			if respErr != nil {
 				return respErr, nil
 			}
+			// 额度耗尽时停止读取，上游请求随 stream.Close 关闭
+			if info.StreamQuotaGuard.Exceeded() {
+				break events
+			}
 		case *bedrockruntimeTypes.UnknownUnionMember:
 			fmt.Println("unknown tag:", v.Tag)
 			return types.NewError(errors.New("unknown response type"), types.ErrorCodeInvalidRequest), nil
@@ -21,6 +21,7 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -544,6 +545,9 @@ type ClaudeResponseInfo struct {
 	ResponseText strings.Builder
 	Usage        *dto.Usage
 	Done         bool
+	// 当前未结束的内容块，额度耗尽中止时用于补发 content_block_stop
+	BlockOpen  bool
+	BlockIndex int
 }
 
 func buildMessageDeltaPatchUsage(claudeResponse *dto.ClaudeResponse, claudeInfo *ClaudeResponseInfo) *dto.ClaudeUsage {
@@ -704,6 +708,17 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 	if claudeResponse.Delta != nil && claudeResponse.Delta.StopReason != nil {
 		maybeMarkClaudeRefusal(c, *claudeResponse.Delta.StopReason)
 	}
+	switch claudeResponse.Type {
+	case "content_block_start":
+		claudeInfo.BlockOpen = true
+		claudeInfo.BlockIndex = lo.FromPtr(claudeResponse.Index)
+	case "content_block_stop":
+		claudeInfo.BlockOpen = false
+	case "content_block_delta":
+		if info.StreamQuotaGuard != nil && claudeResponse.Delta != nil {
+			service.ChargeStreamText(info, claudeDeltaText(claudeResponse.Delta))
+		}
+	}
 	if info.RelayFormat == types.RelayFormatClaude {
 		FormatClaudeResponseInfo(&claudeResponse, nil, claudeInfo)
 
@@ -743,7 +758,17 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 		if common.DebugEnabled {
 			common.SysLog("claude response usage is not complete, maybe upstream error")
 		}
-		claudeInfo.Usage = service.ResponseText2Usage(c, claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
+		promptTokens := claudeInfo.Usage.PromptTokens
+		if promptTokens == 0 && common.GetContextKeyBool(c, constant.ContextKeyClientCancelled) {
+			// 客户端在 message_start 之前断开，按预估的输入计费
+			promptTokens = info.GetEstimatePromptTokens()
+		}
+		claudeInfo.Usage = service.ResponseText2Usage(c, claudeInfo.ResponseText.String(), info.UpstreamModelName, promptTokens)
+	}
+
+	if info.StreamQuotaGuard.Exceeded() {
+		logger.LogWarn(c, fmt.Sprintf("quota exhausted mid-stream, aborting generation after ~%d completion tokens", info.StreamQuotaGuard.CompletionTokens))
+		sendQuotaExhaustedFinish(c, info, claudeInfo)
 	}
 
 	if info.RelayFormat == types.RelayFormatClaude {
@@ -760,6 +785,47 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 	}
 }
 
+// claudeDeltaText 提取 content_block_delta 中新生成的文本（正文、思考内容与工具调用参数）
+func claudeDeltaText(delta *dto.ClaudeMediaMessage) string {
+	return lo.FromPtr(delta.Text) + lo.FromPtr(delta.Thinking) + lo.FromPtr(delta.PartialJson)
+}
+
+// sendQuotaExhaustedFinish 额度耗尽中止流式响应时，按入站格式补发结束事件，stop_reason 为 max_tokens
+func sendQuotaExhaustedFinish(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo) {
+	switch info.RelayFormat {
+	case types.RelayFormatClaude:
+		if claudeInfo.BlockOpen {
+			blockStop := dto.ClaudeResponse{Type: "content_block_stop"}
+			blockStop.SetIndex(claudeInfo.BlockIndex)
+			_ = helper.ClaudeData(c, blockStop)
+			claudeInfo.BlockOpen = false
+		}
+		stopReason := "max_tokens"
+		_ = helper.ClaudeData(c, dto.ClaudeResponse{
+			Type:  "message_delta",
+			Delta: &dto.ClaudeMediaMessage{StopReason: &stopReason},
+			Usage: &dto.ClaudeUsage{
+				InputTokens:  claudeInfo.Usage.PromptTokens,
+				OutputTokens: claudeInfo.Usage.CompletionTokens,
+			},
+		})
+		_ = helper.ClaudeData(c, dto.ClaudeResponse{Type: "message_stop"})
+	case types.RelayFormatOpenAI:
+		finishReason := constant.FinishReasonLength
+		response := dto.ChatCompletionsStreamResponse{
+			Id:      claudeInfo.ResponseId,
+			Object:  "chat.completion.chunk",
+			Created: claudeInfo.Created,
+			Model:   claudeInfo.Model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finishReason}},
+		}
+		if err := helper.ObjectData(c, response); err != nil {
+			common.SysLog("send quota exhausted finish response failed: " + err.Error())
+		}
+	}
+	claudeInfo.Done = true
+}
+
 func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
 	claudeInfo := &ClaudeResponseInfo{
 		ResponseId:   helper.GetResponseID(c),
@@ -774,7 +840,8 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		if err != nil {
 			return false
 		}
-		return true
+		// 额度耗尽时停止读取，上游请求随之关闭
+		return !info.StreamQuotaGuard.Exceeded()
 	})
 	if err != nil {
 		return nil, err
@@ -85,13 +85,50 @@ func NativeGeminiEmbeddingHandler(c *gin.Context, resp *http.Response, info *rel
 func GeminiTextGenerationStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
 	helper.SetEventStreamHeaders(c)
 
-	return geminiStreamHandler(c, info, resp, func(data string, geminiResponse *dto.GeminiChatResponse) bool {
+	usage, apiErr := geminiStreamHandler(c, info, resp, func(data string, geminiResponse *dto.GeminiChatResponse) bool {
+		if helper.ClientGoneWhileDraining(c, info) {
+			// 客户端已断开，继续读取上游以获取最终的 usageMetadata
+			return true
+		}
 		err := helper.StringData(c, data)
 		if err != nil {
+			if helper.ClientGoneWhileDraining(c, info) {
+				return true
+			}
 			logger.LogError(c, "failed to write stream data: "+err.Error())
 			return false
 		}
 		info.SendResponseCount++
 		return true
 	})
+	if apiErr == nil && info.StreamQuotaGuard.Exceeded() {
+		sendGeminiQuotaExhaustedFinish(c, usage)
+	}
+	return usage, apiErr
+}
+
+// sendGeminiQuotaExhaustedFinish 额度耗尽中止流式响应时，补发 finishReason 为 MAX_TOKENS 的结束块
+func sendGeminiQuotaExhaustedFinish(c *gin.Context, usage *dto.Usage) {
+	finishReason := "MAX_TOKENS"
+	finish := dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{
+			{
+				Content:      dto.GeminiChatContent{Role: "model", Parts: []dto.GeminiPart{}},
+				FinishReason: &finishReason,
+			},
+		},
+		UsageMetadata: dto.GeminiUsageMetadata{
+			PromptTokenCount:     usage.PromptTokens,
+			CandidatesTokenCount: usage.CompletionTokens,
+			TotalTokenCount:      usage.PromptTokens + usage.CompletionTokens,
+		},
+	}
+	data, err := common.Marshal(finish)
+	if err != nil {
+		common.SysLog("error marshalling gemini finish response: " + err.Error())
+		return
+	}
+	if err := helper.StringData(c, string(data)); err != nil {
+		logger.LogError(c, "failed to write stream data: "+err.Error())
+	}
 }
@@ -1299,6 +1299,7 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		}
 
 		// 统计图片数量
+		chunkText := strings.Builder{}
 		for _, candidate := range geminiResponse.Candidates {
 			for _, part := range candidate.Content.Parts {
 				if part.InlineData != nil && part.InlineData.MimeType != "" {
@@ -1306,6 +1307,7 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 				}
 				if part.Text != "" {
 					responseText.WriteString(part.Text)
+					chunkText.WriteString(part.Text)
 				}
 			}
 		}
@@ -1316,9 +1318,17 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			*usage = mappedUsage
 		}
 
-		return callback(data, &geminiResponse)
+		if !callback(data, &geminiResponse) {
+			return false
+		}
+		// 额度耗尽时停止读取，上游请求随之关闭
+		return service.ChargeStreamText(info, chunkText.String())
 	})
 
+	if info.StreamQuotaGuard.Exceeded() {
+		logger.LogWarn(c, fmt.Sprintf("quota exhausted mid-stream, aborting generation after ~%d completion tokens", info.StreamQuotaGuard.CompletionTokens))
+	}
+
 	if imageCount != 0 {
 		if usage.CompletionTokens == 0 {
 			usage.CompletionTokens = imageCount * 1400
@@ -1326,7 +1336,8 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 	}
 
 	if usage.CompletionTokens <= 0 {
-		if info.ReceivedResponseCount > 0 {
+		// 客户端中途断开时上游已处理输入，即使还没有输出也按预估的输入计费
+		if info.ReceivedResponseCount > 0 || common.GetContextKeyBool(c, constant.ContextKeyClientCancelled) {
 			usage = service.ResponseText2Usage(c, responseText.String(), info.UpstreamModelName, info.GetEstimatePromptTokens())
 		} else {
 			usage = &dto.Usage{}
@@ -1418,6 +1429,10 @@ func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *
 		return usage, err
 	}
 
+	if info.StreamQuotaGuard.Exceeded() {
+		_ = handleStream(c, info, helper.GenerateStopResponse(id, createAt, info.UpstreamModelName, constant.FinishReasonLength))
+	}
+
 	response := helper.GenerateFinalUsageResponse(id, createAt, info.UpstreamModelName, *usage)
 	handleErr := handleFinalStream(c, info, response)
 	if handleErr != nil {
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
@@ -259,3 +260,102 @@ func sendResponsesStreamData(c *gin.Context, streamResponse dto.ResponsesStreamR
 	}
 	helper.ResponseChunkData(c, streamResponse, data)
 }
+
+// streamChunkCompletionText 提取单个流式响应块中新生成的文本（正文、思考内容与工具调用参数），用于额度守卫计费
+func streamChunkCompletionText(relayMode int, data string) string {
+	var sb strings.Builder
+	if relayMode == relayconstant.RelayModeCompletions {
+		var streamResponse dto.CompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &streamResponse); err != nil {
+			return ""
+		}
+		for _, choice := range streamResponse.Choices {
+			sb.WriteString(choice.Text)
+		}
+		return sb.String()
+	}
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &streamResponse); err != nil {
+		return ""
+	}
+	for _, choice := range streamResponse.Choices {
+		sb.WriteString(choice.Delta.GetContentString())
+		sb.WriteString(choice.Delta.GetReasoningContent())
+		for _, toolCall := range choice.Delta.ToolCalls {
+			sb.WriteString(toolCall.Function.Name)
+			sb.WriteString(toolCall.Function.Arguments)
+		}
+	}
+	return sb.String()
+}
+
+// responsesStreamCompletionText 提取 Responses 流式事件中新生成的文本（正文、思考内容与工具调用参数），用于额度守卫计费
+func responsesStreamCompletionText(streamResponse dto.ResponsesStreamResponse) string {
+	switch streamResponse.Type {
+	case "response.output_text.delta",
+		"response.reasoning_text.delta",
+		"response.reasoning_summary_text.delta",
+		"response.function_call_arguments.delta":
+		return streamResponse.Delta
+	}
+	return ""
+}
+
+// sendResponsesQuotaExhaustedEvent 额度耗尽中止 Responses 流式响应时，基于最近的响应快照发送
+// incomplete_details.reason 为 max_output_tokens 的 response.incomplete 事件
+func sendResponsesQuotaExhaustedEvent(c *gin.Context, lastResponse *dto.OpenAIResponsesResponse) {
+	response := map[string]any{
+		"object":             "response",
+		"status":             "incomplete",
+		"incomplete_details": map[string]any{"reason": "max_output_tokens"},
+		"output":             []any{},
+	}
+	if lastResponse != nil {
+		response["id"] = lastResponse.ID
+		response["created_at"] = lastResponse.CreatedAt
+		response["model"] = lastResponse.Model
+	}
+	event := map[string]any{
+		"type":     "response.incomplete",
+		"response": response,
+	}
+	data, err := common.Marshal(event)
+	if err != nil {
+		return
+	}
+	helper.ResponseChunkData(c, dto.ResponsesStreamResponse{Type: "response.incomplete"}, string(data))
+}
+
+// buildQuotaExhaustedFinishData 额度耗尽中止流式响应时，基于最后一个响应块构造 finish_reason 为 length 的结束块，
+// 后续按入站格式转换为对应的结束事件
+func buildQuotaExhaustedFinishData(relayMode int, lastStreamData string) string {
+	var lastResponse dto.ChatCompletionsStreamResponse
+	_ = common.UnmarshalJsonStr(lastStreamData, &lastResponse)
+	finishReason := constant.FinishReasonLength
+	var finish any
+	if relayMode == relayconstant.RelayModeCompletions {
+		finish = map[string]any{
+			"id":      lastResponse.Id,
+			"object":  "text_completion",
+			"created": lastResponse.Created,
+			"model":   lastResponse.Model,
+			"choices": []map[string]any{{"text": "", "index": 0, "finish_reason": finishReason}},
+		}
+	} else {
+		finish = dto.ChatCompletionsStreamResponse{
+			Id:                lastResponse.Id,
+			Object:            "chat.completion.chunk",
+			Created:           lastResponse.Created,
+			Model:             lastResponse.Model,
+			SystemFingerprint: lastResponse.SystemFingerprint,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{
+				{FinishReason: &finishReason},
+			},
+		}
+	}
+	data, err := common.Marshal(finish)
+	if err != nil {
+		return lastStreamData
+	}
+	return string(data)
+}
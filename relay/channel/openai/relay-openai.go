@@ -141,10 +141,26 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 
 			lastStreamData = data
 			streamItems = append(streamItems, data)
+
+			if info.StreamQuotaGuard != nil && !service.ChargeStreamText(info, streamChunkCompletionText(info.RelayMode, data)) {
+				return false
+			}
 		}
 		return true
 	})
 
+	// 额度耗尽：上游请求已随流读取结束而关闭，补发最后一个响应块，并以 finish_reason=length 的结束块收尾
+	if info.StreamQuotaGuard.Exceeded() {
+		logger.LogWarn(c, fmt.Sprintf("quota exhausted mid-stream, aborting generation after ~%d completion tokens", info.StreamQuotaGuard.CompletionTokens))
+		if lastStreamData != "" {
+			if err := HandleStreamFormat(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
+				common.SysLog("error handling stream format: " + err.Error())
+			}
+		}
+		lastStreamData = buildQuotaExhaustedFinishData(info.RelayMode, lastStreamData)
+		secondLastStreamData = ""
+	}
+
 	// 对音频模型，从倒数第二个stream data中提取usage信息
 	if isAudioModel && secondLastStreamData != "" {
 		var streamResp struct {
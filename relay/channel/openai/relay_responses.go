@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
@@ -78,6 +79,7 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 
 	var usage = &dto.Usage{}
 	var responseTextBuilder strings.Builder
+	var lastResponse *dto.OpenAIResponsesResponse
 
 	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
 
@@ -85,6 +87,12 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		var streamResponse dto.ResponsesStreamResponse
 		if err := common.UnmarshalJsonStr(data, &streamResponse); err == nil {
 			sendResponsesStreamData(c, streamResponse, data)
+			if streamResponse.Response != nil {
+				lastResponse = streamResponse.Response
+			}
+			if info.StreamQuotaGuard != nil && !service.ChargeStreamText(info, responsesStreamCompletionText(streamResponse)) {
+				return false
+			}
 			switch streamResponse.Type {
 			case "response.completed":
 				if streamResponse.Response != nil {
@@ -130,6 +138,12 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		return true
 	})
 
+	// 额度耗尽：上游请求已随流读取结束而关闭，以 max_output_tokens 原因的 response.incomplete 事件收尾
+	if info.StreamQuotaGuard.Exceeded() {
+		logger.LogWarn(c, fmt.Sprintf("quota exhausted mid-stream, aborting generation after ~%d completion tokens", info.StreamQuotaGuard.CompletionTokens))
+		sendResponsesQuotaExhaustedEvent(c, lastResponse)
+	}
+
 	if usage.CompletionTokens == 0 {
 		// 计算输出文本的 token 数量
 		tempStr := responseTextBuilder.String()
@@ -138,9 +152,14 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 			completionTokens := service.CountTextToken(tempStr, info.UpstreamModelName)
 			usage.CompletionTokens = completionTokens
 		}
+		// 中止时思考内容与工具调用参数也已生成，按额度守卫累计的数量计费
+		if info.StreamQuotaGuard.Exceeded() && info.StreamQuotaGuard.CompletionTokens > usage.CompletionTokens {
+			usage.CompletionTokens = info.StreamQuotaGuard.CompletionTokens
+		}
 	}
 
-	if usage.PromptTokens == 0 && usage.CompletionTokens != 0 {
+	// 客户端中途断开时上游已处理输入，即使还没有输出也按预估的输入计费
+	if usage.PromptTokens == 0 && (usage.CompletionTokens != 0 || common.GetContextKeyBool(c, constant.ContextKeyClientCancelled)) {
 		usage.PromptTokens = info.GetEstimatePromptTokens()
 	}
 
@@ -0,0 +1,108 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOaiStreamHandlerAbortsWhenQuotaExhausted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	oldStreamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 300
+	t.Cleanup(func() {
+		constant.StreamingTimeout = oldStreamingTimeout
+	})
+
+	guard := &relaycommon.StreamQuotaGuard{Budget: 10, CompletionPerToken: 1}
+	info := &relaycommon.RelayInfo{
+		RelayMode:        relayconstant.RelayModeChatCompletions,
+		RelayFormat:      types.RelayFormatOpenAI,
+		StreamQuotaGuard: guard,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName: "gpt-4o-mini",
+		},
+	}
+
+	var body strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&body, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":"chunk%d hello world "},"finish_reason":null}]}`+"\n", i)
+	}
+	body.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":100,"total_tokens":105}}` + "\n")
+	body.WriteString("data: [DONE]\n")
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(body.String()))}
+
+	usage, apiErr := OaiStreamHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.True(t, guard.Exceeded())
+
+	output := recorder.Body.String()
+	require.Contains(t, output, `"finish_reason":"length"`)
+	require.Contains(t, output, "[DONE]")
+	require.NotContains(t, output, "chunk19")
+	require.NotContains(t, output, `"finish_reason":"stop"`)
+	// 按实际发出的文本计费，而不是上游最终的 usage
+	require.Greater(t, usage.CompletionTokens, 0)
+	require.Less(t, usage.CompletionTokens, 100)
+}
+
+func TestOaiResponsesStreamHandlerAbortsWhenQuotaExhausted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+
+	oldStreamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 300
+	t.Cleanup(func() {
+		constant.StreamingTimeout = oldStreamingTimeout
+	})
+
+	guard := &relaycommon.StreamQuotaGuard{Budget: 10, CompletionPerToken: 1}
+	info := &relaycommon.RelayInfo{
+		RelayMode:        relayconstant.RelayModeResponses,
+		RelayFormat:      types.RelayFormatOpenAIResponses,
+		StreamQuotaGuard: guard,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName: "gpt-4o-mini",
+		},
+	}
+
+	var body strings.Builder
+	body.WriteString(`data: {"type":"response.created","response":{"id":"resp_1","object":"response","created_at":1,"status":"in_progress","model":"gpt-4o-mini"}}` + "\n")
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&body, `data: {"type":"response.reasoning_summary_text.delta","item_id":"rs_1","delta":"thinking%d about the answer "}`+"\n", i)
+	}
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&body, `data: {"type":"response.output_text.delta","item_id":"msg_1","delta":"chunk%d hello world "}`+"\n", i)
+	}
+	body.WriteString(`data: {"type":"response.completed","response":{"id":"resp_1","object":"response","status":"completed","model":"gpt-4o-mini","usage":{"input_tokens":5,"output_tokens":100,"total_tokens":105}}}` + "\n")
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(body.String()))}
+
+	usage, apiErr := OaiResponsesStreamHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.True(t, guard.Exceeded())
+
+	output := recorder.Body.String()
+	require.Contains(t, output, "event: response.incomplete")
+	require.Contains(t, output, `"reason":"max_output_tokens"`)
+	require.Contains(t, output, `"id":"resp_1"`)
+	require.NotContains(t, output, "chunk9")
+	require.NotContains(t, output, "response.completed")
+	require.Greater(t, usage.CompletionTokens, 0)
+	require.Less(t, usage.CompletionTokens, 100)
+}
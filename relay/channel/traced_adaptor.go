@@ -0,0 +1,117 @@
+package channel
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/tracing"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedAdaptor wraps an Adaptor with one span per request conversion, upstream call and response handling
+type tracedAdaptor struct {
+	Adaptor
+}
+
+// WithTracing returns a wrapped adaptor when tracing is enabled, otherwise a itself
+func WithTracing(a Adaptor) Adaptor {
+	if a == nil || !tracing.Enabled() {
+		return a
+	}
+	return &tracedAdaptor{Adaptor: a}
+}
+
+func (t *tracedAdaptor) startSpan(c *gin.Context, name string, info *relaycommon.RelayInfo) func(err error) {
+	attrs := []attribute.KeyValue{attribute.String("adaptor", t.Adaptor.GetChannelName())}
+	if info != nil && info.ChannelMeta != nil {
+		attrs = append(attrs, attribute.Int("channel.id", info.ChannelId), attribute.String("relay.upstream_model", info.UpstreamModelName))
+	}
+	span, end := tracing.StartSpan(c, name, attrs...)
+	return func(err error) {
+		tracing.RecordError(span, err)
+		end()
+	}
+}
+
+func (t *tracedAdaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	end := t.startSpan(c, "ConvertOpenAIRequest", info)
+	converted, err := t.Adaptor.ConvertOpenAIRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	end := t.startSpan(c, "ConvertRerankRequest", nil)
+	converted, err := t.Adaptor.ConvertRerankRequest(c, relayMode, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	end := t.startSpan(c, "ConvertEmbeddingRequest", info)
+	converted, err := t.Adaptor.ConvertEmbeddingRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	end := t.startSpan(c, "ConvertAudioRequest", info)
+	converted, err := t.Adaptor.ConvertAudioRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	end := t.startSpan(c, "ConvertImageRequest", info)
+	converted, err := t.Adaptor.ConvertImageRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	end := t.startSpan(c, "ConvertOpenAIResponsesRequest", info)
+	converted, err := t.Adaptor.ConvertOpenAIResponsesRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	end := t.startSpan(c, "ConvertClaudeRequest", info)
+	converted, err := t.Adaptor.ConvertClaudeRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+func (t *tracedAdaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
+	end := t.startSpan(c, "ConvertGeminiRequest", info)
+	converted, err := t.Adaptor.ConvertGeminiRequest(c, info, request)
+	end(err)
+	return converted, err
+}
+
+// DoRequest keeps its span current during the upstream call so doRequest injects it as traceparent
+func (t *tracedAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	end := t.startSpan(c, "DoRequest", info)
+	resp, err := t.Adaptor.DoRequest(c, info, requestBody)
+	if httpResp, ok := resp.(*http.Response); ok && httpResp != nil {
+		tracing.SetAttributes(c, attribute.Int("http.response.status_code", httpResp.StatusCode))
+	}
+	end(err)
+	return resp, err
+}
+
+func (t *tracedAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (any, *types.NewAPIError) {
+	end := t.startSpan(c, "DoResponse", info)
+	usage, apiErr := t.Adaptor.DoResponse(c, resp, info)
+	if apiErr != nil {
+		end(apiErr)
+	} else {
+		end(nil)
+	}
+	return usage, apiErr
+}
@@ -92,7 +92,9 @@ type RelayInfo struct {
 	TokenUnlimited    bool
 	StartTime         time.Time
 	FirstResponseTime time.Time
-	isFirstResponse   bool
+	// UpstreamRequestTime 最近一次向上游发起请求的时间，用于计算网关内部排队耗时
+	UpstreamRequestTime time.Time
+	isFirstResponse     bool
 	//SendLastReasoningResponse bool
 	IsStream               bool
 	IsGeminiBatchEmbedding bool
@@ -125,9 +127,13 @@ type RelayInfo struct {
 	// Billing 是计费会话，封装了预扣费/结算/退款的统一生命周期。
 	// 免费模型时为 nil。
 	Billing BillingSettler
-	// BillingSource indicates whether this request is billed from wallet quota or subscription.
-	// "" or "wallet" => wallet; "subscription" => subscription
+	// StreamQuotaGuard 在流式响应中累计补全费用，额度耗尽时中止生成。未启用或按次计费时为 nil。
+	StreamQuotaGuard *StreamQuotaGuard
+	// BillingSource indicates whether this request is billed from wallet quota, subscription or organization.
+	// "" or "wallet" => wallet; "subscription" => subscription; "organization" => organization
 	BillingSource string
+	// OrganizationId is set when the token belongs to an organization; such requests are billed from the organization wallet.
+	OrganizationId int
 	// SubscriptionId is the user_subscriptions.id used when BillingSource == "subscription"
 	SubscriptionId int
 	// SubscriptionPreConsumed is the amount pre-consumed on subscription item (quota units or 1)
@@ -457,6 +463,7 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 		TokenGroup:     tokenGroup,
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
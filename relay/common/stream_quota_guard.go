@@ -0,0 +1,31 @@
+package common
+
+// StreamQuotaGuard 流式响应的额度守卫：累计已生成的补全 token，
+// 预估费用超出调用方本次请求可用的额度（含允许透支的部分）时，通知流处理器中止上游请求
+type StreamQuotaGuard struct {
+	Budget             float64 // 本次请求最多可消耗的额度
+	PromptQuota        float64 // 输入部分的预估费用
+	CompletionPerToken float64 // 每个补全 token 的费用
+	CompletionTokens   int     // 已生成的补全 token 数（估算）
+	exceeded           bool
+}
+
+// AddCompletionTokens 累计补全 token，费用超出预算时返回 false。守卫为 nil 时始终返回 true
+func (g *StreamQuotaGuard) AddCompletionTokens(n int) bool {
+	if g == nil {
+		return true
+	}
+	if g.exceeded {
+		return false
+	}
+	g.CompletionTokens += n
+	if g.PromptQuota+float64(g.CompletionTokens)*g.CompletionPerToken > g.Budget {
+		g.exceeded = true
+	}
+	return !g.exceeded
+}
+
+// Exceeded 返回流式响应是否因额度耗尽被中止
+func (g *StreamQuotaGuard) Exceeded() bool {
+	return g != nil && g.exceeded
+}
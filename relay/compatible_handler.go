@@ -248,6 +248,13 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	modelName := relayInfo.OriginModelName
 
 	tokenName := ctx.GetString("token_name")
+	// Anthropic 语义的 input_tokens 不含缓存读写 tokens，分档按完整的提示 tokens 判断
+	tierPromptTokens := promptTokens
+	if relayInfo.GetFinalRequestRelayFormat() == types.RelayFormatClaude {
+		tierPromptTokens += cacheTokens + cachedCreationTokens
+	}
+	service.ApplyUsagePriceTier(ctx, relayInfo, tierPromptTokens)
+
 	completionRatio := relayInfo.PriceData.CompletionRatio
 	cacheRatio := relayInfo.PriceData.CacheRatio
 	imageRatio := relayInfo.PriceData.ImageRatio
@@ -440,6 +447,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	}
 	logContent := strings.Join(extraContent, ", ")
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	service.AppendRelayPerformance(relayInfo, other, completionTokens)
 	if adminRejectReason != "" {
 		other["reject_reason"] = adminRejectReason
 	}
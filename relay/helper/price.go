@@ -61,8 +61,9 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var audioRatio float64
 	var audioCompletionRatio float64
 	var freeModel bool
+	var preConsumedTokens int
 	if !usePrice {
-		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
+		preConsumedTokens = common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
 			preConsumedTokens += meta.MaxTokens
 		}
@@ -132,6 +133,14 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		QuotaToPreConsume:    preConsumedQuota,
 	}
 
+	// 按估算的提示 tokens 选择分档倍率，结算时再按实际用量重新选择
+	if !usePrice {
+		priceData.PriceTiers = ratio_setting.GetTieredRatio(info.OriginModelName)
+		if priceData.ApplyPromptTier(promptTokens) != nil && !freeModel {
+			priceData.QuotaToPreConsume = int(float64(preConsumedTokens) * priceData.ModelRatio * groupRatioInfo.GroupRatio)
+		}
+	}
+
 	if common.DebugEnabled {
 		println(fmt.Sprintf("model_price_helper result: %s", priceData.ToSetting()))
 	}
@@ -34,6 +34,15 @@ func getScannerBufferSize() int {
 	return DefaultMaxScannerBufferSize
 }
 
+// ClientGoneWhileDraining 判断客户端已断开且渠道开启了 DrainOnClientCancel。此时流处理器应跳过写入、
+// 继续读取上游以获取最终的 usage，而不是因写入失败中止读取
+func ClientGoneWhileDraining(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	if !info.ChannelSetting.DrainOnClientCancel {
+		return false
+	}
+	return common.GetContextKeyBool(c, constant.ContextKeyClientCancelled) || c.Request.Context().Err() != nil
+}
+
 func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, dataHandler func(data string) bool) {
 
 	if resp == nil || dataHandler == nil {
@@ -113,6 +122,13 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 
 	ctx = context.WithValue(ctx, "stop_chan", stopChan)
 
+	// 客户端断开后默认停止读取上游；渠道开启 DrainOnClientCancel 时继续读完，以获取上游最终的 usage
+	drainOnCancel := info.ChannelSetting.DrainOnClientCancel
+	clientDone := c.Request.Context().Done()
+	if drainOnCancel {
+		clientDone = nil
+	}
+
 	// Handle ping data sending with improved error handling
 	if pingEnabled && pingTicker != nil {
 		wg.Add(1)
@@ -219,7 +235,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				return
 			case <-ctx.Done():
 				return
-			case <-c.Request.Context().Done():
+			case <-clientDone:
 				return
 			default:
 			}
@@ -269,15 +285,26 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 	})
 
 	// 主循环等待完成或超时
-	select {
-	case <-ticker.C:
-		// 超时处理逻辑
-		logger.LogError(c, "streaming timeout")
-	case <-stopChan:
-		// 正常结束
-		logger.LogInfo(c, "streaming finished")
-	case <-c.Request.Context().Done():
-		// 客户端断开连接
-		logger.LogInfo(c, "client disconnected")
+	requestDone := c.Request.Context().Done()
+	for {
+		select {
+		case <-ticker.C:
+			// 超时处理逻辑
+			logger.LogError(c, "streaming timeout")
+			return
+		case <-stopChan:
+			// 正常结束
+			logger.LogInfo(c, "streaming finished")
+			return
+		case <-requestDone:
+			// 客户端断开连接，按已收到的内容计费
+			common.SetContextKey(c, constant.ContextKeyClientCancelled, true)
+			if !drainOnCancel {
+				logger.LogInfo(c, "client disconnected")
+				return
+			}
+			logger.LogInfo(c, "client disconnected, draining upstream stream")
+			requestDone = nil
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -519,3 +520,43 @@ func TestStreamScannerHandler_PingInterleavesWithSlowUpstream(t *testing.T) {
 	assert.GreaterOrEqual(t, pingCount, 3,
 		"expected at least 3 pings during 5s stream with 1s ping interval; got %d", pingCount)
 }
+
+// ---------- Client cancellation ----------
+
+func TestStreamScannerHandler_ClientCancelStopsReading(t *testing.T) {
+	t.Parallel()
+
+	body := &slowReader{r: strings.NewReader(buildSSEBody(200)), delay: 5 * time.Millisecond}
+	c, resp, info := setupStreamTest(t, body)
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	cancel()
+
+	var count atomic.Int64
+	StreamScannerHandler(c, resp, info, func(data string) bool {
+		count.Add(1)
+		return true
+	})
+
+	assert.Less(t, count.Load(), int64(200))
+	assert.True(t, c.GetBool(string(constant.ContextKeyClientCancelled)))
+}
+
+func TestStreamScannerHandler_ClientCancelDrainsUpstream(t *testing.T) {
+	t.Parallel()
+
+	c, resp, info := setupStreamTest(t, strings.NewReader(buildSSEBody(200)))
+	info.ChannelSetting.DrainOnClientCancel = true
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	cancel()
+
+	var count atomic.Int64
+	StreamScannerHandler(c, resp, info, func(data string) bool {
+		count.Add(1)
+		return true
+	})
+
+	assert.Equal(t, int64(200), count.Load())
+	assert.True(t, c.GetBool(string(constant.ContextKeyClientCancelled)))
+}
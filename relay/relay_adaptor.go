@@ -51,6 +51,10 @@ import (
 )
 
 func GetAdaptor(apiType int) channel.Adaptor {
+	return channel.WithTracing(getAdaptor(apiType))
+}
+
+func getAdaptor(apiType int) channel.Adaptor {
 	switch apiType {
 	case constant.APITypeAli:
 		return &ali.Adaptor{}
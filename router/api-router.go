@@ -1,6 +1,7 @@
 package router
 
 import (
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
 
@@ -12,6 +13,28 @@ import (
 )
 
 func SetApiRouter(router *gin.Engine) {
+	usersRead := middleware.RequirePermission(constant.AdminPermissionUsersRead)
+	usersWrite := middleware.RequirePermission(constant.AdminPermissionUsersWrite)
+	channelsRead := middleware.RequirePermission(constant.AdminPermissionChannelsRead)
+	channelsWrite := middleware.RequirePermission(constant.AdminPermissionChannelsWrite)
+	channelsReadKey := middleware.RequirePermission(constant.AdminPermissionChannelsReadKey)
+	billingRead := middleware.RequirePermission(constant.AdminPermissionBillingRead)
+	billingWrite := middleware.RequirePermission(constant.AdminPermissionBillingWrite)
+	billingRefund := middleware.RequirePermission(constant.AdminPermissionBillingRefund)
+	logsRead := middleware.RequirePermission(constant.AdminPermissionLogsRead)
+	logsReadContent := middleware.RequirePermission(constant.AdminPermissionLogsRead, constant.AdminPermissionLogsReadContent)
+	logsDelete := middleware.RequirePermission(constant.AdminPermissionLogsDelete)
+	modelsRead := middleware.RequirePermission(constant.AdminPermissionModelsRead)
+	modelsWrite := middleware.RequirePermission(constant.AdminPermissionModelsWrite)
+	deploymentsRead := middleware.RequirePermission(constant.AdminPermissionDeploymentsRead)
+	deploymentsWrite := middleware.RequirePermission(constant.AdminPermissionDeploymentsWrite)
+	optionsRead := middleware.RequirePermission(constant.AdminPermissionOptionsRead)
+	optionsWrite := middleware.RequirePermission(constant.AdminPermissionOptionsWrite)
+	rolesManage := middleware.RequirePermission(constant.AdminPermissionRolesManage)
+	auditRead := middleware.RequirePermission(constant.AdminPermissionAuditRead)
+	payloadRead := middleware.RequirePermission(constant.AdminPermissionPayloadRead)
+	payloadReplay := middleware.RequirePermission(constant.AdminPermissionPayloadReplay, constant.AdminPermissionChannelsWrite)
+
 	apiRouter := router.Group("/api")
 	apiRouter.Use(middleware.RouteTag("api"))
 	apiRouter.Use(gzip.Gzip(gzip.DefaultCompression))
@@ -23,7 +46,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
-		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/test", channelsRead, controller.TestStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/user-agreement", controller.GetUserAgreement)
 		apiRouter.GET("/privacy-policy", controller.GetPrivacyPolicy)
@@ -44,6 +67,13 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/telegram/bind", middleware.CriticalRateLimit(), controller.TelegramBind)
 		// Standard OAuth providers (GitHub, Discord, OIDC, LinuxDO) - unified route
 		apiRouter.GET("/oauth/:provider", middleware.CriticalRateLimit(), controller.HandleOAuth)
+		// SAML SP endpoints, the login result is handed to the OAuth callback above via a one-time code
+		samlRoute := apiRouter.Group("/saml")
+		{
+			samlRoute.GET("/metadata", controller.SAMLMetadata)
+			samlRoute.GET("/login", middleware.CriticalRateLimit(), controller.SAMLLogin)
+			samlRoute.POST("/acs", middleware.CriticalRateLimit(), controller.SAMLACS)
+		}
 		apiRouter.GET("/ratio_config", middleware.CriticalRateLimit(), controller.GetRatioConfig)
 
 		apiRouter.POST("/stripe/webhook", controller.StripeWebhook)
@@ -83,6 +113,9 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.GET("/topup/info", controller.GetTopUpInfo)
 				selfRoute.GET("/topup/self", controller.GetUserTopUps)
+				selfRoute.GET("/statement", controller.GetSelfStatement)
+				selfRoute.GET("/ledger", controller.GetUserQuotaLedgers)
+				selfRoute.GET("/credit_grants", controller.GetUserCreditGrants)
 				selfRoute.POST("/topup", middleware.CriticalRateLimit(), controller.TopUp)
 				selfRoute.POST("/pay", middleware.CriticalRateLimit(), controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
@@ -109,25 +142,25 @@ func SetApiRouter(router *gin.Engine) {
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
+			adminRoute.Use(middleware.AdminAudit(constant.AuditEntityUser))
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/topup", controller.GetAllTopUps)
-				adminRoute.POST("/topup/complete", controller.AdminCompleteTopUp)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id/oauth/bindings", controller.GetUserOAuthBindingsByAdmin)
-				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", controller.UnbindCustomOAuthByAdmin)
-				adminRoute.DELETE("/:id/bindings/:binding_type", controller.AdminClearUserBinding)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
-				adminRoute.DELETE("/:id/reset_passkey", controller.AdminResetPasskey)
+				adminRoute.GET("/", usersRead, controller.GetAllUsers)
+				adminRoute.GET("/topup", billingRead, controller.GetAllTopUps)
+				adminRoute.POST("/topup/complete", billingRefund, middleware.AdminAudit(constant.AuditEntityTopUp), controller.AdminCompleteTopUp)
+				adminRoute.GET("/search", usersRead, controller.SearchUsers)
+				adminRoute.GET("/:id/oauth/bindings", usersRead, controller.GetUserOAuthBindingsByAdmin)
+				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", usersWrite, controller.UnbindCustomOAuthByAdmin)
+				adminRoute.DELETE("/:id/bindings/:binding_type", usersWrite, controller.AdminClearUserBinding)
+				adminRoute.GET("/:id", usersRead, controller.GetUser)
+				adminRoute.POST("/", usersWrite, controller.CreateUser)
+				adminRoute.POST("/manage", usersWrite, controller.ManageUser)
+				adminRoute.PUT("/", usersWrite, controller.UpdateUser)
+				adminRoute.DELETE("/:id", usersWrite, controller.DeleteUser)
+				adminRoute.DELETE("/:id/reset_passkey", usersWrite, controller.AdminResetPasskey)
 
 				// Admin 2FA routes
-				adminRoute.GET("/2fa/stats", controller.Admin2FAStats)
-				adminRoute.DELETE("/:id/2fa", controller.AdminDisable2FA)
+				adminRoute.GET("/2fa/stats", usersRead, controller.Admin2FAStats)
+				adminRoute.DELETE("/:id/2fa", usersWrite, controller.AdminDisable2FA)
 			}
 		}
 
@@ -143,19 +176,19 @@ func SetApiRouter(router *gin.Engine) {
 			subscriptionRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestCreemPay)
 		}
 		subscriptionAdminRoute := apiRouter.Group("/subscription/admin")
-		subscriptionAdminRoute.Use(middleware.AdminAuth())
+		subscriptionAdminRoute.Use(middleware.AdminAudit(constant.AuditEntitySubscriptionPlan))
 		{
-			subscriptionAdminRoute.GET("/plans", controller.AdminListSubscriptionPlans)
-			subscriptionAdminRoute.POST("/plans", controller.AdminCreateSubscriptionPlan)
-			subscriptionAdminRoute.PUT("/plans/:id", controller.AdminUpdateSubscriptionPlan)
-			subscriptionAdminRoute.PATCH("/plans/:id", controller.AdminUpdateSubscriptionPlanStatus)
-			subscriptionAdminRoute.POST("/bind", controller.AdminBindSubscription)
+			subscriptionAdminRoute.GET("/plans", billingRead, controller.AdminListSubscriptionPlans)
+			subscriptionAdminRoute.POST("/plans", billingWrite, controller.AdminCreateSubscriptionPlan)
+			subscriptionAdminRoute.PUT("/plans/:id", billingWrite, controller.AdminUpdateSubscriptionPlan)
+			subscriptionAdminRoute.PATCH("/plans/:id", billingWrite, controller.AdminUpdateSubscriptionPlanStatus)
+			subscriptionAdminRoute.POST("/bind", billingWrite, middleware.AdminAudit(constant.AuditEntitySubscription), controller.AdminBindSubscription)
 
 			// User subscription management (admin)
-			subscriptionAdminRoute.GET("/users/:id/subscriptions", controller.AdminListUserSubscriptions)
-			subscriptionAdminRoute.POST("/users/:id/subscriptions", controller.AdminCreateUserSubscription)
-			subscriptionAdminRoute.POST("/user_subscriptions/:id/invalidate", controller.AdminInvalidateUserSubscription)
-			subscriptionAdminRoute.DELETE("/user_subscriptions/:id", controller.AdminDeleteUserSubscription)
+			subscriptionAdminRoute.GET("/users/:id/subscriptions", billingRead, controller.AdminListUserSubscriptions)
+			subscriptionAdminRoute.POST("/users/:id/subscriptions", billingWrite, middleware.AdminAudit(constant.AuditEntitySubscription), controller.AdminCreateUserSubscription)
+			subscriptionAdminRoute.POST("/user_subscriptions/:id/invalidate", billingRefund, middleware.AdminAudit(constant.AuditEntitySubscription), controller.AdminInvalidateUserSubscription)
+			subscriptionAdminRoute.DELETE("/user_subscriptions/:id", billingRefund, middleware.AdminAudit(constant.AuditEntitySubscription), controller.AdminDeleteUserSubscription)
 		}
 
 		// Subscription payment callbacks (no auth)
@@ -164,79 +197,79 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/subscription/epay/return", controller.SubscriptionEpayReturn)
 		apiRouter.POST("/subscription/epay/return", controller.SubscriptionEpayReturn)
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
+		optionRoute.Use(middleware.AdminAudit(constant.AuditEntityOption))
 		{
-			optionRoute.GET("/", controller.GetOptions)
-			optionRoute.PUT("/", controller.UpdateOption)
-			optionRoute.GET("/channel_affinity_cache", controller.GetChannelAffinityCacheStats)
-			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
-			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
-			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
+			optionRoute.GET("/", optionsRead, controller.GetOptions)
+			optionRoute.PUT("/", optionsWrite, controller.UpdateOption)
+			optionRoute.GET("/channel_affinity_cache", optionsRead, controller.GetChannelAffinityCacheStats)
+			optionRoute.DELETE("/channel_affinity_cache", optionsWrite, controller.ClearChannelAffinityCache)
+			optionRoute.POST("/rest_model_ratio", optionsWrite, controller.ResetModelRatio)
+			optionRoute.POST("/migrate_console_setting", optionsWrite, controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
 
-		// Custom OAuth provider management (root only)
+		// Custom OAuth provider management
 		customOAuthRoute := apiRouter.Group("/custom-oauth-provider")
-		customOAuthRoute.Use(middleware.RootAuth())
+		customOAuthRoute.Use(middleware.AdminAudit(constant.AuditEntityOAuthProvider))
 		{
-			customOAuthRoute.POST("/discovery", controller.FetchCustomOAuthDiscovery)
-			customOAuthRoute.GET("/", controller.GetCustomOAuthProviders)
-			customOAuthRoute.GET("/:id", controller.GetCustomOAuthProvider)
-			customOAuthRoute.POST("/", controller.CreateCustomOAuthProvider)
-			customOAuthRoute.PUT("/:id", controller.UpdateCustomOAuthProvider)
-			customOAuthRoute.DELETE("/:id", controller.DeleteCustomOAuthProvider)
+			customOAuthRoute.POST("/discovery", optionsWrite, controller.FetchCustomOAuthDiscovery)
+			customOAuthRoute.GET("/", optionsRead, controller.GetCustomOAuthProviders)
+			customOAuthRoute.GET("/:id", optionsRead, controller.GetCustomOAuthProvider)
+			customOAuthRoute.POST("/", optionsWrite, controller.CreateCustomOAuthProvider)
+			customOAuthRoute.PUT("/:id", optionsWrite, controller.UpdateCustomOAuthProvider)
+			customOAuthRoute.DELETE("/:id", optionsWrite, controller.DeleteCustomOAuthProvider)
 		}
 		performanceRoute := apiRouter.Group("/performance")
-		performanceRoute.Use(middleware.RootAuth())
+		performanceRoute.Use(middleware.AdminAudit(constant.AuditEntitySystem))
 		{
-			performanceRoute.GET("/stats", controller.GetPerformanceStats)
-			performanceRoute.DELETE("/disk_cache", controller.ClearDiskCache)
-			performanceRoute.POST("/reset_stats", controller.ResetPerformanceStats)
-			performanceRoute.POST("/gc", controller.ForceGC)
+			performanceRoute.GET("/stats", optionsRead, controller.GetPerformanceStats)
+			performanceRoute.DELETE("/disk_cache", optionsWrite, controller.ClearDiskCache)
+			performanceRoute.POST("/reset_stats", optionsWrite, controller.ResetPerformanceStats)
+			performanceRoute.POST("/gc", optionsWrite, controller.ForceGC)
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
-		ratioSyncRoute.Use(middleware.RootAuth())
 		{
-			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
-			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
+			ratioSyncRoute.GET("/channels", optionsRead, controller.GetSyncableChannels)
+			ratioSyncRoute.POST("/fetch", optionsWrite, controller.FetchUpstreamRatios)
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
+		channelRoute.Use(middleware.AdminAudit(constant.AuditEntityChannel))
 		{
-			channelRoute.GET("/", controller.GetAllChannels)
-			channelRoute.GET("/search", controller.SearchChannels)
-			channelRoute.GET("/models", controller.ChannelListModels)
-			channelRoute.GET("/models_enabled", controller.EnabledListModels)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
-			channelRoute.GET("/test", controller.TestAllChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.POST("/tag/disabled", controller.DisableTagChannels)
-			channelRoute.POST("/tag/enabled", controller.EnableTagChannels)
-			channelRoute.PUT("/tag", controller.EditTagChannels)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
-			channelRoute.POST("/batch", controller.DeleteChannelBatch)
-			channelRoute.POST("/fix", controller.FixChannelsAbilities)
-			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
-			channelRoute.POST("/fetch_models", controller.FetchModels)
-			channelRoute.POST("/codex/oauth/start", controller.StartCodexOAuth)
-			channelRoute.POST("/codex/oauth/complete", controller.CompleteCodexOAuth)
-			channelRoute.POST("/:id/codex/oauth/start", controller.StartCodexOAuthForChannel)
-			channelRoute.POST("/:id/codex/oauth/complete", controller.CompleteCodexOAuthForChannel)
-			channelRoute.POST("/:id/codex/refresh", controller.RefreshCodexChannelCredential)
-			channelRoute.GET("/:id/codex/usage", controller.GetCodexChannelUsage)
-			channelRoute.POST("/ollama/pull", controller.OllamaPullModel)
-			channelRoute.POST("/ollama/pull/stream", controller.OllamaPullModelStream)
-			channelRoute.DELETE("/ollama/delete", controller.OllamaDeleteModel)
-			channelRoute.GET("/ollama/version/:id", controller.OllamaVersion)
-			channelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
-			channelRoute.GET("/tag/models", controller.GetTagModels)
-			channelRoute.POST("/copy/:id", controller.CopyChannel)
-			channelRoute.POST("/multi_key/manage", controller.ManageMultiKeys)
+			channelRoute.GET("/", channelsRead, controller.GetAllChannels)
+			channelRoute.GET("/search", channelsRead, controller.SearchChannels)
+			channelRoute.GET("/models", channelsRead, controller.ChannelListModels)
+			channelRoute.GET("/models_enabled", channelsRead, controller.EnabledListModels)
+			channelRoute.GET("/performance", channelsRead, controller.GetChannelPerformance)
+			channelRoute.GET("/:id", channelsRead, controller.GetChannel)
+			channelRoute.POST("/:id/key", channelsReadKey, middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
+			channelRoute.GET("/test", channelsRead, controller.TestAllChannels)
+			channelRoute.GET("/test/:id", channelsRead, controller.TestChannel)
+			channelRoute.GET("/update_balance", channelsRead, controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", channelsRead, controller.UpdateChannelBalance)
+			channelRoute.POST("/", channelsWrite, controller.AddChannel)
+			channelRoute.PUT("/", channelsWrite, controller.UpdateChannel)
+			channelRoute.DELETE("/disabled", channelsWrite, controller.DeleteDisabledChannel)
+			channelRoute.POST("/tag/disabled", channelsWrite, controller.DisableTagChannels)
+			channelRoute.POST("/tag/enabled", channelsWrite, controller.EnableTagChannels)
+			channelRoute.PUT("/tag", channelsWrite, controller.EditTagChannels)
+			channelRoute.DELETE("/:id", channelsWrite, controller.DeleteChannel)
+			channelRoute.POST("/batch", channelsWrite, controller.DeleteChannelBatch)
+			channelRoute.POST("/fix", channelsWrite, controller.FixChannelsAbilities)
+			channelRoute.GET("/fetch_models/:id", channelsRead, controller.FetchUpstreamModels)
+			channelRoute.POST("/fetch_models", channelsWrite, controller.FetchModels)
+			channelRoute.POST("/codex/oauth/start", channelsWrite, controller.StartCodexOAuth)
+			channelRoute.POST("/codex/oauth/complete", channelsWrite, controller.CompleteCodexOAuth)
+			channelRoute.POST("/:id/codex/oauth/start", channelsWrite, controller.StartCodexOAuthForChannel)
+			channelRoute.POST("/:id/codex/oauth/complete", channelsWrite, controller.CompleteCodexOAuthForChannel)
+			channelRoute.POST("/:id/codex/refresh", channelsWrite, controller.RefreshCodexChannelCredential)
+			channelRoute.GET("/:id/codex/usage", channelsRead, controller.GetCodexChannelUsage)
+			channelRoute.POST("/ollama/pull", channelsWrite, controller.OllamaPullModel)
+			channelRoute.POST("/ollama/pull/stream", channelsWrite, controller.OllamaPullModelStream)
+			channelRoute.DELETE("/ollama/delete", channelsWrite, controller.OllamaDeleteModel)
+			channelRoute.GET("/ollama/version/:id", channelsRead, controller.OllamaVersion)
+			channelRoute.POST("/batch/tag", channelsWrite, controller.BatchSetChannelTag)
+			channelRoute.GET("/tag/models", channelsRead, controller.GetTagModels)
+			channelRoute.POST("/copy/:id", channelsWrite, controller.CopyChannel)
+			channelRoute.POST("/multi_key/manage", channelsWrite, controller.ManageMultiKeys)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -246,9 +279,42 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.GET("/:id", controller.GetToken)
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
 		}
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/", controller.GetUserOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.GET("/all", usersRead, controller.GetAllOrganizations)
+			organizationRoute.POST("/:id/admin_quota", billingWrite, middleware.AdminAudit(constant.AuditEntityOrganization), controller.AdminAdjustOrganizationQuota)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.POST("/:id/quota", controller.TransferQuotaToOrganization)
+			organizationRoute.POST("/:id/owner", controller.TransferOrganizationOwnership)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/members", controller.AddOrganizationMember)
+			organizationRoute.PUT("/:id/members", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.GET("/:id/tokens", controller.GetOrganizationTokens)
+			organizationRoute.POST("/:id/tokens", controller.AddOrganizationToken)
+			organizationRoute.DELETE("/:id/tokens/:token_id", controller.DeleteOrganizationToken)
+			organizationRoute.GET("/:id/usage", controller.GetOrganizationUsage)
+			organizationRoute.GET("/:id/logs", controller.GetOrganizationLogs)
+			organizationRoute.GET("/:id/statement", controller.GetOrganizationStatement)
+		}
+		subTokenRoute := apiRouter.Group("/sub_token")
+		subTokenRoute.Use(middleware.CriticalRateLimit(), middleware.SubTokenAuth())
+		{
+			subTokenRoute.GET("/", controller.GetSubTokens)
+			subTokenRoute.GET("/:id", controller.GetSubToken)
+			subTokenRoute.POST("/", controller.AddSubToken)
+			subTokenRoute.PUT("/", controller.UpdateSubToken)
+			subTokenRoute.DELETE("/:id", controller.DeleteSubToken)
+		}
 
 		usageRoute := apiRouter.Group("/usage")
 		usageRoute.Use(middleware.CORS(), middleware.CriticalRateLimit())
@@ -261,108 +327,158 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
+		redemptionRoute.Use(middleware.AdminAudit(constant.AuditEntityRedemption))
 		{
-			redemptionRoute.GET("/", controller.GetAllRedemptions)
-			redemptionRoute.GET("/search", controller.SearchRedemptions)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionRoute.GET("/", billingRead, controller.GetAllRedemptions)
+			redemptionRoute.GET("/search", billingRead, controller.SearchRedemptions)
+			redemptionRoute.GET("/:id", billingRead, controller.GetRedemption)
+			redemptionRoute.POST("/", billingWrite, controller.AddRedemption)
+			redemptionRoute.PUT("/", billingWrite, controller.UpdateRedemption)
+			redemptionRoute.DELETE("/invalid", billingWrite, controller.DeleteInvalidRedemption)
+			redemptionRoute.DELETE("/:id", billingWrite, controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", logsRead, controller.GetAllLogs)
+		logRoute.DELETE("/", logsDelete, middleware.AdminAudit(constant.AuditEntityLog), controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", logsRead, controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), controller.GetChannelAffinityUsageCacheStats)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/channel_affinity_usage_cache", logsRead, controller.GetChannelAffinityUsageCacheStats)
+		logRoute.GET("/search", logsRead, controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), middleware.SearchRateLimit(), controller.SearchUserLogs)
+		logRoute.GET("/archive", logsRead, controller.GetLogArchives)
+		logRoute.GET("/archive/:id", logsRead, controller.QueryLogArchive)
+		logRoute.POST("/archive/:id/restore", logsDelete, middleware.AdminAudit(constant.AuditEntityLog), controller.RestoreLogArchive)
 
 		dataRoute := apiRouter.Group("/data")
-		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/", billingRead, controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
 
+		analyticsRoute := apiRouter.Group("/analytics")
+		analyticsRoute.GET("/", billingRead, controller.GetUsageAnalytics)
+		analyticsRoute.GET("/self", middleware.UserAuth(), controller.GetUserUsageAnalytics)
+
+		statementRoute := apiRouter.Group("/statement")
+		statementRoute.GET("/", billingRead, controller.GetStatement)
+		statementRoute.POST("/regenerate", billingWrite, middleware.AdminAudit(constant.AuditEntityStatement), controller.RegenerateStatement)
+
+		creditRoute := apiRouter.Group("/credit")
+		creditRoute.GET("/", billingRead, controller.GetCreditAccounts)
+		creditRoute.GET("/settlements", billingRead, controller.GetCreditSettlements)
+		creditRoute.POST("/limit", billingWrite, middleware.AdminAudit(constant.AuditEntityCredit), controller.UpdateCreditLimit)
+		creditRoute.POST("/settle", billingWrite, middleware.AdminAudit(constant.AuditEntityCredit), controller.SettleCreditInvoice)
+
+		ledgerRoute := apiRouter.Group("/ledger")
+		ledgerRoute.GET("/", billingRead, controller.GetQuotaLedgers)
+		ledgerRoute.GET("/mismatches", billingRead, controller.GetQuotaLedgerMismatches)
+
 		logRoute.Use(middleware.CORS(), middleware.CriticalRateLimit())
 		{
 			logRoute.GET("/token", middleware.TokenAuthReadOnly(), controller.GetLogByKey)
 		}
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
+		groupRoute.Use(modelsRead)
 		{
 			groupRoute.GET("/", controller.GetGroups)
 		}
 
 		prefillGroupRoute := apiRouter.Group("/prefill_group")
-		prefillGroupRoute.Use(middleware.AdminAuth())
+		prefillGroupRoute.Use(middleware.AdminAudit(constant.AuditEntityPrefillGroup))
 		{
-			prefillGroupRoute.GET("/", controller.GetPrefillGroups)
-			prefillGroupRoute.POST("/", controller.CreatePrefillGroup)
-			prefillGroupRoute.PUT("/", controller.UpdatePrefillGroup)
-			prefillGroupRoute.DELETE("/:id", controller.DeletePrefillGroup)
+			prefillGroupRoute.GET("/", modelsRead, controller.GetPrefillGroups)
+			prefillGroupRoute.POST("/", modelsWrite, controller.CreatePrefillGroup)
+			prefillGroupRoute.PUT("/", modelsWrite, controller.UpdatePrefillGroup)
+			prefillGroupRoute.DELETE("/:id", modelsWrite, controller.DeletePrefillGroup)
 		}
 
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", logsReadContent, controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
-			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/", logsReadContent, controller.GetAllTask)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
-		vendorRoute.Use(middleware.AdminAuth())
+		vendorRoute.Use(middleware.AdminAudit(constant.AuditEntityVendor))
 		{
-			vendorRoute.GET("/", controller.GetAllVendors)
-			vendorRoute.GET("/search", controller.SearchVendors)
-			vendorRoute.GET("/:id", controller.GetVendorMeta)
-			vendorRoute.POST("/", controller.CreateVendorMeta)
-			vendorRoute.PUT("/", controller.UpdateVendorMeta)
-			vendorRoute.DELETE("/:id", controller.DeleteVendorMeta)
+			vendorRoute.GET("/", modelsRead, controller.GetAllVendors)
+			vendorRoute.GET("/search", modelsRead, controller.SearchVendors)
+			vendorRoute.GET("/:id", modelsRead, controller.GetVendorMeta)
+			vendorRoute.POST("/", modelsWrite, controller.CreateVendorMeta)
+			vendorRoute.PUT("/", modelsWrite, controller.UpdateVendorMeta)
+			vendorRoute.DELETE("/:id", modelsWrite, controller.DeleteVendorMeta)
 		}
 
 		modelsRoute := apiRouter.Group("/models")
-		modelsRoute.Use(middleware.AdminAuth())
+		modelsRoute.Use(middleware.AdminAudit(constant.AuditEntityModel))
 		{
-			modelsRoute.GET("/sync_upstream/preview", controller.SyncUpstreamPreview)
-			modelsRoute.POST("/sync_upstream", controller.SyncUpstreamModels)
-			modelsRoute.GET("/missing", controller.GetMissingModels)
-			modelsRoute.GET("/", controller.GetAllModelsMeta)
-			modelsRoute.GET("/search", controller.SearchModelsMeta)
-			modelsRoute.GET("/:id", controller.GetModelMeta)
-			modelsRoute.POST("/", controller.CreateModelMeta)
-			modelsRoute.PUT("/", controller.UpdateModelMeta)
-			modelsRoute.DELETE("/:id", controller.DeleteModelMeta)
+			modelsRoute.GET("/sync_upstream/preview", modelsRead, controller.SyncUpstreamPreview)
+			modelsRoute.POST("/sync_upstream", modelsWrite, controller.SyncUpstreamModels)
+			modelsRoute.GET("/missing", modelsRead, controller.GetMissingModels)
+			modelsRoute.GET("/", modelsRead, controller.GetAllModelsMeta)
+			modelsRoute.GET("/search", modelsRead, controller.SearchModelsMeta)
+			modelsRoute.GET("/:id", modelsRead, controller.GetModelMeta)
+			modelsRoute.POST("/", modelsWrite, controller.CreateModelMeta)
+			modelsRoute.PUT("/", modelsWrite, controller.UpdateModelMeta)
+			modelsRoute.DELETE("/:id", modelsWrite, controller.DeleteModelMeta)
 		}
 
 		// Deployments (model deployment management)
 		deploymentsRoute := apiRouter.Group("/deployments")
-		deploymentsRoute.Use(middleware.AdminAuth())
+		deploymentsRoute.Use(middleware.AdminAudit(constant.AuditEntityDeployment))
+		{
+			deploymentsRoute.GET("/settings", deploymentsRead, controller.GetModelDeploymentSettings)
+			deploymentsRoute.POST("/settings/test-connection", deploymentsRead, controller.TestIoNetConnection)
+			deploymentsRoute.GET("/", deploymentsRead, controller.GetAllDeployments)
+			deploymentsRoute.GET("/search", deploymentsRead, controller.SearchDeployments)
+			deploymentsRoute.POST("/test-connection", deploymentsRead, controller.TestIoNetConnection)
+			deploymentsRoute.GET("/hardware-types", deploymentsRead, controller.GetHardwareTypes)
+			deploymentsRoute.GET("/locations", deploymentsRead, controller.GetLocations)
+			deploymentsRoute.GET("/available-replicas", deploymentsRead, controller.GetAvailableReplicas)
+			deploymentsRoute.POST("/price-estimation", deploymentsRead, controller.GetPriceEstimation)
+			deploymentsRoute.GET("/check-name", deploymentsRead, controller.CheckClusterNameAvailability)
+			deploymentsRoute.POST("/", deploymentsWrite, controller.CreateDeployment)
+
+			deploymentsRoute.GET("/:id", deploymentsRead, controller.GetDeployment)
+			deploymentsRoute.GET("/:id/logs", deploymentsRead, controller.GetDeploymentLogs)
+			deploymentsRoute.GET("/:id/containers", deploymentsRead, controller.ListDeploymentContainers)
+			deploymentsRoute.GET("/:id/containers/:container_id", deploymentsRead, controller.GetContainerDetails)
+			deploymentsRoute.PUT("/:id", deploymentsWrite, controller.UpdateDeployment)
+			deploymentsRoute.PUT("/:id/name", deploymentsWrite, controller.UpdateDeploymentName)
+			deploymentsRoute.POST("/:id/extend", deploymentsWrite, controller.ExtendDeployment)
+			deploymentsRoute.DELETE("/:id", deploymentsWrite, controller.DeleteDeployment)
+		}
+
+		// Custom admin roles composed from fine-grained permissions
+		adminRoleRoute := apiRouter.Group("/admin_role")
+		adminRoleRoute.Use(rolesManage, middleware.AdminAudit(constant.AuditEntityAdminRole))
+		{
+			adminRoleRoute.GET("/", controller.GetAdminRoles)
+			adminRoleRoute.GET("/permissions", controller.GetAdminPermissions)
+			adminRoleRoute.GET("/:id", controller.GetAdminRole)
+			adminRoleRoute.POST("/", controller.CreateAdminRole)
+			adminRoleRoute.PUT("/", controller.UpdateAdminRole)
+			adminRoleRoute.DELETE("/:id", controller.DeleteAdminRole)
+			adminRoleRoute.POST("/assign", controller.AssignAdminRole)
+		}
+
+		auditLogRoute := apiRouter.Group("/audit_log")
+		auditLogRoute.Use(auditRead)
+		{
+			auditLogRoute.GET("/", controller.GetAuditLogs)
+		}
+
+		// Opt-in request/response payload captures, see operation_setting.PayloadCaptureSetting
+		payloadCaptureRoute := apiRouter.Group("/payload_capture")
+		payloadCaptureRoute.Use(payloadRead, middleware.AdminAudit(constant.AuditEntityPayloadCapture))
 		{
-			deploymentsRoute.GET("/settings", controller.GetModelDeploymentSettings)
-			deploymentsRoute.POST("/settings/test-connection", controller.TestIoNetConnection)
-			deploymentsRoute.GET("/", controller.GetAllDeployments)
-			deploymentsRoute.GET("/search", controller.SearchDeployments)
-			deploymentsRoute.POST("/test-connection", controller.TestIoNetConnection)
-			deploymentsRoute.GET("/hardware-types", controller.GetHardwareTypes)
-			deploymentsRoute.GET("/locations", controller.GetLocations)
-			deploymentsRoute.GET("/available-replicas", controller.GetAvailableReplicas)
-			deploymentsRoute.POST("/price-estimation", controller.GetPriceEstimation)
-			deploymentsRoute.GET("/check-name", controller.CheckClusterNameAvailability)
-			deploymentsRoute.POST("/", controller.CreateDeployment)
-
-			deploymentsRoute.GET("/:id", controller.GetDeployment)
-			deploymentsRoute.GET("/:id/logs", controller.GetDeploymentLogs)
-			deploymentsRoute.GET("/:id/containers", controller.ListDeploymentContainers)
-			deploymentsRoute.GET("/:id/containers/:container_id", controller.GetContainerDetails)
-			deploymentsRoute.PUT("/:id", controller.UpdateDeployment)
-			deploymentsRoute.PUT("/:id/name", controller.UpdateDeploymentName)
-			deploymentsRoute.POST("/:id/extend", controller.ExtendDeployment)
-			deploymentsRoute.DELETE("/:id", controller.DeleteDeployment)
+			payloadCaptureRoute.GET("/", controller.GetPayloadCaptures)
+			payloadCaptureRoute.GET("/:id", controller.GetPayloadCapture)
+			// 重放会使用渠道密钥真实调用上游且不计费，需单独授权并限流
+			payloadCaptureRoute.POST("/:id/replay", middleware.CriticalRateLimit(), payloadReplay, controller.ReplayPayloadCapture)
 		}
 	}
 }
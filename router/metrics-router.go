@@ -0,0 +1,34 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetMetricsRouter 在主端口挂载 /metrics；配置了 METRICS_PORT 时改由独立端口提供
+func SetMetricsRouter(router *gin.Engine) {
+	if !metrics.Enabled() || constant.MetricsPort != "" {
+		return
+	}
+	router.GET("/metrics", middleware.RouteTag("metrics"), middleware.MetricsAuth(), gin.WrapH(metrics.Handler()))
+}
+
+// StartMetricsServer 配置了 METRICS_PORT 时在独立端口提供 /metrics，便于只在内网暴露
+func StartMetricsServer() {
+	if !metrics.Enabled() || constant.MetricsPort == "" {
+		return
+	}
+	server := gin.New()
+	server.Use(gin.Recovery())
+	server.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(metrics.Handler()))
+	go func() {
+		if err := server.Run(":" + constant.MetricsPort); err != nil {
+			common.SysError("failed to start metrics server: " + err.Error())
+		}
+	}()
+	common.SysLog("metrics server listening on port " + constant.MetricsPort)
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetScimRouter SCIM 2.0 用户与组同步接口，供身份提供方调用
+func SetScimRouter(router *gin.Engine) {
+	scimRouter := router.Group("/scim/v2")
+	scimRouter.Use(middleware.RouteTag("scim"))
+	scimRouter.Use(middleware.GlobalAPIRateLimit())
+	scimRouter.Use(middleware.ScimAuth())
+	{
+		scimRouter.GET("/ServiceProviderConfig", controller.ScimServiceProviderConfig)
+
+		userRoute := scimRouter.Group("/Users")
+		userRoute.Use(middleware.AdminAudit(constant.AuditEntityUser))
+		{
+			userRoute.GET("", controller.ScimListUsers)
+			userRoute.POST("", controller.ScimCreateUser)
+			userRoute.GET("/:id", controller.ScimGetUser)
+			userRoute.PUT("/:id", controller.ScimReplaceUser)
+			userRoute.PATCH("/:id", controller.ScimPatchUser)
+			userRoute.DELETE("/:id", controller.ScimDeleteUser)
+		}
+
+		groupRoute := scimRouter.Group("/Groups")
+		{
+			groupRoute.GET("", controller.ScimListGroups)
+			groupRoute.POST("", controller.ScimCreateGroup)
+			groupRoute.GET("/:id", controller.ScimGetGroup)
+			groupRoute.PUT("/:id", controller.ScimReplaceGroup)
+			groupRoute.PATCH("/:id", controller.ScimPatchGroup)
+			groupRoute.DELETE("/:id", controller.ScimDeleteGroup)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	auditLogCleanupTickInterval = 1 * time.Hour
+	auditLogCleanupBatchSize    = 500
+)
+
+var (
+	auditLogCleanupOnce    sync.Once
+	auditLogCleanupRunning atomic.Bool
+)
+
+// StartAuditLogCleanupTask 按保留天数定期清理过期的审计日志，仅在主节点运行
+func StartAuditLogCleanupTask() {
+	auditLogCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("audit log cleanup task started: tick=%s", auditLogCleanupTickInterval))
+			ticker := time.NewTicker(auditLogCleanupTickInterval)
+			defer ticker.Stop()
+
+			runAuditLogCleanupOnce()
+			for range ticker.C {
+				runAuditLogCleanupOnce()
+			}
+		})
+	})
+}
+
+func runAuditLogCleanupOnce() {
+	if !auditLogCleanupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer auditLogCleanupRunning.Store(false)
+
+	retentionDays := operation_setting.GetAuditSetting().RetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+	ctx := context.Background()
+	targetTimestamp := common.GetTimestamp() - int64(retentionDays)*24*3600
+	count, err := model.DeleteOldAuditLogs(ctx, targetTimestamp, auditLogCleanupBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("audit log cleanup task failed: %v", err))
+		return
+	}
+	if count > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("audit log cleanup: deleted=%d, retention_days=%d", count, retentionDays))
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// checkCreditDunning 扣费后检查后付费客户的信用额度使用比例，达到新的催缴档位时通知用户（组织通知所有者）
+func checkCreditDunning(relayInfo *relaycommon.RelayInfo) {
+	if relayInfo == nil {
+		return
+	}
+	subjectType, subjectId := model.CreditSubjectUser, relayInfo.UserId
+	if relayInfo.OrganizationId > 0 {
+		subjectType, subjectId = model.CreditSubjectOrganization, relayInfo.OrganizationId
+	} else if creditLimit, err := model.GetUserCreditLimit(relayInfo.UserId); err != nil || creditLimit <= 0 {
+		return
+	}
+	gopool.Go(func() {
+		if err := CheckCreditDunning(subjectType, subjectId); err != nil {
+			common.SysError(fmt.Sprintf("failed to check credit dunning for %s %d: %s", subjectType, subjectId, err.Error()))
+		}
+	})
+}
+
+// CheckCreditDunning 检查指定用户或组织的信用额度使用情况，进入新的催缴档位时发送通知
+func CheckCreditDunning(subjectType string, subjectId int) error {
+	account, err := model.GetCreditAccount(subjectType, subjectId)
+	if err != nil {
+		return err
+	}
+	if account.CreditLimit <= 0 || account.Quota >= 0 {
+		return nil
+	}
+	usedPercent := int(int64(-account.Quota) * 100 / int64(account.CreditLimit))
+	level := operation_setting.GetCreditSetting().DunningLevel(usedPercent)
+	if level <= account.CreditDunning {
+		return nil
+	}
+	advanced, err := model.AdvanceCreditDunning(subjectType, subjectId, level)
+	if err != nil || !advanced {
+		return err
+	}
+	return sendCreditDunningNotify(account, level)
+}
+
+func sendCreditDunningNotify(account *model.CreditAccount, level int) error {
+	recipientId := account.SubjectId
+	subject := "您的账户"
+	if account.SubjectType == model.CreditSubjectOrganization {
+		org, err := model.GetOrganizationById(account.SubjectId)
+		if err != nil {
+			return err
+		}
+		recipientId = org.OwnerId
+		subject = fmt.Sprintf("组织「%s」", account.Name)
+	}
+	user, err := model.GetUserById(recipientId, false)
+	if err != nil {
+		return err
+	}
+	prompt := fmt.Sprintf("%s的信用额度已使用 %d%%", subject, level)
+	content := "{{value}}，当前欠款 {{value}}，信用额度 {{value}}。额度用尽后请求将被拒绝，请及时结算账单。"
+	values := []interface{}{prompt, logger.FormatQuota(-account.Quota), logger.FormatQuota(account.CreditLimit)}
+	return NotifyUser(user.Id, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeCreditDunning, prompt, content, values))
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	creditGrantExpireTickInterval = 1 * time.Hour
+	creditGrantExpireBatchSize    = 500
+)
+
+var (
+	creditGrantExpireOnce    sync.Once
+	creditGrantExpireRunning atomic.Bool
+)
+
+// StartCreditGrantExpireTask 定期处理到期的赠送额度，从余额中扣除未消耗的部分，仅在主节点运行
+func StartCreditGrantExpireTask() {
+	creditGrantExpireOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("credit grant expire task started: tick=%s", creditGrantExpireTickInterval))
+			ticker := time.NewTicker(creditGrantExpireTickInterval)
+			defer ticker.Stop()
+
+			runCreditGrantExpireOnce()
+			for range ticker.C {
+				runCreditGrantExpireOnce()
+			}
+		})
+	})
+}
+
+func runCreditGrantExpireOnce() {
+	if !creditGrantExpireRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer creditGrantExpireRunning.Store(false)
+
+	ctx := context.Background()
+	count, err := expireCreditGrants(common.GetTimestamp())
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("credit grant expire task failed: %v", err))
+	}
+	if count > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("credit grant expire: expired=%d", count))
+	}
+}
+
+// expireCreditGrants 处理所有已到期的赠送额度，返回处理的条数
+func expireCreditGrants(now int64) (int, error) {
+	count := 0
+	for {
+		grants, err := model.GetExpiredCreditGrants(now, creditGrantExpireBatchSize)
+		if err != nil {
+			return count, err
+		}
+		for _, grant := range grants {
+			expired, err := model.ExpireCreditGrant(grant.Id)
+			if err != nil {
+				return count, err
+			}
+			count++
+			if expired > 0 {
+				model.RecordLog(grant.UserId, model.LogTypeSystem, fmt.Sprintf("赠送额度已过期，扣除剩余 %s（来源：%s）", logger.LogQuota(expired), grant.Source))
+			}
+		}
+		if len(grants) < creditGrantExpireBatchSize {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedCreditGrant(t *testing.T, userId int, amount int, priority int, expiresAt int64) *model.CreditGrant {
+	t.Helper()
+	grant := &model.CreditGrant{
+		UserId:    userId,
+		Source:    "checkin",
+		Amount:    amount,
+		Remaining: amount,
+		Priority:  priority,
+		Status:    model.CreditGrantStatusActive,
+		ExpiresAt: expiresAt,
+		CreatedAt: common.GetTimestamp(),
+	}
+	require.NoError(t, model.DB.Create(grant).Error)
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ? AND credit_grant_until < ?", userId, expiresAt).
+		Update("credit_grant_until", expiresAt).Error)
+	return grant
+}
+
+func creditGrantRemaining(t *testing.T, id int) int {
+	t.Helper()
+	var grant model.CreditGrant
+	require.NoError(t, model.DB.First(&grant, id).Error)
+	return grant.Remaining
+}
+
+func TestWalletFunding_ConsumesSoonestExpiringGrantFirst(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 1000)
+	now := common.GetTimestamp()
+	later := seedCreditGrant(t, 1, 100, 0, now+7200)
+	sooner := seedCreditGrant(t, 1, 100, 0, now+3600)
+
+	funding := &WalletFunding{userId: 1, requestId: "req-1"}
+	require.NoError(t, funding.PreConsume(150))
+	assert.Equal(t, 0, creditGrantRemaining(t, sooner.Id))
+	assert.Equal(t, 50, creditGrantRemaining(t, later.Id))
+
+	// 退还时先补回最后消耗的赠送额度
+	require.NoError(t, funding.Settle(-80))
+	assert.Equal(t, 30, creditGrantRemaining(t, sooner.Id))
+	assert.Equal(t, 100, creditGrantRemaining(t, later.Id))
+	assert.Equal(t, 930, getUserQuota(t, 1))
+}
+
+func TestExpireCreditGrants_DeductsRemainingAndLogs(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 1000)
+	now := common.GetTimestamp()
+	grant := seedCreditGrant(t, 1, 300, 0, now-1)
+	require.NoError(t, model.DB.Model(grant).Update("remaining", 120).Error)
+	seedCreditGrant(t, 1, 100, 0, now+3600)
+
+	count, err := expireCreditGrants(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 880, getUserQuota(t, 1))
+
+	var expired model.CreditGrant
+	require.NoError(t, model.DB.First(&expired, grant.Id).Error)
+	assert.Equal(t, model.CreditGrantStatusExpired, expired.Status)
+	assert.Equal(t, 0, expired.Remaining)
+	assert.Equal(t, int64(1), countLogs(t))
+
+	count, err = expireCreditGrants(now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWalletRelayInfo(userId int) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		UserId:          userId,
+		RequestId:       "req-credit",
+		IsPlayground:    true,
+		ForcePreConsume: true,
+		UserSetting:     dto.UserSetting{BillingPreference: "wallet_only"},
+	}
+}
+
+func TestNewBillingSession_WalletOverdraftsUpToCreditLimit(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 100)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	_, apiErr := NewBillingSession(c, newWalletRelayInfo(1), 300)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, types.ErrorCodeInsufficientUserQuota, apiErr.GetErrorCode())
+
+	require.NoError(t, model.SetCreditLimit(model.CreditSubjectUser, 1, 500))
+	session, apiErr := NewBillingSession(c, newWalletRelayInfo(1), 300)
+	require.Nil(t, apiErr)
+	assert.Equal(t, 300, session.GetPreConsumedQuota())
+	assert.Equal(t, -200, getUserQuota(t, 1))
+
+	_, apiErr = NewBillingSession(c, newWalletRelayInfo(1), 400)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, types.ErrorCodeInsufficientUserQuota, apiErr.GetErrorCode())
+}
+
+func TestCheckCreditDunning_NotifiesEachLevelOnceAndSettleResets(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, -450)
+	limit := constant.NotifyLimitCount
+	constant.NotifyLimitCount = 10
+	t.Cleanup(func() { constant.NotifyLimitCount = limit })
+	require.NoError(t, model.SetCreditLimit(model.CreditSubjectUser, 1, 500))
+
+	require.NoError(t, CheckCreditDunning(model.CreditSubjectUser, 1))
+	account, err := model.GetCreditAccount(model.CreditSubjectUser, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 80, account.CreditDunning)
+
+	advanced, err := model.AdvanceCreditDunning(model.CreditSubjectUser, 1, 80)
+	require.NoError(t, err)
+	assert.False(t, advanced, "the same level must not be notified twice")
+
+	settlement, err := model.SettleCreditInvoice(model.CreditSubjectUser, 1, 12.5, "INV-001", "", 99)
+	require.NoError(t, err)
+	assert.Equal(t, 450, settlement.Quota)
+	assert.Equal(t, 0, getUserQuota(t, 1))
+	account, err = model.GetCreditAccount(model.CreditSubjectUser, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, account.CreditDunning)
+
+	_, err = model.SettleCreditInvoice(model.CreditSubjectUser, 1, 0, "INV-002", "", 99)
+	assert.ErrorIs(t, err, model.ErrNoOutstandingCredit)
+}
+
+func TestCreditDunning_ResetWhenTopUpClearsDebt(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, -450)
+	require.NoError(t, model.SetCreditLimit(model.CreditSubjectUser, 1, 500))
+	_, err := model.AdvanceCreditDunning(model.CreditSubjectUser, 1, 80)
+	require.NoError(t, err)
+
+	topUp := model.QuotaLedgerRef{Source: model.QuotaSourceTopUp, RefId: "trade-1"}
+	require.NoError(t, model.IncreaseUserQuota(1, 200, true, topUp))
+	account, err := model.GetCreditAccount(model.CreditSubjectUser, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 80, account.CreditDunning, "balance is still negative")
+
+	require.NoError(t, model.IncreaseUserQuota(1, 300, true, topUp))
+	account, err = model.GetCreditAccount(model.CreditSubjectUser, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 50, account.Quota)
+	assert.Equal(t, 0, account.CreditDunning)
+}
@@ -7,12 +7,12 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// FundingSource — 资金来源接口（钱包 or 订阅）
+// FundingSource — 资金来源接口（钱包 / 订阅 / 组织）
 // ---------------------------------------------------------------------------
 
 // FundingSource 抽象了预扣费的资金来源。
 type FundingSource interface {
-	// Source 返回资金来源标识："wallet" 或 "subscription"
+	// Source 返回资金来源标识："wallet"、"subscription" 或 "organization"
 	Source() string
 	// PreConsume 从该资金来源预扣 amount 额度
 	PreConsume(amount int) error
@@ -27,8 +27,14 @@ type FundingSource interface {
 // ---------------------------------------------------------------------------
 
 type WalletFunding struct {
-	userId   int
-	consumed int // 实际预扣的用户额度
+	userId      int
+	requestId   string
+	creditLimit int // 后付费信用额度，余额可透支到 -creditLimit
+	consumed    int // 实际预扣的用户额度
+}
+
+func (w *WalletFunding) ledgerRef() model.QuotaLedgerRef {
+	return model.QuotaLedgerRef{Source: model.QuotaSourceConsume, RefId: w.requestId}
 }
 
 func (w *WalletFunding) Source() string { return BillingSourceWallet }
@@ -37,7 +43,7 @@ func (w *WalletFunding) PreConsume(amount int) error {
 	if amount <= 0 {
 		return nil
 	}
-	if err := model.DecreaseUserQuota(w.userId, amount); err != nil {
+	if err := model.DecreaseUserQuota(w.userId, amount, w.ledgerRef()); err != nil {
 		return err
 	}
 	w.consumed = amount
@@ -49,9 +55,9 @@ func (w *WalletFunding) Settle(delta int) error {
 		return nil
 	}
 	if delta > 0 {
-		return model.DecreaseUserQuota(w.userId, delta)
+		return model.DecreaseUserQuota(w.userId, delta, w.ledgerRef())
 	}
-	return model.IncreaseUserQuota(w.userId, -delta, false)
+	return model.IncreaseUserQuota(w.userId, -delta, false, w.ledgerRef())
 }
 
 func (w *WalletFunding) Refund() error {
@@ -60,7 +66,43 @@ func (w *WalletFunding) Refund() error {
 	}
 	// IncreaseUserQuota 是 quota += N 的非幂等操作，不能重试，否则会多退额度。
 	// 订阅的 RefundSubscriptionPreConsume 有 requestId 幂等保护所以可以重试。
-	return model.IncreaseUserQuota(w.userId, w.consumed, false)
+	return model.IncreaseUserQuota(w.userId, w.consumed, false, w.ledgerRef())
+}
+
+// ---------------------------------------------------------------------------
+// OrganizationFunding — 组织资金来源实现
+// ---------------------------------------------------------------------------
+
+// OrganizationFunding 从组织的共享钱包扣费，并按令牌所属成员累计消耗以执行成员消费上限。
+type OrganizationFunding struct {
+	organizationId int
+	userId         int
+	consumed       int // 实际预扣的组织额度
+}
+
+func (o *OrganizationFunding) Source() string { return BillingSourceOrganization }
+
+func (o *OrganizationFunding) PreConsume(amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	if err := model.PreConsumeOrganizationQuota(o.organizationId, o.userId, amount); err != nil {
+		return err
+	}
+	o.consumed = amount
+	return nil
+}
+
+func (o *OrganizationFunding) Settle(delta int) error {
+	return model.AdjustOrganizationConsumedQuota(o.organizationId, o.userId, delta)
+}
+
+func (o *OrganizationFunding) Refund() error {
+	if o.consumed <= 0 {
+		return nil
+	}
+	// 与钱包相同，增量调整不是幂等操作，不能重试
+	return model.AdjustOrganizationConsumedQuota(o.organizationId, o.userId, -o.consumed)
 }
 
 // ---------------------------------------------------------------------------
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/samber/hot"
+)
+
+// 幂等请求的处理结果
+const (
+	IdempotencyAcquired = iota // 获得处理权，处理结束后必须调用 CompleteIdempotency 或 ReleaseIdempotency
+	IdempotencyReplay          // 相同 key 的请求已成功处理，返回保存的结果
+	IdempotencyInFlight        // 等待超时后相同 key 的请求仍在处理中
+	IdempotencyMismatch        // 相同 key 已用于内容不同的请求
+)
+
+// idempotencyLockTTL 处理中状态的最长保留时间，防止实例崩溃后 key 永久无法使用
+const idempotencyLockTTL = 15 * time.Minute
+
+const idempotencyPollInterval = 200 * time.Millisecond
+
+// idempotencyMemoryCapacity 未启用 Redis 时本实例最多保留的幂等记录数，超出后淘汰最久未使用的记录
+const idempotencyMemoryCapacity = 100000
+
+var (
+	ErrIdempotencyInFlight = errors.New("相同幂等键的请求正在处理中")
+	// ErrIdempotencyLockLost 处理时间超过 idempotencyLockTTL，幂等键已过期并被其他请求占用
+	ErrIdempotencyLockLost = errors.New("幂等键已过期并被其他请求占用")
+)
+
+// IdempotencyRecord 幂等键对应的处理状态与结果
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Owner       string `json:"owner"` // 获得处理权的请求标识，完成或释放时用于确认 key 仍归本次请求所有
+	Completed   bool   `json:"completed"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	Replayable  bool   `json:"replayable,omitempty"` // 响应体已完整保存，可以重放
+}
+
+// idempotencyStore 保存幂等记录，启用 Redis 时跨实例共享，否则仅在本实例内生效
+type idempotencyStore interface {
+	// claim 在 key 不存在时写入 record 并返回 true，否则返回已有记录
+	claim(key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	// replace 仅当 key 仍归 owner 所有时写入 record，返回是否写入
+	replace(key string, owner string, record *IdempotencyRecord, ttl time.Duration) (bool, error)
+	// release 仅当 key 仍归 owner 所有时删除
+	release(key string, owner string) error
+}
+
+func getIdempotencyStore() idempotencyStore {
+	if common.RedisEnabled && common.RDB != nil {
+		return redisIdempotencyStore{}
+	}
+	return defaultMemoryIdempotencyStore
+}
+
+// IdempotencyStoresResponse 判断是否保存响应体用于重放。只有启用 Redis 时保存，避免占用实例内存
+func IdempotencyStoresResponse() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+type redisIdempotencyStore struct{}
+
+// redisIdempotencyReplaceScript 在 key 仍归 ARGV[1] 所有时写入 ARGV[2]（为空时删除），ARGV[3] 为过期毫秒数
+var redisIdempotencyReplaceScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return 0
+end
+local ok, record = pcall(cjson.decode, value)
+if not ok or record.owner ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '' then
+	redis.call('DEL', KEYS[1])
+else
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+end
+return 1
+`)
+
+func (redisIdempotencyStore) claim(key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	data, err := common.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx := context.Background()
+	ok, err := common.RDB.SetNX(ctx, key, data, ttl).Result()
+	if err != nil || ok {
+		return nil, ok, err
+	}
+	value, err := common.RDB.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		// 已有记录恰好过期或被释放，重新争取
+		return redisIdempotencyStore{}.claim(key, record, ttl)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var existing IdempotencyRecord
+	if err := common.UnmarshalJsonStr(value, &existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (redisIdempotencyStore) replace(key string, owner string, record *IdempotencyRecord, ttl time.Duration) (bool, error) {
+	data, err := common.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	return redisIdempotencyStore{}.eval(key, owner, string(data), ttl)
+}
+
+func (redisIdempotencyStore) release(key string, owner string) error {
+	_, err := redisIdempotencyStore{}.eval(key, owner, "", 0)
+	return err
+}
+
+func (redisIdempotencyStore) eval(key string, owner string, value string, ttl time.Duration) (bool, error) {
+	result, err := redisIdempotencyReplaceScript.Run(context.Background(), common.RDB, []string{key}, owner, value, ttl.Milliseconds()).Int()
+	return result == 1, err
+}
+
+// memoryIdempotencyStore 未启用 Redis 时的本实例存储，容量有上限，过期记录由 janitor 清理
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex // 保证 claim 与 replace 的读写原子
+	entries *hot.HotCache[string, IdempotencyRecord]
+}
+
+var defaultMemoryIdempotencyStore = &memoryIdempotencyStore{
+	entries: hot.NewHotCache[string, IdempotencyRecord](hot.LRU, idempotencyMemoryCapacity).
+		WithTTL(idempotencyLockTTL).
+		WithJanitor().
+		Build(),
+}
+
+func (s *memoryIdempotencyStore) claim(key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok, _ := s.entries.Get(key); ok {
+		return &existing, false, nil
+	}
+	s.entries.SetWithTTL(key, *record, ttl)
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) replace(key string, owner string, record *IdempotencyRecord, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok, _ := s.entries.Get(key); !ok || existing.Owner != owner {
+		return false, nil
+	}
+	s.entries.SetWithTTL(key, *record, ttl)
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) release(key string, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok, _ := s.entries.Get(key); ok && existing.Owner == owner {
+		s.entries.Delete(key)
+	}
+	return nil
+}
+
+func idempotencyStoreKey(key string) string {
+	return "idempotency:" + key
+}
+
+// BeginIdempotency 尝试获得幂等键 key 的处理权。相同 key 的请求正在处理时最多等待 wait，
+// 期间对方成功完成则返回其结果，对方失败释放 key 则由本次请求接手处理。
+// 获得处理权时返回的记录中 Owner 标识本次请求，完成或释放 key 时需要传入
+func BeginIdempotency(ctx context.Context, key string, fingerprint string, wait time.Duration) (int, *IdempotencyRecord, error) {
+	store := getIdempotencyStore()
+	storeKey := idempotencyStoreKey(key)
+	deadline := time.Now().Add(wait)
+	lock := &IdempotencyRecord{Fingerprint: fingerprint, Owner: common.GetUUID()}
+	for {
+		existing, claimed, err := store.claim(storeKey, lock, idempotencyLockTTL)
+		if err != nil {
+			return 0, nil, err
+		}
+		if claimed {
+			return IdempotencyAcquired, lock, nil
+		}
+		if existing.Fingerprint != fingerprint {
+			return IdempotencyMismatch, existing, nil
+		}
+		if existing.Completed {
+			return IdempotencyReplay, existing, nil
+		}
+		if !time.Now().Before(deadline) {
+			return IdempotencyInFlight, existing, nil
+		}
+		select {
+		case <-ctx.Done():
+			return IdempotencyInFlight, existing, nil
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// CompleteIdempotency 保存成功处理的结果，在配置的时间窗口内重放。
+// key 已不归 owner 所有时不覆盖，返回 ErrIdempotencyLockLost
+func CompleteIdempotency(key string, owner string, record *IdempotencyRecord) error {
+	record.Completed = true
+	record.Owner = owner
+	if !IdempotencyStoresResponse() {
+		record.Body = nil
+		record.Replayable = false
+	}
+	window := time.Duration(operation_setting.GetIdempotencySetting().WindowSeconds) * time.Second
+	if window <= 0 {
+		return ReleaseIdempotency(key, owner)
+	}
+	replaced, err := getIdempotencyStore().replace(idempotencyStoreKey(key), owner, record, window)
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
+
+// ReleaseIdempotency 处理失败时释放幂等键，允许客户端重试。key 已不归 owner 所有时不做处理
+func ReleaseIdempotency(key string, owner string) error {
+	return getIdempotencyStore().release(idempotencyStoreKey(key), owner)
+}
+
+// RunIdempotent 以幂等键 key 执行 fn：相同 key 已成功执行过时直接返回 executed=false；
+// 仍在其他请求中执行时返回 ErrIdempotencyInFlight；fn 失败时释放 key 以便重试。key 为空时直接执行
+func RunIdempotent(key string, fn func() error) (executed bool, err error) {
+	if key == "" {
+		return true, fn()
+	}
+	wait := time.Duration(operation_setting.GetIdempotencySetting().WaitSeconds) * time.Second
+	status, lock, err := BeginIdempotency(context.Background(), key, "", wait)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case IdempotencyReplay:
+		return false, nil
+	case IdempotencyAcquired:
+	default:
+		return false, ErrIdempotencyInFlight
+	}
+	if err := fn(); err != nil {
+		if releaseErr := ReleaseIdempotency(key, lock.Owner); releaseErr != nil {
+			common.SysLog(fmt.Sprintf("failed to release idempotency key %s: %s", key, releaseErr.Error()))
+		}
+		return true, err
+	}
+	if err := CompleteIdempotency(key, lock.Owner, &IdempotencyRecord{}); err != nil {
+		common.SysLog(fmt.Sprintf("failed to complete idempotency key %s: %s", key, err.Error()))
+	}
+	return true, nil
+}
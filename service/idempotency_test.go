@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBeginIdempotencyReplayAndMismatch(t *testing.T) {
+	key := "test:replay"
+
+	status, lock, err := BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyAcquired, status)
+	defer ReleaseIdempotency(key, lock.Owner)
+
+	status, _, err = BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyInFlight, status)
+
+	require.NoError(t, CompleteIdempotency(key, lock.Owner, &IdempotencyRecord{Fingerprint: "fp", StatusCode: 200, Body: []byte("ok"), Replayable: true}))
+
+	status, record, err := BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyReplay, status)
+	// 未启用 Redis 时不保存响应体
+	require.False(t, record.Replayable)
+	require.Empty(t, record.Body)
+
+	status, _, err = BeginIdempotency(context.Background(), key, "other", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyMismatch, status)
+}
+
+func TestBeginIdempotencyWaitsForInFlight(t *testing.T) {
+	key := "test:wait"
+
+	status, lock, err := BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyAcquired, status)
+	defer ReleaseIdempotency(key, lock.Owner)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = CompleteIdempotency(key, lock.Owner, &IdempotencyRecord{Fingerprint: "fp", StatusCode: 200, Replayable: true})
+	}()
+	status, _, err = BeginIdempotency(context.Background(), key, "fp", 2*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyReplay, status)
+}
+
+func TestCompleteIdempotencyKeepsKeyTakenByAnotherRequest(t *testing.T) {
+	key := "test:lost"
+
+	status, stale, err := BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyAcquired, status)
+
+	// 模拟处理超时后 key 过期并被其他请求占用
+	require.NoError(t, ReleaseIdempotency(key, stale.Owner))
+	status, current, err := BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyAcquired, status)
+	defer ReleaseIdempotency(key, current.Owner)
+
+	err = CompleteIdempotency(key, stale.Owner, &IdempotencyRecord{Fingerprint: "fp", StatusCode: 200})
+	require.ErrorIs(t, err, ErrIdempotencyLockLost)
+	require.NoError(t, ReleaseIdempotency(key, stale.Owner))
+
+	status, _, err = BeginIdempotency(context.Background(), key, "fp", 0)
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyInFlight, status, "the current owner must keep the key")
+}
+
+func TestRunIdempotentReleasesOnFailure(t *testing.T) {
+	key := "test:run"
+
+	executed, err := RunIdempotent(key, func() error { return errors.New("boom") })
+	require.True(t, executed)
+	require.Error(t, err)
+
+	calls := 0
+	for i := 0; i < 2; i++ {
+		executed, err = RunIdempotent(key, func() error { calls++; return nil })
+		require.NoError(t, err)
+		require.Equal(t, i == 0, executed)
+	}
+	require.Equal(t, 1, calls)
+}
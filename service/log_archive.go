@@ -0,0 +1,251 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/objectstore"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const (
+	logArchiveExportBatchSize = 1000
+	logArchiveDeleteBatchSize = 500
+	logArchiveRestoreBatch    = 500
+	logArchiveMaxLineBytes    = 64 << 20
+)
+
+// NewLogArchiveStore 按配置创建归档存储
+func NewLogArchiveStore(setting *operation_setting.LogArchiveSetting) (objectstore.Store, error) {
+	switch setting.Storage {
+	case operation_setting.LogArchiveStorageS3:
+		return objectstore.NewS3(objectstore.S3Config{
+			Endpoint:        setting.S3Endpoint,
+			Region:          setting.S3Region,
+			Bucket:          setting.S3Bucket,
+			AccessKeyId:     setting.S3AccessKeyId,
+			SecretAccessKey: setting.S3Secret,
+			PathStyle:       setting.S3PathStyle,
+		}, nil)
+	case operation_setting.LogArchiveStorageLocal, "":
+		return objectstore.NewLocal(setting.LocalDir)
+	default:
+		return nil, fmt.Errorf("unknown log archive storage: %s", setting.Storage)
+	}
+}
+
+// ArchiveLogDay 将 [startTime, endTime) 内的日志导出为 gzip 压缩的 NDJSON 并写入存储，返回新建的归档索引；
+// 该范围内没有日志时返回 nil。此时数据库中的日志尚未删除，由 FinalizeLogArchive 校验后删除。
+func ArchiveLogDay(ctx context.Context, store objectstore.Store, prefix string, startTime int64, endTime int64) (*model.LogArchive, error) {
+	tmp, err := os.CreateTemp("", "log-archive-*.ndjson.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	hasher := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, hasher))
+	archive := &model.LogArchive{
+		Day:       time.Unix(startTime, 0).UTC().Format(time.DateOnly),
+		StartTime: startTime,
+		EndTime:   endTime,
+		Storage:   store.Name(),
+		Status:    model.LogArchiveStatusUploaded,
+	}
+	err = model.ExportLogsInRange(ctx, startTime, endTime, logArchiveExportBatchSize, func(logs []*model.Log) error {
+		for _, log := range logs {
+			line, err := common.Marshal(log)
+			if err != nil {
+				return err
+			}
+			if _, err := gz.Write(append(line, '\n')); err != nil {
+				return err
+			}
+			if archive.RowCount == 0 {
+				archive.MinLogId = log.Id
+			}
+			archive.MaxLogId = log.Id
+			archive.RowCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if archive.RowCount == 0 {
+		return nil, nil
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	archive.SizeBytes = size
+	archive.Sha256 = hex.EncodeToString(hasher.Sum(nil))
+	archive.ObjectKey = path.Join(prefix, "dt="+archive.Day, fmt.Sprintf("logs-%d-%d.ndjson.gz", archive.MinLogId, archive.MaxLogId))
+	if err := store.Put(ctx, archive.ObjectKey, tmp, size); err != nil {
+		return nil, err
+	}
+	if err := model.CreateLogArchive(archive); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// FinalizeLogArchive 回读存储中的归档，校验摘要与行数后删除数据库中对应的日志。
+// 数据库中的行数可以少于归档行数（上次删除中途中断），但不能多于。
+func FinalizeLogArchive(ctx context.Context, store objectstore.Store, archive *model.LogArchive) (int64, error) {
+	objectRows := int64(0)
+	err := readLogArchive(ctx, store, archive, func(*model.Log) (bool, error) {
+		objectRows++
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if objectRows != archive.RowCount {
+		return 0, fmt.Errorf("archive %d holds %d rows, expected %d", archive.Id, objectRows, archive.RowCount)
+	}
+	dbRows, err := model.CountArchivedLogs(archive)
+	if err != nil {
+		return 0, err
+	}
+	if dbRows > archive.RowCount {
+		return 0, fmt.Errorf("archive %d covers %d rows but the database has %d in range", archive.Id, archive.RowCount, dbRows)
+	}
+	deleted, err := model.DeleteArchivedLogs(ctx, archive, logArchiveDeleteBatchSize)
+	if err != nil {
+		return deleted, err
+	}
+	return deleted, model.UpdateLogArchiveStatus(archive.Id, model.LogArchiveStatusArchived, 0)
+}
+
+// LogArchiveFilter 查询归档时的过滤条件，零值表示不过滤
+type LogArchiveFilter struct {
+	Type      int
+	UserId    int
+	Username  string
+	TokenName string
+	ModelName string
+	ChannelId int
+	RequestId string
+}
+
+func (f LogArchiveFilter) match(log *model.Log) bool {
+	return (f.Type == 0 || log.Type == f.Type) &&
+		(f.UserId == 0 || log.UserId == f.UserId) &&
+		(f.Username == "" || log.Username == f.Username) &&
+		(f.TokenName == "" || log.TokenName == f.TokenName) &&
+		(f.ModelName == "" || log.ModelName == f.ModelName) &&
+		(f.ChannelId == 0 || log.ChannelId == f.ChannelId) &&
+		(f.RequestId == "" || log.RequestId == f.RequestId)
+}
+
+// QueryLogArchive 直接从存储中读取归档并过滤，无需恢复到数据库
+func QueryLogArchive(ctx context.Context, store objectstore.Store, archive *model.LogArchive, filter LogArchiveFilter, startIdx int, num int) (logs []*model.Log, total int, err error) {
+	err = readLogArchive(ctx, store, archive, func(log *model.Log) (bool, error) {
+		if !filter.match(log) {
+			return true, nil
+		}
+		if total >= startIdx && len(logs) < num {
+			logs = append(logs, log)
+		}
+		total++
+		return true, nil
+	})
+	return logs, total, err
+}
+
+// RestoreLogArchive 将归档中的日志写回数据库，在 holdUntil 之前不会被再次归档删除
+func RestoreLogArchive(ctx context.Context, store objectstore.Store, archive *model.LogArchive, holdUntil int64) (int64, error) {
+	// 先完整校验一遍摘要，避免把损坏的归档写回数据库
+	if err := readLogArchive(ctx, store, archive, func(*model.Log) (bool, error) { return true, nil }); err != nil {
+		return 0, err
+	}
+	restored := int64(0)
+	batch := make([]*model.Log, 0, logArchiveRestoreBatch)
+	flush := func() error {
+		if err := model.RestoreLogs(batch); err != nil {
+			return err
+		}
+		restored += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+	err := readLogArchive(ctx, store, archive, func(log *model.Log) (bool, error) {
+		batch = append(batch, log)
+		if len(batch) >= logArchiveRestoreBatch {
+			return true, flush()
+		}
+		return true, nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return restored, err
+	}
+	return restored, model.UpdateLogArchiveStatus(archive.Id, model.LogArchiveStatusRestored, holdUntil)
+}
+
+// readLogArchive 逐行解析归档，fn 返回 false 时提前结束；完整读完时校验摘要
+func readLogArchive(ctx context.Context, store objectstore.Store, archive *model.LogArchive, fn func(log *model.Log) (bool, error)) error {
+	reader, err := store.Get(ctx, archive.ObjectKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	hasher := sha256.New()
+	tee := io.TeeReader(reader, hasher)
+	gz, err := gzip.NewReader(tee)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64<<10), logArchiveMaxLineBytes)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var log model.Log
+		if err := common.Unmarshal(scanner.Bytes(), &log); err != nil {
+			return err
+		}
+		next, err := fn(&log)
+		if err != nil || !next {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// 读完 gzip 尾部之后的剩余字节，使摘要覆盖整个对象
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); archive.Sha256 != "" && sum != archive.Sha256 {
+		return errors.New("archive checksum mismatch, the stored object may be corrupted")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/objectstore"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	logArchiveTickInterval = 1 * time.Hour
+	// logArchiveMaxDaysPerRun 每轮最多归档的天数，积压较多时分多轮完成
+	logArchiveMaxDaysPerRun = 7
+	logArchiveDaySeconds    = 24 * 3600
+)
+
+var (
+	logArchiveOnce    sync.Once
+	logArchiveRunning atomic.Bool
+)
+
+// StartLogArchiveTask 定期将超过保留天数的日志按天归档并删除，仅在主节点运行
+func StartLogArchiveTask() {
+	logArchiveOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("log archive task started: tick=%s", logArchiveTickInterval))
+			ticker := time.NewTicker(logArchiveTickInterval)
+			defer ticker.Stop()
+
+			runLogArchiveOnce()
+			for range ticker.C {
+				runLogArchiveOnce()
+			}
+		})
+	})
+}
+
+func runLogArchiveOnce() {
+	if !logArchiveRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer logArchiveRunning.Store(false)
+
+	setting := operation_setting.GetLogArchiveSetting()
+	if !setting.Enabled || setting.RetentionDays <= 0 {
+		return
+	}
+	ctx := context.Background()
+	store, err := NewLogArchiveStore(setting)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("log archive task skipped: %v", err))
+		return
+	}
+	now := common.GetTimestamp()
+
+	// 先完成上次中断的归档，以及恢复保留期已结束的归档
+	pending, err := model.GetPendingLogArchives(now)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("log archive task failed to load pending archives: %v", err))
+		return
+	}
+	for _, archive := range pending {
+		finalizeLogArchiveWithLog(ctx, store, archive)
+	}
+
+	// 按天（UTC）归档保留期之前的日志
+	cutoff := now - int64(setting.RetentionDays)*logArchiveDaySeconds
+	cutoff -= cutoff % logArchiveDaySeconds
+	from := int64(0)
+	for days := 0; days < logArchiveMaxDaysPerRun; {
+		oldest, ok, err := model.GetOldestLogCreatedAt(from, cutoff)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("log archive task failed: %v", err))
+			return
+		}
+		if !ok {
+			return
+		}
+		dayStart := oldest - oldest%logArchiveDaySeconds
+		dayEnd := dayStart + logArchiveDaySeconds
+		from = dayEnd
+		held, err := model.HasHeldLogArchive(dayStart, now)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("log archive task failed: %v", err))
+			return
+		}
+		if held {
+			continue
+		}
+		archive, err := ArchiveLogDay(ctx, store, setting.Prefix, dayStart, dayEnd)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("log archive failed for %s: %v", time.Unix(dayStart, 0).UTC().Format(time.DateOnly), err))
+			return
+		}
+		if archive != nil {
+			finalizeLogArchiveWithLog(ctx, store, archive)
+		}
+		days++
+	}
+}
+
+func finalizeLogArchiveWithLog(ctx context.Context, store objectstore.Store, archive *model.LogArchive) {
+	deleted, err := FinalizeLogArchive(ctx, store, archive)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("log archive %d (%s) not finalized: %v", archive.Id, archive.Day, err))
+		return
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("log archive %d (%s): %d rows archived to %s, %d deleted", archive.Id, archive.Day, archive.RowCount, archive.ObjectKey, deleted))
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/objectstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedArchiveLog(t *testing.T, createdAt int64, userId int, modelName string) {
+	t.Helper()
+	require.NoError(t, model.LOG_DB.Create(&model.Log{
+		CreatedAt: createdAt,
+		Type:      model.LogTypeConsume,
+		UserId:    userId,
+		ModelName: modelName,
+		Content:   "test",
+	}).Error)
+}
+
+func TestLogArchiveRoundTrip(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := objectstore.NewLocal(dir)
+	require.NoError(t, err)
+
+	const day = int64(1_700_006_400) // 2023-11-15 00:00:00 UTC
+	seedArchiveLog(t, day+10, 1, "gpt-4o")
+	seedArchiveLog(t, day+20, 2, "gpt-4o-mini")
+	seedArchiveLog(t, day+30, 1, "gpt-4o-mini")
+	seedArchiveLog(t, day+86400+5, 1, "gpt-4o")
+
+	archive, err := ArchiveLogDay(ctx, store, "logs", day, day+86400)
+	require.NoError(t, err)
+	require.NotNil(t, archive)
+	assert.Equal(t, "2023-11-15", archive.Day)
+	assert.EqualValues(t, 3, archive.RowCount)
+	assert.Equal(t, int64(4), countLogs(t), "logs must stay until the archive is verified")
+
+	deleted, err := FinalizeLogArchive(ctx, store, archive)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, deleted)
+	assert.Equal(t, int64(1), countLogs(t))
+
+	logs, total, err := QueryLogArchive(ctx, store, archive, LogArchiveFilter{UserId: 1}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "gpt-4o", logs[0].ModelName)
+
+	restored, err := RestoreLogArchive(ctx, store, archive, day+2*86400)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, restored)
+	assert.Equal(t, int64(4), countLogs(t))
+
+	stored, err := model.GetLogArchiveById(archive.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.LogArchiveStatusRestored, stored.Status)
+}
+
+func TestLogArchiveFinalizeRejectsCorruptedObject(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := objectstore.NewLocal(dir)
+	require.NoError(t, err)
+
+	const day = int64(1_700_006_400)
+	seedArchiveLog(t, day+10, 1, "gpt-4o")
+
+	archive, err := ArchiveLogDay(ctx, store, "logs", day, day+86400)
+	require.NoError(t, err)
+	require.NotNil(t, archive)
+
+	// 用另一份合法但内容不同的归档覆盖对象，行数一致但摘要不符
+	seedArchiveLog(t, day+86400+10, 2, "other")
+	other, err := ArchiveLogDay(ctx, store, "other", day+86400, day+2*86400)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, other.ObjectKey))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, archive.ObjectKey), data, 0o644))
+
+	_, err = FinalizeLogArchive(ctx, store, archive)
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), countLogs(t))
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var channelStatusNames = map[int]string{
+	common.ChannelStatusEnabled:          "enabled",
+	common.ChannelStatusManuallyDisabled: "manually_disabled",
+	common.ChannelStatusAutoDisabled:     "auto_disabled",
+	common.ChannelStatusExpiredDisabled:  "expired_disabled",
+}
+
+// InitMetrics 启用 Prometheus 指标并注册数据库、Redis 连接池与渠道状态采集器，需在数据库与 Redis 初始化之后调用
+func InitMetrics() {
+	if !constant.MetricsEnabled {
+		return
+	}
+	metrics.Init(metrics.Config{
+		Labels:         constant.MetricsLabels,
+		MaxLabelValues: constant.MetricsMaxLabelValues,
+	})
+
+	if sqlDB, err := model.DB.DB(); err == nil {
+		registerMetricsCollector(collectors.NewDBStatsCollector(sqlDB, "main"))
+	}
+	if model.LOG_DB != nil && model.LOG_DB != model.DB {
+		if sqlDB, err := model.LOG_DB.DB(); err == nil {
+			registerMetricsCollector(collectors.NewDBStatsCollector(sqlDB, "log"))
+		}
+	}
+	if common.RedisEnabled && common.RDB != nil {
+		registerMetricsCollector(metrics.NewRedisPoolCollector(common.RDB))
+	}
+	registerMetricsCollector(metrics.NewChannelStatusCollector(countChannelsByStatusName))
+
+	common.SysLog(fmt.Sprintf("prometheus metrics enabled, labels: %v, max label values: %d", constant.MetricsLabels, constant.MetricsMaxLabelValues))
+}
+
+func registerMetricsCollector(c prometheus.Collector) {
+	if err := metrics.Register(c); err != nil {
+		common.SysError("failed to register metrics collector: " + err.Error())
+	}
+}
+
+func countChannelsByStatusName() (map[string]int, error) {
+	counts, err := model.CountChannelsGroupByStatus()
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]int, len(counts))
+	for status, count := range counts {
+		name, ok := channelStatusNames[status]
+		if !ok {
+			name = strconv.Itoa(status)
+		}
+		named[name] += count
+	}
+	return named, nil
+}
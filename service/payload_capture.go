@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// PayloadCaptureSession 一次请求的抓包状态，由 middleware.PayloadCapture 创建并放入上下文。
+// 重试时上游请求与响应以最后一次尝试为准。
+type PayloadCaptureSession struct {
+	mu               sync.Mutex
+	maxBytes         int
+	truncated        bool
+	relayFormat      string
+	isStream         bool
+	upstreamRequest  []byte
+	upstreamResponse bytes.Buffer
+	clientResponse   bytes.Buffer
+}
+
+func NewPayloadCaptureSession() *PayloadCaptureSession {
+	maxBytes := operation_setting.GetPayloadCaptureSetting().MaxBodyKB << 10
+	if maxBytes <= 0 {
+		maxBytes = 256 << 10
+	}
+	return &PayloadCaptureSession{maxBytes: maxBytes}
+}
+
+func getPayloadCaptureSession(c *gin.Context) *PayloadCaptureSession {
+	if c == nil {
+		return nil
+	}
+	session, _ := common.GetContextKeyType[*PayloadCaptureSession](c, constant.ContextKeyPayloadCapture)
+	return session
+}
+
+func (s *PayloadCaptureSession) appendCapped(buf *bytes.Buffer, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remaining := s.maxBytes - buf.Len()
+	if remaining <= 0 {
+		if len(data) > 0 {
+			s.truncated = true
+		}
+		return
+	}
+	if len(data) > remaining {
+		s.truncated = true
+		data = data[:remaining]
+	}
+	buf.Write(data)
+}
+
+// AppendClientResponse 记录返回给客户端的响应内容
+func (s *PayloadCaptureSession) AppendClientResponse(data []byte) {
+	s.appendCapped(&s.clientResponse, data)
+}
+
+// CapturePayloadUpstreamRequest 记录转换后发往上游的请求体，读取后放回，使请求仍可正常发送
+func CapturePayloadUpstreamRequest(c *gin.Context, info *relaycommon.RelayInfo, req *http.Request) {
+	session := getPayloadCaptureSession(c)
+	if session == nil {
+		return
+	}
+	var data []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		data, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("payload capture: failed to read upstream request: %s", err.Error()))
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.ContentLength = int64(len(data))
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.upstreamRequest = data
+	session.upstreamResponse.Reset()
+	if info != nil {
+		session.relayFormat = string(info.RelayFormat)
+		session.isStream = info.IsStream
+	}
+}
+
+// CapturePayloadUpstreamResponse 包装上游响应体，在下游读取的同时记录原始响应
+func CapturePayloadUpstreamResponse(c *gin.Context, body io.ReadCloser) io.ReadCloser {
+	session := getPayloadCaptureSession(c)
+	if session == nil || body == nil {
+		return body
+	}
+	return &payloadCaptureReader{ReadCloser: body, session: session}
+}
+
+type payloadCaptureReader struct {
+	io.ReadCloser
+	session *PayloadCaptureSession
+}
+
+func (r *payloadCaptureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.session.appendCapped(&r.session.upstreamResponse, p[:n])
+	}
+	return n, err
+}
+
+// SavePayloadCapture 脱敏、压缩并异步写入抓包记录。
+// 请求体先完整脱敏再截断；响应体在读取时已截断，截断处无法解析的内容由 RedactJSONPaths 整段替换。
+func SavePayloadCapture(c *gin.Context, session *PayloadCaptureSession, inboundRequest []byte, statusCode int) {
+	paths, err := common.ParseJSONPaths(operation_setting.GetPayloadCaptureSetting().RedactPaths)
+	if err != nil {
+		// 脱敏规则无效时宁可丢弃抓包，也不保存未脱敏的载荷
+		logger.LogWarn(c, fmt.Sprintf("payload capture skipped, invalid redact paths: %s", err.Error()))
+		return
+	}
+
+	session.mu.Lock()
+	capture := &model.PayloadCapture{
+		RequestId:   c.GetString(common.RequestIdKey),
+		UserId:      c.GetInt("id"),
+		TokenId:     common.GetContextKeyInt(c, constant.ContextKeyTokenId),
+		ChannelId:   common.GetContextKeyInt(c, constant.ContextKeyChannelId),
+		ModelName:   common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		RelayFormat: session.relayFormat,
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		StatusCode:  statusCode,
+		IsStream:    session.isStream,
+	}
+	bodies := [][]byte{
+		inboundRequest,
+		session.upstreamRequest,
+		bytes.Clone(session.upstreamResponse.Bytes()),
+		bytes.Clone(session.clientResponse.Bytes()),
+	}
+	capture.Truncated = session.truncated
+	maxBytes := session.maxBytes
+	session.mu.Unlock()
+
+	gopool.Go(func() {
+		targets := []*[]byte{&capture.InboundRequest, &capture.UpstreamRequest, &capture.UpstreamResponse, &capture.ClientResponse}
+		for i, body := range bodies {
+			body = common.RedactJSONPaths(body, paths)
+			if len(body) > maxBytes {
+				body = body[:maxBytes]
+				capture.Truncated = true
+			}
+			compressed, err := common.GzipBytes(body)
+			if err != nil {
+				common.SysError(fmt.Sprintf("payload capture: failed to compress body: %s", err.Error()))
+				return
+			}
+			*targets[i] = compressed
+		}
+		if err := model.CreatePayloadCapture(capture); err != nil {
+			common.SysError(fmt.Sprintf("failed to save payload capture: %s", err.Error()))
+		}
+	})
+}
+
+// payloadDiffIgnoredFields 每次请求必然不同的顶层字段，比较时忽略
+var payloadDiffIgnoredFields = []string{"id", "created", "created_at", "system_fingerprint", "responseId"}
+
+// payloadStreamTextPaths 各格式流式事件中增量文本所在的位置
+var payloadStreamTextPaths = []string{"choices.0.delta.content", "delta.text", "candidates.0.content.parts.0.text", "delta"}
+
+// DiffPayloadResponses 比较抓包中的客户端响应与重放得到的响应。
+// JSON 响应逐字段比较；SSE 响应合并增量文本后比较事件数与完整内容；其他内容整体比较。
+func DiffPayloadResponses(original []byte, replayed []byte) (diff map[string]any, identical bool) {
+	diff = common.BuildAuditDiff(normalizePayloadForDiff(original), normalizePayloadForDiff(replayed))
+	return diff, len(diff) == 0
+}
+
+func normalizePayloadForDiff(body []byte) map[string]any {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var m map[string]any
+		if err := common.Unmarshal(trimmed, &m); err == nil {
+			for _, field := range payloadDiffIgnoredFields {
+				delete(m, field)
+			}
+			return m
+		}
+	}
+	if bytes.Contains(trimmed, []byte("data:")) {
+		events := 0
+		var content strings.Builder
+		for _, line := range bytes.Split(trimmed, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				continue
+			}
+			data := bytes.TrimSpace(line[len("data:"):])
+			if len(data) == 0 || string(data) == "[DONE]" {
+				continue
+			}
+			events++
+			for _, path := range payloadStreamTextPaths {
+				if result := gjson.GetBytes(data, path); result.Type == gjson.String {
+					content.WriteString(result.String())
+					break
+				}
+			}
+		}
+		return map[string]any{"events": events, "content": content.String()}
+	}
+	return map[string]any{"body": string(trimmed)}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	payloadCaptureCleanupTickInterval = 10 * time.Minute
+	payloadCaptureCleanupBatchSize    = 500
+
+	payloadCaptureDefaultRetentionHours = 72
+)
+
+var (
+	payloadCaptureCleanupOnce    sync.Once
+	payloadCaptureCleanupRunning atomic.Bool
+)
+
+// StartPayloadCaptureCleanupTask 按保留时长定期清理过期的请求载荷抓包，仅在主节点运行
+func StartPayloadCaptureCleanupTask() {
+	payloadCaptureCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("payload capture cleanup task started: tick=%s", payloadCaptureCleanupTickInterval))
+			ticker := time.NewTicker(payloadCaptureCleanupTickInterval)
+			defer ticker.Stop()
+
+			runPayloadCaptureCleanupOnce()
+			for range ticker.C {
+				runPayloadCaptureCleanupOnce()
+			}
+		})
+	})
+}
+
+func runPayloadCaptureCleanupOnce() {
+	if !payloadCaptureCleanupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer payloadCaptureCleanupRunning.Store(false)
+
+	// 载荷包含用户内容，不提供永久保留选项
+	retentionHours := operation_setting.GetPayloadCaptureSetting().RetentionHours
+	if retentionHours <= 0 {
+		retentionHours = payloadCaptureDefaultRetentionHours
+	}
+	ctx := context.Background()
+	targetTimestamp := common.GetTimestamp() - int64(retentionHours)*3600
+	count, err := model.DeleteOldPayloadCaptures(ctx, targetTimestamp, payloadCaptureCleanupBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("payload capture cleanup task failed: %v", err))
+		return
+	}
+	if count > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("payload capture cleanup: deleted=%d, retention_hours=%d", count, retentionHours))
+	}
+}
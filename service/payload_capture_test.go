@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffPayloadResponses(t *testing.T) {
+	diff, identical := DiffPayloadResponses(
+		[]byte(`{"id":"a","created":1,"choices":[{"message":{"content":"hi"}}],"model":"gpt-4o"}`),
+		[]byte(`{"id":"b","created":2,"choices":[{"message":{"content":"hi"}}],"model":"gpt-4o"}`),
+	)
+	require.True(t, identical, "忽略 id 与 created")
+	require.Empty(t, diff)
+
+	diff, identical = DiffPayloadResponses(
+		[]byte("data: {\"choices\":[{\"delta\":{\"content\":\"he\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"llo\"}}]}\n\ndata: [DONE]\n"),
+		[]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\ndata: [DONE]\n"),
+	)
+	require.False(t, identical)
+	require.NotContains(t, diff, "content", "合并后的文本相同")
+	require.Equal(t, map[string]any{"before": 2, "after": 1}, diff["events"])
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyUsagePriceTierReselectsByActualPrompt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	relayInfo := &relaycommon.RelayInfo{
+		PriceData: types.PriceData{
+			ModelRatio:           0.625,
+			CompletionRatio:      8,
+			CacheRatio:           0.25,
+			CacheCreationRatio:   1.25,
+			CacheCreation5mRatio: 1.25,
+			CacheCreation1hRatio: 2,
+			PriceTiers: []types.PriceTier{
+				{Threshold: 200000, ModelRatio: 1.25, CompletionRatio: 6, CacheCreationRatio: 2.5},
+			},
+		},
+	}
+
+	// 预扣费按估算的提示 tokens 命中高档
+	require.NotNil(t, relayInfo.PriceData.ApplyPromptTier(250000))
+	require.Equal(t, 1.25, relayInfo.PriceData.ModelRatio)
+	require.Equal(t, 0.25, relayInfo.PriceData.CacheRatio)
+	require.Equal(t, 4.0, relayInfo.PriceData.CacheCreation1hRatio)
+
+	// 实际用量未超过阈值时恢复基础倍率
+	ApplyUsagePriceTier(ctx, relayInfo, 150000)
+	require.Nil(t, relayInfo.PriceData.PriceTier)
+	require.Equal(t, 0.625, relayInfo.PriceData.ModelRatio)
+	require.Equal(t, 8.0, relayInfo.PriceData.CompletionRatio)
+	require.Equal(t, 1.25, relayInfo.PriceData.CacheCreationRatio)
+
+	ApplyUsagePriceTier(ctx, relayInfo, 200001)
+	other := map[string]interface{}{}
+	appendPriceTierInfo(relayInfo, other)
+	require.Equal(t, 200000, other["price_tier"])
+	require.Equal(t, 6.0, relayInfo.PriceData.CompletionRatio)
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"strings"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -86,16 +85,53 @@ func calculateAudioQuota(info QuotaInfo) int {
 	return int(quota.Round(0).IntPart())
 }
 
+// ApplyUsagePriceTier 按实际的提示 tokens（含缓存读写 tokens）重新选择分档倍率，需在读取 PriceData 倍率前调用
+func ApplyUsagePriceTier(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, promptTokens int) {
+	estimated := relayInfo.PriceData.PriceTier
+	tier := relayInfo.PriceData.ApplyPromptTier(promptTokens)
+	if tier != estimated && common.DebugEnabled {
+		logger.LogDebug(ctx, fmt.Sprintf("price tier changed after usage, prompt tokens %d, tier %v", promptTokens, tier))
+	}
+}
+
+// appendPriceTierInfo 在日志中记录适用的分档
+func appendPriceTierInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}) {
+	if relayInfo == nil || other == nil || relayInfo.PriceData.PriceTier == nil {
+		return
+	}
+	other["price_tier"] = relayInfo.PriceData.PriceTier.Threshold
+}
+
+// GetAvailableQuota 返回本次请求可扣费的额度：组织令牌为组织余额，否则为用户余额，均包含后付费信用额度
+func GetAvailableQuota(relayInfo *relaycommon.RelayInfo) (int, error) {
+	if relayInfo.OrganizationId > 0 {
+		org, err := model.GetOrganizationById(relayInfo.OrganizationId)
+		if err != nil {
+			return 0, err
+		}
+		return org.Quota + org.CreditLimit, nil
+	}
+	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return 0, err
+	}
+	creditLimit, err := model.GetUserCreditLimit(relayInfo.UserId)
+	if err != nil {
+		return 0, err
+	}
+	return userQuota + creditLimit, nil
+}
+
 func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage) error {
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := GetAvailableQuota(relayInfo)
 	if err != nil {
 		return err
 	}
 
-	token, err := model.GetTokenByKey(strings.TrimPrefix(relayInfo.TokenKey, "sk-"), false)
+	token, err := model.GetTokenByKeyHash(relayInfo.TokenKey, false)
 	if err != nil {
 		return err
 	}
@@ -169,6 +205,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(modelName))
 
+	ApplyUsagePriceTier(ctx, relayInfo, usage.InputTokens)
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 	modelPrice := relayInfo.PriceData.ModelPrice
@@ -219,6 +256,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	AppendRelayPerformance(relayInfo, other, usage.OutputTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -246,12 +284,19 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	modelName := relayInfo.OriginModelName
 
 	tokenName := ctx.GetString("token_name")
+	cacheTokens := usage.PromptTokensDetails.CachedTokens
+	// Anthropic 的 input_tokens 不含缓存读写 tokens，OpenRouter 的 prompt_tokens 已包含
+	tierPromptTokens := promptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		tierPromptTokens += cacheTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	ApplyUsagePriceTier(ctx, relayInfo, tierPromptTokens)
+
 	completionRatio := relayInfo.PriceData.CompletionRatio
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 	modelPrice := relayInfo.PriceData.ModelPrice
 	cacheRatio := relayInfo.PriceData.CacheRatio
-	cacheTokens := usage.PromptTokensDetails.CachedTokens
 
 	cacheCreationRatio := relayInfo.PriceData.CacheCreationRatio
 	cacheCreationRatio5m := relayInfo.PriceData.CacheCreation5mRatio
@@ -320,6 +365,7 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		cacheCreationTokens5m, cacheCreationRatio5m,
 		cacheCreationTokens1h, cacheCreationRatio1h,
 		modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	AppendRelayPerformance(relayInfo, other, completionTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -372,6 +418,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
 
+	ApplyUsagePriceTier(ctx, relayInfo, usage.PromptTokens)
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 	modelPrice := relayInfo.PriceData.ModelPrice
@@ -426,6 +473,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	AppendRelayPerformance(relayInfo, other, usage.CompletionTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -452,7 +500,7 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	//if relayInfo.TokenUnlimited {
 	//	return nil
 	//}
-	token, err := model.GetTokenByKey(relayInfo.TokenKey, false)
+	token, err := model.GetTokenByKeyHash(relayInfo.TokenKey, false)
 	if err != nil {
 		return err
 	}
@@ -480,12 +528,17 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 			}
 			relayInfo.SubscriptionPostDelta += delta
 		}
+	} else if relayInfo != nil && relayInfo.OrganizationId > 0 {
+		if err := model.AdjustOrganizationConsumedQuota(relayInfo.OrganizationId, relayInfo.UserId, quota); err != nil {
+			return err
+		}
 	} else {
 		// Wallet
+		ref := model.QuotaLedgerRef{Source: model.QuotaSourceConsume, RefId: relayInfo.RequestId}
 		if quota > 0 {
-			err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+			err = model.DecreaseUserQuota(relayInfo.UserId, quota, ref)
 		} else {
-			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false, ref)
 		}
 		if err != nil {
 			return err
@@ -506,6 +559,7 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 	if sendEmail {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
+			checkCreditDunning(relayInfo)
 		}
 	}
 
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	quotaLedgerReconcileTickInterval = 10 * time.Minute
+	quotaLedgerReconcileBatchSize    = 1000
+	quotaLedgerReconcileMaxBatches   = 50
+	quotaLedgerBalanceDiffLimit      = 500
+	// quotaLedgerReconcileLag 只校验生成超过该时长的流水，避免并发事务提交顺序与 id 顺序不一致导致漏检
+	quotaLedgerReconcileLag = int64(60)
+)
+
+var (
+	quotaLedgerReconcileOnce    sync.Once
+	quotaLedgerReconcileRunning atomic.Bool
+)
+
+// quotaLedgerReconciler 保存对账游标与上一轮发现的余额差异。
+// 余额差异需要在连续两轮中保持一致才记为异常，避免把批量更新尚未落库的变动误报
+type quotaLedgerReconciler struct {
+	cursor      int64
+	initialized bool
+	pending     map[int]model.QuotaBalanceDiff
+	reported    map[int]model.QuotaBalanceDiff
+}
+
+var quotaLedgerReconcileState = &quotaLedgerReconciler{}
+
+// StartQuotaLedgerReconcileTask 定期核对用户余额与额度流水，仅在主节点运行
+func StartQuotaLedgerReconcileTask() {
+	quotaLedgerReconcileOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("quota ledger reconcile task started: tick=%s", quotaLedgerReconcileTickInterval))
+			ticker := time.NewTicker(quotaLedgerReconcileTickInterval)
+			defer ticker.Stop()
+
+			runQuotaLedgerReconcileOnce()
+			for range ticker.C {
+				runQuotaLedgerReconcileOnce()
+			}
+		})
+	})
+}
+
+func runQuotaLedgerReconcileOnce() {
+	if !quotaLedgerReconcileRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer quotaLedgerReconcileRunning.Store(false)
+
+	ctx := context.Background()
+	mismatches, err := quotaLedgerReconcileState.run(common.GetTimestamp())
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("quota ledger reconcile failed: %v", err))
+	}
+	if len(mismatches) == 0 {
+		return
+	}
+	for _, mismatch := range mismatches {
+		logger.LogWarn(ctx, "quota ledger reconcile: "+mismatch.String())
+	}
+	if err := model.CreateQuotaLedgerMismatches(mismatches); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("quota ledger reconcile: failed to save mismatches: %v", err))
+	}
+}
+
+// run 执行一轮对账，返回新发现的异常
+func (r *quotaLedgerReconciler) run(now int64) ([]*model.QuotaLedgerMismatch, error) {
+	if !r.initialized {
+		// 首次运行从当前最大 id 开始，历史流水不再重复校验
+		cursor, err := model.GetMaxQuotaLedgerId()
+		if err != nil {
+			return nil, err
+		}
+		r.cursor = cursor
+		r.initialized = true
+	}
+	mismatches, err := r.checkGaps(now)
+	if err != nil {
+		return mismatches, err
+	}
+	balanceMismatches, err := r.checkBalances(now)
+	return append(mismatches, balanceMismatches...), err
+}
+
+// checkGaps 校验游标之后的每条流水与同一用户的上一条流水是否衔接：上一条余额 + 本条变动 = 本条余额
+func (r *quotaLedgerReconciler) checkGaps(now int64) ([]*model.QuotaLedgerMismatch, error) {
+	var mismatches []*model.QuotaLedgerMismatch
+	lastBalance := make(map[int]int64)
+	for i := 0; i < quotaLedgerReconcileMaxBatches; i++ {
+		entries, err := model.GetQuotaLedgersAfter(r.cursor, quotaLedgerReconcileBatchSize)
+		if err != nil {
+			return mismatches, err
+		}
+		for _, entry := range entries {
+			if entry.CreatedAt > now-quotaLedgerReconcileLag {
+				return mismatches, nil
+			}
+			previous, ok := lastBalance[entry.UserId]
+			if !ok {
+				prev, err := model.GetPreviousQuotaLedger(entry.UserId, entry.Id)
+				if err != nil {
+					return mismatches, err
+				}
+				if prev != nil {
+					previous, ok = prev.Balance, true
+				}
+			}
+			if ok && previous+entry.Delta != entry.Balance {
+				mismatches = append(mismatches, &model.QuotaLedgerMismatch{
+					UserId:     entry.UserId,
+					Kind:       model.QuotaLedgerMismatchGap,
+					LedgerId:   entry.Id,
+					Expected:   previous + entry.Delta,
+					Actual:     entry.Balance,
+					DetectedAt: now,
+				})
+			}
+			lastBalance[entry.UserId] = entry.Balance
+			r.cursor = entry.Id
+		}
+		if len(entries) < quotaLedgerReconcileBatchSize {
+			break
+		}
+	}
+	return mismatches, nil
+}
+
+// checkBalances 比较用户当前余额与最新流水余额，连续两轮差异相同时记为异常，同一差异只上报一次
+func (r *quotaLedgerReconciler) checkBalances(now int64) ([]*model.QuotaLedgerMismatch, error) {
+	diffs, err := model.FindQuotaBalanceDiffs(quotaLedgerBalanceDiffLimit)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []*model.QuotaLedgerMismatch
+	pending := make(map[int]model.QuotaBalanceDiff)
+	reported := make(map[int]model.QuotaBalanceDiff)
+	for _, diff := range diffs {
+		if previous, ok := r.reported[diff.UserId]; ok && previous == *diff {
+			reported[diff.UserId] = *diff
+			continue
+		}
+		if previous, ok := r.pending[diff.UserId]; ok && previous == *diff {
+			mismatches = append(mismatches, &model.QuotaLedgerMismatch{
+				UserId:     diff.UserId,
+				Kind:       model.QuotaLedgerMismatchBalance,
+				LedgerId:   diff.LedgerId,
+				Expected:   diff.Balance,
+				Actual:     diff.Quota,
+				DetectedAt: now,
+			})
+			reported[diff.UserId] = *diff
+			continue
+		}
+		pending[diff.UserId] = *diff
+	}
+	r.pending = pending
+	r.reported = reported
+	return mismatches, nil
+}
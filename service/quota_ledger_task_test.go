@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaLedgerReconciler_FlagsGapAndPersistentBalanceDiff(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 1000)
+	r := &quotaLedgerReconciler{}
+	_, err := r.run(common.GetTimestamp())
+	require.NoError(t, err)
+
+	ref := model.QuotaLedgerRef{Source: model.QuotaSourceConsume, RefId: "req-1"}
+	require.NoError(t, model.DecreaseUserQuota(1, 100, ref))
+	// 绕过流水直接改余额，再记一笔正常变动，形成流水断档
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("quota", 500).Error)
+	require.NoError(t, model.DecreaseUserQuota(1, 50, ref))
+	// 再次绕过流水修改余额，使最新流水与余额不一致
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("quota", 400).Error)
+
+	now := common.GetTimestamp() + quotaLedgerReconcileLag + 1
+	mismatches, err := r.run(now)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, model.QuotaLedgerMismatchGap, mismatches[0].Kind)
+	assert.EqualValues(t, 850, mismatches[0].Expected)
+	assert.EqualValues(t, 450, mismatches[0].Actual)
+
+	// 余额差异需连续两轮一致才上报，且只上报一次
+	mismatches, err = r.run(now)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, model.QuotaLedgerMismatchBalance, mismatches[0].Kind)
+	assert.EqualValues(t, 450, mismatches[0].Expected)
+	assert.EqualValues(t, 400, mismatches[0].Actual)
+
+	mismatches, err = r.run(now)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 分位数统计保存在各节点内存中，只反映处理了这些请求的节点，重启后清空。
+// 需要全局视图时应基于日志中的 ttft_ms、duration_ms 等字段或用量聚合数据计算。
+const (
+	// relayPerformanceWindow 滚动统计的时间窗口
+	relayPerformanceWindow = time.Hour
+	// relayPerformanceMaxSamples 每个渠道与模型组合最多保留的样本数，超出后覆盖最旧的样本
+	relayPerformanceMaxSamples = 1024
+	// relayResponseTimeInterval 以真实流量的总耗时中位数刷新 Channel.ResponseTime 的最小间隔
+	relayResponseTimeInterval = 5 * time.Minute
+)
+
+var relayPerformanceNode = func() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}()
+
+// RelayTiming 单次中继请求的耗时指标
+type RelayTiming struct {
+	TTFTMs          int64   // 首字时间，仅流式请求有效，否则为 -1
+	DurationMs      int64   // 从请求进入到计费完成的总耗时
+	QueueMs         int64   // 网关内部耗时（鉴权、选渠道、转换、重试）直到最后一次向上游发起请求
+	TokensPerSecond float64 // 生成阶段的输出速度，流式从首字开始计算，非流式从发起上游请求开始计算
+}
+
+// ComputeRelayTiming 根据 RelayInfo 中记录的时间点计算耗时指标
+func ComputeRelayTiming(info *relaycommon.RelayInfo, completionTokens int, now time.Time) RelayTiming {
+	timing := RelayTiming{
+		TTFTMs:     -1,
+		DurationMs: now.Sub(info.StartTime).Milliseconds(),
+	}
+	generationStart := info.StartTime
+	if !info.UpstreamRequestTime.IsZero() {
+		timing.QueueMs = info.UpstreamRequestTime.Sub(info.StartTime).Milliseconds()
+		generationStart = info.UpstreamRequestTime
+	}
+	if info.IsStream && info.HasSendResponse() {
+		timing.TTFTMs = info.FirstResponseTime.Sub(info.StartTime).Milliseconds()
+		generationStart = info.FirstResponseTime
+	}
+	if generationSeconds := now.Sub(generationStart).Seconds(); completionTokens > 0 && generationSeconds > 0 {
+		timing.TokensPerSecond = math.Round(float64(completionTokens)/generationSeconds*100) / 100
+	}
+	return timing
+}
+
+// AppendRelayPerformance 将耗时指标写入日志 other 字段，并计入渠道与模型的滚动统计
+func AppendRelayPerformance(info *relaycommon.RelayInfo, other map[string]interface{}, completionTokens int) {
+	if info == nil {
+		return
+	}
+	timing := ComputeRelayTiming(info, completionTokens, time.Now())
+	if other != nil {
+		if timing.TTFTMs >= 0 {
+			other["ttft_ms"] = timing.TTFTMs
+		}
+		other["duration_ms"] = timing.DurationMs
+		other["queue_ms"] = timing.QueueMs
+		if timing.TokensPerSecond > 0 {
+			other["tps"] = timing.TokensPerSecond
+		}
+	}
+	if info.ChannelMeta != nil {
+		relayPerformance.record(info.ChannelId, info.OriginModelName, timing, time.Now())
+	}
+}
+
+type relayPerformanceKey struct {
+	ChannelId int
+	Model     string
+}
+
+type relayPerformanceSample struct {
+	At     time.Time
+	Timing RelayTiming
+}
+
+type relayPerformanceStore struct {
+	mu      sync.Mutex
+	samples map[relayPerformanceKey][]relayPerformanceSample
+	next    map[relayPerformanceKey]int
+	// responseTimeAt 各渠道上次刷新 Channel.ResponseTime 的时间
+	responseTimeAt map[int]time.Time
+}
+
+var relayPerformance = &relayPerformanceStore{
+	samples:        make(map[relayPerformanceKey][]relayPerformanceSample),
+	next:           make(map[relayPerformanceKey]int),
+	responseTimeAt: make(map[int]time.Time),
+}
+
+func (s *relayPerformanceStore) record(channelId int, modelName string, timing RelayTiming, now time.Time) {
+	key := relayPerformanceKey{ChannelId: channelId, Model: modelName}
+	sample := relayPerformanceSample{At: now, Timing: timing}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := s.samples[key]
+	if len(samples) < relayPerformanceMaxSamples {
+		s.samples[key] = append(samples, sample)
+	} else {
+		idx := s.next[key]
+		samples[idx] = sample
+		s.next[key] = (idx + 1) % relayPerformanceMaxSamples
+	}
+	s.refreshResponseTimeLocked(channelId, now)
+}
+
+// refreshResponseTimeLocked 按间隔用渠道在窗口内全部模型的总耗时中位数更新 Channel.ResponseTime，
+// 使渠道列表中的响应时间反映真实流量而不只是渠道测试结果
+func (s *relayPerformanceStore) refreshResponseTimeLocked(channelId int, now time.Time) {
+	if channelId <= 0 || now.Sub(s.responseTimeAt[channelId]) < relayResponseTimeInterval {
+		return
+	}
+	s.responseTimeAt[channelId] = now
+	cutoff := now.Add(-relayPerformanceWindow)
+	var durations []float64
+	for key, samples := range s.samples {
+		if key.ChannelId != channelId {
+			continue
+		}
+		for _, sample := range samples {
+			if !sample.At.Before(cutoff) {
+				durations = append(durations, float64(sample.Timing.DurationMs))
+			}
+		}
+	}
+	if len(durations) == 0 {
+		return
+	}
+	responseTime := int(computePercentiles(durations).P50)
+	gopool.Go(func() {
+		err := model.DB.Model(&model.Channel{}).Where("id = ?", channelId).Update("response_time", responseTime).Error
+		if err != nil {
+			common.SysLog("failed to update channel response time: " + err.Error())
+		}
+	})
+}
+
+// Percentiles 一组样本的分位数
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// RelayPerformanceReport 本节点的耗时分位数统计，Scope 固定为 node 以标明数据不是全局汇总
+type RelayPerformanceReport struct {
+	Scope         string                 `json:"scope"`
+	Node          string                 `json:"node"`
+	WindowSeconds int64                  `json:"window_seconds"`
+	MaxSamples    int                    `json:"max_samples"`
+	Items         []RelayPerformanceStat `json:"items"`
+}
+
+// NewRelayPerformanceReport 为统计结果附上节点与统计窗口信息
+func NewRelayPerformanceReport(stats []RelayPerformanceStat) RelayPerformanceReport {
+	return RelayPerformanceReport{
+		Scope:         "node",
+		Node:          relayPerformanceNode,
+		WindowSeconds: int64(relayPerformanceWindow / time.Second),
+		MaxSamples:    relayPerformanceMaxSamples,
+		Items:         stats,
+	}
+}
+
+// RelayPerformanceStat 渠道与模型组合在统计窗口内的耗时分位数
+type RelayPerformanceStat struct {
+	ChannelId       int         `json:"channel_id"`
+	ChannelName     string      `json:"channel_name"`
+	Model           string      `json:"model"`
+	Count           int         `json:"count"`
+	StreamCount     int         `json:"stream_count"`
+	TTFTMs          Percentiles `json:"ttft_ms"`
+	DurationMs      Percentiles `json:"duration_ms"`
+	QueueMs         Percentiles `json:"queue_ms"`
+	TokensPerSecond Percentiles `json:"tps"`
+}
+
+// GetRelayPerformanceStats 返回本节点最近一小时内的滚动分位数统计，channelId 为 0 或 model 为空表示不过滤
+func GetRelayPerformanceStats(channelId int, modelName string) []RelayPerformanceStat {
+	cutoff := time.Now().Add(-relayPerformanceWindow)
+	relayPerformance.mu.Lock()
+	snapshot := make(map[relayPerformanceKey][]relayPerformanceSample, len(relayPerformance.samples))
+	for key, samples := range relayPerformance.samples {
+		if (channelId != 0 && key.ChannelId != channelId) || (modelName != "" && key.Model != modelName) {
+			continue
+		}
+		snapshot[key] = append([]relayPerformanceSample(nil), samples...)
+	}
+	relayPerformance.mu.Unlock()
+
+	stats := make([]RelayPerformanceStat, 0, len(snapshot))
+	for key, samples := range snapshot {
+		var ttft, duration, queue, tps []float64
+		for _, sample := range samples {
+			if sample.At.Before(cutoff) {
+				continue
+			}
+			duration = append(duration, float64(sample.Timing.DurationMs))
+			queue = append(queue, float64(sample.Timing.QueueMs))
+			if sample.Timing.TTFTMs >= 0 {
+				ttft = append(ttft, float64(sample.Timing.TTFTMs))
+			}
+			if sample.Timing.TokensPerSecond > 0 {
+				tps = append(tps, sample.Timing.TokensPerSecond)
+			}
+		}
+		if len(duration) == 0 {
+			continue
+		}
+		stats = append(stats, RelayPerformanceStat{
+			ChannelId:       key.ChannelId,
+			Model:           key.Model,
+			Count:           len(duration),
+			StreamCount:     len(ttft),
+			TTFTMs:          computePercentiles(ttft),
+			DurationMs:      computePercentiles(duration),
+			QueueMs:         computePercentiles(queue),
+			TokensPerSecond: computePercentiles(tps),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Model != stats[j].Model {
+			return stats[i].Model < stats[j].Model
+		}
+		return stats[i].ChannelId < stats[j].ChannelId
+	})
+	return stats
+}
+
+// computePercentiles 使用最近秩法计算分位数
+func computePercentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		idx := int(math.Ceil(p*float64(len(values)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return values[idx]
+	}
+	return Percentiles{P50: rank(0.5), P90: rank(0.9), P99: rank(0.99)}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeRelayTimingStream(t *testing.T) {
+	start := time.Now()
+	info := &relaycommon.RelayInfo{
+		StartTime:           start,
+		UpstreamRequestTime: start.Add(50 * time.Millisecond),
+		FirstResponseTime:   start.Add(450 * time.Millisecond),
+		IsStream:            true,
+	}
+	timing := ComputeRelayTiming(info, 200, start.Add(2450*time.Millisecond))
+
+	require.Equal(t, int64(50), timing.QueueMs)
+	require.Equal(t, int64(450), timing.TTFTMs)
+	require.Equal(t, int64(2450), timing.DurationMs)
+	require.Equal(t, 100.0, timing.TokensPerSecond)
+}
+
+func TestComputeRelayTimingNonStream(t *testing.T) {
+	start := time.Now()
+	info := &relaycommon.RelayInfo{
+		StartTime:           start,
+		UpstreamRequestTime: start.Add(100 * time.Millisecond),
+		FirstResponseTime:   start.Add(-time.Second),
+	}
+	timing := ComputeRelayTiming(info, 50, start.Add(1100*time.Millisecond))
+
+	require.Equal(t, int64(-1), timing.TTFTMs)
+	require.Equal(t, 50.0, timing.TokensPerSecond)
+}
+
+func TestRelayPerformanceStatsPercentiles(t *testing.T) {
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		relayPerformance.record(-1, "perf-test-model", RelayTiming{
+			TTFTMs:          int64(i),
+			DurationMs:      int64(i * 10),
+			TokensPerSecond: float64(i),
+		}, now)
+	}
+	// 超出窗口的样本不参与统计
+	relayPerformance.record(-1, "perf-test-model", RelayTiming{DurationMs: 99999}, now.Add(-2*relayPerformanceWindow))
+
+	stats := GetRelayPerformanceStats(-1, "perf-test-model")
+	require.Len(t, stats, 1)
+	require.Equal(t, 100, stats[0].Count)
+	require.Equal(t, Percentiles{P50: 50, P90: 90, P99: 99}, stats[0].TTFTMs)
+	require.Equal(t, Percentiles{P50: 500, P90: 900, P99: 990}, stats[0].DurationMs)
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/pdfdoc"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+const statementPeriodLayout = "2006-01"
+
+// ResolveStatementPeriod 解析账单月份（YYYY-MM，按配置的时区划分），为空时取上一个月。
+// 返回账期的起止时间戳 [start, end)
+func ResolveStatementPeriod(period string, now time.Time) (string, int64, int64, error) {
+	loc := operation_setting.GetStatementSetting().Location()
+	now = now.In(loc)
+	var month time.Time
+	if period == "" {
+		month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, -1, 0)
+	} else {
+		parsed, err := time.ParseInLocation(statementPeriodLayout, period, loc)
+		if err != nil {
+			return "", 0, 0, errors.New("账单月份格式应为 YYYY-MM")
+		}
+		month = parsed
+	}
+	if month.After(now) {
+		return "", 0, 0, errors.New("账单月份尚未开始")
+	}
+	return month.Format(statementPeriodLayout), month.Unix(), month.AddDate(0, 1, 0).Unix(), nil
+}
+
+// resolveSubjectStatementPeriod 解析账单月份，并拒绝早于账单对象创建时间所在月份的账期
+func resolveSubjectStatementPeriod(subjectType string, subjectId int, period string, now time.Time) (string, int64, int64, error) {
+	period, start, end, err := ResolveStatementPeriod(period, now)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	var createdTime int64
+	switch subjectType {
+	case model.StatementSubjectUser:
+		user, err := model.GetUserById(subjectId, false)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		createdTime = user.CreatedTime
+	case model.StatementSubjectOrganization:
+		org, err := model.GetOrganizationById(subjectId)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		createdTime = org.CreatedTime
+	default:
+		return "", 0, 0, errors.New("未知的账单对象类型")
+	}
+	// 早期创建的用户没有记录创建时间，不做限制
+	if createdTime > 0 && end <= createdTime {
+		return "", 0, 0, errors.New("账单月份早于账户创建时间")
+	}
+	return period, start, end, nil
+}
+
+// GetStatement 返回指定月份的账单，只读：已保存快照时返回快照，否则实时生成且不保存。
+// 快照只由账期结束后的定时任务或管理员重新生成时写入
+func GetStatement(subjectType string, subjectId int, period string) (*model.Statement, error) {
+	now := time.Now()
+	period, start, end, err := resolveSubjectStatementPeriod(subjectType, subjectId, period, now)
+	if err != nil {
+		return nil, err
+	}
+	if now.Unix() >= end {
+		statement, err := model.GetStatement(subjectType, subjectId, period)
+		if err == nil {
+			return statement, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return model.BuildStatement(subjectType, subjectId, period, start, end)
+}
+
+// RegenerateStatement 重新生成已结束月份的账单并覆盖保存快照
+func RegenerateStatement(subjectType string, subjectId int, period string) (*model.Statement, error) {
+	now := time.Now()
+	period, start, end, err := resolveSubjectStatementPeriod(subjectType, subjectId, period, now)
+	if err != nil {
+		return nil, err
+	}
+	if now.Unix() < end {
+		return nil, errors.New("账单月份尚未结束，无法保存快照")
+	}
+	statement, err := model.BuildStatement(subjectType, subjectId, period, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.SaveStatement(statement); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+func formatStatementTime(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	loc := operation_setting.GetStatementSetting().Location()
+	return time.Unix(timestamp, 0).In(loc).Format(time.DateTime)
+}
+
+func formatStatementMoney(money float64) string {
+	return strconv.FormatFloat(money, 'f', 2, 64)
+}
+
+func statementSubjectLabel(statement *model.Statement) string {
+	if statement.SubjectType == model.StatementSubjectOrganization {
+		return fmt.Sprintf("组织 %s (#%d)", statement.SubjectName, statement.SubjectId)
+	}
+	return fmt.Sprintf("用户 %s (#%d)", statement.SubjectName, statement.SubjectId)
+}
+
+func statementSummaryRows(statement *model.Statement) [][]string {
+	return [][]string{
+		{"充值支付金额", formatStatementMoney(statement.PaymentMoney)},
+		{"订阅支付金额", formatStatementMoney(statement.SubscriptionMoney)},
+		{"请求次数", strconv.FormatInt(statement.RequestCount, 10)},
+		{"消费额度", logger.FormatQuota(int(statement.ConsumedQuota))},
+		{"退款额度", logger.FormatQuota(int(statement.RefundedQuota))},
+		{"净消费额度", logger.FormatQuota(int(statement.ConsumedQuota - statement.RefundedQuota))},
+	}
+}
+
+func statementPaymentRows(statement *model.Statement) [][]string {
+	rows := make([][]string, 0, len(statement.Details.Payments))
+	for _, payment := range statement.Details.Payments {
+		kind := "充值"
+		if payment.Subscription {
+			kind = "订阅"
+		}
+		rows = append(rows, []string{
+			formatStatementTime(payment.CompleteTime),
+			kind,
+			payment.TradeNo,
+			payment.PaymentMethod,
+			formatStatementMoney(payment.Money),
+		})
+	}
+	return rows
+}
+
+func statementTopupRows(statement *model.Statement) [][]string {
+	rows := make([][]string, 0, len(statement.Details.TopupEvents))
+	for _, event := range statement.Details.TopupEvents {
+		rows = append(rows, []string{formatStatementTime(event.CreatedAt), event.Content})
+	}
+	return rows
+}
+
+func statementUsageRows(statement *model.Statement) [][]string {
+	rows := make([][]string, 0, len(statement.Details.Usage))
+	for _, line := range statement.Details.Usage {
+		rows = append(rows, []string{
+			line.ModelName,
+			line.TokenName,
+			strconv.FormatInt(line.Requests, 10),
+			strconv.FormatInt(line.PromptTokens, 10),
+			strconv.FormatInt(line.CompletionTokens, 10),
+			strconv.FormatInt(line.Quota, 10),
+			logger.FormatQuota(int(line.Quota)),
+		})
+	}
+	return rows
+}
+
+func statementRefundRows(statement *model.Statement) [][]string {
+	rows := make([][]string, 0, len(statement.Details.Refunds))
+	for _, line := range statement.Details.Refunds {
+		rows = append(rows, []string{
+			line.ModelName,
+			strconv.FormatInt(line.Count, 10),
+			strconv.FormatInt(line.Quota, 10),
+			logger.FormatQuota(int(line.Quota)),
+		})
+	}
+	return rows
+}
+
+var (
+	statementPaymentHeader = []string{"完成时间", "类型", "订单号", "支付方式", "金额"}
+	statementTopupHeader   = []string{"时间", "内容"}
+	statementUsageHeader   = []string{"模型", "令牌", "请求次数", "输入 tokens", "输出 tokens", "额度", "金额"}
+	statementRefundHeader  = []string{"模型", "退款次数", "额度", "金额"}
+)
+
+// RenderStatementCSV 将账单导出为 CSV，各部分之间以空行分隔；带 UTF-8 BOM 以便 Excel 正确识别中文
+func RenderStatementCSV(statement *model.Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\uFEFF")
+	w := csv.NewWriter(&buf)
+	section := func(title string, header []string, rows [][]string) {
+		_ = w.Write([]string{title})
+		if header != nil {
+			_ = w.Write(header)
+		}
+		_ = w.WriteAll(rows)
+		_ = w.Write(nil)
+	}
+	section("账单", nil, [][]string{
+		{"对象", statementSubjectLabel(statement)},
+		{"账期", statement.Period},
+		{"开始时间", formatStatementTime(statement.PeriodStart)},
+		{"结束时间", formatStatementTime(statement.PeriodEnd)},
+		{"生成时间", formatStatementTime(statement.GeneratedAt)},
+	})
+	section("汇总", nil, statementSummaryRows(statement))
+	section("支付记录", statementPaymentHeader, statementPaymentRows(statement))
+	section("充值记录", statementTopupHeader, statementTopupRows(statement))
+	section("消费明细", statementUsageHeader, statementUsageRows(statement))
+	section("退款明细", statementRefundHeader, statementRefundRows(statement))
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// RenderStatementPDF 将账单导出为 PDF，抬头使用配置的公司信息
+func RenderStatementPDF(statement *model.Statement) []byte {
+	setting := operation_setting.GetStatementSetting()
+	doc := pdfdoc.New()
+	if setting.CompanyName != "" {
+		doc.Text(16, setting.CompanyName)
+	}
+	for _, line := range []string{setting.CompanyAddress, setting.CompanyTaxId, setting.CompanyEmail} {
+		if line != "" {
+			doc.Text(9, line)
+		}
+	}
+	doc.Space(8)
+	doc.Text(14, fmt.Sprintf("月度账单 %s", statement.Period))
+	doc.Text(10, statementSubjectLabel(statement))
+	doc.Text(9, fmt.Sprintf("账期：%s 至 %s（%s）", formatStatementTime(statement.PeriodStart), formatStatementTime(statement.PeriodEnd), setting.Location().String()))
+	doc.Text(9, "生成时间："+formatStatementTime(statement.GeneratedAt))
+	doc.Rule()
+
+	doc.Space(6)
+	doc.Text(11, "汇总")
+	doc.Table(9, []float64{0.4, 0.6}, []string{"项目", "数值"}, statementSummaryRows(statement))
+
+	if rows := statementPaymentRows(statement); len(rows) > 0 {
+		doc.Space(10)
+		doc.Text(11, "支付记录")
+		doc.Table(8, []float64{0.22, 0.1, 0.38, 0.15, 0.15}, statementPaymentHeader, rows)
+	}
+	if rows := statementTopupRows(statement); len(rows) > 0 {
+		doc.Space(10)
+		doc.Text(11, "充值记录")
+		doc.Table(8, []float64{0.22, 0.78}, statementTopupHeader, rows)
+	}
+	if rows := statementUsageRows(statement); len(rows) > 0 {
+		doc.Space(10)
+		doc.Text(11, "消费明细")
+		doc.Table(8, []float64{0.24, 0.16, 0.1, 0.12, 0.12, 0.12, 0.14}, statementUsageHeader, rows)
+	}
+	if rows := statementRefundRows(statement); len(rows) > 0 {
+		doc.Space(10)
+		doc.Text(11, "退款明细")
+		doc.Table(8, []float64{0.4, 0.2, 0.2, 0.2}, statementRefundHeader, rows)
+	}
+	if setting.FooterNote != "" {
+		doc.Space(16)
+		doc.Text(8, setting.FooterNote)
+	}
+	return doc.Bytes()
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	statementSnapshotTickInterval = 1 * time.Hour
+	// statementSnapshotDelay 账期结束后等待的时间，让批量写入、异步结算等迟到的日志落库后再保存快照
+	statementSnapshotDelay = 24 * time.Hour
+)
+
+var (
+	statementSnapshotOnce    sync.Once
+	statementSnapshotRunning atomic.Bool
+	// statementSnapshotDone 已完成快照的最近账期，避免每次都扫描日志
+	statementSnapshotDone atomic.Value
+)
+
+// StartStatementSnapshotTask 定期为上一个月有账单数据的用户与组织保存账单快照，仅在主节点运行
+func StartStatementSnapshotTask() {
+	statementSnapshotOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("statement snapshot task started: tick=%s", statementSnapshotTickInterval))
+			ticker := time.NewTicker(statementSnapshotTickInterval)
+			defer ticker.Stop()
+
+			runStatementSnapshotOnce()
+			for range ticker.C {
+				runStatementSnapshotOnce()
+			}
+		})
+	})
+}
+
+func runStatementSnapshotOnce() {
+	if !statementSnapshotRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer statementSnapshotRunning.Store(false)
+
+	ctx := context.Background()
+	now := time.Now()
+	period, start, end, err := ResolveStatementPeriod("", now)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("statement snapshot task failed: %v", err))
+		return
+	}
+	if now.Before(time.Unix(end, 0).Add(statementSnapshotDelay)) || statementSnapshotDone.Load() == period {
+		return
+	}
+	count, err := snapshotStatements(period, start, end)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("statement snapshot task failed: period=%s, err=%v", period, err))
+		return
+	}
+	statementSnapshotDone.Store(period)
+	if count > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("statement snapshot: period=%s, saved=%d", period, count))
+	}
+}
+
+// snapshotStatements 为账期内有数据且尚无快照的用户与组织保存账单，返回保存的份数
+func snapshotStatements(period string, start int64, end int64) (int, error) {
+	userIds, orgIds, err := model.GetStatementSubjects(start, end)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for subjectType, ids := range map[string][]int{
+		model.StatementSubjectUser:         userIds,
+		model.StatementSubjectOrganization: orgIds,
+	} {
+		saved, err := model.GetSavedStatementSubjectIds(subjectType, period)
+		if err != nil {
+			return count, err
+		}
+		exists := make(map[int]bool, len(saved))
+		for _, id := range saved {
+			exists[id] = true
+		}
+		for _, id := range ids {
+			if exists[id] {
+				continue
+			}
+			statement, err := model.BuildStatement(subjectType, id, period, start, end)
+			if err != nil {
+				// 对象已被删除等情况跳过，不影响其他账单
+				common.SysLog(fmt.Sprintf("failed to build statement %s:%d:%s: %s", subjectType, id, period, err.Error()))
+				continue
+			}
+			if err := model.SaveStatement(statement); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestStatementSnapshotAndRegenerate(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+
+	const sep2023 = int64(1_693_526_400) // 2023-09-01 00:00:00 UTC
+	const oct2023 = int64(1_696_118_400) // 2023-10-01 00:00:00 UTC
+	seedLog := func(logType int, createdAt int64, modelName string, tokenName string, quota int) {
+		require.NoError(t, model.LOG_DB.Create(&model.Log{
+			UserId: 1, Type: logType, CreatedAt: createdAt, ModelName: modelName, TokenName: tokenName,
+			Quota: quota, PromptTokens: 10, CompletionTokens: 5, Content: "test",
+		}).Error)
+	}
+	seedLog(model.LogTypeConsume, sep2023+10, "gpt-4o", "default", 100)
+	seedLog(model.LogTypeConsume, sep2023+20, "gpt-4o", "default", 200)
+	seedLog(model.LogTypeConsume, sep2023+30, "claude", "ci", 50)
+	seedLog(model.LogTypeRefund, sep2023+40, "claude", "ci", 20)
+	seedLog(model.LogTypeTopup, sep2023+50, "", "", 0)
+	seedLog(model.LogTypeConsume, oct2023+10, "gpt-4o", "default", 999)
+
+	require.NoError(t, model.DB.Create(&model.TopUp{UserId: 1, TradeNo: "T1", Money: 10, Status: common.TopUpStatusSuccess, CompleteTime: sep2023 + 100}).Error)
+	require.NoError(t, model.DB.Create(&model.TopUp{UserId: 1, TradeNo: "S1", Money: 30, Status: common.TopUpStatusSuccess, CompleteTime: sep2023 + 200}).Error)
+	require.NoError(t, model.DB.Create(&model.SubscriptionOrder{UserId: 1, TradeNo: "S1", Money: 30, Status: common.TopUpStatusSuccess}).Error)
+
+	statement, err := GetStatement(model.StatementSubjectUser, 1, "2023-09")
+	require.NoError(t, err)
+	assert.EqualValues(t, 350, statement.ConsumedQuota)
+	assert.EqualValues(t, 20, statement.RefundedQuota)
+	assert.EqualValues(t, 3, statement.RequestCount)
+	assert.Equal(t, 10.0, statement.PaymentMoney)
+	assert.Equal(t, 30.0, statement.SubscriptionMoney)
+	require.Len(t, statement.Details.Usage, 2)
+	assert.Equal(t, "gpt-4o", statement.Details.Usage[0].ModelName)
+	assert.Len(t, statement.Details.TopupEvents, 1)
+	// 查询账单不保存快照
+	_, err = model.GetStatement(model.StatementSubjectUser, 1, "2023-09")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// 定时任务为有数据的对象保存快照，重复执行不会覆盖
+	count, err := snapshotStatements("2023-09", sep2023, oct2023)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = snapshotStatements("2023-09", sep2023, oct2023)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// 已保存快照后读取快照，新增的日志要重新生成后才会体现
+	seedLog(model.LogTypeConsume, sep2023+60, "gpt-4o", "default", 1)
+	statement, err = GetStatement(model.StatementSubjectUser, 1, "2023-09")
+	require.NoError(t, err)
+	assert.EqualValues(t, 350, statement.ConsumedQuota)
+	statement, err = RegenerateStatement(model.StatementSubjectUser, 1, "2023-09")
+	require.NoError(t, err)
+	assert.EqualValues(t, 351, statement.ConsumedQuota)
+
+	// 早于账户创建时间或尚未开始的月份无效
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("created_time", oct2023+10).Error)
+	_, err = GetStatement(model.StatementSubjectUser, 1, "2023-09")
+	assert.Error(t, err)
+	_, err = GetStatement(model.StatementSubjectUser, 1, "2999-01")
+	assert.Error(t, err)
+
+	csv, err := RenderStatementCSV(statement)
+	require.NoError(t, err)
+	assert.Contains(t, string(csv), "S1")
+	assert.True(t, strings.Contains(string(csv), "claude,ci,1,10,5,50"))
+	assert.True(t, strings.HasPrefix(string(RenderStatementPDF(statement)), "%PDF-"))
+}
+
+func TestStatementCountsOrganizationUsageOnce(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+	require.NoError(t, model.DB.Create(&model.Organization{Id: 7, Name: "acme", OwnerId: 1}).Error)
+
+	const sep2023 = int64(1_693_526_400) // 2023-09-01 00:00:00 UTC
+	const oct2023 = int64(1_696_118_400) // 2023-10-01 00:00:00 UTC
+	seedLog := func(organizationId int, quota int) {
+		require.NoError(t, model.LOG_DB.Create(&model.Log{
+			UserId: 1, OrganizationId: organizationId, Type: model.LogTypeConsume, CreatedAt: sep2023 + 10,
+			ModelName: "gpt-4o", Quota: quota, Content: "test",
+		}).Error)
+	}
+	seedLog(0, 100)
+	seedLog(7, 300)
+
+	statement, err := GetStatement(model.StatementSubjectUser, 1, "2023-09")
+	require.NoError(t, err)
+	assert.EqualValues(t, 100, statement.ConsumedQuota)
+	statement, err = GetStatement(model.StatementSubjectOrganization, 7, "2023-09")
+	require.NoError(t, err)
+	assert.EqualValues(t, 300, statement.ConsumedQuota)
+
+	userIds, orgIds, err := model.GetStatementSubjects(sep2023, oct2023)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, userIds)
+	assert.Equal(t, []int{7}, orgIds)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"math"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+)
+
+// setupStreamQuotaGuard 为按量计费的流式请求创建额度守卫。
+// 预算取资金来源与令牌剩余额度中较小的一个，再加上允许透支的额度；无法确定预算时不启用
+func setupStreamQuotaGuard(c *gin.Context, relayInfo *relaycommon.RelayInfo, session *BillingSession) {
+	quotaSetting := operation_setting.GetQuotaSetting()
+	if !quotaSetting.StreamQuotaGuardEnabled || !relayInfo.IsStream {
+		return
+	}
+	priceData := relayInfo.PriceData
+	if priceData.UsePrice || priceData.FreeModel {
+		return
+	}
+	ratio := priceData.ModelRatio * priceData.GroupRatioInfo.GroupRatio
+	if ratio <= 0 {
+		return
+	}
+	budget, limited := session.fundingBudget()
+	if !relayInfo.TokenUnlimited && !relayInfo.IsPlayground {
+		// token_quota 为预扣前的令牌剩余额度
+		tokenBudget := float64(c.GetInt("token_quota"))
+		if !limited || tokenBudget < budget {
+			budget = tokenBudget
+		}
+		limited = true
+	}
+	if !limited {
+		return
+	}
+	relayInfo.StreamQuotaGuard = &relaycommon.StreamQuotaGuard{
+		Budget:             budget + float64(quotaSetting.StreamOverdraftQuota),
+		PromptQuota:        float64(relayInfo.GetEstimatePromptTokens()) * ratio,
+		CompletionPerToken: ratio * priceData.CompletionRatio,
+	}
+}
+
+// fundingBudget 返回资金来源本次请求最多可消耗的额度（含已预扣部分），第二个返回值为 false 表示不限额
+func (s *BillingSession) fundingBudget() (float64, bool) {
+	switch funding := s.funding.(type) {
+	case *WalletFunding:
+		// relayInfo.UserQuota 为预扣前的用户余额
+		return float64(s.relayInfo.UserQuota + funding.creditLimit), true
+	case *OrganizationFunding:
+		org, err := model.GetOrganizationById(funding.organizationId)
+		if err != nil {
+			return 0, false
+		}
+		budget := float64(org.Quota + org.CreditLimit + funding.consumed)
+		member, err := model.GetOrganizationMember(funding.organizationId, funding.userId)
+		if err == nil && member.QuotaLimit > 0 {
+			budget = math.Min(budget, float64(member.QuotaLimit-member.UsedQuota+funding.consumed))
+		}
+		return budget, true
+	case *SubscriptionFunding:
+		if funding.AmountTotal <= 0 {
+			return 0, false
+		}
+		return float64(funding.AmountTotal - funding.AmountUsedAfter + funding.preConsumed), true
+	}
+	return 0, false
+}
+
+// ChargeStreamText 将流式响应中新生成的文本计入额度守卫，额度耗尽时返回 false，调用方应中止流式响应
+func ChargeStreamText(relayInfo *relaycommon.RelayInfo, text string) bool {
+	guard := relayInfo.StreamQuotaGuard
+	if guard == nil {
+		return true
+	}
+	if text == "" {
+		return !guard.Exceeded()
+	}
+	return guard.AddCompletionTokens(EstimateTokenByModel(relayInfo.UpstreamModelName, text))
+}
@@ -43,6 +43,15 @@ func TestMain(m *testing.M) {
 		&model.Log{},
 		&model.Channel{},
 		&model.UserSubscription{},
+		&model.LogArchive{},
+		&model.TopUp{},
+		&model.SubscriptionOrder{},
+		&model.Statement{},
+		&model.QuotaLedger{},
+		&model.QuotaLedgerMismatch{},
+		&model.CreditGrant{},
+		&model.CreditSettlement{},
+		&model.Organization{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -63,6 +72,15 @@ func truncate(t *testing.T) {
 		model.DB.Exec("DELETE FROM logs")
 		model.DB.Exec("DELETE FROM channels")
 		model.DB.Exec("DELETE FROM user_subscriptions")
+		model.DB.Exec("DELETE FROM log_archives")
+		model.DB.Exec("DELETE FROM top_ups")
+		model.DB.Exec("DELETE FROM subscription_orders")
+		model.DB.Exec("DELETE FROM statements")
+		model.DB.Exec("DELETE FROM quota_ledgers")
+		model.DB.Exec("DELETE FROM quota_ledger_mismatches")
+		model.DB.Exec("DELETE FROM credit_grants")
+		model.DB.Exec("DELETE FROM credit_settlements")
+		model.DB.Exec("DELETE FROM organizations")
 	})
 }
 
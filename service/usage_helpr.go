@@ -23,7 +23,12 @@ func ResponseText2Usage(c *gin.Context, responseText string, modeName string, pr
 	common.SetContextKey(c, constant.ContextKeyLocalCountTokens, true)
 	usage := &dto.Usage{}
 	usage.PromptTokens = promptTokens
-	usage.CompletionTokens = EstimateTokenByModel(modeName, responseText)
+	if common.GetContextKeyBool(c, constant.ContextKeyClientCancelled) {
+		// 客户端中途断开，上游没有返回最终 usage，按已收到的文本计数
+		usage.CompletionTokens = CountTextToken(responseText, modeName)
+	} else {
+		usage.CompletionTokens = EstimateTokenByModel(modeName, responseText)
+	}
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	return usage
 }
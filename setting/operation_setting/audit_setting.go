@@ -0,0 +1,23 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// AuditSetting 管理审计日志相关配置
+type AuditSetting struct {
+	RetentionDays int `json:"retention_days"` // 审计日志保留天数，0 表示永久保留
+}
+
+// 默认配置
+var auditSetting = AuditSetting{
+	RetentionDays: 180,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audit_setting", &auditSetting)
+}
+
+// GetAuditSetting 获取审计日志配置
+func GetAuditSetting() *AuditSetting {
+	return &auditSetting
+}
@@ -0,0 +1,57 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// 赠送额度来源
+const (
+	CreditGrantSourceSignup     = "signup"
+	CreditGrantSourceCheckin    = "checkin"
+	CreditGrantSourceRedemption = "redemption"
+	CreditGrantSourceInvite     = "invite"
+	CreditGrantSourceOAuthClaim = "oauth_claim"
+)
+
+// CreditGrantSetting 赠送额度（注册赠送、签到、兑换码、邀请码、身份提供方映射）的有效期与消耗优先级。
+// 有效天数为 0 表示该来源的额度不过期，直接并入余额；优先级数值越小越先消耗，同优先级按过期时间先后消耗
+type CreditGrantSetting struct {
+	SignupExpireDays     int `json:"signup_expire_days"`
+	SignupPriority       int `json:"signup_priority"`
+	CheckinExpireDays    int `json:"checkin_expire_days"`
+	CheckinPriority      int `json:"checkin_priority"`
+	RedemptionExpireDays int `json:"redemption_expire_days"`
+	RedemptionPriority   int `json:"redemption_priority"`
+	InviteExpireDays     int `json:"invite_expire_days"`
+	InvitePriority       int `json:"invite_priority"`
+	OAuthClaimExpireDays int `json:"oauth_claim_expire_days"`
+	OAuthClaimPriority   int `json:"oauth_claim_priority"`
+}
+
+// 默认配置，赠送额度默认不过期
+var creditGrantSetting = CreditGrantSetting{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("credit_grant_setting", &creditGrantSetting)
+}
+
+// GetCreditGrantSetting 获取赠送额度配置
+func GetCreditGrantSetting() *CreditGrantSetting {
+	return &creditGrantSetting
+}
+
+// Rule 返回指定来源的有效天数与优先级，未知来源返回 0, 0
+func (s *CreditGrantSetting) Rule(source string) (expireDays int, priority int) {
+	switch source {
+	case CreditGrantSourceSignup:
+		return s.SignupExpireDays, s.SignupPriority
+	case CreditGrantSourceCheckin:
+		return s.CheckinExpireDays, s.CheckinPriority
+	case CreditGrantSourceRedemption:
+		return s.RedemptionExpireDays, s.RedemptionPriority
+	case CreditGrantSourceInvite:
+		return s.InviteExpireDays, s.InvitePriority
+	case CreditGrantSourceOAuthClaim:
+		return s.OAuthClaimExpireDays, s.OAuthClaimPriority
+	}
+	return 0, 0
+}
@@ -0,0 +1,49 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// CreditSetting 后付费信用额度配置
+type CreditSetting struct {
+	// DunningPercents 信用额度使用比例达到这些百分比时发送催缴通知，每个结算周期每档只通知一次
+	DunningPercents []int `json:"dunning_percents"`
+}
+
+// 默认配置
+var creditSetting = CreditSetting{
+	DunningPercents: []int{50, 80, 100},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("credit_setting", &creditSetting)
+}
+
+// GetCreditSetting 获取后付费信用额度配置
+func GetCreditSetting() *CreditSetting {
+	return &creditSetting
+}
+
+// DunningLevel 返回已用比例 usedPercent 达到的最高催缴档位，未达到任何档位时返回 0
+func (s *CreditSetting) DunningLevel(usedPercent int) int {
+	level := 0
+	for _, percent := range s.DunningPercents {
+		if percent > 0 && usedPercent >= percent {
+			level = max(level, percent)
+		}
+	}
+	return level
+}
+
+// ValidDunningPercents 催缴档位必须在 1-100 之间且不重复
+func ValidDunningPercents(percents []int) bool {
+	for i, percent := range percents {
+		if percent < 1 || percent > 100 || slices.Contains(percents[:i], percent) {
+			return false
+		}
+	}
+	return true
+}
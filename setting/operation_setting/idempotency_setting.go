@@ -0,0 +1,33 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// IdempotencySetting Idempotency-Key 幂等请求配置
+type IdempotencySetting struct {
+	// Enabled 是否支持中转与任务提交接口的 Idempotency-Key 请求头；支付回调的去重不受此开关影响
+	Enabled bool `json:"enabled"`
+	// WindowSeconds 成功请求的响应保留时长，窗口内相同 Idempotency-Key 的请求直接重放该响应
+	WindowSeconds int `json:"window_seconds"`
+	// WaitSeconds 相同 Idempotency-Key 的请求仍在处理中时，重复请求最多等待的时长
+	WaitSeconds int `json:"wait_seconds"`
+	// MaxResponseBytes 可保存重放的最大响应体积，超过时只记录已处理，重复请求返回 409。响应体只在启用 Redis 时保存
+	MaxResponseBytes int `json:"max_response_bytes"`
+}
+
+// 默认配置
+var idempotencySetting = IdempotencySetting{
+	Enabled:          true,
+	WindowSeconds:    24 * 3600,
+	WaitSeconds:      30,
+	MaxResponseBytes: 4 << 20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("idempotency_setting", &idempotencySetting)
+}
+
+// GetIdempotencySetting 获取幂等请求配置
+func GetIdempotencySetting() *IdempotencySetting {
+	return &idempotencySetting
+}
@@ -0,0 +1,43 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	LogArchiveStorageLocal = "local"
+	LogArchiveStorageS3    = "s3"
+)
+
+// LogArchiveSetting 日志归档相关配置，超过保留天数的日志按天导出为 gzip 压缩的 NDJSON 后再删除
+type LogArchiveSetting struct {
+	Enabled       bool   `json:"enabled"`
+	RetentionDays int    `json:"retention_days"` // 数据库中保留的天数，更早的日志会被归档并删除
+	Storage       string `json:"storage"`        // local 或 s3
+	LocalDir      string `json:"local_dir"`
+	Prefix        string `json:"prefix"` // 对象键前缀
+	S3Endpoint    string `json:"s3_endpoint"`
+	S3Region      string `json:"s3_region"`
+	S3Bucket      string `json:"s3_bucket"`
+	S3AccessKeyId string `json:"s3_access_key_id"`
+	S3Secret      string `json:"s3_secret"`
+	S3PathStyle   bool   `json:"s3_path_style"`
+}
+
+// 默认配置
+var logArchiveSetting = LogArchiveSetting{
+	Enabled:       false,
+	RetentionDays: 90,
+	Storage:       LogArchiveStorageLocal,
+	LocalDir:      "./log-archive",
+	Prefix:        "logs",
+	S3Region:      "us-east-1",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_archive_setting", &logArchiveSetting)
+}
+
+// GetLogArchiveSetting 获取日志归档配置
+func GetLogArchiveSetting() *LogArchiveSetting {
+	return &logArchiveSetting
+}
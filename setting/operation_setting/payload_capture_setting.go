@@ -0,0 +1,46 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// PayloadCaptureSetting 请求载荷抓包相关配置，仅对列出的用户或令牌生效
+type PayloadCaptureSetting struct {
+	Enabled        bool     `json:"enabled"`
+	UserIds        []int    `json:"user_ids"`        // 抓取这些用户的全部请求
+	TokenIds       []int    `json:"token_ids"`       // 抓取这些令牌的请求
+	RedactPaths    []string `json:"redact_paths"`    // 入库前脱敏的 JSONPath，同时作用于四段载荷
+	MaxBodyKB      int      `json:"max_body_kb"`     // 每段载荷保留的最大长度，超出部分截断
+	RetentionHours int      `json:"retention_hours"` // 抓包保留时长（小时）
+}
+
+// 默认配置
+var payloadCaptureSetting = PayloadCaptureSetting{
+	Enabled:        false,
+	UserIds:        []int{},
+	TokenIds:       []int{},
+	RedactPaths:    []string{"$..api_key", "$..authorization"},
+	MaxBodyKB:      256,
+	RetentionHours: 72,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("payload_capture_setting", &payloadCaptureSetting)
+}
+
+// GetPayloadCaptureSetting 获取请求载荷抓包配置
+func GetPayloadCaptureSetting() *PayloadCaptureSetting {
+	return &payloadCaptureSetting
+}
+
+// ShouldCapturePayload 判断指定用户或令牌的请求是否需要抓包
+func ShouldCapturePayload(userId int, tokenId int) bool {
+	setting := GetPayloadCaptureSetting()
+	if !setting.Enabled {
+		return false
+	}
+	return slices.Contains(setting.UserIds, userId) || (tokenId != 0 && slices.Contains(setting.TokenIds, tokenId))
+}
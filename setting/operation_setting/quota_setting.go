@@ -4,11 +4,14 @@ import "github.com/QuantumNous/new-api/setting/config"
 
 type QuotaSetting struct {
 	EnableFreeModelPreConsume bool `json:"enable_free_model_pre_consume"` // 是否对免费模型启用预消耗
+	StreamQuotaGuardEnabled   bool `json:"stream_quota_guard_enabled"`    // 流式响应中途额度耗尽时是否中止生成
+	StreamOverdraftQuota      int  `json:"stream_overdraft_quota"`        // 中止流式响应前允许透支的额度
 }
 
 // 默认配置
 var quotaSetting = QuotaSetting{
 	EnableFreeModelPreConsume: true,
+	StreamQuotaGuardEnabled:   true,
 }
 
 func init() {
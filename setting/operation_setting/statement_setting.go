@@ -0,0 +1,44 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// StatementSetting 月度账单相关配置，公司信息显示在 PDF 抬头
+type StatementSetting struct {
+	CompanyName    string `json:"company_name"`
+	CompanyAddress string `json:"company_address"`
+	CompanyTaxId   string `json:"company_tax_id"`
+	CompanyEmail   string `json:"company_email"`
+	FooterNote     string `json:"footer_note"`
+	Timezone       string `json:"timezone"` // 账单月份的划分时区，IANA 名称，如 Asia/Shanghai
+}
+
+// 默认配置
+var statementSetting = StatementSetting{
+	Timezone: "UTC",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("statement_setting", &statementSetting)
+}
+
+// GetStatementSetting 获取月度账单配置
+func GetStatementSetting() *StatementSetting {
+	return &statementSetting
+}
+
+// Location 返回账单使用的时区，配置无效时使用 UTC
+func (s *StatementSetting) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -4,12 +4,14 @@ import "github.com/QuantumNous/new-api/setting/config"
 
 // TokenSetting 令牌相关配置
 type TokenSetting struct {
-	MaxUserTokens int `json:"max_user_tokens"` // 每用户最大令牌数量
+	MaxUserTokens          int   `json:"max_user_tokens"`           // 每用户最大令牌数量
+	KeyRotationGracePeriod int64 `json:"key_rotation_grace_period"` // 令牌轮换后旧密钥的默认宽限期（秒）
 }
 
 // 默认配置
 var tokenSetting = TokenSetting{
-	MaxUserTokens: 1000, // 默认每用户最多 1000 个令牌
+	MaxUserTokens:          1000,  // 默认每用户最多 1000 个令牌
+	KeyRotationGracePeriod: 86400, // 默认旧密钥保留 24 小时
 }
 
 func init() {
@@ -26,3 +28,8 @@ func GetTokenSetting() *TokenSetting {
 func GetMaxUserTokens() int {
 	return GetTokenSetting().MaxUserTokens
 }
+
+// GetKeyRotationGracePeriod 获取令牌轮换的默认宽限期（秒）
+func GetKeyRotationGracePeriod() int64 {
+	return GetTokenSetting().KeyRotationGracePeriod
+}
@@ -0,0 +1,58 @@
+package ratio_setting
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// tieredRatioMap 模型按提示 tokens 分档的计费倍率，例如提示超过 200k tokens 后使用更高的倍率
+var tieredRatioMap = types.NewRWMap[string, []types.PriceTier]()
+
+func TieredRatio2JSONString() string {
+	return tieredRatioMap.MarshalJSONString()
+}
+
+// UpdateTieredRatioByJSONString 校验并更新分档倍率，每个模型的分档按 Threshold 升序保存
+func UpdateTieredRatioByJSONString(jsonStr string) error {
+	tiered := make(map[string][]types.PriceTier)
+	if err := common.Unmarshal([]byte(jsonStr), &tiered); err != nil {
+		return err
+	}
+	for name, tiers := range tiered {
+		seen := make(map[int]bool, len(tiers))
+		for _, tier := range tiers {
+			if tier.Threshold <= 0 {
+				return fmt.Errorf("模型 %s 的分档阈值必须大于 0", name)
+			}
+			if seen[tier.Threshold] {
+				return fmt.Errorf("模型 %s 存在重复的分档阈值 %d", name, tier.Threshold)
+			}
+			seen[tier.Threshold] = true
+			if tier.ModelRatio < 0 || tier.CompletionRatio < 0 || tier.CacheRatio < 0 || tier.CacheCreationRatio < 0 {
+				return fmt.Errorf("模型 %s 的分档倍率不能为负数", name)
+			}
+		}
+		types.SortPriceTiers(tiers)
+	}
+	tieredRatioMap.Clear()
+	tieredRatioMap.AddAll(tiered)
+	InvalidateExposedDataCache()
+	return nil
+}
+
+// GetTieredRatio 返回模型按提示 tokens 分档的倍率，未配置时返回 nil
+func GetTieredRatio(name string) []types.PriceTier {
+	name = FormatMatchingModelName(name)
+	tiers, ok := tieredRatioMap.Get(name)
+	if !ok {
+		return nil
+	}
+	// 返回副本，避免调用方修改共享配置
+	return append([]types.PriceTier(nil), tiers...)
+}
+
+func GetTieredRatioCopy() map[string][]types.PriceTier {
+	return tieredRatioMap.ReadAll()
+}
@@ -0,0 +1,20 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type ClaimMappingSettings struct {
+	// AllowAdminRole 允许身份提供方声明映射规则将用户设为管理员，关闭时映射角色最高为普通用户，仅超级管理员可以修改
+	AllowAdminRole bool `json:"allow_admin_role"`
+}
+
+// 默认配置
+var defaultClaimMappingSettings = ClaimMappingSettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("claim_mapping", &defaultClaimMappingSettings)
+}
+
+func GetClaimMappingSettings() *ClaimMappingSettings {
+	return &defaultClaimMappingSettings
+}
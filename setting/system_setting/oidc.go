@@ -10,6 +10,7 @@ type OIDCSettings struct {
 	AuthorizationEndpoint string `json:"authorization_endpoint"`
 	TokenEndpoint         string `json:"token_endpoint"`
 	UserInfoEndpoint      string `json:"user_info_endpoint"`
+	ClaimMappings         string `json:"claim_mappings"` // JSON 规则，按 userinfo 声明映射用户分组、角色与初始额度
 }
 
 // 默认配置
@@ -0,0 +1,37 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type SAMLSettings struct {
+	Enabled           bool   `json:"enabled"`
+	IdPMetadataURL    string `json:"idp_metadata_url"`    // 与 IdPMetadataXML 二选一，优先使用 XML
+	IdPMetadataXML    string `json:"idp_metadata_xml"`    // 直接粘贴的 IdP 元数据
+	EntityId          string `json:"entity_id"`           // SP Entity ID，为空时使用元数据地址
+	Certificate       string `json:"certificate"`         // SP 证书（PEM），用于签名请求
+	PrivateKey        string `json:"private_key"`         // SP 私钥（PEM），用于签名请求和解密断言
+	SignRequests      bool   `json:"sign_requests"`       // 是否对 AuthnRequest 签名，需要配置证书和私钥
+	AllowIdPInitiated bool   `json:"allow_idp_initiated"` // 是否允许 IdP 发起的登录
+	// 断言属性映射，为空时使用 NameID
+	UsernameAttribute    string `json:"username_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	GroupAttribute       string `json:"group_attribute"` // 属性值与已配置的分组名称一致时，新用户加入该分组
+	// SSOOnly 开启后仅允许通过 SAML 登录，超级管理员仍可使用密码登录以防被锁定
+	SSOOnly bool `json:"sso_only"`
+}
+
+// 默认配置
+var defaultSAMLSettings = SAMLSettings{
+	UsernameAttribute:    "uid",
+	EmailAttribute:       "email",
+	DisplayNameAttribute: "displayName",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("saml", &defaultSAMLSettings)
+}
+
+func GetSAMLSettings() *SAMLSettings {
+	return &defaultSAMLSettings
+}
@@ -0,0 +1,25 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type SCIMSettings struct {
+	Enabled bool `json:"enabled"`
+	// Secret 身份提供方调用 /scim/v2 接口时使用的 Bearer 密钥
+	Secret string `json:"secret"`
+	// GroupMapping 将 SCIM 组名映射为用户分组，未配置映射时组名需与已有分组一致
+	GroupMapping map[string]string `json:"group_mapping"`
+}
+
+// 默认配置
+var defaultSCIMSettings = SCIMSettings{
+	GroupMapping: map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("scim", &defaultSCIMSettings)
+}
+
+func GetSCIMSettings() *SCIMSettings {
+	return &defaultSCIMSettings
+}
@@ -64,6 +64,8 @@ const (
 	ErrorCodeReadRequestBodyFailed ErrorCode = "read_request_body_failed"
 	ErrorCodeConvertRequestFailed  ErrorCode = "convert_request_failed"
 	ErrorCodeAccessDenied          ErrorCode = "access_denied"
+	ErrorCodeIdempotencyKeyReused  ErrorCode = "idempotency_key_reused"
+	ErrorCodeIdempotencyInFlight   ErrorCode = "idempotency_request_in_progress"
 
 	// request error
 	ErrorCodeBadRequestBody ErrorCode = "bad_request_body"
@@ -0,0 +1,79 @@
+package types
+
+import "sort"
+
+// PriceTier 按提示 tokens 分档的计费倍率，提示 tokens 超过 Threshold 时适用。
+// 倍率为 0 表示沿用模型的基础倍率
+type PriceTier struct {
+	Threshold          int     `json:"threshold"`
+	ModelRatio         float64 `json:"model_ratio,omitempty"`
+	CompletionRatio    float64 `json:"completion_ratio,omitempty"`
+	CacheRatio         float64 `json:"cache_ratio,omitempty"`
+	CacheCreationRatio float64 `json:"cache_creation_ratio,omitempty"`
+}
+
+// SortPriceTiers 按 Threshold 升序排列分档
+func SortPriceTiers(tiers []PriceTier) {
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return tiers[i].Threshold < tiers[j].Threshold
+	})
+}
+
+// SelectPriceTier 返回提示 tokens 适用的最高分档，tiers 需按 Threshold 升序排列；没有适用分档时返回 nil
+func SelectPriceTier(tiers []PriceTier, promptTokens int) *PriceTier {
+	var selected *PriceTier
+	for i := range tiers {
+		if promptTokens > tiers[i].Threshold {
+			selected = &tiers[i]
+		}
+	}
+	return selected
+}
+
+// ApplyPromptTier 根据提示 tokens 从 PriceTiers 中选择分档并更新倍率，可重复调用：
+// 预扣费时按估算的提示 tokens 选择，结算时再按实际用量重新选择。返回适用的分档，nil 表示使用基础倍率
+func (p *PriceData) ApplyPromptTier(promptTokens int) *PriceTier {
+	if p.UsePrice || len(p.PriceTiers) == 0 {
+		return nil
+	}
+	if p.tierBase == nil {
+		p.tierBase = &PriceData{
+			ModelRatio:           p.ModelRatio,
+			CompletionRatio:      p.CompletionRatio,
+			CacheRatio:           p.CacheRatio,
+			CacheCreationRatio:   p.CacheCreationRatio,
+			CacheCreation5mRatio: p.CacheCreation5mRatio,
+			CacheCreation1hRatio: p.CacheCreation1hRatio,
+		}
+	}
+	base := p.tierBase
+	p.ModelRatio = base.ModelRatio
+	p.CompletionRatio = base.CompletionRatio
+	p.CacheRatio = base.CacheRatio
+	p.CacheCreationRatio = base.CacheCreationRatio
+	p.CacheCreation5mRatio = base.CacheCreation5mRatio
+	p.CacheCreation1hRatio = base.CacheCreation1hRatio
+
+	p.PriceTier = SelectPriceTier(p.PriceTiers, promptTokens)
+	tier := p.PriceTier
+	if tier == nil {
+		return nil
+	}
+	if tier.ModelRatio > 0 {
+		p.ModelRatio = tier.ModelRatio
+	}
+	if tier.CompletionRatio > 0 {
+		p.CompletionRatio = tier.CompletionRatio
+	}
+	if tier.CacheRatio > 0 {
+		p.CacheRatio = tier.CacheRatio
+	}
+	if tier.CacheCreationRatio > 0 && base.CacheCreationRatio > 0 {
+		// 5m / 1h 缓存写入倍率与基础缓存写入倍率保持相同比例
+		scale := tier.CacheCreationRatio / base.CacheCreationRatio
+		p.CacheCreationRatio = tier.CacheCreationRatio
+		p.CacheCreation5mRatio = base.CacheCreation5mRatio * scale
+		p.CacheCreation1hRatio = base.CacheCreation1hRatio * scale
+	}
+	return tier
+}
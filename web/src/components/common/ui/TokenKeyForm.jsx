@@ -0,0 +1,71 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useState } from 'react';
+import { Button, Card, Input, Typography } from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import { maskTokenKey } from '../../../helpers';
+
+const { Text } = Typography;
+
+// 令牌密钥只在创建或轮换时显示一次，使用聊天应用前需要用户输入完整的密钥
+const TokenKeyForm = ({ tokens, onSubmit }) => {
+  const { t } = useTranslation();
+  const [value, setValue] = useState('');
+  const [invalid, setInvalid] = useState(false);
+
+  const handleSubmit = () => {
+    setInvalid(!onSubmit(value));
+  };
+
+  return (
+    <div className='mt-[80px] px-2 flex justify-center'>
+      <Card className='w-full max-w-[480px]'>
+        <div className='flex flex-col gap-3'>
+          <Text strong>{t('请输入要使用的令牌密钥')}</Text>
+          <Text type='tertiary' size='small'>
+            {t('可用令牌')}：
+            {tokens.map((token) => maskTokenKey(token)).join('，')}
+          </Text>
+          <Input
+            mode='password'
+            value={value}
+            placeholder='sk-...'
+            validateStatus={invalid ? 'error' : 'default'}
+            onChange={(v) => {
+              setValue(v);
+              setInvalid(false);
+            }}
+            onEnterPress={handleSubmit}
+          />
+          {invalid && (
+            <Text type='danger' size='small'>
+              {t('密钥与任何启用的令牌都不匹配')}
+            </Text>
+          )}
+          <Button theme='solid' type='primary' onClick={handleSubmit}>
+            {t('确定')}
+          </Button>
+        </div>
+      </Card>
+    </div>
+  );
+};
+
+export default TokenKeyForm;
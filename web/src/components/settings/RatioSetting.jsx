@@ -37,6 +37,7 @@ const RatioSetting = () => {
     ModelRatio: '',
     CacheRatio: '',
     CreateCacheRatio: '',
+    TieredRatio: '',
     CompletionRatio: '',
     GroupRatio: '',
     GroupGroupRatio: '',
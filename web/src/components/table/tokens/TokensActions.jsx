@@ -20,31 +20,18 @@ For commercial licensing, please contact support@quantumnous.com
 import React, { useState } from 'react';
 import { Button, Space } from '@douyinfe/semi-ui';
 import { showError } from '../../../helpers';
-import CopyTokensModal from './modals/CopyTokensModal';
 import DeleteTokensModal from './modals/DeleteTokensModal';
 
 const TokensActions = ({
   selectedKeys,
   setEditingToken,
   setShowEdit,
-  batchCopyTokens,
   batchDeleteTokens,
-  copyText,
   t,
 }) => {
   // Modal states
-  const [showCopyModal, setShowCopyModal] = useState(false);
   const [showDeleteModal, setShowDeleteModal] = useState(false);
 
-  // Handle copy selected tokens with options
-  const handleCopySelectedTokens = () => {
-    if (selectedKeys.length === 0) {
-      showError(t('请至少选择一个令牌！'));
-      return;
-    }
-    setShowCopyModal(true);
-  };
-
   // Handle delete selected tokens with confirmation
   const handleDeleteSelectedTokens = () => {
     if (selectedKeys.length === 0) {
@@ -77,15 +64,6 @@ const TokensActions = ({
           {t('添加令牌')}
         </Button>
 
-        <Button
-          type='tertiary'
-          className='flex-1 md:flex-initial'
-          onClick={handleCopySelectedTokens}
-          size='small'
-        >
-          {t('复制所选令牌')}
-        </Button>
-
         <Button
           type='danger'
           className='w-full md:w-auto'
@@ -96,14 +74,6 @@ const TokensActions = ({
         </Button>
       </div>
 
-      <CopyTokensModal
-        visible={showCopyModal}
-        onCancel={() => setShowCopyModal(false)}
-        selectedKeys={selectedKeys}
-        copyText={copyText}
-        t={t}
-      />
-
       <DeleteTokensModal
         visible={showDeleteModal}
         onCancel={() => setShowDeleteModal(false)}
@@ -39,13 +39,9 @@ import {
   renderQuota,
   getModelCategories,
   showError,
+  maskTokenKey,
 } from '../../../helpers';
-import {
-  IconTreeTriangleDown,
-  IconCopy,
-  IconEyeOpened,
-  IconEyeClosed,
-} from '@douyinfe/semi-icons';
+import { IconTreeTriangleDown } from '@douyinfe/semi-icons';
 
 // progress color helper
 const getProgressColor = (pct) => {
@@ -107,46 +103,11 @@ const renderGroupColumn = (text, record, t) => {
   return renderGroup(text);
 };
 
-// Render token key column with show/hide and copy functionality
-const renderTokenKey = (text, record, showKeys, setShowKeys, copyText) => {
-  const fullKey = 'sk-' + record.key;
-  const maskedKey =
-    'sk-' + record.key.slice(0, 4) + '**********' + record.key.slice(-4);
-  const revealed = !!showKeys[record.id];
-
+// Render token key column. 密钥只保存摘要，列表中只展示可见前缀
+const renderTokenKey = (text, record) => {
   return (
     <div className='w-[200px]'>
-      <Input
-        readOnly
-        value={revealed ? fullKey : maskedKey}
-        size='small'
-        suffix={
-          <div className='flex items-center'>
-            <Button
-              theme='borderless'
-              size='small'
-              type='tertiary'
-              icon={revealed ? <IconEyeClosed /> : <IconEyeOpened />}
-              aria-label='toggle token visibility'
-              onClick={(e) => {
-                e.stopPropagation();
-                setShowKeys((prev) => ({ ...prev, [record.id]: !revealed }));
-              }}
-            />
-            <Button
-              theme='borderless'
-              size='small'
-              type='tertiary'
-              icon={<IconCopy />}
-              aria-label='copy token key'
-              onClick={async (e) => {
-                e.stopPropagation();
-                await copyText(fullKey);
-              }}
-            />
-          </div>
-        }
-      />
+      <Input readOnly value={maskTokenKey(record)} size='small' />
     </div>
   );
 };
@@ -314,6 +275,7 @@ const renderOperations = (
   setEditingToken,
   setShowEdit,
   manageToken,
+  rotateToken,
   refresh,
   t,
 ) => {
@@ -402,6 +364,20 @@ const renderOperations = (
         {t('编辑')}
       </Button>
 
+      <Button
+        type='warning'
+        size='small'
+        onClick={() => {
+          Modal.confirm({
+            title: t('确定要轮换此令牌的密钥吗？'),
+            content: t('将生成新的密钥，旧密钥在宽限期后失效'),
+            onOk: () => rotateToken(record),
+          });
+        }}
+      >
+        {t('轮换密钥')}
+      </Button>
+
       <Button
         type='danger'
         size='small'
@@ -426,10 +402,8 @@ const renderOperations = (
 
 export const getTokensColumns = ({
   t,
-  showKeys,
-  setShowKeys,
-  copyText,
   manageToken,
+  rotateToken,
   onOpenLink,
   setEditingToken,
   setShowEdit,
@@ -460,8 +434,7 @@ export const getTokensColumns = ({
     {
       title: t('密钥'),
       key: 'token_key',
-      render: (text, record) =>
-        renderTokenKey(text, record, showKeys, setShowKeys, copyText),
+      render: (text, record) => renderTokenKey(text, record),
     },
     {
       title: t('可用模型'),
@@ -503,6 +476,7 @@ export const getTokensColumns = ({
           setEditingToken,
           setShowEdit,
           manageToken,
+          rotateToken,
           refresh,
           t,
         ),
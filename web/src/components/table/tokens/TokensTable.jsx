@@ -38,10 +38,8 @@ const TokensTable = (tokensData) => {
     handlePageSizeChange,
     rowSelection,
     handleRow,
-    showKeys,
-    setShowKeys,
-    copyText,
     manageToken,
+    rotateToken,
     onOpenLink,
     setEditingToken,
     setShowEdit,
@@ -53,10 +51,8 @@ const TokensTable = (tokensData) => {
   const columns = useMemo(() => {
     return getTokensColumns({
       t,
-      showKeys,
-      setShowKeys,
-      copyText,
       manageToken,
+      rotateToken,
       onOpenLink,
       setEditingToken,
       setShowEdit,
@@ -64,10 +60,8 @@ const TokensTable = (tokensData) => {
     });
   }, [
     t,
-    showKeys,
-    setShowKeys,
-    copyText,
     manageToken,
+    rotateToken,
     onOpenLink,
     setEditingToken,
     setShowEdit,
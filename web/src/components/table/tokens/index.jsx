@@ -38,6 +38,7 @@ import TokensActions from './TokensActions';
 import TokensFilters from './TokensFilters';
 import TokensDescription from './TokensDescription';
 import EditTokenModal from './modals/EditTokenModal';
+import TokenKeyResultModal from './modals/TokenKeyResultModal';
 import { useTokensData } from '../../../hooks/tokens/useTokensData';
 import { useIsMobile } from '../../../hooks/common/useIsMobile';
 import { createCardProPagination } from '../../../helpers/utils';
@@ -55,6 +56,7 @@ function TokensPage() {
     t: (k) => k,
     selectedModel: '',
     prefillKey: '',
+    promptTokenKey: async () => '',
   });
   const [modelOptions, setModelOptions] = useState([]);
   const [selectedModel, setSelectedModel] = useState('');
@@ -69,6 +71,7 @@ function TokensPage() {
       t: tokensData.t,
       selectedModel,
       prefillKey,
+      promptTokenKey: tokensData.promptTokenKey,
     };
   }, [
     tokensData.tokens,
@@ -184,13 +187,14 @@ function TokensPage() {
   openFluentNotificationRef.current = openFluentNotification;
 
   // Prefill to Fluent handler
-  const handlePrefillToFluent = () => {
+  const handlePrefillToFluent = async () => {
     const {
       tokens,
       selectedKeys,
       t,
       selectedModel: chosenModel,
       prefillKey: overrideKey,
+      promptTokenKey,
     } = latestRef.current;
     const container = document.getElementById('fluent-new-api-container');
     if (!container) {
@@ -227,7 +231,10 @@ function TokensPage() {
         Toast.warning(t('没有可用令牌用于填充'));
         return;
       }
-      apiKeyToUse = 'sk-' + token.key;
+      // 令牌只保存摘要，需要用户输入完整密钥
+      const key = await promptTokenKey(token);
+      if (!key) return;
+      apiKeyToUse = 'sk-' + key;
     }
 
     const payload = {
@@ -335,9 +342,10 @@ function TokensPage() {
     selectedKeys,
     setEditingToken,
     setShowEdit,
-    batchCopyTokens,
     batchDeleteTokens,
     copyText,
+    issuedKeys,
+    setIssuedKeys,
 
     // Filters state
     formInitValues,
@@ -361,6 +369,14 @@ function TokensPage() {
         editingToken={editingToken}
         visiable={showEdit}
         handleClose={closeEdit}
+        onKeysIssued={setIssuedKeys}
+      />
+
+      <TokenKeyResultModal
+        issuedKeys={issuedKeys}
+        onClose={() => setIssuedKeys([])}
+        copyText={copyText}
+        t={t}
       />
 
       <CardPro
@@ -378,9 +394,7 @@ function TokensPage() {
               selectedKeys={selectedKeys}
               setEditingToken={setEditingToken}
               setShowEdit={setShowEdit}
-              batchCopyTokens={batchCopyTokens}
               batchDeleteTokens={batchDeleteTokens}
-              copyText={copyText}
               t={t}
             />
 
@@ -0,0 +1,83 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React from 'react';
+import { Banner, Button, Input, Modal, Space } from '@douyinfe/semi-ui';
+import { IconCopy } from '@douyinfe/semi-icons';
+
+// 展示创建或轮换后返回的明文密钥，服务端只保存摘要，关闭后无法再次查看
+const TokenKeyResultModal = ({ issuedKeys, onClose, copyText, t }) => {
+  const handleCopyAll = async () => {
+    const content = issuedKeys
+      .map((item) => item.name + '    sk-' + item.key)
+      .join('\n');
+    await copyText(content);
+  };
+
+  return (
+    <Modal
+      title={t('请立即复制令牌密钥')}
+      visible={issuedKeys.length > 0}
+      onCancel={onClose}
+      maskClosable={false}
+      footer={
+        <Space>
+          {issuedKeys.length > 1 && (
+            <Button type='tertiary' onClick={handleCopyAll}>
+              {t('复制全部')}
+            </Button>
+          )}
+          <Button theme='solid' type='primary' onClick={onClose}>
+            {t('我已保存')}
+          </Button>
+        </Space>
+      }
+    >
+      <Banner
+        type='warning'
+        closeIcon={null}
+        description={t('密钥只显示这一次，关闭后将无法再次查看，请妥善保存')}
+        className='mb-3'
+      />
+      <div className='flex flex-col gap-2'>
+        {issuedKeys.map((item) => (
+          <div key={item.id}>
+            <div className='mb-1'>{item.name}</div>
+            <Input
+              readOnly
+              value={'sk-' + item.key}
+              suffix={
+                <Button
+                  theme='borderless'
+                  size='small'
+                  type='tertiary'
+                  icon={<IconCopy />}
+                  aria-label='copy token key'
+                  onClick={() => copyText('sk-' + item.key)}
+                />
+              }
+            />
+          </div>
+        ))}
+      </div>
+    </Modal>
+  );
+};
+
+export default TokenKeyResultModal;
@@ -20,24 +20,54 @@ For commercial licensing, please contact support@quantumnous.com
 import { API } from './api';
 
 /**
- * 获取可用的token keys
- * @returns {Promise<string[]>} 返回active状态的token key数组
+ * 获取启用状态的令牌。令牌密钥只保存摘要，列表中只有可见前缀 key_prefix
+ * @returns {Promise<object[]>} 返回active状态的令牌数组
  */
-export async function fetchTokenKeys() {
+export async function fetchActiveTokens() {
   try {
     const response = await API.get('/api/token/?p=1&size=10');
     const { success, data } = response.data;
-    if (!success) throw new Error('Failed to fetch token keys');
+    if (!success) throw new Error('Failed to fetch tokens');
 
     const tokenItems = Array.isArray(data) ? data : data.items || [];
-    const activeTokens = tokenItems.filter((token) => token.status === 1);
-    return activeTokens.map((token) => token.key);
+    return tokenItems.filter((token) => token.status === 1);
   } catch (error) {
-    console.error('Error fetching token keys:', error);
+    console.error('Error fetching tokens:', error);
     return [];
   }
 }
 
+/**
+ * 规范化用户输入的令牌密钥：去掉空白与 sk- 前缀
+ * @param {string} key
+ * @returns {string}
+ */
+export function normalizeTokenKey(key) {
+  return (key || '').trim().replace(/^sk-/, '');
+}
+
+/**
+ * 判断输入的密钥是否与某个令牌的可见前缀一致
+ * @param {string} key 不带 sk- 前缀的密钥
+ * @param {object[]} tokens
+ * @returns {boolean}
+ */
+export function matchesTokenPrefix(key, tokens) {
+  if (!key) return false;
+  return tokens.some(
+    (token) => token.key_prefix && key.startsWith(token.key_prefix),
+  );
+}
+
+/**
+ * 渲染掩码后的令牌密钥，仅展示可见前缀
+ * @param {object} token
+ * @returns {string}
+ */
+export function maskTokenKey(token) {
+  return 'sk-' + (token?.key_prefix || '') + '**********';
+}
+
 /**
  * 获取服务器地址
  * @returns {string} 服务器地址
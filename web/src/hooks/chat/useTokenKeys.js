@@ -18,24 +18,31 @@ For commercial licensing, please contact support@quantumnous.com
 */
 
 import { useEffect, useState } from 'react';
-import { fetchTokenKeys, getServerAddress } from '../../helpers/token';
+import {
+  fetchActiveTokens,
+  getServerAddress,
+  matchesTokenPrefix,
+  normalizeTokenKey,
+} from '../../helpers/token';
 import { showError } from '../../helpers';
 
+// 令牌密钥只保存摘要，服务端无法返回完整密钥，需要用户自行输入要使用的令牌密钥
 export function useTokenKeys(id) {
+  const [tokens, setTokens] = useState([]);
   const [keys, setKeys] = useState([]);
   const [serverAddress, setServerAddress] = useState('');
   const [isLoading, setIsLoading] = useState(true);
 
   useEffect(() => {
     const loadAllData = async () => {
-      const fetchedKeys = await fetchTokenKeys();
-      if (fetchedKeys.length === 0) {
+      const fetchedTokens = await fetchActiveTokens();
+      if (fetchedTokens.length === 0) {
         showError('当前没有可用的启用令牌，请确认是否有令牌处于启用状态！');
         setTimeout(() => {
           window.location.href = '/console/token';
         }, 1500); // 延迟 1.5 秒后跳转
       }
-      setKeys(fetchedKeys);
+      setTokens(fetchedTokens);
       setIsLoading(false);
 
       const address = getServerAddress();
@@ -45,5 +52,15 @@ export function useTokenKeys(id) {
     loadAllData();
   }, []);
 
-  return { keys, serverAddress, isLoading };
+  // 校验并使用用户输入的令牌密钥，返回是否成功
+  const submitKey = (input) => {
+    const key = normalizeTokenKey(input);
+    if (!matchesTokenPrefix(key, tokens)) {
+      return false;
+    }
+    setKeys([key]);
+    return true;
+  };
+
+  return { tokens, keys, submitKey, serverAddress, isLoading };
 }
@@ -19,13 +19,15 @@ For commercial licensing, please contact support@quantumnous.com
 
 import { useState, useEffect } from 'react';
 import { useTranslation } from 'react-i18next';
-import { Modal } from '@douyinfe/semi-ui';
+import { Input, Modal } from '@douyinfe/semi-ui';
 import {
   API,
   copy,
   showError,
   showSuccess,
   encodeToBase64,
+  maskTokenKey,
+  normalizeTokenKey,
 } from '../../helpers';
 import { ITEMS_PER_PAGE } from '../../constants';
 import { useTableCompactMode } from '../common/useTableCompactMode';
@@ -53,7 +55,8 @@ export const useTokensData = (openFluentNotification) => {
 
   // UI state
   const [compactMode, setCompactMode] = useTableCompactMode('tokens');
-  const [showKeys, setShowKeys] = useState({});
+  // 创建或轮换后返回的明文密钥，只在结果弹窗中展示一次
+  const [issuedKeys, setIssuedKeys] = useState([]);
 
   // Form state
   const [formApi, setFormApi] = useState(null);
@@ -122,10 +125,46 @@ export const useTokensData = (openFluentNotification) => {
     }
   };
 
+  // 令牌只保存摘要，使用聊天应用前需要用户输入该令牌的完整密钥
+  const promptTokenKey = (record) =>
+    new Promise((resolve) => {
+      let value = '';
+      Modal.confirm({
+        title: t('请输入令牌密钥'),
+        icon: null,
+        content: (
+          <div className='flex flex-col gap-2'>
+            <div>
+              {t('令牌')}：{record.name}（{maskTokenKey(record)}）
+            </div>
+            <Input
+              mode='password'
+              placeholder='sk-...'
+              onChange={(v) => {
+                value = v;
+              }}
+            />
+          </div>
+        ),
+        onOk: () => {
+          const key = normalizeTokenKey(value);
+          if (!key || !key.startsWith(record.key_prefix || '')) {
+            showError(t('密钥与所选令牌不匹配'));
+            resolve('');
+            return;
+          }
+          resolve(key);
+        },
+        onCancel: () => resolve(''),
+      });
+    });
+
   // Open link function for chat integrations
   const onOpenLink = async (type, url, record) => {
+    const key = await promptTokenKey(record);
+    if (!key) return;
     if (url && url.startsWith('fluent')) {
-      openFluentNotification(record.key);
+      openFluentNotification(key);
       return;
     }
     let status = localStorage.getItem('status');
@@ -141,7 +180,7 @@ export const useTokensData = (openFluentNotification) => {
       let cherryConfig = {
         id: 'new-api',
         baseUrl: serverAddress,
-        apiKey: 'sk-' + record.key,
+        apiKey: 'sk-' + key,
       };
       let encodedConfig = encodeURIComponent(
         encodeToBase64(JSON.stringify(cherryConfig)),
@@ -150,7 +189,7 @@ export const useTokensData = (openFluentNotification) => {
     } else {
       let encodedServerAddress = encodeURIComponent(serverAddress);
       url = url.replaceAll('{address}', encodedServerAddress);
-      url = url.replaceAll('{key}', 'sk-' + record.key);
+      url = url.replaceAll('{key}', 'sk-' + key);
     }
 
     window.open(url, '_blank');
@@ -189,6 +228,24 @@ export const useTokensData = (openFluentNotification) => {
     setLoading(false);
   };
 
+  // 轮换令牌密钥，新密钥只在结果弹窗中展示一次
+  const rotateToken = async (record) => {
+    setLoading(true);
+    try {
+      const res = await API.post(`/api/token/${record.id}/rotate`);
+      const { success, message, data } = res.data;
+      if (success) {
+        showSuccess(t('密钥已轮换，旧密钥将在宽限期后失效'));
+        setIssuedKeys([{ id: data.id, name: data.name, key: data.key }]);
+        await refresh();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
   // Search tokens function
   const searchTokens = async (page = 1, size = pageSize) => {
     const normalizedPage = Number.isInteger(page) && page > 0 ? page : 1;
@@ -299,51 +356,6 @@ export const useTokensData = (openFluentNotification) => {
     }
   };
 
-  // Batch copy tokens
-  const batchCopyTokens = (copyType) => {
-    if (selectedKeys.length === 0) {
-      showError(t('请至少选择一个令牌！'));
-      return;
-    }
-
-    Modal.info({
-      title: t('复制令牌'),
-      icon: null,
-      content: t('请选择你的复制方式'),
-      footer: (
-        <div className='flex gap-2'>
-          <button
-            className='px-3 py-1 bg-gray-200 rounded'
-            onClick={async () => {
-              let content = '';
-              for (let i = 0; i < selectedKeys.length; i++) {
-                content +=
-                  selectedKeys[i].name + '    sk-' + selectedKeys[i].key + '\n';
-              }
-              await copyText(content);
-              Modal.destroyAll();
-            }}
-          >
-            {t('名称+密钥')}
-          </button>
-          <button
-            className='px-3 py-1 bg-blue-500 text-white rounded'
-            onClick={async () => {
-              let content = '';
-              for (let i = 0; i < selectedKeys.length; i++) {
-                content += 'sk-' + selectedKeys[i].key + '\n';
-              }
-              await copyText(content);
-              Modal.destroyAll();
-            }}
-          >
-            {t('仅密钥')}
-          </button>
-        </div>
-      ),
-    });
-  };
-
   // Initialize data
   useEffect(() => {
     loadTokens(1)
@@ -376,8 +388,8 @@ export const useTokensData = (openFluentNotification) => {
     // UI state
     compactMode,
     setCompactMode,
-    showKeys,
-    setShowKeys,
+    issuedKeys,
+    setIssuedKeys,
 
     // Form state
     formApi,
@@ -391,6 +403,8 @@ export const useTokensData = (openFluentNotification) => {
     copyText,
     onOpenLink,
     manageToken,
+    rotateToken,
+    promptTokenKey,
     searchTokens,
     sortToken,
     handlePageChange,
@@ -398,7 +412,6 @@ export const useTokensData = (openFluentNotification) => {
     rowSelection,
     handleRow,
     batchDeleteTokens,
-    batchCopyTokens,
     syncPageData,
 
     // Translation
@@ -559,6 +559,14 @@ export const useLogsData = () => {
           value: other.request_path,
         });
       }
+      if (other?.price_tier) {
+        expandDataLocal.push({
+          key: t('计费分档'),
+          value: t('提示超过 {{threshold}} tokens', {
+            threshold: other.price_tier,
+          }),
+        });
+      }
       if (other?.billing_source === 'subscription') {
         const planId = other?.subscription_plan_id;
         const planTitle = other?.subscription_plan_title || '';
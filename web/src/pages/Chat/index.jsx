@@ -19,6 +19,7 @@ For commercial licensing, please contact support@quantumnous.com
 
 import React from 'react';
 import { useTokenKeys } from '../../hooks/chat/useTokenKeys';
+import TokenKeyForm from '../../components/common/ui/TokenKeyForm';
 import { Spin } from '@douyinfe/semi-ui';
 import { useParams } from 'react-router-dom';
 import { useTranslation } from 'react-i18next';
@@ -26,7 +27,8 @@ import { useTranslation } from 'react-i18next';
 const ChatPage = () => {
   const { t } = useTranslation();
   const { id } = useParams();
-  const { keys, serverAddress, isLoading } = useTokenKeys(id);
+  const { tokens, keys, submitKey, serverAddress, isLoading } =
+    useTokenKeys(id);
 
   const comLink = (key) => {
     // console.log('chatLink:', chatLink);
@@ -53,6 +55,10 @@ const ChatPage = () => {
 
   const iframeSrc = keys.length > 0 ? comLink(keys[0]) : '';
 
+  if (!isLoading && tokens.length > 0 && keys.length === 0) {
+    return <TokenKeyForm tokens={tokens} onSubmit={submitKey} />;
+  }
+
   return !isLoading && iframeSrc ? (
     <iframe
       src={iframeSrc}
@@ -19,9 +19,11 @@ For commercial licensing, please contact support@quantumnous.com
 
 import React from 'react';
 import { useTokenKeys } from '../../hooks/chat/useTokenKeys';
+import TokenKeyForm from '../../components/common/ui/TokenKeyForm';
 
 const chat2page = () => {
-  const { keys, chatLink, serverAddress, isLoading } = useTokenKeys();
+  const { tokens, keys, submitKey, chatLink, serverAddress, isLoading } =
+    useTokenKeys();
 
   const comLink = (key) => {
     if (!chatLink || !serverAddress || !key) return '';
@@ -35,6 +37,10 @@ const chat2page = () => {
     }
   }
 
+  if (!isLoading && tokens.length > 0 && keys.length === 0) {
+    return <TokenKeyForm tokens={tokens} onSubmit={submitKey} />;
+  }
+
   return (
     <div className='mt-[60px] px-2'>
       <h3>正在加载，请稍候...</h3>
@@ -44,6 +44,7 @@ export default function ModelRatioSettings(props) {
     ModelRatio: '',
     CacheRatio: '',
     CreateCacheRatio: '',
+    TieredRatio: '',
     CompletionRatio: '',
     ImageRatio: '',
     AudioRatio: '',
@@ -225,6 +226,30 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('提示长度分档倍率')}
+              extraText={t(
+                '提示 tokens 超过 threshold 时使用该档倍率，可设置 model_ratio、completion_ratio、cache_ratio、cache_creation_ratio，未设置的沿用基础倍率',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，键为模型名称，值为分档数组，例如 {"gemini-2.5-pro": [{"threshold": 200000, "model_ratio": 1.25, "completion_ratio": 6}]}',
+              )}
+              field={'TieredRatio'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) => setInputs({ ...inputs, TieredRatio: value })}
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col xs={24} sm={16}>
             <Form.TextArea